@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"github.com/minh6824pro/nxrGO/internal/utils"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+type AddressController struct {
+	service services.AddressService
+}
+
+func NewAddressController(service services.AddressService) *AddressController {
+	return &AddressController{service}
+}
+
+// Create godoc
+// @Summary      Save an address
+// @Description  Save a shipping address to the address book, the first address becomes the default one. Requires authentication.
+// @Tags         addresses
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        address  body      dto.CreateAddressInput  true  "Address"
+// @Success      201      {object}  models.Address
+// @Router       /user/addresses [post]
+func (a *AddressController) Create(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var input dto.CreateAddressInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	address, err := a.service.Create(c.Request.Context(), userID, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, address)
+}
+
+// List godoc
+// @Summary      List saved addresses
+// @Description  List the address book of the current user, default address first. Requires authentication.
+// @Tags         addresses
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}  models.Address
+// @Router       /user/addresses [get]
+func (a *AddressController) List(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	addresses, err := a.service.List(c.Request.Context(), userID)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, addresses)
+}
+
+// GetByID godoc
+// @Summary      Get a saved address
+// @Tags         addresses
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "Address ID"
+// @Success      200  {object}  models.Address
+// @Router       /user/addresses/{id} [get]
+func (a *AddressController) GetByID(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := addressIDParam(c)
+	if !ok {
+		return
+	}
+
+	address, err := a.service.GetByID(c.Request.Context(), userID, id)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, address)
+}
+
+// Update godoc
+// @Summary      Update a saved address
+// @Description  Update some fields of an address, lat and lon change together. Past orders keep the old address.
+// @Tags         addresses
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                     true  "Address ID"
+// @Param        address  body  dto.UpdateAddressInput  true  "Fields to update"
+// @Success      200  {object}  models.Address
+// @Router       /user/addresses/{id} [patch]
+func (a *AddressController) Update(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := addressIDParam(c)
+	if !ok {
+		return
+	}
+	var input dto.UpdateAddressInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	address, err := a.service.Update(c.Request.Context(), userID, id, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, address)
+}
+
+// Delete godoc
+// @Summary      Delete a saved address
+// @Description  Delete an address, deleting the default address makes the latest updated one default
+// @Tags         addresses
+// @Security     BearerAuth
+// @Param        id  path  int  true  "Address ID"
+// @Success      204
+// @Router       /user/addresses/{id} [delete]
+func (a *AddressController) Delete(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := addressIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := a.service.Delete(c.Request.Context(), userID, id); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetDefault godoc
+// @Summary      Set default address
+// @Tags         addresses
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "Address ID"
+// @Success      200  {object}  models.Address
+// @Router       /user/addresses/{id}/default [patch]
+func (a *AddressController) SetDefault(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := addressIDParam(c)
+	if !ok {
+		return
+	}
+
+	address, err := a.service.SetDefault(c.Request.Context(), userID, id)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, address)
+}
+
+func bindAddressInput(c *gin.Context, input interface{}) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		if errors.Is(err, io.EOF) {
+			customErr.WriteError(c, customErr.NewError(
+				customErr.BAD_REQUEST,
+				"Request body is empty",
+				http.StatusBadRequest,
+				err,
+			))
+			return false
+		}
+		if !utils.HandleValidationError(c, err) {
+			customErr.WriteError(c, err)
+		}
+		return false
+	}
+	return true
+}
+
+func addressIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.BAD_REQUEST,
+			"Invalid address id",
+			http.StatusBadRequest,
+			err))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// currentUserID reads the user set by RequireAuth
+func currentUserID(c *gin.Context) (uint, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.UNAUTHORIZED,
+			"Unauthorized",
+			http.StatusUnauthorized,
+			nil))
+		return 0, false
+	}
+	return userID.(uint), true
+}
@@ -9,6 +9,7 @@ import (
 	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
 	"io"
 	"net/http"
+	"strconv"
 )
 
 type AuthController struct {
@@ -48,6 +49,9 @@ func (a *AuthController) Register(c *gin.Context) {
 		return
 	}
 
+	req.UserAgent = c.Request.UserAgent()
+	req.IP = c.ClientIP()
+
 	resp, err := a.authService.Register(req)
 	if err != nil {
 		customErr.WriteError(c, err)
@@ -85,6 +89,9 @@ func (a *AuthController) Login(c *gin.Context) {
 		return
 	}
 
+	req.UserAgent = c.Request.UserAgent()
+	req.IP = c.ClientIP()
+
 	resp, err := a.authService.Login(req)
 	if err != nil {
 		customErr.WriteError(c, err)
@@ -129,26 +136,237 @@ func (a *AuthController) GetProfile(c *gin.Context) {
 
 // RefreshToken godoc
 // @Summary Refresh Token
-// @Description Refresh access token with body "refresh_token"
+// @Description Rotate the refresh token and get a new token pair. Using an already rotated refresh token revokes its session.
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param refreshToken body object true "Refresh token payload"
+// @Param refreshToken body dto.RefreshTokenRequest true "Refresh token payload"
+// @Success 200 {object} dto.AuthResponse
 // @Router /auth/refresh [post]
 func (a *AuthController) RefreshToken(c *gin.Context) {
-	var req struct {
-		RefreshToken string `json:"refresh_token" binding:"required"`
-	}
+	var req dto.RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if errors.Is(err, io.EOF) {
+			customErr.WriteError(c, customErr.NewError(
+				customErr.BAD_REQUEST,
+				"Request body is empty",
+				http.StatusBadRequest,
+				err,
+			))
+			return
+		}
+		if utils.HandleValidationError(c, err) {
+			return
+		}
+		customErr.WriteError(c, err)
+		return
+	}
+	req.UserAgent = c.Request.UserAgent()
+	req.IP = c.ClientIP()
+
+	resp, err := a.authService.RefreshToken(c.Request.Context(), req)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the session of the current access token
+// @Tags auth
+// @Produce json
+// @Security     BearerAuth
+// @Success 200 {object} map[string]string
+// @Router /auth/logout [post]
+func (a *AuthController) Logout(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	if err := a.authService.Logout(c.Request.Context(), userID.(uint), sessionID.(string)); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll godoc
+// @Summary Logout all sessions
+// @Description Revoke every session of the current user
+// @Tags auth
+// @Produce json
+// @Security     BearerAuth
+// @Success 200 {object} map[string]string
+// @Router /auth/logout_all [post]
+func (a *AuthController) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := a.authService.LogoutAll(c.Request.Context(), userID.(uint)); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
+
+// SendVerificationEmail godoc
+// @Summary Resend verification email
+// @Description Mail a new email verification token. Always succeeds for unknown emails, rate-limited per email.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.EmailRequest true "Email"
+// @Success 200 {object} map[string]string
+// @Router /auth/verify_email/send [post]
+func (a *AuthController) SendVerificationEmail(c *gin.Context) {
+	var req dto.EmailRequest
+	if !bindAuthRequest(c, &req) {
+		return
+	}
+
+	if err := a.authService.SendVerificationEmail(c.Request.Context(), req.Email); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email needs verification, a verification email has been sent"})
+}
+
+// VerifyEmail godoc
+// @Summary Verify email
+// @Description Confirm the email with the mailed token, the token can only be used once
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.VerifyEmailRequest true "Email and token"
+// @Success 200 {object} map[string]string
+// @Router /auth/verify_email [post]
+func (a *AuthController) VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+	if !bindAuthRequest(c, &req) {
 		return
 	}
 
-	newAccessToken, err := a.authService.RefreshToken(req.RefreshToken)
+	if err := a.authService.VerifyEmail(c.Request.Context(), req); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ForgotPassword godoc
+// @Summary Forgot password
+// @Description Mail a password reset token. Always succeeds for unknown emails, rate-limited per email.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.EmailRequest true "Email"
+// @Success 200 {object} map[string]string
+// @Router /auth/password/forgot [post]
+func (a *AuthController) ForgotPassword(c *gin.Context) {
+	var req dto.EmailRequest
+	if !bindAuthRequest(c, &req) {
+		return
+	}
+
+	if err := a.authService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a password reset email has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with the mailed token, every session of the user is logged out
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.ResetPasswordRequest true "Email, token and new password"
+// @Success 200 {object} map[string]string
+// @Router /auth/password/reset [post]
+func (a *AuthController) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if !bindAuthRequest(c, &req) {
+		return
+	}
+
+	if err := a.authService.ResetPassword(c.Request.Context(), req); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// ListLockedUsers godoc
+// @Summary List locked users
+// @Description Users whose login is locked after too many failed attempts. Requires Admin Role.
+// @Tags auth
+// @Produce json
+// @Security     BearerAuth
+// @Success 200 {array} models.User
+// @Router /admin/users/locked [get]
+func (a *AuthController) ListLockedUsers(c *gin.Context) {
+	users, err := a.authService.ListLockedUsers()
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// UnlockUser godoc
+// @Summary Unlock user
+// @Description Lift the login lockout of a user. Requires Admin Role.
+// @Tags auth
+// @Produce json
+// @Security     BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Router /admin/users/{id}/unlock [post]
+func (a *AuthController) UnlockUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		customErr.WriteError(c, customErr.NewError(
+			customErr.BAD_REQUEST,
+			"Invalid user id",
+			http.StatusBadRequest,
+			err))
 		return
 	}
+	adminID, _ := c.Get("user_id")
 
-	c.JSON(http.StatusOK, gin.H{"access_token": newAccessToken})
+	user, err := a.authService.UnlockUser(c.Request.Context(), adminID.(uint), uint(id))
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func bindAuthRequest(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		if errors.Is(err, io.EOF) {
+			customErr.WriteError(c, customErr.NewError(
+				customErr.BAD_REQUEST,
+				"Request body is empty",
+				http.StatusBadRequest,
+				err,
+			))
+			return false
+		}
+		if !utils.HandleValidationError(c, err) {
+			customErr.WriteError(c, err)
+		}
+		return false
+	}
+	return true
 }
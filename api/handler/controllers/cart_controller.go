@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+type CartController struct {
+	service services.CartService
+}
+
+func NewCartController(service services.CartService) *CartController {
+	return &CartController{service}
+}
+
+// Get godoc
+// @Summary      Get cart
+// @Description  Get the cart of the current user, every line is checked against the current price and available stock. Requires authentication.
+// @Tags         cart
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  dto.CartResponse
+// @Router       /cart [get]
+func (cc *CartController) Get(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	cart, err := cc.service.Get(c.Request.Context(), userID)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+// AddItem godoc
+// @Summary      Add item to cart
+// @Description  Add a product variant to the cart, the quantity is added to the existing line. Requires authentication.
+// @Tags         cart
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        item  body      dto.CartItemInput  true  "Item"
+// @Success      200   {object}  dto.CartResponse
+// @Router       /cart/items [post]
+func (cc *CartController) AddItem(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var input dto.CartItemInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	cart, err := cc.service.AddItem(c.Request.Context(), userID, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+// UpdateItem godoc
+// @Summary      Update cart item quantity
+// @Tags         cart
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        variantId  path      int                      true  "Product variant ID"
+// @Param        item       body      dto.UpdateCartItemInput  true  "Quantity"
+// @Success      200        {object}  dto.CartResponse
+// @Router       /cart/items/{variantId} [patch]
+func (cc *CartController) UpdateItem(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	variantID, ok := variantIDParam(c)
+	if !ok {
+		return
+	}
+	var input dto.UpdateCartItemInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	cart, err := cc.service.UpdateItem(c.Request.Context(), userID, variantID, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+// RemoveItem godoc
+// @Summary      Remove item from cart
+// @Tags         cart
+// @Produce      json
+// @Security     BearerAuth
+// @Param        variantId  path      int  true  "Product variant ID"
+// @Success      200        {object}  dto.CartResponse
+// @Router       /cart/items/{variantId} [delete]
+func (cc *CartController) RemoveItem(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	variantID, ok := variantIDParam(c)
+	if !ok {
+		return
+	}
+
+	cart, err := cc.service.RemoveItem(c.Request.Context(), userID, variantID)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+// Clear godoc
+// @Summary      Clear cart
+// @Tags         cart
+// @Security     BearerAuth
+// @Success      204
+// @Router       /cart [delete]
+func (cc *CartController) Clear(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := cc.service.Clear(c.Request.Context(), userID); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Merge godoc
+// @Summary      Merge guest cart
+// @Description  Merge the cart kept by the client before login into the cart of the current user. Quantities are summed and capped at the available stock. Requires authentication.
+// @Tags         cart
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        cart  body      dto.MergeCartInput  true  "Guest cart"
+// @Success      200   {object}  dto.CartResponse
+// @Router       /cart/merge [post]
+func (cc *CartController) Merge(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var input dto.MergeCartInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	cart, err := cc.service.Merge(c.Request.Context(), userID, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+// Checkout godoc
+// @Summary      Checkout cart
+// @Description  Create an order from the cart with the current prices and the shipping fees of the chosen deliveries. Ordered items are removed from the cart. Requires authentication.
+// @Tags         cart
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        checkout  body      dto.CheckoutCartInput  true  "Checkout"
+// @Success      201       {object}  dto.CreateOrderResponse
+// @Router       /cart/checkout [post]
+func (cc *CartController) Checkout(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var input dto.CheckoutCartInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	order, err := cc.service.Checkout(c.Request.Context(), userID, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, order)
+}
+
+func variantIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("variantId"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.BAD_REQUEST,
+			"Invalid product variant id",
+			http.StatusBadRequest,
+			err))
+		return 0, false
+	}
+	return uint(id), true
+}
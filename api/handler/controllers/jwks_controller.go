@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/jwt"
+	"net/http"
+)
+
+type JWKSController struct {
+	jwtService *jwt.JWTService
+}
+
+func NewJWKSController(jwtService *jwt.JWTService) *JWKSController {
+	return &JWKSController{jwtService}
+}
+
+// GetJWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens, tokens carry the key id in the kid header
+// @Tags auth
+// @Produce json
+// @Success 200 {object} jwt.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (j *JWKSController) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, j.jwtService.JWKS())
+}
@@ -150,3 +150,42 @@ func (c *MerchantController) Patch(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, updated)
 }
+
+// AddMember godoc
+// @Summary      Add a merchant member
+// @Description  Give a user the MERCHANT role for this merchant. Requires Admin Role.
+// @Tags         merchants
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path  int                         true  "Merchant ID"
+// @Param        member  body  dto.AddMerchantMemberInput  true  "User to add"
+// @Success      200  {object}  models.User
+// @Router       /merchants/{id}/members [post]
+func (c *MerchantController) AddMember(ctx *gin.Context) {
+	id, _ := strconv.Atoi(ctx.Param("id"))
+
+	var input dto.AddMerchantMemberInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		if errors.Is(err, io.EOF) {
+			customErr.WriteError(ctx, customErr.NewError(
+				customErr.BAD_REQUEST,
+				"Request body is empty",
+				http.StatusBadRequest,
+				err,
+			))
+			return
+		}
+
+		utils.HandleValidationError(ctx, err)
+		return
+	}
+
+	user, err := c.service.AddMember(ctx.Request.Context(), uint(id), input.UserID)
+	if err != nil {
+		customErr.WriteError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/services"
 	"github.com/minh6824pro/nxrGO/internal/utils"
 	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
@@ -29,6 +30,7 @@ func NewOrderController(service services.OrderService) *OrderController {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Param        Idempotency-Key  header  string  false  "Replays the first response when the same key is sent again"
 // @Param        order  body      dto.CreateOrderInput  true  "Create order request"
 // @Success      201    {object}  dto.CreateOrderResponse  "Success response with order data"
 // @Router       /orders [post]
@@ -148,7 +150,8 @@ func (o *OrderController) UpdateOrderStatus(c *gin.Context) {
 		customErr.WriteError(c, err)
 		return
 	}
-	order, err := o.service.UpdateOrderStatus(c, uint(id), event.Event)
+	actor, _ := actorFromContext(c)
+	order, err := o.service.UpdateOrderStatus(c, uint(id), event.Event, actor, event.Note)
 	if err != nil {
 		customErr.WriteError(c, err)
 		return
@@ -273,6 +276,32 @@ func (o *OrderController) ListByAdmin(c *gin.Context) {
 	c.JSON(http.StatusOK, list)
 }
 
+// ListByMerchant godoc
+// @Summary      Get orders of current merchant
+// @Description  Retrieve the orders holding items of the caller's merchant, split orders show only the sub order of that merchant. Requires Merchant Role.
+// @Tags         orders
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}  dto.OrderData  "List of orders"
+// @Router       /orders/merchant [get]
+func (o *OrderController) ListByMerchant(c *gin.Context) {
+	actor, exists := actorFromContext(c)
+	if !exists || actor.MerchantID == 0 {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.FORBIDDEN,
+			"Insufficient permissions",
+			http.StatusForbidden,
+			nil))
+		return
+	}
+	list, err := o.service.ListByMerchant(c, actor.MerchantID)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": list})
+}
+
 // GetShippingFee godoc
 // @Summary      Calculate delivery fee
 // @Description  Calculate delivery fee base on user location
@@ -281,8 +310,9 @@ func (o *OrderController) ListByAdmin(c *gin.Context) {
 // @Produce      json
 // @Security     BearerAuth
 // @Param merchantId query []string true "List of Merchant IDs" collectionFormat(multi)
-// @Param        lat          query     string   true "User Latitude"
-// @Param        lon          query     string   true "User Longitude"
+// @Param        address_id   query     int      false "Saved address ID, replaces lat and lon"
+// @Param        lat          query     string   false "User Latitude"
+// @Param        lon          query     string   false "User Longitude"
 // @Success      200  {object}  map[string][]dto.ShippingFeeResponse
 // @Router       /orders/shippingFee [get]
 func (o *OrderController) GetShippingFee(c *gin.Context) {
@@ -290,8 +320,23 @@ func (o *OrderController) GetShippingFee(c *gin.Context) {
 	userLat := c.Query("lat")
 	userLon := c.Query("lon")
 
+	if addressIDStr := c.Query("address_id"); addressIDStr != "" {
+		addressID, err := strconv.ParseUint(addressIDStr, 10, 64)
+		if err != nil {
+			customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "address_id invalid", http.StatusBadRequest, err))
+			return
+		}
+		userID, _ := c.Get("user_id")
+		address, err := o.service.ResolveShippingAddress(c, userID.(uint), uint(addressID))
+		if err != nil {
+			customErr.WriteError(c, err)
+			return
+		}
+		userLat, userLon = address.Latitude, address.Longitude
+	}
+
 	if userLat == "" || userLon == "" || len(merchantIDs) == 0 {
-		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "merchantId, lat and lon are required", http.StatusBadRequest, nil))
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "merchantId and address_id or lat and lon are required", http.StatusBadRequest, nil))
 		return
 	}
 
@@ -327,9 +372,162 @@ func (o *OrderController) PaymentSuccessMock(c *gin.Context) {
 
 	fmt.Println("OrderID:", id)
 
-	o.service.PayOSPaymentSuccess(c, id)
+	o.service.PaymentSuccess(c, id)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Payment success mock",
 		"orderId": id,
 	})
 }
+
+// CancelItems godoc
+// @Summary      Cancel part of an order
+// @Description  Cancel quantities of order items before the order is processed. Cancelled stock is restored and paid money refunded. Requires authentication.
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path  int                    true  "Order ID"
+// @Param        items  body  dto.OrderItemsRequest  true  "Items to cancel"
+// @Success      200  {object}  models.Order  "Updated order"
+// @Router       /orders/{id}/items/cancel [post]
+func (o *OrderController) CancelItems(c *gin.Context) {
+	o.changeItems(c, func(orderID uint, actor models.Actor, input dto.OrderItemsRequest) (interface{}, error) {
+		return o.service.CancelItems(c, orderID, actor, input)
+	})
+}
+
+// RequestItemReturn godoc
+// @Summary      Return part of an order
+// @Description  Request the return of quantities of shipped or delivered order items. Requires authentication.
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path  int                    true  "Order ID"
+// @Param        items  body  dto.OrderItemsRequest  true  "Items to return"
+// @Success      200  {object}  models.Order  "Updated order"
+// @Router       /orders/{id}/items/return [post]
+func (o *OrderController) RequestItemReturn(c *gin.Context) {
+	o.changeItems(c, func(orderID uint, actor models.Actor, input dto.OrderItemsRequest) (interface{}, error) {
+		return o.service.RequestItemReturn(c, orderID, actor.UserID, input)
+	})
+}
+
+// ReceiveItemReturn godoc
+// @Summary      Receive returned items
+// @Description  Record returned items arriving back, restock them and request their refund. Requires Admin Role.
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path  int                    true  "Order ID"
+// @Param        items  body  dto.OrderItemsRequest  true  "Received items"
+// @Success      200  {object}  models.Order  "Updated order"
+// @Router       /orders/{id}/items/return/receive [patch]
+func (o *OrderController) ReceiveItemReturn(c *gin.Context) {
+	o.changeItems(c, func(orderID uint, actor models.Actor, input dto.OrderItemsRequest) (interface{}, error) {
+		return o.service.ReceiveItemReturn(c, orderID, actor, input)
+	})
+}
+
+// RejectItemReturn godoc
+// @Summary      Reject item returns
+// @Description  Drop requested returns of order items. Requires Admin Role.
+// @Tags         orders
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path  int                    true  "Order ID"
+// @Param        items  body  dto.OrderItemsRequest  true  "Rejected items"
+// @Success      200  {object}  models.Order  "Updated order"
+// @Router       /orders/{id}/items/return/reject [patch]
+func (o *OrderController) RejectItemReturn(c *gin.Context) {
+	o.changeItems(c, func(orderID uint, _ models.Actor, input dto.OrderItemsRequest) (interface{}, error) {
+		return o.service.RejectItemReturn(c, orderID, input)
+	})
+}
+
+func (o *OrderController) changeItems(c *gin.Context, change func(orderID uint, actor models.Actor, input dto.OrderItemsRequest) (interface{}, error)) {
+	actor, exists := actorFromContext(c)
+	if !exists {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.UNAUTHORIZED,
+			"Unauthorized",
+			http.StatusUnauthorized,
+			nil))
+		return
+	}
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "Invalid order id", http.StatusBadRequest, err))
+		return
+	}
+	var input dto.OrderItemsRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		if errors.Is(err, io.EOF) {
+			customErr.WriteError(c, customErr.NewError(
+				customErr.BAD_REQUEST,
+				"Request body is empty",
+				http.StatusBadRequest,
+				err,
+			))
+			return
+		}
+		if utils.HandleValidationError(c, err) {
+			return
+		}
+		customErr.WriteError(c, err)
+		return
+	}
+	order, err := change(uint(orderID), actor, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": order})
+}
+
+// GetHistory godoc
+// @Summary      Get order status history
+// @Description  Timeline of every status change of the order with who made it. Requires being the order owner or Admin Role.
+// @Tags         orders
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "Order ID"
+// @Success      200  {array}  models.OrderStatusHistory  "Order status history"
+// @Router       /orders/{id}/history [get]
+func (o *OrderController) GetHistory(c *gin.Context) {
+	actor, exists := actorFromContext(c)
+	if !exists {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.UNAUTHORIZED,
+			"Unauthorized",
+			http.StatusUnauthorized,
+			nil))
+		return
+	}
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "Invalid order id", http.StatusBadRequest, err))
+		return
+	}
+	history, err := o.service.GetStatusHistory(c, uint(orderID), actor)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": history})
+}
+
+// actorFromContext reads the user set by AuthMiddleware
+func actorFromContext(c *gin.Context) (models.Actor, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return models.Actor{}, false
+	}
+	role, _ := c.Get("role")
+	actorRole, _ := role.(models.Role)
+	merchantID, _ := c.Get("merchant_id")
+	actorMerchantID, _ := merchantID.(uint)
+	return models.Actor{UserID: userID.(uint), Role: actorRole, MerchantID: actorMerchantID}, true
+}
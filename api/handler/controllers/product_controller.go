@@ -12,6 +12,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type ProductController struct {
@@ -109,7 +110,7 @@ func (pc *ProductController) Delete(ctx *gin.Context) {
 
 // ListProductQuery godoc
 // @Summary      Get landing page products with query options
-// @Description  Retrieve a paginated list of products for landing page with optional filters and sorting.
+// @Description  Retrieve a paginated list of products for landing page with optional filters and sorting. Filters of the same facet are combined with OR, different facets with AND. The response has facet counts next to the products, each facet is counted with all filters except its own.
 // @Tags         products
 // @Accept       json
 // @Produce      json
@@ -119,7 +120,14 @@ func (pc *ProductController) Delete(ctx *gin.Context) {
 // @Param        totalBuyDesc   query     bool    false  "Sort by total purchases descending" example(true)
 // @Param        page           query     int     false  "Page number (starts from 0)"     example(0)
 // @Param        pageSize       query     int     false  "Number of items per page"        example(16)
-// @Success      200            {array}  CacheModel.ProductMiniCache "Success response with products and total count"
+// @Param        brand          query     []string  false  "Brand names, repeatable"  collectionFormat(multi)
+// @Param        category       query     []string  false  "Category names, repeatable"  collectionFormat(multi)
+// @Param        merchant       query     []string  false  "Merchant names, repeatable"  collectionFormat(multi)
+// @Param        option         query     []string  false  "Variant option value as Name=Value, repeatable"  collectionFormat(multi)  example(Color=Red)
+// @Param        minRating      query     number  false  "Minimum average rating"          example(4)
+// @Param        inStock        query     bool    false  "Only products with a variant in stock"  example(true)
+// @Param        priceInterval  query     number  false  "Width of the price histogram buckets"  example(100000)
+// @Success      200            {object}  dto.ProductSearchResponse "Products, total pages, facets and a did you mean keyword when nothing matched"
 // @Router       /products/query [post]
 func (pc *ProductController) ListProductQuery(ctx *gin.Context) {
 	// Lấy query param
@@ -132,6 +140,9 @@ func (pc *ProductController) ListProductQuery(ctx *gin.Context) {
 	filterTotalBuyStr := ctx.Query("totalBuyDesc")
 	pageStr := ctx.DefaultQuery("page", "0")
 	pageSizeStr := ctx.DefaultQuery("pageSize", "16")
+	minRatingStr := ctx.Query("minRating")
+	inStockStr := ctx.Query("inStock")
+	priceIntervalStr := ctx.Query("priceInterval")
 
 	// Parse float64 pointer
 	var priceMin, priceMax *float64
@@ -202,15 +213,97 @@ func (pc *ProductController) ListProductQuery(ctx *gin.Context) {
 		customErr.WriteError(ctx, customErr.NewError(customErr.BAD_REQUEST, "Sorting is allowed only by totalBuyDesc or priceAsc", http.StatusBadRequest, err))
 		return
 	}
+
+	query := dto.ProductSearchQuery{
+		Name:         name,
+		PriceMin:     priceMin,
+		PriceMax:     priceMax,
+		PriceAsc:     priceAsc,
+		TotalBuyDesc: filterTotalBuy,
+		Page:         page,
+		PageSize:     pageSize,
+		Lat:          lat,
+		Lon:          lon,
+		Brands:       ctx.QueryArray("brand"),
+		Categories:   ctx.QueryArray("category"),
+		Merchants:    ctx.QueryArray("merchant"),
+	}
+
+	// Parse facet filters
+	if minRatingStr != "" {
+		v, err := strconv.ParseFloat(minRatingStr, 64)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "minRating invalid"})
+			return
+		}
+		query.MinRating = &v
+	}
+	if inStockStr != "" {
+		v, err := strconv.ParseBool(inStockStr)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "inStock invalid"})
+			return
+		}
+		query.InStock = v
+	}
+	if priceIntervalStr != "" {
+		v, err := strconv.ParseFloat(priceIntervalStr, 64)
+		if err != nil || v <= 0 {
+			ctx.JSON(400, gin.H{"error": "priceInterval invalid"})
+			return
+		}
+		query.PriceInterval = v
+	}
+	for _, option := range ctx.QueryArray("option") {
+		optionName, value, ok := strings.Cut(option, "=")
+		if !ok || optionName == "" || value == "" {
+			ctx.JSON(400, gin.H{"error": "option must be Name=Value"})
+			return
+		}
+		if query.Options == nil {
+			query.Options = map[string][]string{}
+		}
+		query.Options[optionName] = append(query.Options[optionName], value)
+	}
+
 	// Gọi service
-	products, total, err := pc.service.GetProductList(ctx, name, priceMin, priceMax, priceAsc, filterTotalBuy, page, pageSize, lat, lon)
+	result, err := pc.service.GetProductList(ctx, query)
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": err.Error()})
 		log.Println(err)
 		return
 	}
 
-	ctx.JSON(200, gin.H{"data": products, "total": total})
+	ctx.JSON(200, result)
+}
+
+// Autocomplete godoc
+// @Summary      Autocomplete the search box
+// @Description  Suggest products by name, and brands and categories, whose words start with the typed text. Vietnamese diacritics are ignored.
+// @Tags         products
+// @Produce      json
+// @Param        q     query     string  true   "Typed text"  example(iph)
+// @Param        size  query     int     false  "Max suggestions of each kind, 1 to 20"  example(8)
+// @Success      200   {object}  dto.AutocompleteResponse
+// @Router       /products/autocomplete [get]
+func (pc *ProductController) Autocomplete(ctx *gin.Context) {
+	prefix := strings.TrimSpace(ctx.Query("q"))
+	if prefix == "" {
+		customErr.WriteError(ctx, customErr.NewError(customErr.INVALID_INPUT, "q is required", http.StatusBadRequest, nil))
+		return
+	}
+	size, err := strconv.Atoi(ctx.DefaultQuery("size", "8"))
+	if err != nil || size < 1 || size > 20 {
+		customErr.WriteError(ctx, customErr.NewError(customErr.INVALID_INPUT, "size must be between 1 and 20", http.StatusBadRequest, err))
+		return
+	}
+
+	result, err := pc.service.Autocomplete(ctx, prefix, size)
+	if err != nil {
+		customErr.WriteError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
 }
 
 func (pc *ProductController) ListProductManagement(ctx *gin.Context) {
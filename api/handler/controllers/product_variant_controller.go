@@ -99,6 +99,46 @@ func (pc *ProductVariantController) IncreaseStock(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": updated})
 }
 
+// UpdatePrice godoc
+// @Summary		Update product variant price
+// @Description	Update product variant price, the search index is updated in the background
+// @Tags		Product Variants
+// @Accept		json
+// @Produce		json
+// @Param id path string true "Product Variant ID"
+// @Param		updatePriceRequest body dto.UpdatePriceRequest true "Update Price Request"
+// @Success		200 {object} models.ProductVariant
+// @Router		/product_variants/{id}/price [patch]
+func (pc *ProductVariantController) UpdatePrice(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var input dto.UpdatePriceRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+
+		if errors.Is(err, io.EOF) {
+			customErr.WriteError(c, customErr.NewError(
+				customErr.BAD_REQUEST,
+				"Request body is empty",
+				http.StatusBadRequest,
+				err,
+			))
+			return
+		}
+
+		if utils.HandleValidationError(c, err) {
+			return
+		}
+		customErr.WriteError(c, err)
+		return
+	}
+
+	updated, err := pc.service.UpdatePrice(c.Request.Context(), uint(id), input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
 // DecreaseStock godoc
 // @Summary		Decrease product variant quantity
 // @Description	Decrease product variant quantity
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+type PromotionController struct {
+	service services.PromotionService
+}
+
+func NewPromotionController(service services.PromotionService) *PromotionController {
+	return &PromotionController{service}
+}
+
+// Create godoc
+// @Summary      Create promotion
+// @Description  Create a coupon code. Merchants can only create promotions for their own products. Requires Admin or Merchant Role.
+// @Tags         promotions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        promotion  body      dto.CreatePromotionInput  true  "Promotion"
+// @Success      201        {object}  models.Promotion
+// @Router       /promotions [post]
+func (p *PromotionController) Create(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		customErr.WriteError(c, customErr.NewError(customErr.UNAUTHORIZED, "Unauthorized", http.StatusUnauthorized, nil))
+		return
+	}
+	var input dto.CreatePromotionInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	promotion, err := p.service.Create(c.Request.Context(), actor, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, promotion)
+}
+
+// List godoc
+// @Summary      List promotions
+// @Description  List promotions with pagination, merchants only see their own. Requires Admin or Merchant Role.
+// @Tags         promotions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page      query  int  false  "Page number, starts at 0"      example(0)
+// @Param        pageSize  query  int  false  "Number of promotions per page"  example(20)
+// @Success      200  {object}  map[string]interface{}  "data: promotions, total: total pages"
+// @Router       /promotions [get]
+func (p *PromotionController) List(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		customErr.WriteError(c, customErr.NewError(customErr.UNAUTHORIZED, "Unauthorized", http.StatusUnauthorized, nil))
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "0"))
+	if err != nil || page < 0 {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "page invalid", http.StatusBadRequest, err))
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize <= 0 || pageSize > 100 {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "pageSize invalid", http.StatusBadRequest, err))
+		return
+	}
+
+	promotions, total, err := p.service.List(c.Request.Context(), actor, page, pageSize)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": promotions, "total": total})
+}
+
+// GetByID godoc
+// @Summary      Get promotion
+// @Tags         promotions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "Promotion ID"
+// @Success      200  {object}  models.Promotion
+// @Router       /promotions/{id} [get]
+func (p *PromotionController) GetByID(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		customErr.WriteError(c, customErr.NewError(customErr.UNAUTHORIZED, "Unauthorized", http.StatusUnauthorized, nil))
+		return
+	}
+	id, ok := promotionIDParam(c)
+	if !ok {
+		return
+	}
+
+	promotion, err := p.service.GetByID(c.Request.Context(), actor, id)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, promotion)
+}
+
+// Update godoc
+// @Summary      Update promotion
+// @Description  Update value, limits, validity window or status of a promotion. Code, type and scope can not change. Requires Admin or Merchant Role.
+// @Tags         promotions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path      int                       true  "Promotion ID"
+// @Param        promotion  body      dto.UpdatePromotionInput  true  "Fields to update"
+// @Success      200        {object}  models.Promotion
+// @Router       /promotions/{id} [patch]
+func (p *PromotionController) Update(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		customErr.WriteError(c, customErr.NewError(customErr.UNAUTHORIZED, "Unauthorized", http.StatusUnauthorized, nil))
+		return
+	}
+	id, ok := promotionIDParam(c)
+	if !ok {
+		return
+	}
+	var input dto.UpdatePromotionInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	promotion, err := p.service.Update(c.Request.Context(), actor, id, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, promotion)
+}
+
+// Delete godoc
+// @Summary      Delete promotion
+// @Tags         promotions
+// @Security     BearerAuth
+// @Param        id  path  int  true  "Promotion ID"
+// @Success      204
+// @Router       /promotions/{id} [delete]
+func (p *PromotionController) Delete(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		customErr.WriteError(c, customErr.NewError(customErr.UNAUTHORIZED, "Unauthorized", http.StatusUnauthorized, nil))
+		return
+	}
+	id, ok := promotionIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := p.service.Delete(c.Request.Context(), actor, id); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Quote godoc
+// @Summary      Preview coupon
+// @Description  Compute the discount of a coupon for items at their current price. Send the resulting total with coupon_code when creating the order. Requires authentication.
+// @Tags         promotions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        quote  body      dto.QuotePromotionInput  true  "Coupon and items"
+// @Success      200    {object}  dto.PromotionQuote
+// @Router       /promotions/quote [post]
+func (p *PromotionController) Quote(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var input dto.QuotePromotionInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	quote, err := p.service.Quote(c.Request.Context(), userID, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, quote)
+}
+
+func promotionIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.BAD_REQUEST,
+			"Invalid promotion id",
+			http.StatusBadRequest,
+			err))
+		return 0, false
+	}
+	return uint(id), true
+}
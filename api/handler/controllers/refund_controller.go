@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"github.com/minh6824pro/nxrGO/internal/utils"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+type RefundController struct {
+	service services.RefundService
+}
+
+func NewRefundController(service services.RefundService) *RefundController {
+	return &RefundController{service}
+}
+
+// Create godoc
+// @Summary      Request a refund
+// @Description  Request a refund of a cancelled or returned order paid online. Items select a partial refund, empty items refund everything left. Requires authentication.
+// @Tags         refunds
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        refund  body      dto.CreateRefundRequest  true  "Create refund request"
+// @Success      201     {object}  models.Refund  "Requested refund"
+// @Router       /refunds [post]
+func (r *RefundController) Create(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.UNAUTHORIZED,
+			"Unauthorized",
+			http.StatusUnauthorized,
+			nil))
+		return
+	}
+	var input dto.CreateRefundRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		if errors.Is(err, io.EOF) {
+			customErr.WriteError(c, customErr.NewError(
+				customErr.BAD_REQUEST,
+				"Request body is empty",
+				http.StatusBadRequest,
+				err,
+			))
+			return
+		}
+		if utils.HandleValidationError(c, err) {
+			return
+		}
+		customErr.WriteError(c, err)
+		return
+	}
+	refund, err := r.service.Request(c, userID.(uint), input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "success", "data": refund})
+}
+
+// List godoc
+// @Summary      Get refunds of current user
+// @Description  Retrieve all refunds requested for the authenticated user orders
+// @Tags         refunds
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}  models.Refund  "List of refunds"
+// @Router       /refunds [get]
+func (r *RefundController) List(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.UNAUTHORIZED,
+			"Unauthorized",
+			http.StatusUnauthorized,
+			nil))
+		return
+	}
+	refunds, err := r.service.ListByUserId(c, userID.(uint))
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": refunds})
+}
+
+// ListByAdmin godoc
+// @Summary      Get refunds to review
+// @Description  Retrieve refunds, optionally filtered by status. Requires Admin Role.
+// @Tags         refunds
+// @Produce      json
+// @Security     BearerAuth
+// @Param        status  query  string  false  "REQUESTED, APPROVED, COMPLETED, REJECTED or FAILED"
+// @Success      200  {array}  models.Refund  "List of refunds"
+// @Router       /refunds/admin [get]
+func (r *RefundController) ListByAdmin(c *gin.Context) {
+	refunds, err := r.service.ListByAdmin(c, models.RefundStatus(c.Query("status")))
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": refunds})
+}
+
+// Approve godoc
+// @Summary      Approve a refund
+// @Description  Approve a requested or failed refund and send it to the payment provider. Requires Admin Role.
+// @Tags         refunds
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "Refund ID"
+// @Success      200  {object}  models.Refund  "Approved refund"
+// @Router       /refunds/{id}/approve [patch]
+func (r *RefundController) Approve(c *gin.Context) {
+	id, ok := parseRefundID(c)
+	if !ok {
+		return
+	}
+	adminID, _ := c.Get("user_id")
+	refund, err := r.service.Approve(c, id, adminID.(uint))
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": refund})
+}
+
+// Reject godoc
+// @Summary      Reject a refund
+// @Description  Reject a requested refund. Requires Admin Role.
+// @Tags         refunds
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path  int                      true  "Refund ID"
+// @Param        reject  body  dto.RejectRefundRequest  true  "Reject reason"
+// @Success      200  {object}  models.Refund  "Rejected refund"
+// @Router       /refunds/{id}/reject [patch]
+func (r *RefundController) Reject(c *gin.Context) {
+	id, ok := parseRefundID(c)
+	if !ok {
+		return
+	}
+	var input dto.RejectRefundRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		if errors.Is(err, io.EOF) {
+			customErr.WriteError(c, customErr.NewError(
+				customErr.BAD_REQUEST,
+				"Request body is empty",
+				http.StatusBadRequest,
+				err,
+			))
+			return
+		}
+		if utils.HandleValidationError(c, err) {
+			return
+		}
+		customErr.WriteError(c, err)
+		return
+	}
+	adminID, _ := c.Get("user_id")
+	refund, err := r.service.Reject(c, id, adminID.(uint), input.Reason)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": refund})
+}
+
+// Complete godoc
+// @Summary      Complete a manual refund
+// @Description  Confirm an approved refund whose money was transferred outside the payment provider. Requires Admin Role.
+// @Tags         refunds
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "Refund ID"
+// @Success      200  {object}  models.Refund  "Completed refund"
+// @Router       /refunds/{id}/complete [patch]
+func (r *RefundController) Complete(c *gin.Context) {
+	id, ok := parseRefundID(c)
+	if !ok {
+		return
+	}
+	refund, err := r.service.Complete(c, id)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success", "data": refund})
+}
+
+func parseRefundID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "Invalid refund id", http.StatusBadRequest, err))
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+type ReviewController struct {
+	service services.ReviewService
+}
+
+func NewReviewController(service services.ReviewService) *ReviewController {
+	return &ReviewController{service}
+}
+
+// ListByProduct godoc
+// @Summary      List product reviews
+// @Description  List visible reviews of a product with pagination, newest first
+// @Tags         reviews
+// @Produce      json
+// @Param        id        path   int  true   "Product ID"
+// @Param        rating    query  int  false  "Only reviews with this rating"  example(5)
+// @Param        page      query  int  false  "Page number, starts at 0"       example(0)
+// @Param        pageSize  query  int  false  "Number of reviews per page"     example(20)
+// @Success      200  {object}  map[string]interface{}  "data: reviews, total: total pages"
+// @Router       /products/{id}/reviews [get]
+func (r *ReviewController) ListByProduct(c *gin.Context) {
+	productID, ok := reviewIDParam(c, "Invalid product id")
+	if !ok {
+		return
+	}
+	rating, ok := reviewRatingQuery(c)
+	if !ok {
+		return
+	}
+	page, pageSize, ok := reviewPageQuery(c)
+	if !ok {
+		return
+	}
+
+	reviews, total, err := r.service.ListByProduct(c.Request.Context(), productID, rating, page, pageSize)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": reviews, "total": total})
+}
+
+// Create godoc
+// @Summary      Review product
+// @Description  Rate a product received in a DONE order, one review per product. Requires authentication.
+// @Tags         reviews
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      int                    true  "Product ID"
+// @Param        review  body      dto.CreateReviewInput  true  "Review"
+// @Success      201     {object}  models.Review
+// @Router       /products/{id}/reviews [post]
+func (r *ReviewController) Create(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	productID, ok := reviewIDParam(c, "Invalid product id")
+	if !ok {
+		return
+	}
+	var input dto.CreateReviewInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	review, err := r.service.Create(c.Request.Context(), userID, productID, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, review)
+}
+
+// Update godoc
+// @Summary      Update review
+// @Description  Change the rating, text or images of your own review. Requires authentication.
+// @Tags         reviews
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      int                    true  "Review ID"
+// @Param        review  body      dto.UpdateReviewInput  true  "Fields to update"
+// @Success      200     {object}  models.Review
+// @Router       /reviews/{id} [patch]
+func (r *ReviewController) Update(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := reviewIDParam(c, "Invalid review id")
+	if !ok {
+		return
+	}
+	var input dto.UpdateReviewInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	review, err := r.service.Update(c.Request.Context(), userID, id, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+// Delete godoc
+// @Summary      Delete review
+// @Description  Delete your own review, admins can delete any review. Requires authentication.
+// @Tags         reviews
+// @Security     BearerAuth
+// @Param        id  path  int  true  "Review ID"
+// @Success      204
+// @Router       /reviews/{id} [delete]
+func (r *ReviewController) Delete(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		customErr.WriteError(c, customErr.NewError(customErr.UNAUTHORIZED, "Unauthorized", http.StatusUnauthorized, nil))
+		return
+	}
+	id, ok := reviewIDParam(c, "Invalid review id")
+	if !ok {
+		return
+	}
+
+	if err := r.service.Delete(c.Request.Context(), actor, id); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Reply godoc
+// @Summary      Reply to review
+// @Description  Answer a review of one of your products, replaces the previous reply. Requires Admin or Merchant Role.
+// @Tags         reviews
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path      int                   true  "Review ID"
+// @Param        reply  body      dto.ReplyReviewInput  true  "Reply"
+// @Success      200    {object}  models.Review
+// @Router       /reviews/{id}/reply [post]
+func (r *ReviewController) Reply(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		customErr.WriteError(c, customErr.NewError(customErr.UNAUTHORIZED, "Unauthorized", http.StatusUnauthorized, nil))
+		return
+	}
+	id, ok := reviewIDParam(c, "Invalid review id")
+	if !ok {
+		return
+	}
+	var input dto.ReplyReviewInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	review, err := r.service.Reply(c.Request.Context(), actor, id, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+// Moderate godoc
+// @Summary      Moderate review
+// @Description  Hide or show a review. Hidden reviews are not listed and do not count in the product rating. Requires Admin Role.
+// @Tags         reviews
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path      int                      true  "Review ID"
+// @Param        status  body      dto.ModerateReviewInput  true  "New status"
+// @Success      200     {object}  models.Review
+// @Router       /reviews/{id}/moderate [patch]
+func (r *ReviewController) Moderate(c *gin.Context) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		customErr.WriteError(c, customErr.NewError(customErr.UNAUTHORIZED, "Unauthorized", http.StatusUnauthorized, nil))
+		return
+	}
+	id, ok := reviewIDParam(c, "Invalid review id")
+	if !ok {
+		return
+	}
+	var input dto.ModerateReviewInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	review, err := r.service.Moderate(c.Request.Context(), actor, id, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+// List godoc
+// @Summary      List reviews for moderation
+// @Description  List reviews of every status with pagination. Requires Admin Role.
+// @Tags         reviews
+// @Produce      json
+// @Security     BearerAuth
+// @Param        productId  query  int     false  "Filter by product"
+// @Param        status     query  string  false  "VISIBLE or HIDDEN"
+// @Param        rating     query  int     false  "Filter by rating"            example(1)
+// @Param        page       query  int     false  "Page number, starts at 0"    example(0)
+// @Param        pageSize   query  int     false  "Number of reviews per page"  example(20)
+// @Success      200  {object}  map[string]interface{}  "data: reviews, total: total pages"
+// @Router       /reviews [get]
+func (r *ReviewController) List(c *gin.Context) {
+	var filter dto.ReviewFilter
+	if raw := c.Query("productId"); raw != "" {
+		productID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "productId invalid", http.StatusBadRequest, err))
+			return
+		}
+		id := uint(productID)
+		filter.ProductID = &id
+	}
+	switch status := models.ReviewStatus(c.Query("status")); status {
+	case "", models.ReviewVisible, models.ReviewHidden:
+		filter.Status = status
+	default:
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "status invalid", http.StatusBadRequest, nil))
+		return
+	}
+	rating, ok := reviewRatingQuery(c)
+	if !ok {
+		return
+	}
+	filter.Rating = rating
+	page, pageSize, ok := reviewPageQuery(c)
+	if !ok {
+		return
+	}
+
+	reviews, total, err := r.service.List(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": reviews, "total": total})
+}
+
+func reviewIDParam(c *gin.Context, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.BAD_REQUEST,
+			message,
+			http.StatusBadRequest,
+			err))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func reviewRatingQuery(c *gin.Context) (*uint8, bool) {
+	raw := c.Query("rating")
+	if raw == "" {
+		return nil, true
+	}
+	rating, err := strconv.ParseUint(raw, 10, 8)
+	if err != nil || rating < 1 || rating > 5 {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "rating invalid", http.StatusBadRequest, err))
+		return nil, false
+	}
+	value := uint8(rating)
+	return &value, true
+}
+
+func reviewPageQuery(c *gin.Context) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "0"))
+	if err != nil || page < 0 {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "page invalid", http.StatusBadRequest, err))
+		return 0, 0, false
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize <= 0 || pageSize > 100 {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "pageSize invalid", http.StatusBadRequest, err))
+		return 0, 0, false
+	}
+	return page, pageSize, true
+}
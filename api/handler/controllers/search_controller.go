@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"net/http"
+)
+
+type SearchController struct {
+	service services.SearchIndexService
+}
+
+func NewSearchController(service services.SearchIndexService) *SearchController {
+	return &SearchController{service}
+}
+
+// SyncStatus godoc
+// @Summary      Get search index sync status
+// @Description  Get how far the products index is behind the database: catalog change events waiting in the outbox, dead events, lag of the oldest pending event and the last indexing error. Requires admin role.
+// @Tags         search
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  dto.SearchSyncStatus
+// @Router       /search/sync [get]
+func (sc *SearchController) SyncStatus(c *gin.Context) {
+	status, err := sc.service.Status(c.Request.Context())
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"github.com/minh6824pro/nxrGO/internal/utils"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+type StockController struct {
+	service services.StockLedgerService
+}
+
+func NewStockController(service services.StockLedgerService) *StockController {
+	return &StockController{service}
+}
+
+// Rebuild godoc
+// @Summary      Rebuild stock from the ledger
+// @Description  Recompute the quantity of every product variant from the whole stock movement ledger, used after a crash left product_variants.quantity out of sync. Requires admin role.
+// @Tags         stock
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]string
+// @Router       /stock/rebuild [post]
+func (sc *StockController) Rebuild(c *gin.Context) {
+	if err := sc.service.Rebuild(c.Request.Context()); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Stock rebuilt from ledger"})
+}
+
+// ReconcileCache godoc
+// @Summary      Reconcile cached stock with the ledger
+// @Description  Overwrite the cached quantity of the given product variants with the quantity available in the ledger. Requires admin role.
+// @Tags         stock
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        input  body      dto.ReconcileStockInput  true  "Product variants to reconcile"
+// @Success      200  {object}  map[string]string
+// @Router       /stock/reconcile [post]
+func (sc *StockController) ReconcileCache(c *gin.Context) {
+	var input dto.ReconcileStockInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		if errors.Is(err, io.EOF) {
+			customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "Request body is empty", http.StatusBadRequest, err))
+			return
+		}
+		if utils.HandleValidationError(c, err) {
+			return
+		}
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "Invalid request body", http.StatusBadRequest, err))
+		return
+	}
+	if err := sc.service.ReconcileCache(c.Request.Context(), input.ProductVariantIDs); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cached stock reconciled"})
+}
+
+// ListMovements godoc
+// @Summary      List stock movements of a product variant
+// @Description  List the reservations, releases, sales, returns and adjustments recorded in the ledger for a product variant. Requires admin role.
+// @Tags         stock
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      int  true  "Product variant ID"
+// @Success      200  {array}   models.StockMovement
+// @Router       /stock/variants/{id}/movements [get]
+func (sc *StockController) ListMovements(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "Invalid product variant id", http.StatusBadRequest, err))
+		return
+	}
+	movements, err := sc.service.ListByVariant(c.Request.Context(), uint(id))
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, movements)
+}
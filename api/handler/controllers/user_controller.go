@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"github.com/minh6824pro/nxrGO/internal/utils"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+type UserController struct {
+	service services.UserService
+}
+
+func NewUserController(s services.UserService) *UserController {
+	return &UserController{s}
+}
+
+// List godoc
+// @Summary      List users
+// @Description  List users with filters and pagination. Requires Admin Role.
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page      query  int     false  "Page number, starts at 0"  example(0)
+// @Param        pageSize  query  int     false  "Number of users per page"  example(20)
+// @Param        search    query  string  false  "Search email, name or phone number"
+// @Param        role      query  string  false  "ADMIN, USER or MERCHANT"
+// @Param        active    query  int     false  "1 active, 0 deactivated"
+// @Success      200  {object}  map[string]interface{}  "data: users, total: total pages"
+// @Router       /admin/users [get]
+func (u *UserController) List(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "0"))
+	if err != nil || page < 0 {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "page invalid", http.StatusBadRequest, err))
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize <= 0 || pageSize > 100 {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "pageSize invalid", http.StatusBadRequest, err))
+		return
+	}
+
+	filter := dto.UserFilter{
+		Search: c.Query("search"),
+		Role:   models.Role(c.Query("role")),
+	}
+	if activeStr := c.Query("active"); activeStr != "" {
+		active, err := strconv.ParseUint(activeStr, 10, 8)
+		if err != nil || active > 1 {
+			customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "active invalid", http.StatusBadRequest, err))
+			return
+		}
+		value := uint8(active)
+		filter.Active = &value
+	}
+
+	users, total, err := u.service.List(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": users, "total": total})
+}
+
+// GetByID godoc
+// @Summary      Get user
+// @Description  Get a user by ID. Requires Admin Role.
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "User ID"
+// @Success      200  {object}  models.User
+// @Router       /admin/users/{id} [get]
+func (u *UserController) GetByID(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := u.service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangeRole godoc
+// @Summary      Change user role
+// @Description  Change the role of a user, MERCHANT needs merchant_id. The user is logged out of every session. Requires Admin Role.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path  int                      true  "User ID"
+// @Param        role  body  dto.ChangeUserRoleInput  true  "New role"
+// @Success      200  {object}  models.User
+// @Router       /admin/users/{id}/role [patch]
+func (u *UserController) ChangeRole(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input dto.ChangeUserRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		if errors.Is(err, io.EOF) {
+			customErr.WriteError(c, customErr.NewError(
+				customErr.BAD_REQUEST,
+				"Request body is empty",
+				http.StatusBadRequest,
+				err,
+			))
+			return
+		}
+
+		utils.HandleValidationError(c, err)
+		return
+	}
+
+	adminID, _ := c.Get("user_id")
+	user, err := u.service.ChangeRole(c.Request.Context(), adminID.(uint), id, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// Deactivate godoc
+// @Summary      Deactivate user
+// @Description  Deactivate a user, its current tokens stop working immediately. Requires Admin Role.
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "User ID"
+// @Success      200  {object}  models.User
+// @Router       /admin/users/{id}/deactivate [post]
+func (u *UserController) Deactivate(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	adminID, _ := c.Get("user_id")
+	user, err := u.service.Deactivate(c.Request.Context(), adminID.(uint), id)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// Reactivate godoc
+// @Summary      Reactivate user
+// @Description  Allow a deactivated user to log in again. Requires Admin Role.
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "User ID"
+// @Success      200  {object}  models.User
+// @Router       /admin/users/{id}/reactivate [post]
+func (u *UserController) Reactivate(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := u.service.Reactivate(c.Request.Context(), id)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ForceLogout godoc
+// @Summary      Force logout user
+// @Description  Revoke every session and access token of a user. Requires Admin Role.
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "User ID"
+// @Success      200  {object}  map[string]string
+// @Router       /admin/users/{id}/logout [post]
+func (u *UserController) ForceLogout(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := u.service.ForceLogout(c.Request.Context(), id); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User logged out of all sessions"})
+}
+
+func userIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(
+			customErr.BAD_REQUEST,
+			"Invalid user id",
+			http.StatusBadRequest,
+			err))
+		return 0, false
+	}
+	return uint(id), true
+}
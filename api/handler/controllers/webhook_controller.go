@@ -1,56 +1,129 @@
 package controllers
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/payment"
 	"github.com/minh6824pro/nxrGO/internal/services"
-	payos "github.com/payOSHQ/payos-lib-golang"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type WebhookController struct {
-	orderService services.OrderService
+	orderService      services.OrderService
+	paymentReconciler services.PaymentReconciler
+	paymentProvider   payment.PaymentProvider
 }
 
-func NewWebhookController(orderService services.OrderService) *WebhookController {
+func NewWebhookController(orderService services.OrderService, paymentReconciler services.PaymentReconciler, paymentProvider payment.PaymentProvider) *WebhookController {
 	return &WebhookController{
-		orderService: orderService,
+		orderService:      orderService,
+		paymentReconciler: paymentReconciler,
+		paymentProvider:   paymentProvider,
 	}
 }
 
 // HandleWebhook godoc
-// @Summary      Handle incoming webhook from PayOS
-// @Description  Receive and verify webhook payload with checksum key, then process payment success.
+// @Summary      Handle incoming webhook from the payment provider
+// @Description  Receive and verify webhook payload with the provider signature, then finalize the payment. Safe to redeliver.
 // @Tags         webhooks
 // @Accept       json
 // @Produce      plain
 // @Param        webhookBody  body  dto.WebhookType  true  "Webhook payload"
 // @Success      200  {string}  string  "Webhook processed successfully"
-// @Router       /webhook [post]
+// @Router       /payments/webhook [post]
 func (pc *WebhookController) HandleWebhook(c *gin.Context) {
-	var webhookBody payos.WebhookType
-	if err := c.ShouldBindJSON(&webhookBody); err != nil {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
 		log.Println("Invalid webhook payload:", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 		return
 	}
 
-	log.Println("Received webhook payload:", webhookBody)
-
-	data, err := payos.VerifyPaymentWebhookData(webhookBody)
+	data, err := pc.paymentProvider.VerifyWebhook(c, body)
 	if err != nil {
 		log.Println("Invalid webhook payload:", err)
+		if errors.Is(err, payment.ErrInvalidWebhook) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid signature"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot verify webhook"})
 		return
 	}
-	log.Println(data)
-	log.Printf("[Webhook] DraftOrderId: %d | Reference: %s | Status: %s\n",
-		data.OrderCode,
+	log.Printf("[Webhook %s] PaymentId: %d | Reference: %s | Success: %t\n",
+		pc.paymentProvider.Name(),
+		data.PaymentID,
 		data.Reference,
-		data.Desc,
+		data.Success,
 	)
-	if data.Reference == "TF230204212323" {
+	if data.Test {
+		c.String(http.StatusOK, "Webhook processed successfully")
 		return
 	}
-	pc.orderService.PayOSPaymentSuccess(c, data.OrderCode)
+	// Finalizing is idempotent, a redelivered webhook or a racing reconciliation poll is a no-op
+	if data.Success {
+		pc.orderService.PaymentSuccess(c, data.PaymentID)
+	} else if err := pc.paymentReconciler.Reconcile(c, data.PaymentID); err != nil {
+		log.Printf("Error reconciling payment %d from webhook: %v", data.PaymentID, err)
+	}
 	c.String(http.StatusOK, "Webhook processed successfully")
 }
+
+// SimulateFakePayment godoc
+// @Summary      Finish a payment of the local fake provider
+// @Description  Only available when PAYMENT_PROVIDER=fake. Outcome is paid, cancelled or expired, the fake provider then sends a signed webhook.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id       path  int     true  "Payment ID"
+// @Param        outcome  path  string  true  "paid | cancelled | expired"
+// @Success      200  {object}  map[string]string
+// @Router       /payments/fake/{id}/{outcome} [post]
+func (pc *WebhookController) SimulateFakePayment(c *gin.Context) {
+	fake, ok := pc.paymentProvider.(*payment.FakeProvider)
+	if !ok {
+		customErr.WriteError(c, customErr.NewError(customErr.ITEM_NOT_FOUND, "Fake payment provider is not enabled", http.StatusNotFound, nil))
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "Invalid payment id", http.StatusBadRequest, err))
+		return
+	}
+	outcome := payment.LinkStatus(strings.ToUpper(c.Param("outcome")))
+	if err := fake.Simulate(c, id, outcome); err != nil {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, err.Error(), http.StatusBadRequest, err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "success", "status": outcome})
+}
+
+// GetFakePayment godoc
+// @Summary      Checkout page of the local fake provider
+// @Description  Only available when PAYMENT_PROVIDER=fake. Returns the payment status at the fake provider.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id  path  int  true  "Payment ID"
+// @Success      200  {object}  map[string]interface{}
+// @Router       /payments/fake/{id} [get]
+func (pc *WebhookController) GetFakePayment(c *gin.Context) {
+	fake, ok := pc.paymentProvider.(*payment.FakeProvider)
+	if !ok {
+		customErr.WriteError(c, customErr.NewError(customErr.ITEM_NOT_FOUND, "Fake payment provider is not enabled", http.StatusNotFound, nil))
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(customErr.BAD_REQUEST, "Invalid payment id", http.StatusBadRequest, err))
+		return
+	}
+	status, err := fake.GetStatus(c, id)
+	if err != nil {
+		customErr.WriteError(c, customErr.NewError(customErr.ITEM_NOT_FOUND, err.Error(), http.StatusNotFound, err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payment_id": id, "status": status.Status, "cancellation_reason": status.CancellationReason})
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/payment"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeOrderService records the payments finalized by the webhook
+type fakeOrderService struct {
+	services.OrderService
+	paid []int64
+}
+
+func (f *fakeOrderService) PaymentSuccess(ctx context.Context, paymentInfoID int64) {
+	f.paid = append(f.paid, paymentInfoID)
+}
+
+type fakePaymentReconciler struct {
+	reconciled []int64
+}
+
+func (f *fakePaymentReconciler) Start(ctx context.Context) {}
+
+func (f *fakePaymentReconciler) Reconcile(ctx context.Context, paymentInfoID int64) error {
+	f.reconciled = append(f.reconciled, paymentInfoID)
+	return nil
+}
+
+// newFakePaymentRouter serves the payment routes with the fake provider, its webhooks go
+// straight back into the router so the whole flow runs without network
+func newFakePaymentRouter(t *testing.T) (*gin.Engine, *payment.FakeProvider, *fakeOrderService, *fakePaymentReconciler) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	fake := payment.NewFakeProvider("secret", "http://localhost/api/payments/fake")
+	orders := &fakeOrderService{}
+	reconciler := &fakePaymentReconciler{}
+	controller := NewWebhookController(orders, reconciler, fake)
+
+	r := gin.New()
+	r.POST("/api/payments/webhook", controller.HandleWebhook)
+	r.GET("/api/payments/fake/:id", controller.GetFakePayment)
+	r.POST("/api/payments/fake/:id/:outcome", controller.SimulateFakePayment)
+
+	fake.SetWebhookSink(func(ctx context.Context, body []byte) error {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/payments/webhook", bytes.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			return fmt.Errorf("webhook returned status %d", rec.Code)
+		}
+		return nil
+	})
+	return r, fake, orders, reconciler
+}
+
+func TestFakePaymentPaidFinalizesOrder(t *testing.T) {
+	r, fake, orders, reconciler := newFakePaymentRouter(t)
+	if _, err := fake.CreateLink(context.Background(), payment.CreateLinkRequest{PaymentID: 42, Amount: 10000, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/payments/fake/42/paid", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("simulate returned %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(orders.paid) != 1 || orders.paid[0] != 42 {
+		t.Fatalf("expected payment 42 to be finalized, got %v", orders.paid)
+	}
+	if len(reconciler.reconciled) != 0 {
+		t.Fatalf("a paid webhook must not reconcile, got %v", reconciler.reconciled)
+	}
+}
+
+func TestFakePaymentCancelledIsReconciled(t *testing.T) {
+	r, fake, orders, reconciler := newFakePaymentRouter(t)
+	if _, err := fake.CreateLink(context.Background(), payment.CreateLinkRequest{PaymentID: 7, Amount: 10000}); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/payments/fake/7/cancelled", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("simulate returned %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(orders.paid) != 0 {
+		t.Fatalf("a cancelled payment must not be finalized, got %v", orders.paid)
+	}
+	if len(reconciler.reconciled) != 1 || reconciler.reconciled[0] != 7 {
+		t.Fatalf("expected payment 7 to be reconciled, got %v", reconciler.reconciled)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/payments/fake/7", nil))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"status":"CANCELLED"`)) {
+		t.Fatalf("unexpected fake payment page %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestWebhookRejectsForgedSignature(t *testing.T) {
+	r, _, orders, _ := newFakePaymentRouter(t)
+	body := []byte(`{"paymentId":42,"status":"PAID","code":"00","reference":"X","signature":"00"}`)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/payments/webhook", bytes.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if len(orders.paid) != 0 {
+		t.Fatalf("a forged webhook must not finalize, got %v", orders.paid)
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"net/http"
+)
+
+type WishlistController struct {
+	service services.WishlistService
+}
+
+func NewWishlistController(service services.WishlistService) *WishlistController {
+	return &WishlistController{service}
+}
+
+// List godoc
+// @Summary      Get wishlist
+// @Description  Get the product variants saved by the current user, newest first. Requires authentication.
+// @Tags         wishlist
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}  models.WishlistItem
+// @Router       /wishlist [get]
+func (wc *WishlistController) List(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	items, err := wc.service.List(c.Request.Context(), userID)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// AddItem godoc
+// @Summary      Add item to wishlist
+// @Description  Save a product variant, with notify_back_in_stock the user is notified when a sold out variant is restocked. Adding it again only changes the notification setting. Requires authentication.
+// @Tags         wishlist
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        item  body      dto.WishlistItemInput  true  "Item"
+// @Success      200   {object}  models.WishlistItem
+// @Router       /wishlist/items [post]
+func (wc *WishlistController) AddItem(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var input dto.WishlistItemInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	item, err := wc.service.AddItem(c.Request.Context(), userID, input)
+	if err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// RemoveItem godoc
+// @Summary      Remove item from wishlist
+// @Tags         wishlist
+// @Security     BearerAuth
+// @Param        variantId  path  int  true  "Product variant ID"
+// @Success      204
+// @Router       /wishlist/items/{variantId} [delete]
+func (wc *WishlistController) RemoveItem(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	variantID, ok := variantIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := wc.service.RemoveItem(c.Request.Context(), userID, variantID); err != nil {
+		customErr.WriteError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
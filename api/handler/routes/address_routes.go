@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/modules"
+)
+
+func RegisterAddressRoutes(rg *gin.RouterGroup, addressModule *modules.AddressModule) {
+
+	address := rg.Group("/user/addresses")
+	address.Use(addressModule.AuthMiddleware.RequireAuth())
+	{
+		address.POST("", addressModule.Controller.Create)
+		address.GET("", addressModule.Controller.List)
+		address.GET("/:id", addressModule.Controller.GetByID)
+		address.PATCH("/:id", addressModule.Controller.Update)
+		address.DELETE("/:id", addressModule.Controller.Delete)
+		address.PATCH("/:id/default", addressModule.Controller.SetDefault)
+	}
+}
@@ -15,6 +15,12 @@ func RegisterAuthRoutes(rg *gin.RouterGroup, authModule *modules.AuthModule) {
 		auth.POST("/register", authModule.AuthController.Register)
 		auth.POST("/login", authModule.AuthController.Login)
 		auth.POST("/refresh", authModule.AuthController.RefreshToken)
+		auth.POST("/verify_email/send", authModule.AuthController.SendVerificationEmail)
+		auth.POST("/verify_email", authModule.AuthController.VerifyEmail)
+		auth.POST("/password/forgot", authModule.AuthController.ForgotPassword)
+		auth.POST("/password/reset", authModule.AuthController.ResetPassword)
+		auth.POST("/logout", authModule.AuthMiddleware.RequireAuth(), authModule.AuthController.Logout)
+		auth.POST("/logout_all", authModule.AuthMiddleware.RequireAuth(), authModule.AuthController.LogoutAll)
 	}
 
 	// Protected routes
@@ -30,6 +36,18 @@ func RegisterAuthRoutes(rg *gin.RouterGroup, authModule *modules.AuthModule) {
 	admin.Use(authModule.AuthMiddleware.RequireAuth())
 	admin.Use(authModule.AuthMiddleware.RequireRole(models.RoleAdmin))
 	{
-		// Thêm các routes admin ở đây
+		admin.GET("/users/locked", authModule.AuthController.ListLockedUsers)
+		admin.POST("/users/:id/unlock", authModule.AuthController.UnlockUser)
+		admin.GET("/users", authModule.UserController.List)
+		admin.GET("/users/:id", authModule.UserController.GetByID)
+		admin.PATCH("/users/:id/role", authModule.UserController.ChangeRole)
+		admin.POST("/users/:id/deactivate", authModule.UserController.Deactivate)
+		admin.POST("/users/:id/reactivate", authModule.UserController.Reactivate)
+		admin.POST("/users/:id/logout", authModule.UserController.ForceLogout)
 	}
 }
+
+// RegisterJWKSRoutes publishes the token verification keys at the server root
+func RegisterJWKSRoutes(r *gin.Engine, authModule *modules.AuthModule) {
+	r.GET("/.well-known/jwks.json", authModule.JWKSController.GetJWKS)
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/modules"
+)
+
+func RegisterCartRoutes(rg *gin.RouterGroup, cartModule *modules.CartModule) {
+
+	cart := rg.Group("/cart")
+	cart.Use(cartModule.AuthMiddleware.RequireAuth())
+	{
+		cart.GET("", cartModule.Controller.Get)
+		cart.DELETE("", cartModule.Controller.Clear)
+		cart.POST("/items", cartModule.Controller.AddItem)
+		cart.PATCH("/items/:variantId", cartModule.Controller.UpdateItem)
+		cart.DELETE("/items/:variantId", cartModule.Controller.RemoveItem)
+		cart.POST("/merge", cartModule.Controller.Merge)
+		cart.POST("/checkout", cartModule.IdempotencyMiddleware.Idempotent(), cartModule.Controller.Checkout)
+	}
+}
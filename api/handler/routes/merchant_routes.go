@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/modules"
 )
 
@@ -14,5 +15,7 @@ func RegisterMerchantRoutes(rg *gin.RouterGroup, merchantModule *modules.Merchan
 		merchant.GET("/:id", merchantModule.Controller.GetByID)
 		merchant.DELETE("/:id", merchantModule.Controller.Delete)
 		merchant.PATCH("/:id", merchantModule.Controller.Patch)
+		merchant.POST("/:id/members", merchantModule.AuthMiddleware.RequireAuth(),
+			merchantModule.AuthMiddleware.RequireRole(models.RoleAdmin), merchantModule.Controller.AddMember)
 	}
 }
@@ -11,20 +11,29 @@ func RegisterOrderRoutes(rg *gin.RouterGroup, orderModule *modules.OrderModule)
 	order := rg.Group("/orders")
 	order.Use(orderModule.AuthMiddleware.RequireAuth())
 	{
-		order.POST("", orderModule.Controller.Create)
+		order.POST("", orderModule.IdempotencyMiddleware.Idempotent(), orderModule.Controller.Create)
 		order.GET("/:id", orderModule.Controller.GetById)
 		order.POST("/updatedb", orderModule.Controller.UpdateDb)
 		order.GET("/status", orderModule.Controller.GetByStatus)
 		order.GET("", orderModule.Controller.List)
-		order.POST("/changepaymentmethod", orderModule.Controller.ChangePaymentMethod)
+		order.POST("/changepaymentmethod", orderModule.IdempotencyMiddleware.Idempotent(), orderModule.Controller.ChangePaymentMethod)
 		order.GET("/shippingFee", orderModule.Controller.GetShippingFee)
 		order.GET("/mockpayos/:id", orderModule.Controller.PaymentSuccessMock)
+		order.POST("/:id/items/cancel", orderModule.Controller.CancelItems)
+		order.POST("/:id/items/return", orderModule.Controller.RequestItemReturn)
+		order.GET("/:id/history", orderModule.Controller.GetHistory)
 
 	}
+	merchant := order.Group("", orderModule.AuthMiddleware.RequireAnyRole(models.RoleAdmin, models.RoleMerchant))
+	{
+		merchant.PATCH("/:id", orderModule.MerchantScope.OrderParam("id"), orderModule.Controller.UpdateOrderStatus)
+		merchant.GET("/merchant", orderModule.AuthMiddleware.RequireRole(models.RoleMerchant), orderModule.Controller.ListByMerchant)
+	}
 	order.Use(orderModule.AuthMiddleware.RequireRole(models.RoleAdmin))
 	{
-		order.PATCH("/:id", orderModule.Controller.UpdateOrderStatus)
 		order.GET("/admin", orderModule.Controller.ListByAdmin)
+		order.PATCH("/:id/items/return/receive", orderModule.Controller.ReceiveItemReturn)
+		order.PATCH("/:id/items/return/reject", orderModule.Controller.RejectItemReturn)
 	}
 
 }
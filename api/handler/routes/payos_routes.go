@@ -7,6 +7,14 @@ import (
 
 func RegisterPayOSRoutes(router *gin.RouterGroup, payOSModule *modules.PayOsModule) {
 
+	payments := router.Group("/payments")
+	{
+		payments.POST("/webhook", payOSModule.Controller.HandleWebhook)
+		payments.GET("/fake/:id", payOSModule.Controller.GetFakePayment)
+		payments.POST("/fake/:id/:outcome", payOSModule.Controller.SimulateFakePayment)
+	}
+
+	// Old webhook url, kept for PayOS dashboards still pointing to it
 	payos := router.Group("/payos")
 	{
 		payos.POST("/webhook", payOSModule.Controller.HandleWebhook)
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/modules"
 )
 
@@ -9,12 +10,17 @@ func RegisterProductRoutes(rg *gin.RouterGroup, productModule *modules.ProductMo
 
 	product := rg.Group("/products")
 	{
-		product.POST("", productModule.Controller.Create)
 		product.GET("", productModule.Controller.List)
 		product.GET("/:id", productModule.Controller.GetByID)
-		product.DELETE("/:id", productModule.Controller.Delete)
 		product.GET("/query", productModule.Controller.ListProductQuery)
+		product.GET("/autocomplete", productModule.Controller.Autocomplete)
 		product.GET("/admin", productModule.Controller.ListProductManagement)
 
 	}
+	manage := product.Group("", productModule.AuthMiddleware.RequireAuth(),
+		productModule.AuthMiddleware.RequireAnyRole(models.RoleAdmin, models.RoleMerchant))
+	{
+		manage.POST("", productModule.MerchantScope.MerchantInBody("merchant_id"), productModule.Controller.Create)
+		manage.DELETE("/:id", productModule.MerchantScope.ProductParam("id"), productModule.Controller.Delete)
+	}
 }
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/modules"
 )
 
@@ -9,10 +10,15 @@ func RegisterProductVariantRoutes(rg *gin.RouterGroup, productVariantModule *mod
 
 	productVariants := rg.Group("/product_variants")
 	{
-		productVariants.POST("", productVariantModule.Controller.Create)
-		productVariants.PATCH("/:id/increase_stock", productVariantModule.Controller.IncreaseStock)
-		productVariants.PATCH("/:id/decrease_stock", productVariantModule.Controller.DecreaseStock)
 		productVariants.POST("/listbyids", productVariantModule.Controller.ListByIds)
 	}
+	manage := productVariants.Group("", productVariantModule.AuthMiddleware.RequireAuth(),
+		productVariantModule.AuthMiddleware.RequireAnyRole(models.RoleAdmin, models.RoleMerchant))
+	{
+		manage.POST("", productVariantModule.MerchantScope.ProductInBody("product_id"), productVariantModule.Controller.Create)
+		manage.PATCH("/:id/increase_stock", productVariantModule.MerchantScope.VariantParam("id"), productVariantModule.Controller.IncreaseStock)
+		manage.PATCH("/:id/decrease_stock", productVariantModule.MerchantScope.VariantParam("id"), productVariantModule.Controller.DecreaseStock)
+		manage.PATCH("/:id/price", productVariantModule.MerchantScope.VariantParam("id"), productVariantModule.Controller.UpdatePrice)
+	}
 
 }
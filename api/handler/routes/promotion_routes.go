@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/modules"
+)
+
+func RegisterPromotionRoutes(rg *gin.RouterGroup, promotionModule *modules.PromotionModule) {
+
+	promotion := rg.Group("/promotions")
+	promotion.Use(promotionModule.AuthMiddleware.RequireAuth())
+	{
+		promotion.POST("/quote", promotionModule.Controller.Quote)
+	}
+	manage := promotion.Group("", promotionModule.AuthMiddleware.RequireAnyRole(models.RoleAdmin, models.RoleMerchant))
+	{
+		manage.POST("", promotionModule.Controller.Create)
+		manage.GET("", promotionModule.Controller.List)
+		manage.GET("/:id", promotionModule.Controller.GetByID)
+		manage.PATCH("/:id", promotionModule.Controller.Update)
+		manage.DELETE("/:id", promotionModule.Controller.Delete)
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/modules"
+)
+
+func RegisterRefundRoutes(rg *gin.RouterGroup, refundModule *modules.RefundModule) {
+
+	refund := rg.Group("/refunds")
+	refund.Use(refundModule.AuthMiddleware.RequireAuth())
+	{
+		refund.POST("", refundModule.Controller.Create)
+		refund.GET("", refundModule.Controller.List)
+	}
+	refund.Use(refundModule.AuthMiddleware.RequireRole(models.RoleAdmin))
+	{
+		refund.GET("/admin", refundModule.Controller.ListByAdmin)
+		refund.PATCH("/:id/approve", refundModule.Controller.Approve)
+		refund.PATCH("/:id/reject", refundModule.Controller.Reject)
+		refund.PATCH("/:id/complete", refundModule.Controller.Complete)
+	}
+}
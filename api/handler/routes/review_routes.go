@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/modules"
+)
+
+func RegisterReviewRoutes(rg *gin.RouterGroup, reviewModule *modules.ReviewModule) {
+
+	product := rg.Group("/products")
+	{
+		product.GET("/:id/reviews", reviewModule.Controller.ListByProduct)
+		product.POST("/:id/reviews", reviewModule.AuthMiddleware.RequireAuth(), reviewModule.Controller.Create)
+	}
+
+	review := rg.Group("/reviews")
+	review.Use(reviewModule.AuthMiddleware.RequireAuth())
+	{
+		review.PATCH("/:id", reviewModule.Controller.Update)
+		review.DELETE("/:id", reviewModule.Controller.Delete)
+		review.POST("/:id/reply", reviewModule.AuthMiddleware.RequireAnyRole(models.RoleAdmin, models.RoleMerchant), reviewModule.Controller.Reply)
+	}
+	admin := review.Group("", reviewModule.AuthMiddleware.RequireAnyRole(models.RoleAdmin))
+	{
+		admin.GET("", reviewModule.Controller.List)
+		admin.PATCH("/:id/moderate", reviewModule.Controller.Moderate)
+	}
+}
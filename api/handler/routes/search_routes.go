@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/modules"
+)
+
+func RegisterSearchRoutes(rg *gin.RouterGroup, searchModule *modules.SearchModule) {
+
+	search := rg.Group("/search")
+	search.Use(searchModule.AuthMiddleware.RequireAuth(), searchModule.AuthMiddleware.RequireAnyRole(models.RoleAdmin))
+	{
+		search.GET("/sync", searchModule.Controller.SyncStatus)
+	}
+}
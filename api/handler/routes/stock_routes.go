@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/modules"
+)
+
+func RegisterStockRoutes(rg *gin.RouterGroup, stockModule *modules.StockModule) {
+
+	stock := rg.Group("/stock")
+	stock.Use(stockModule.AuthMiddleware.RequireAuth(), stockModule.AuthMiddleware.RequireAnyRole(models.RoleAdmin))
+	{
+		stock.POST("/rebuild", stockModule.Controller.Rebuild)
+		stock.POST("/reconcile", stockModule.Controller.ReconcileCache)
+		stock.GET("/variants/:id/movements", stockModule.Controller.ListMovements)
+	}
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/modules"
+)
+
+func RegisterWishlistRoutes(rg *gin.RouterGroup, wishlistModule *modules.WishlistModule) {
+
+	wishlist := rg.Group("/wishlist")
+	wishlist.Use(wishlistModule.AuthMiddleware.RequireAuth())
+	{
+		wishlist.GET("", wishlistModule.Controller.List)
+		wishlist.POST("/items", wishlistModule.Controller.AddItem)
+		wishlist.DELETE("/items/:variantId", wishlistModule.Controller.RemoveItem)
+	}
+}
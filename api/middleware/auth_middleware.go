@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"github.com/minh6824pro/nxrGO/internal/cache"
 	"github.com/minh6824pro/nxrGO/internal/jwt"
 	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/pkg/errors"
+	"log"
 	"net/http"
 	"strings"
 
@@ -12,11 +14,15 @@ import (
 
 type AuthMiddleware struct {
 	jwtService *jwt.JWTService
+	denylist   cache.TokenDenylistService
+	userStatus cache.UserStatusService
 }
 
-func NewAuthMiddleware(jwtService *jwt.JWTService) *AuthMiddleware {
+func NewAuthMiddleware(jwtService *jwt.JWTService, denylist cache.TokenDenylistService, userStatus cache.UserStatusService) *AuthMiddleware {
 	return &AuthMiddleware{
 		jwtService: jwtService,
+		denylist:   denylist,
+		userStatus: userStatus,
 	}
 }
 
@@ -59,10 +65,54 @@ func (a *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		// Không kiểm tra được denylist thì từ chối, token đã thu hồi không được lọt qua khi Redis down
+		revoked, err := a.denylist.IsRevoked(c, claims.SessionID, claims.UserID, claims.IssuedTime())
+		if err != nil {
+			log.Printf("Error checking token denylist: %v", err)
+			errors.WriteError(c,
+				errors.NewError(
+					errors.SERVICE_UNAVAILABLE,
+					"Authentication temporarily unavailable",
+					http.StatusServiceUnavailable,
+					err))
+			c.Abort()
+			return
+		}
+		if revoked {
+			errors.WriteError(c,
+				errors.NewError(
+					errors.UNAUTHORIZED,
+					"Token has been revoked",
+					http.StatusUnauthorized,
+					nil))
+			c.Abort()
+			return
+		}
+
+		// Tài khoản bị khoá không dùng được token còn hạn
+		active, err := a.userStatus.IsActive(c, claims.UserID)
+		if err != nil {
+			errors.WriteError(c, err)
+			c.Abort()
+			return
+		}
+		if !active {
+			errors.WriteError(c,
+				errors.NewError(
+					errors.UNAUTHORIZED,
+					"Account is deactivated",
+					http.StatusUnauthorized,
+					nil))
+			c.Abort()
+			return
+		}
+
 		// Lưu thông tin user vào context
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
 		c.Set("role", claims.Role)
+		c.Set("merchant_id", claims.MerchantID)
+		c.Set("session_id", claims.SessionID)
 		c.Next()
 	}
 }
@@ -96,3 +146,34 @@ func (a *AuthMiddleware) RequireRole(role models.Role) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireAnyRole middleware cho phép một trong các quyền
+func (a *AuthMiddleware) RequireAnyRole(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRole, exists := c.Get("role")
+		if !exists {
+			errors.WriteError(c,
+				errors.NewError(
+					errors.UNAUTHORIZED,
+					"Unauthorized",
+					http.StatusUnauthorized,
+					nil))
+			c.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			if userRole == role {
+				c.Next()
+				return
+			}
+		}
+		errors.WriteError(c,
+			errors.NewError(
+				errors.FORBIDDEN,
+				"Insufficient permissions",
+				http.StatusForbidden,
+				nil))
+		c.Abort()
+	}
+}
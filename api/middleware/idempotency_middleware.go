@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"github.com/minh6824pro/nxrGO/pkg/errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	idempotencyKeyMaxLength  = 255
+	idempotencyContentType   = "application/json; charset=utf-8"
+)
+
+type IdempotencyMiddleware struct {
+	idempotencyService services.IdempotencyService
+}
+
+func NewIdempotencyMiddleware(idempotencyService services.IdempotencyService) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		idempotencyService: idempotencyService,
+	}
+}
+
+// bodyCaptureWriter keeps a copy of the response so it can be replayed
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent middleware trả về response đã lưu khi client gửi lại cùng Idempotency-Key, dùng sau RequireAuth
+func (m *IdempotencyMiddleware) Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > idempotencyKeyMaxLength {
+			errors.WriteError(c,
+				errors.NewError(
+					errors.BAD_REQUEST,
+					"Idempotency-Key is too long",
+					http.StatusBadRequest,
+					nil))
+			c.Abort()
+			return
+		}
+
+		userID, exists := c.Get("user_id")
+		if !exists {
+			errors.WriteError(c,
+				errors.NewError(
+					errors.UNAUTHORIZED,
+					"Unauthorized",
+					http.StatusUnauthorized,
+					nil))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			errors.WriteError(c,
+				errors.NewError(
+					errors.BAD_REQUEST,
+					"Invalid request body",
+					http.StatusBadRequest,
+					err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, replay, err := m.idempotencyService.Begin(c, userID.(uint), key, fingerprint(c, body))
+		if err != nil {
+			errors.WriteError(c, err)
+			c.Abort()
+			return
+		}
+		if replay {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.ResponseCode, idempotencyContentType, []byte(record.ResponseBody))
+			c.Abort()
+			return
+		}
+
+		stopRenewal := m.renewWhileRunning(record, key)
+		// A panicking handler leaves no response to replay, free the key before gin recovers
+		defer func() {
+			stopRenewal()
+			if r := recover(); r != nil {
+				if err := m.idempotencyService.Abort(context.Background(), record); err != nil {
+					log.Printf("Error releasing idempotency key %s: %v", key, err)
+				}
+				panic(r)
+			}
+		}()
+
+		writer := &bodyCaptureWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+		c.Next()
+		stopRenewal()
+
+		// Server errors are stored as well, the handler may have committed part of its work before failing
+		if err := m.idempotencyService.Complete(c, record, c.Writer.Status(), writer.body.Bytes()); err != nil {
+			log.Printf("Error saving idempotent response of key %s: %v", key, err)
+		}
+	}
+}
+
+// renewWhileRunning keeps the short in-progress lease alive until the returned stop is called
+func (m *IdempotencyMiddleware) renewWhileRunning(record *models.IdempotencyKey, key string) func() {
+	interval := time.Until(record.ExpiresAt) / 3
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := m.idempotencyService.Renew(context.Background(), record); err != nil {
+					log.Printf("Error renewing idempotency key %s: %v", key, err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// fingerprint hashes method, path and body, JSON bodies are compacted so whitespace does not matter
+func fingerprint(c *gin.Context, body []byte) string {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, body); err == nil {
+		body = compacted.Bytes()
+	}
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
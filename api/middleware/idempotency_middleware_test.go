@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/services/impl"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryIdempotencyKeyRepo keeps the keys in a map like the unique index of the table would
+type memoryIdempotencyKeyRepo struct {
+	mu     sync.Mutex
+	nextID uint
+	byID   map[uint]*models.IdempotencyKey
+}
+
+func newMemoryIdempotencyKeyRepo() *memoryIdempotencyKeyRepo {
+	return &memoryIdempotencyKeyRepo{byID: make(map[uint]*models.IdempotencyKey)}
+}
+
+func (r *memoryIdempotencyKeyRepo) find(userID uint, key string) *models.IdempotencyKey {
+	for _, record := range r.byID {
+		if record.UserID == userID && record.Key == key {
+			return record
+		}
+	}
+	return nil
+}
+
+func (r *memoryIdempotencyKeyRepo) CreateIfAbsent(ctx context.Context, record *models.IdempotencyKey) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.find(record.UserID, record.Key) != nil {
+		return false, nil
+	}
+	r.nextID++
+	record.ID = r.nextID
+	stored := *record
+	r.byID[record.ID] = &stored
+	return true, nil
+}
+
+func (r *memoryIdempotencyKeyRepo) GetByUserIdAndKey(ctx context.Context, userID uint, key string) (*models.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := r.find(userID, key)
+	if record == nil {
+		return nil, nil
+	}
+	found := *record
+	return &found, nil
+}
+
+func (r *memoryIdempotencyKeyRepo) SaveResponse(ctx context.Context, id uint, responseCode int, responseBody string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := r.byID[id]
+	record.Status = models.IdempotencyCompleted
+	record.ResponseCode = responseCode
+	record.ResponseBody = responseBody
+	record.ExpiresAt = expiresAt
+	return nil
+}
+
+func (r *memoryIdempotencyKeyRepo) ExtendLease(ctx context.Context, id uint, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.byID[id]
+	if !ok || record.Status != models.IdempotencyInProgress {
+		return false, nil
+	}
+	record.ExpiresAt = expiresAt
+	return true, nil
+}
+
+func (r *memoryIdempotencyKeyRepo) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byID, id)
+	return nil
+}
+
+func (r *memoryIdempotencyKeyRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// newIdempotentRouter serves POST /orders behind the middleware, handler decides the response of each call
+func newIdempotentRouter(repo *memoryIdempotencyKeyRepo, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	m := NewIdempotencyMiddleware(impl.NewIdempotencyService(repo))
+	r := gin.New()
+	r.Use(gin.Recovery(), func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+	})
+	r.POST("/orders", m.Idempotent(), handler)
+	return r
+}
+
+func postOrder(r *gin.Engine, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotentReplaysStoredResponse(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(newMemoryIdempotencyKeyRepo(), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"order": calls})
+	})
+
+	first := postOrder(r, "key-1", `{"total": 100}`)
+	// Same request with other whitespace has the same fingerprint
+	second := postOrder(r, "key-1", `{"total":100}`)
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, expected once", calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay %d %q differs from %d %q", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatal("replayed response must be flagged")
+	}
+	if first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatal("first response must not be flagged")
+	}
+}
+
+func TestIdempotentRejectsKeyReusedForAnotherRequest(t *testing.T) {
+	r := newIdempotentRouter(newMemoryIdempotencyKeyRepo(), func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	postOrder(r, "key-1", `{"total": 100}`)
+	rec := postOrder(r, "key-1", `{"total": 200}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+}
+
+func TestIdempotentReplaysServerErrors(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(newMemoryIdempotencyKeyRepo(), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "INTERNAL_ERROR"})
+	})
+
+	postOrder(r, "key-1", `{}`)
+	rec := postOrder(r, "key-1", `{}`)
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, a failed request may have committed work and must not run again", calls)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected the stored 500, got %d", rec.Code)
+	}
+}
+
+func TestIdempotentReleasesKeyWhenHandlerPanics(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(newMemoryIdempotencyKeyRepo(), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	if rec := postOrder(r, "key-1", `{}`); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected the recovered panic to answer 500, got %d", rec.Code)
+	}
+	rec := postOrder(r, "key-1", `{}`)
+	if calls != 2 || rec.Code != http.StatusCreated {
+		t.Fatalf("expected the retry to run the handler again, calls %d status %d", calls, rec.Code)
+	}
+}
+
+func TestIdempotentRejectsKeyStillInProgress(t *testing.T) {
+	var r *gin.Engine
+	retryStatus := 0
+	r = newIdempotentRouter(newMemoryIdempotencyKeyRepo(), func(c *gin.Context) {
+		// The client retries while the first request is still being handled
+		if retryStatus == 0 {
+			retryStatus = postOrder(r, "key-1", `{}`).Code
+		}
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+
+	if rec := postOrder(r, "key-1", `{}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	if retryStatus != http.StatusConflict {
+		t.Fatalf("expected the concurrent retry to get 409, got %d", retryStatus)
+	}
+}
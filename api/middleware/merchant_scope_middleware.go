@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// MerchantScopeMiddleware lets admins through and limits merchants to the products,
+// variants and orders of their own merchant. It runs after RequireAuth.
+type MerchantScopeMiddleware struct {
+	productRepo        repositories.ProductRepository
+	productVariantRepo repositories.ProductVariantRepository
+	orderRepo          repositories.OrderRepository
+}
+
+func NewMerchantScopeMiddleware(productRepo repositories.ProductRepository, productVariantRepo repositories.ProductVariantRepository,
+	orderRepo repositories.OrderRepository) *MerchantScopeMiddleware {
+	return &MerchantScopeMiddleware{
+		productRepo:        productRepo,
+		productVariantRepo: productVariantRepo,
+		orderRepo:          orderRepo,
+	}
+}
+
+// ProductParam scopes routes addressing a product by path param
+func (m *MerchantScopeMiddleware) ProductParam(param string) gin.HandlerFunc {
+	return m.scope(func(c *gin.Context) ([]uint, error) {
+		id, err := pathID(c, param)
+		if err != nil {
+			return nil, err
+		}
+		merchantID, err := m.productRepo.GetMerchantID(c, id)
+		return []uint{merchantID}, err
+	})
+}
+
+// VariantParam scopes routes addressing a product variant by path param
+func (m *MerchantScopeMiddleware) VariantParam(param string) gin.HandlerFunc {
+	return m.scope(func(c *gin.Context) ([]uint, error) {
+		id, err := pathID(c, param)
+		if err != nil {
+			return nil, err
+		}
+		merchantID, err := m.productVariantRepo.GetMerchantID(c, id)
+		return []uint{merchantID}, err
+	})
+}
+
+// OrderParam scopes routes addressing an order by path param, the order must hold only items of the merchant
+func (m *MerchantScopeMiddleware) OrderParam(param string) gin.HandlerFunc {
+	return m.scope(func(c *gin.Context) ([]uint, error) {
+		id, err := pathID(c, param)
+		if err != nil {
+			return nil, err
+		}
+		return m.orderRepo.ListMerchantIDs(c, id)
+	})
+}
+
+// ProductInBody scopes creation of resources under the product given in the json body
+func (m *MerchantScopeMiddleware) ProductInBody(field string) gin.HandlerFunc {
+	return m.scope(func(c *gin.Context) ([]uint, error) {
+		id, err := bodyID(c, field)
+		if err != nil {
+			return nil, err
+		}
+		merchantID, err := m.productRepo.GetMerchantID(c, id)
+		return []uint{merchantID}, err
+	})
+}
+
+// MerchantInBody scopes creation of resources for the merchant given in the json body
+func (m *MerchantScopeMiddleware) MerchantInBody(field string) gin.HandlerFunc {
+	return m.scope(func(c *gin.Context) ([]uint, error) {
+		id, err := bodyID(c, field)
+		if err != nil {
+			return nil, err
+		}
+		return []uint{id}, nil
+	})
+}
+
+func (m *MerchantScopeMiddleware) scope(owners func(c *gin.Context) ([]uint, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		if role == models.RoleAdmin {
+			c.Next()
+			return
+		}
+		value, _ := c.Get("merchant_id")
+		merchantID, _ := value.(uint)
+		if role != models.RoleMerchant || merchantID == 0 {
+			errors.WriteError(c, errors.NewError(errors.FORBIDDEN, "Insufficient permissions", http.StatusForbidden, nil))
+			c.Abort()
+			return
+		}
+
+		ownerIDs, err := owners(c)
+		if err != nil {
+			errors.WriteError(c, err)
+			c.Abort()
+			return
+		}
+		if len(ownerIDs) != 1 || ownerIDs[0] != merchantID {
+			errors.WriteError(c, errors.NewError(errors.FORBIDDEN, "Resource belongs to another merchant", http.StatusForbidden, nil))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func pathID(c *gin.Context, param string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 64)
+	if err != nil {
+		return 0, errors.NewError(errors.BAD_REQUEST, "Invalid "+param, http.StatusBadRequest, err)
+	}
+	return uint(id), nil
+}
+
+// bodyID reads a numeric field of the json body and puts the body back for the handler
+func bodyID(c *gin.Context, field string) (uint, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return 0, errors.NewError(errors.BAD_REQUEST, "Cannot read request body", http.StatusBadRequest, err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return 0, errors.NewError(errors.BAD_REQUEST, "Invalid request body", http.StatusBadRequest, err)
+	}
+	var id uint
+	if err := json.Unmarshal(fields[field], &id); err != nil || id == 0 {
+		return 0, errors.NewError(errors.VALIDATION_ERROR, field+" is required", http.StatusBadRequest, err)
+	}
+	return id, nil
+}
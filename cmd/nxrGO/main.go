@@ -8,16 +8,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/minh6824pro/nxrGO/api/handler/routes"
 	"github.com/minh6824pro/nxrGO/docs"
-	"github.com/minh6824pro/nxrGO/internal/cache"
 	"github.com/minh6824pro/nxrGO/internal/config"
 	"github.com/minh6824pro/nxrGO/internal/database"
 	"github.com/minh6824pro/nxrGO/internal/elastic"
 	"github.com/minh6824pro/nxrGO/internal/event"
-	"github.com/minh6824pro/nxrGO/internal/models"
+	repoImpl "github.com/minh6824pro/nxrGO/internal/repositories/impl"
 	"github.com/minh6824pro/nxrGO/internal/wire"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
-	"gorm.io/gorm"
 	"log"
 	"time"
 )
@@ -66,8 +64,11 @@ func main() {
 	elasticRepo.DBToElastic(context.Background())
 
 	// Init necessary dependency
-	eventPub := event.NewChannelEventPublisher()
-	updateStockAgg := event.NewUpdateStockAggregator()
+	config.InitJWTKeyring()
+	config.InitMailSender()
+	config.InitNotifier()
+	config.InitPaymentProvider()
+	eventPub := event.NewOutboxEventPublisher(repoImpl.NewOutboxGormRepository(db))
 
 	//configs.InitRabbitMQ()
 	//defer configs.CloseRabbitMQ()
@@ -78,6 +79,10 @@ func main() {
 	//consumers.ConsumeOrderDLQ(orderRepo)
 
 	r := gin.Default()
+	// c.ClientIP() chỉ đọc X-Forwarded-For khi request đi qua proxy tin cậy
+	if err := r.SetTrustedProxies(config.TrustedProxies()); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	// Add CORS middleware
 
@@ -91,17 +96,39 @@ func main() {
 
 	api := r.Group("/api")
 
-	auth := wire.InitAuthModule(db)
-	merchant := wire.InitMerchantModule(db)
-	brand := wire.InitBrandModule(db)
-	category := wire.InitCategoryModule(db)
-	product := wire.InitProductModule(db, config.RedisClient, config.EsClient, config.RedisCtx, updateStockAgg)
+	auth := wire.InitAuthModule(db, config.RedisClient, config.MailSender)
+	merchant := wire.InitMerchantModule(db, config.RedisClient, eventPub)
+	brand := wire.InitBrandModule(db, eventPub)
+	category := wire.InitCategoryModule(db, eventPub)
+	product := wire.InitProductModule(db, config.RedisClient, config.EsClient, config.RedisCtx, eventPub, config.Notifier)
 	variant := wire.InitVariantModule(db)
-	order := wire.InitOrderModule(db, config.RedisClient, config.RedisCtx, eventPub, updateStockAgg)
-	productVariant := wire.InitProductVariantModule(db, config.RedisClient, config.RedisCtx, updateStockAgg)
-	payOsModule := wire.InitPayOSModule(db, config.RedisClient, config.RedisCtx, eventPub, updateStockAgg)
+	order := wire.InitOrderModule(db, config.RedisClient, config.RedisCtx, eventPub, config.PaymentProvider, config.Notifier)
+	productVariant := wire.InitProductVariantModule(db, config.RedisClient, config.RedisCtx, eventPub, config.Notifier)
+	payOsModule := wire.InitPayOSModule(db, config.RedisClient, config.RedisCtx, eventPub, config.PaymentProvider, config.Notifier)
+	refund := wire.InitRefundModule(db, config.RedisClient, config.PaymentProvider)
+	address := wire.InitAddressModule(db, config.RedisClient)
+	cart := wire.InitCartModule(db, config.RedisClient, config.RedisCtx, eventPub, config.PaymentProvider, config.Notifier)
+	promotion := wire.InitPromotionModule(db, config.RedisClient)
+	review := wire.InitReviewModule(db, config.RedisClient, config.RedisCtx)
+	wishlist := wire.InitWishlistModule(db, config.RedisClient, config.Notifier)
+	search := wire.InitSearchModule(db, config.RedisClient)
+	stock := wire.InitStockModule(db, config.RedisClient, config.RedisCtx, eventPub)
+
+	// Schedule reconciliation of PayOS payment links, the outbox relay redelivers events not handled before a crash
+	eventPub.Subscribe(order.Service.HandlePaymentCreated)
+	// Keep the products index in sync with catalog writes
+	eventPub.SubscribeCatalogChanged(search.Service.HandleCatalogChanged)
+
+	// Apply stock movements recorded in the ledger but not applied before last shutdown
+	if err := order.StockLedger.SeedOpeningBalances(context.Background()); err != nil {
+		log.Printf("Error seeding stock ledger: %v", err)
+	}
+	if _, err := order.StockLedger.Flush(context.Background()); err != nil {
+		log.Printf("Error flushing stock ledger: %v", err)
+	}
 	// Register auth routes FIRST
 	routes.RegisterAuthRoutes(api, auth)
+	routes.RegisterJWKSRoutes(r, auth)
 
 	// Existing routes
 	routes.RegisterMerchantRoutes(api, merchant)
@@ -111,6 +138,14 @@ func main() {
 	routes.RegisterVariantRoutes(api, variant)
 	routes.RegisterOrderRoutes(api, order)
 	routes.RegisterPayOSRoutes(api, payOsModule)
+	routes.RegisterRefundRoutes(api, refund)
+	routes.RegisterAddressRoutes(api, address)
+	routes.RegisterCartRoutes(api, cart)
+	routes.RegisterPromotionRoutes(api, promotion)
+	routes.RegisterReviewRoutes(api, review)
+	routes.RegisterWishlistRoutes(api, wishlist)
+	routes.RegisterSearchRoutes(api, search)
+	routes.RegisterStockRoutes(api, stock)
 	routes.RegisterProductVariantRoutes(api, productVariant)
 	// setup swagger info
 	docs.SwaggerInfo.Title = "nxrGO"
@@ -141,86 +176,36 @@ func main() {
 		defer ticker.Stop()
 
 		for range ticker.C {
-			data := updateStockAgg.Flush()
-			log.Println("Flushed:", data)
-			updateStocks(db, data, order.ProductVariantRedisService)
 			err := order.Service.UpdateQuantity(context.Background())
 			if err != nil {
 				return
 			}
+			if err := order.Idempotency.DeleteExpired(context.Background()); err != nil {
+				log.Printf("Error deleting expired idempotency keys: %v", err)
+			}
 
 		}
 	}()
 
-	<-ready
-	fmt.Println("Server ready, init PayOS...")
-	config.InitPayOS()
-
-	// Publish payOS payment event not yet handle because of app crash
+	// Give back stock of unpaid drafts whose payment link expired
 	go func() {
-		err := processPendingDraftOrders(db, eventPub)
-		if err != nil {
-			log.Printf("Error processing pending draft orders: %v", err)
-		}
-	}()
-
-	select {}
-}
-
-func updateStocks(db *gorm.DB, data map[uint]int, productVariantCache cache.ProductVariantRedis) {
-	for key, value := range data {
-		err := db.Model(&models.ProductVariant{}).
-			Where("id = ?", key).
-			UpdateColumn("quantity", gorm.Expr("quantity + ?", value)).
-			Error
-		if err != nil {
-			log.Printf("Error updating product variant: %d: %v", key, err)
-		}
-		err = productVariantCache.DeleteProductVariantHash(key)
-		if err != nil {
-			log.Printf("Error deleting product variant cache: %v", err)
-		}
-	}
-	log.Print("Update stocks successfully")
-}
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
 
-func processPendingDraftOrders(db *gorm.DB, eventPub *event.ChannelEventPublisher) error {
-	var paymentInfos []models.PaymentInfo
-
-	latestSub := db.
-		Table("payment_infos").
-		Select("order_id, MAX(created_at) as max_created_at").
-		//	Where("order_type = ?", "draft_order").
-		Where("status = ?", "PENDING").
-		Where("payment_link <> ?", "").
-		Group("order_id")
-
-	err := db.
-		Table("payment_infos p").
-		Joins("JOIN (?) latest ON p.order_id = latest.order_id AND p.created_at = latest.max_created_at", latestSub).
-		Scan(&paymentInfos).Error
-	if err != nil {
-		log.Printf("Error scanning pending bank payment: %v", err)
-		return err
-	}
-	for _, p := range paymentInfos {
-		payOSEvent := event.PayOSPaymentCreatedEvent{
-			Id:            p.ID,
-			OrderID:       p.OrderID,
-			PaymentLink:   p.PaymentLink,
-			Total:         p.Total,
-			PaymentMethod: "BANK",
-			CreatedAt:     time.Now(),
+		for range ticker.C {
+			if _, err := order.Service.ExpireReservations(context.Background()); err != nil {
+				log.Printf("Error expiring draft reservations: %v", err)
+			}
 		}
+	}()
 
-		err := eventPub.PublishPaymentCreated(payOSEvent)
-		if err != nil {
-			log.Printf("Error publishing draft order: %v", err)
-			continue
-		}
-		log.Println("draft order published")
+	<-ready
+	log.Println("Server ready, register payment webhook...")
+	config.RegisterPaymentWebhook()
 
-	}
+	// Start delivering outbox events and reconciling pending payments once the provider is ready
+	eventPub.Start(context.Background())
+	payOsModule.Reconciler.Start(context.Background())
 
-	return nil
+	select {}
 }
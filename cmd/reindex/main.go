@@ -0,0 +1,58 @@
+// main.go
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/minh6824pro/nxrGO/internal/config"
+	"github.com/minh6824pro/nxrGO/internal/database"
+	"github.com/minh6824pro/nxrGO/internal/elastic"
+	"github.com/minh6824pro/nxrGO/internal/wire"
+	"log"
+)
+
+// Build the next products index version from MySQL and swap the aliases to it:
+//
+//	go run ./cmd/reindex
+//
+// Swap the aliases back to the previous version:
+//
+//	go run ./cmd/reindex -rollback
+func main() {
+	rollback := flag.Bool("rollback", false, "swap the aliases back to the previous index version")
+	flag.Parse()
+
+	// Load env var
+	config.LoadEnv()
+
+	// Connect DB
+	database.ConnectDatabase()
+	db := database.DB
+
+	config.InitRedis()
+	config.InitElastic()
+
+	// Create the first version behind the aliases if the server never ran
+	ctx := context.Background()
+	if err := elastic.NewElasticClient().EnsureProductIndex(ctx); err != nil {
+		log.Fatalf("Error ensuring products index: %v", err)
+	}
+
+	search := wire.InitSearchModule(db, config.RedisClient)
+	if *rollback {
+		result, err := search.Service.Rollback(ctx)
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		log.Printf("Rolled back from %s to %s (%d documents, %d changes replayed)",
+			result.PreviousIndex, result.Index, result.Documents, result.ReplayedEvents)
+		return
+	}
+
+	result, err := search.Service.Reindex(ctx)
+	if err != nil {
+		log.Fatalf("Reindex failed: %v", err)
+	}
+	log.Printf("Reindexed from %s to %s (%d documents, %d changes replayed)",
+		result.PreviousIndex, result.Index, result.Documents, result.ReplayedEvents)
+}
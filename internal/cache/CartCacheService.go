@@ -0,0 +1,13 @@
+package cache
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+// CartCacheService keeps carts in Redis, the cart_items table stays the durable copy
+type CartCacheService interface {
+	Get(ctx context.Context, userID uint) ([]models.CartItem, bool, error)
+	Set(ctx context.Context, userID uint, items []models.CartItem) error
+	Delete(ctx context.Context, userID uint) error
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type cartCacheService struct {
+	rdb *redis.Client
+}
+
+const (
+	cartKeyPattern = "cart:%d"
+	cartTTL        = 7 * 24 * time.Hour
+)
+
+func NewCartCacheService(rdb *redis.Client) CartCacheService {
+	return &cartCacheService{rdb: rdb}
+}
+
+// Get returns false when the cart is not cached
+func (s *cartCacheService) Get(ctx context.Context, userID uint) ([]models.CartItem, bool, error) {
+	data, err := s.rdb.Get(ctx, fmt.Sprintf(cartKeyPattern, userID)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var items []models.CartItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, false, err
+	}
+	return items, true, nil
+}
+
+func (s *cartCacheService) Set(ctx context.Context, userID uint, items []models.CartItem) error {
+	if items == nil {
+		items = []models.CartItem{}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, fmt.Sprintf(cartKeyPattern, userID), data, cartTTL).Err()
+}
+
+func (s *cartCacheService) Delete(ctx context.Context, userID uint) error {
+	return s.rdb.Del(ctx, fmt.Sprintf(cartKeyPattern, userID)).Err()
+}
@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// LoginAttemptService counts failed logins per account and per IP
+type LoginAttemptService interface {
+	RecordFailure(ctx context.Context, email string, ip string) (accountFailures int64, ipFailures int64, err error)
+	IPFailures(ctx context.Context, ip string) (int64, error)
+	Lock(ctx context.Context, email string, ttl time.Duration) error
+	LockedFor(ctx context.Context, email string) (time.Duration, error)
+	Throttle(ctx context.Context, email string, ttl time.Duration) error
+	ThrottledFor(ctx context.Context, email string) (time.Duration, error)
+	Reset(ctx context.Context, email string) error
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+type loginAttemptService struct {
+	rdb *redis.Client
+}
+
+const (
+	loginAccountFailuresKeyPattern = "login:failures:account:%s"
+	loginIPFailuresKeyPattern      = "login:failures:ip:%s"
+	loginLockKeyPattern            = "login:lock:%s"
+	loginThrottleKeyPattern        = "login:throttle:%s"
+	// loginFailureWindow starts with the first failure, counters reset after it
+	loginFailureWindow = 15 * time.Minute
+)
+
+func NewLoginAttemptService(rdb *redis.Client) LoginAttemptService {
+	return &loginAttemptService{rdb: rdb}
+}
+
+func (s *loginAttemptService) RecordFailure(ctx context.Context, email string, ip string) (int64, int64, error) {
+	accountKey := fmt.Sprintf(loginAccountFailuresKeyPattern, strings.ToLower(email))
+	ipKey := fmt.Sprintf(loginIPFailuresKeyPattern, ip)
+
+	counts, err := incrInWindow(ctx, s.rdb, loginFailureWindow, accountKey, ipKey)
+	if err != nil {
+		return 0, 0, err
+	}
+	return counts[0], counts[1], nil
+}
+
+func (s *loginAttemptService) IPFailures(ctx context.Context, ip string) (int64, error) {
+	count, err := s.rdb.Get(ctx, fmt.Sprintf(loginIPFailuresKeyPattern, ip)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return count, err
+}
+
+// Lock blocks the account for ttl and restarts its failure count
+func (s *loginAttemptService) Lock(ctx context.Context, email string, ttl time.Duration) error {
+	email = strings.ToLower(email)
+	pipe := s.rdb.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf(loginLockKeyPattern, email), 1, ttl)
+	pipe.Del(ctx, fmt.Sprintf(loginAccountFailuresKeyPattern, email))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *loginAttemptService) LockedFor(ctx context.Context, email string) (time.Duration, error) {
+	return s.remaining(ctx, fmt.Sprintf(loginLockKeyPattern, strings.ToLower(email)))
+}
+
+// Throttle makes the account wait ttl before its next login attempt
+func (s *loginAttemptService) Throttle(ctx context.Context, email string, ttl time.Duration) error {
+	return s.rdb.Set(ctx, fmt.Sprintf(loginThrottleKeyPattern, strings.ToLower(email)), 1, ttl).Err()
+}
+
+func (s *loginAttemptService) ThrottledFor(ctx context.Context, email string) (time.Duration, error) {
+	return s.remaining(ctx, fmt.Sprintf(loginThrottleKeyPattern, strings.ToLower(email)))
+}
+
+func (s *loginAttemptService) remaining(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// -2 missing key, -1 no expiry
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Reset clears the failures, the throttle and the lock of the account
+func (s *loginAttemptService) Reset(ctx context.Context, email string) error {
+	email = strings.ToLower(email)
+	return s.rdb.Del(ctx,
+		fmt.Sprintf(loginAccountFailuresKeyPattern, email),
+		fmt.Sprintf(loginThrottleKeyPattern, email),
+		fmt.Sprintf(loginLockKeyPattern, email)).Err()
+}
@@ -10,6 +10,7 @@ type ProductCacheService interface {
 	GetProductMiniCacheBulk(ctx context.Context, list []CacheModel.ListProductQueryCache) ([]*CacheModel.ProductMiniCache, []CacheModel.ListProductQueryCache, error)
 	CacheMiniProduct(ctx context.Context, product *CacheModel.ProductMiniCache) error
 	CacheMiniProducts(ctx context.Context, products []*CacheModel.ProductMiniCache) error
+	DeleteProductMiniCache(ctx context.Context, productID uint, variantIDs []uint) error
 	GenerateListProductCacheKey(priceMin, priceMax *float64, priceAsc, totalBuyDesc *bool, page, pageSize int) string
 	GetListProductCache(ctx context.Context, key string) ([]CacheModel.ListProductQueryCache, error)
 	CacheListProduct(ctx context.Context, key string, data []CacheModel.ListProductQueryCache) error
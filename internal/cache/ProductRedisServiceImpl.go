@@ -173,6 +173,18 @@ func (s *productCacheServiceImpl) CacheMiniProducts(ctx context.Context, product
 	return err
 }
 
+// Xoá cache mini của các variant, lần đọc sau sẽ lấy lại từ DB
+func (s *productCacheServiceImpl) DeleteProductMiniCache(ctx context.Context, productID uint, variantIDs []uint) error {
+	if len(variantIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, len(variantIDs))
+	for i, variantID := range variantIDs {
+		keys[i] = s.getCacheKey(productID, variantID)
+	}
+	return s.rdb.Del(ctx, keys...).Err()
+}
+
 func (s *productCacheServiceImpl) PingRedis(ctx context.Context) error {
 	// Set timeout riêng cho lệnh ping
 	healthCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
@@ -0,0 +1,11 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// RateLimiter counts hits per key in a fixed window
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, error)
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type rateLimiter struct {
+	rdb *redis.Client
+}
+
+func NewRateLimiter(rdb *redis.Client) RateLimiter {
+	return &rateLimiter{rdb: rdb}
+}
+
+// Allow counts the hit, the window starts with the first hit of the key
+func (r *rateLimiter) Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, error) {
+	counts, err := incrInWindow(ctx, r.rdb, window, "ratelimit:"+key)
+	if err != nil {
+		return false, err
+	}
+	return counts[0] <= limit, nil
+}
+
+// incrInWindow counts a hit on every key and sets the expiry of a new counter in the same script,
+// a counter left without expiry would block the key forever
+func incrInWindow(ctx context.Context, rdb *redis.Client, window time.Duration, keys ...string) ([]int64, error) {
+	script := `
+local counts = {}
+for i = 1, #KEYS do
+    counts[i] = redis.call("INCR", KEYS[i])
+    if counts[i] == 1 or redis.call("PTTL", KEYS[i]) == -1 then
+        redis.call("PEXPIRE", KEYS[i], ARGV[1])
+    end
+end
+return counts
+`
+	values, err := rdb.Eval(ctx, script, keys, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count hits: %w", err)
+	}
+	return values, nil
+}
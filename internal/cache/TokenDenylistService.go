@@ -0,0 +1,13 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// TokenDenylistService keeps revoked access tokens out until they expire
+type TokenDenylistService interface {
+	RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error
+	RevokeUser(ctx context.Context, userID uint, ttl time.Duration) error
+	IsRevoked(ctx context.Context, sessionID string, userID uint, issuedAt time.Time) (bool, error)
+}
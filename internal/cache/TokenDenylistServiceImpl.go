@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+type tokenDenylistService struct {
+	rdb *redis.Client
+}
+
+const (
+	deniedSessionKeyPattern = "denylist:session:%s"
+	// deniedUserKeyPattern holds the unix time in milliseconds until which tokens of the user are revoked
+	deniedUserKeyPattern = "denylist:user:%d"
+)
+
+func NewTokenDenylistService(rdb *redis.Client) TokenDenylistService {
+	return &tokenDenylistService{rdb: rdb}
+}
+
+// RevokeSession denies every access token of the session
+func (s *tokenDenylistService) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	return s.rdb.Set(ctx, fmt.Sprintf(deniedSessionKeyPattern, sessionID), 1, ttl).Err()
+}
+
+// RevokeUser denies every access token of the user issued until now
+func (s *tokenDenylistService) RevokeUser(ctx context.Context, userID uint, ttl time.Duration) error {
+	return s.rdb.Set(ctx, fmt.Sprintf(deniedUserKeyPattern, userID), time.Now().UnixMilli(), ttl).Err()
+}
+
+func (s *tokenDenylistService) IsRevoked(ctx context.Context, sessionID string, userID uint, issuedAt time.Time) (bool, error) {
+	values, err := s.rdb.MGet(ctx,
+		fmt.Sprintf(deniedSessionKeyPattern, sessionID),
+		fmt.Sprintf(deniedUserKeyPattern, userID)).Result()
+	if err != nil {
+		return false, err
+	}
+	if values[0] != nil {
+		return true, nil
+	}
+	if values[1] == nil {
+		return false, nil
+	}
+	revokedAt, err := strconv.ParseInt(fmt.Sprint(values[1]), 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return issuedAt.UnixMilli() <= revokedAt, nil
+}
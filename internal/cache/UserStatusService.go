@@ -0,0 +1,9 @@
+package cache
+
+import "context"
+
+// UserStatusService tells whether an account may still use its access tokens
+type UserStatusService interface {
+	IsActive(ctx context.Context, userID uint) (bool, error)
+	Invalidate(ctx context.Context, userID uint) error
+}
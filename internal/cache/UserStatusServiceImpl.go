@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"net/http"
+	"time"
+)
+
+type userStatusService struct {
+	rdb      *redis.Client
+	userRepo repositories.UserRepository
+}
+
+const (
+	userActiveKeyPattern = "user:active:%d"
+	// userActiveTTL bounds how long a status change made outside UserService goes unnoticed
+	userActiveTTL = 5 * time.Minute
+)
+
+func NewUserStatusService(rdb *redis.Client, userRepo repositories.UserRepository) UserStatusService {
+	return &userStatusService{rdb: rdb, userRepo: userRepo}
+}
+
+// IsActive reads the cached flag and falls back to the database when it is missing or Redis is down
+func (s *userStatusService) IsActive(ctx context.Context, userID uint) (bool, error) {
+	key := fmt.Sprintf(userActiveKeyPattern, userID)
+	cached, err := s.rdb.Get(ctx, key).Result()
+	if err == nil {
+		return cached == "1", nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		log.Printf("Error reading cached status of user %d: %v", userID, err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if customErr.ParseError(err).HTTPCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	active := user.Active == 1
+	value := "0"
+	if active {
+		value = "1"
+	}
+	if err := s.rdb.Set(ctx, key, value, userActiveTTL).Err(); err != nil {
+		log.Printf("Error caching status of user %d: %v", userID, err)
+	}
+	return active, nil
+}
+
+func (s *userStatusService) Invalidate(ctx context.Context, userID uint) error {
+	return s.rdb.Del(ctx, fmt.Sprintf(userActiveKeyPattern, userID)).Err()
+}
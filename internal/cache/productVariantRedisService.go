@@ -10,8 +10,10 @@ type ProductVariantRedis interface {
 	GetProductVariantHash(id uint) (map[string]string, error)
 	EvalLua(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
 	IncrementStock(orderItems []models.OrderItem) error
+	IncrementStockIfExists(orderItems []models.OrderItem) error
 	DecrementStock(orderItems []models.OrderItem) error
 	DeleteProductVariantHash(id uint) error
 	PingRedis(ctx context.Context) error
 	DeleteMiniProduct(variantId uint) error
+	SetQuantityIfExists(id uint, quantity int) error
 }
@@ -100,6 +100,47 @@ func (r *productVariantRedisService) IncrementStock(orderItems []models.OrderIte
 	return nil
 }
 
+// IncrementStockIfExists gives back stock of all items in one script, missing hashes are reloaded from DB later
+func (r *productVariantRedisService) IncrementStockIfExists(orderItems []models.OrderItem) error {
+	if len(orderItems) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(orderItems))
+	args := make([]interface{}, 0, len(orderItems))
+	for _, oi := range orderItems {
+		keys = append(keys, fmt.Sprintf(ProductVariantKeyPattern, oi.ProductVariantID))
+		args = append(args, oi.Quantity)
+	}
+	script := `
+for i = 1, #KEYS do
+    if redis.call("EXISTS", KEYS[i]) == 1 then
+        redis.call("HINCRBY", KEYS[i], "quantity", ARGV[i])
+    end
+end
+return 1
+`
+	if err := r.client.Eval(r.ctx, script, keys, args...).Err(); err != nil {
+		return fmt.Errorf("failed to increment stock: %w", err)
+	}
+	for _, oi := range orderItems {
+		r.DeleteMiniProduct(oi.ProductVariantID)
+	}
+	return nil
+}
+
+// SetQuantityIfExists only touches hashes that are already cached, a partial hash would be read as a hit
+func (r *productVariantRedisService) SetQuantityIfExists(id uint, quantity int) error {
+	key := fmt.Sprintf(ProductVariantKeyPattern, id)
+	script := `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+    redis.call("HSET", KEYS[1], "quantity", ARGV[1])
+    return 1
+end
+return 0
+`
+	return r.client.Eval(r.ctx, script, []string{key}, quantity).Err()
+}
+
 func (r *productVariantRedisService) DeleteProductVariantHash(id uint) error {
 	key := fmt.Sprintf(ProductVariantKeyPattern, id)
 
@@ -132,7 +173,7 @@ func (r *productVariantRedisService) DeleteMiniProduct(variantId uint) error {
 	}
 	log.Println(productID)
 
-	key := fmt.Sprintf(productMiniCacheKeyPattern, productID)
+	key := fmt.Sprintf(productMiniCacheKeyPattern, productID, variantId)
 
 	// Thực hiện xoá key
 	return r.client.Del(r.ctx, key).Err()
@@ -22,6 +22,21 @@ func AutoMigrate() {
 		&models.DraftOrder{},
 		&models.Delivery{},
 		&models.DeliveryDetail{},
+		&models.StockMovement{},
+		&models.OutboxEvent{},
+		&models.IdempotencyKey{},
+		&models.Refund{},
+		&models.RefundItem{},
+		&models.OrderStatusHistory{},
+		&models.RefreshToken{},
+		&models.AuthToken{},
+		&models.Address{},
+		&models.CartItem{},
+		&models.Promotion{},
+		&models.PromotionUsage{},
+		&models.Review{},
+		&models.ReviewImage{},
+		&models.WishlistItem{},
 	)
 
 	if err != nil {
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"github.com/minh6824pro/nxrGO/internal/jwt"
+	"log"
+	"os"
+)
+
+// InitJWTKeyring đọc key ký JWT từ JWT_KEYS_DIR (mỗi file <kid>.pem), JWT_ACTIVE_KID là key dùng để ký.
+// Thiếu key thì dừng khởi động, chỉ khi dev bật JWT_EPHEMERAL_KEY=true mới tạo key Ed25519 tạm (token mất hiệu lực khi restart).
+func InitJWTKeyring() {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		if os.Getenv("JWT_EPHEMERAL_KEY") != "true" || IsProduction() {
+			log.Fatal("JWT_KEYS_DIR is required, set JWT_EPHEMERAL_KEY=true outside production to sign with a temporary key")
+		}
+		key, err := jwt.GenerateEd25519Key("ephemeral-" + jwt.NewTokenID()[:8])
+		if err != nil {
+			log.Fatalf("Error generating JWT key: %v", err)
+		}
+		keyring, err := jwt.NewKeyring(key.ID, key)
+		if err != nil {
+			log.Fatalf("Error creating JWT keyring: %v", err)
+		}
+		jwt.SetKeyring(keyring)
+		log.Println("JWT_EPHEMERAL_KEY set, signing tokens with an ephemeral Ed25519 key")
+		return
+	}
+
+	keyring, err := jwt.LoadKeyringDir(dir, os.Getenv("JWT_ACTIVE_KID"))
+	if err != nil {
+		log.Fatalf("Error loading JWT keys: %v", err)
+	}
+	jwt.SetKeyring(keyring)
+	log.Printf("JWT keyring loaded, active key: %s", keyring.Active().ID)
+}
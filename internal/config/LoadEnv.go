@@ -3,6 +3,8 @@ package config
 import (
 	"github.com/joho/godotenv"
 	"log"
+	"os"
+	"strings"
 )
 
 func LoadEnv() {
@@ -13,3 +15,19 @@ func LoadEnv() {
 
 	log.Println("Environment variables loaded")
 }
+
+// IsProduction báo APP_ENV=production, các tiện ích dev (key tạm, cổng thanh toán giả) bị tắt
+func IsProduction() bool {
+	return os.Getenv("APP_ENV") == "production"
+}
+
+// TrustedProxies đọc TRUSTED_PROXIES (phân tách bằng dấu phẩy), rỗng thì không tin X-Forwarded-For và dùng địa chỉ kết nối
+func TrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
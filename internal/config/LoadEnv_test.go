@@ -0,0 +1,19 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", " 10.0.0.1, ,192.168.0.0/16 ")
+	if got := TrustedProxies(); !reflect.DeepEqual(got, []string{"10.0.0.1", "192.168.0.0/16"}) {
+		t.Fatalf("unexpected proxies %v", got)
+	}
+
+	// Nothing configured trusts no proxy, c.ClientIP() is then the remote address
+	t.Setenv("TRUSTED_PROXIES", "")
+	if got := TrustedProxies(); got != nil {
+		t.Fatalf("expected nil, got %v", got)
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"github.com/minh6824pro/nxrGO/internal/mail"
+	"log"
+	"os"
+)
+
+var (
+	MailSender mail.Sender
+	// RequireEmailVerification chặn login cho tới khi email được xác thực
+	RequireEmailVerification bool
+	// FrontendURL là gốc của link trong email xác thực và đặt lại mật khẩu
+	FrontendURL string
+)
+
+// InitMailSender chọn cách gửi mail theo MAIL_SENDER (smtp | file), mặc định file ghi vào MAIL_OUTBOX_DIR
+func InitMailSender() {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@nxrgo.local"
+	}
+	switch os.Getenv("MAIL_SENDER") {
+	case "smtp":
+		MailSender = mail.NewSMTPSender(os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+	default:
+		dir := os.Getenv("MAIL_OUTBOX_DIR")
+		if dir == "" {
+			dir = "mail_outbox"
+		}
+		MailSender = mail.NewFileSender(dir, from)
+	}
+
+	RequireEmailVerification = os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true"
+	FrontendURL = os.Getenv("FE_URL")
+	if FrontendURL == "" {
+		FrontendURL = "http://localhost:5173"
+	}
+	log.Printf("Mail sender: %s", MailSender.Name())
+}
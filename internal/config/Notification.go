@@ -0,0 +1,20 @@
+package config
+
+import (
+	"github.com/minh6824pro/nxrGO/internal/notification"
+	"log"
+	"os"
+)
+
+var Notifier notification.Notifier
+
+// InitNotifier chọn kênh thông báo theo NOTIFIER (mail | log), mặc định mail qua MailSender
+func InitNotifier() {
+	switch os.Getenv("NOTIFIER") {
+	case "log":
+		Notifier = notification.NewLogNotifier()
+	default:
+		Notifier = notification.NewMailNotifier(MailSender)
+	}
+	log.Printf("Notifier: %s", Notifier.Name())
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/payment"
+	"log"
+	"os"
+)
+
+var PaymentProvider payment.PaymentProvider
+
+// InitPaymentProvider chọn cổng thanh toán theo PAYMENT_PROVIDER (payos | fake), mặc định payos
+func InitPaymentProvider() {
+	provider, err := newPaymentProvider(os.Getenv, IsProduction())
+	if err != nil {
+		log.Fatalf("cannot init payment provider: %v", err)
+	}
+	PaymentProvider = provider
+	log.Printf("Payment provider: %s", PaymentProvider.Name())
+}
+
+// newPaymentProvider đọc cấu hình qua getenv, cổng giả cần FAKE_PAYMENT_SECRET và không được chạy ở production
+func newPaymentProvider(getenv func(string) string, production bool) (payment.PaymentProvider, error) {
+	switch getenv("PAYMENT_PROVIDER") {
+	case "fake":
+		if production {
+			return nil, fmt.Errorf("the fake payment provider is not allowed when APP_ENV=production")
+		}
+		secret := getenv("FAKE_PAYMENT_SECRET")
+		if secret == "" {
+			return nil, fmt.Errorf("FAKE_PAYMENT_SECRET is required by the fake payment provider")
+		}
+		return payment.NewFakeProvider(secret, getenv("BE_URL")+"/api/payments/fake"), nil
+	default:
+		return payment.NewPayOSProvider(getenv("PAYOS_CLIENT_ID"), getenv("PAYOS_API_KEY"), getenv("PAYOS_CHECKSUM_KEY")), nil
+	}
+}
+
+// RegisterPaymentWebhook must run once the server is listening, PayOS calls the url to confirm it
+func RegisterPaymentWebhook() {
+	registrar, ok := PaymentProvider.(payment.WebhookRegistrar)
+	if !ok {
+		return
+	}
+	webHookUrl := os.Getenv("BE_URL") + "/api/payments/webhook"
+	if err := registrar.RegisterWebhook(context.Background(), webHookUrl); err != nil {
+		log.Println(err.Error())
+		return
+	}
+	log.Println("Payment webhook registered:", webHookUrl)
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"github.com/minh6824pro/nxrGO/internal/payment"
+	"testing"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func TestNewPaymentProviderFakeNeedsSecret(t *testing.T) {
+	_, err := newPaymentProvider(env(map[string]string{"PAYMENT_PROVIDER": "fake"}), false)
+	if err == nil {
+		t.Fatal("expected an error without FAKE_PAYMENT_SECRET")
+	}
+}
+
+func TestNewPaymentProviderFakeRefusedInProduction(t *testing.T) {
+	_, err := newPaymentProvider(env(map[string]string{
+		"PAYMENT_PROVIDER":    "fake",
+		"FAKE_PAYMENT_SECRET": "secret",
+	}), true)
+	if err == nil {
+		t.Fatal("expected the fake provider to be refused in production")
+	}
+}
+
+func TestNewPaymentProviderFake(t *testing.T) {
+	provider, err := newPaymentProvider(env(map[string]string{
+		"PAYMENT_PROVIDER":    "fake",
+		"FAKE_PAYMENT_SECRET": "secret",
+		"BE_URL":              "http://localhost:8080",
+	}), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := provider.(*payment.FakeProvider); !ok {
+		t.Fatalf("expected the fake provider, got %s", provider.Name())
+	}
+}
+
+func TestNewPaymentProviderDefaultsToPayOS(t *testing.T) {
+	provider, err := newPaymentProvider(env(map[string]string{}), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if provider.Name() != "payos" {
+		t.Fatalf("expected payos, got %s", provider.Name())
+	}
+}
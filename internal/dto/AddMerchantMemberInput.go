@@ -0,0 +1,5 @@
+package dto
+
+type AddMerchantMemberInput struct {
+	UserID uint `json:"user_id" binding:"required"`
+}
@@ -0,0 +1,27 @@
+package dto
+
+type CreateAddressInput struct {
+	Label         string `json:"label" binding:"max=50"`
+	RecipientName string `json:"recipient_name" binding:"required,max=255"`
+	PhoneNumber   string `json:"phone_number" binding:"required,len=10"`
+	Street        string `json:"street" binding:"required,max=255"`
+	Ward          string `json:"ward" binding:"max=100"`
+	District      string `json:"district" binding:"max=100"`
+	Province      string `json:"province" binding:"required,max=100"`
+	Latitude      string `json:"lat" binding:"required,latitude"`
+	Longitude     string `json:"lon" binding:"required,longitude"`
+	IsDefault     bool   `json:"is_default"`
+}
+
+// UpdateAddressInput changes only the fields sent, the fields required on create can not be cleared
+type UpdateAddressInput struct {
+	Label         *string `json:"label" binding:"omitempty,max=50"`
+	RecipientName *string `json:"recipient_name" binding:"omitnil,min=1,max=255"`
+	PhoneNumber   *string `json:"phone_number" binding:"omitnil,len=10"`
+	Street        *string `json:"street" binding:"omitnil,min=1,max=255"`
+	Ward          *string `json:"ward" binding:"omitempty,max=100"`
+	District      *string `json:"district" binding:"omitempty,max=100"`
+	Province      *string `json:"province" binding:"omitnil,min=1,max=100"`
+	Latitude      *string `json:"lat" binding:"omitnil,latitude"`
+	Longitude     *string `json:"lon" binding:"omitnil,longitude"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+import "github.com/minh6824pro/nxrGO/internal/models"
+
+type CartItemInput struct {
+	ProductVariantID uint `json:"product_variant_id" binding:"required"`
+	Quantity         uint `json:"quantity" binding:"required,min=1"`
+}
+
+type UpdateCartItemInput struct {
+	Quantity uint `json:"quantity" binding:"required,min=1"`
+}
+
+// MergeCartInput carries the cart kept by the client before login
+type MergeCartInput struct {
+	Items []CartItemInput `json:"items" binding:"required,dive"`
+}
+
+type CartDeliveryInput struct {
+	MerchantID uint `json:"merchant_id" binding:"required"`
+	DeliveryID uint `json:"delivery_id" binding:"required"`
+}
+
+type CheckoutCartInput struct {
+	PaymentMethod models.PaymentMethod `json:"payment_method" binding:"required,oneof=COD BANK"`
+	AddressID     uint                 `json:"address_id" binding:"required"`
+	// Deliveries picks one delivery per merchant in the cart
+	Deliveries []CartDeliveryInput `json:"deliveries" binding:"required,dive"`
+	CouponCode string              `json:"coupon_code" binding:"max=50"`
+}
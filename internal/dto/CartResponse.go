@@ -0,0 +1,23 @@
+package dto
+
+// CartLineResponse is a cart line checked against the current price and stock
+type CartLineResponse struct {
+	ProductVariantID  uint    `json:"product_variant_id"`
+	Quantity          uint    `json:"quantity"`
+	AvailableQuantity uint    `json:"available_quantity"`
+	UnitPrice         float64 `json:"unit_price"`
+	PriceAtAdd        float64 `json:"price_at_add"`
+	PriceChanged      bool    `json:"price_changed"`
+	InStock           bool    `json:"in_stock"`
+	// Available is false for variants no longer sold
+	Available bool                     `json:"available"`
+	Subtotal  float64                  `json:"subtotal"`
+	Variant   *VariantCartInfoResponse `json:"variant,omitempty"`
+}
+
+type CartResponse struct {
+	Items    []CartLineResponse `json:"items"`
+	Subtotal float64            `json:"subtotal"`
+	// Valid is true when every line can be checked out as is
+	Valid bool `json:"valid"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+// ClientInfo describes the device a session is opened from
+type ClientInfo struct {
+	Device    string `json:"device,omitempty"`
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
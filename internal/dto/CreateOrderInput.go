@@ -5,16 +5,21 @@ import (
 )
 
 type CreateOrderInput struct {
-	UserID           uint                  `json:"-"`
-	Total            float64               `json:"total" binding:"required"`
-	PaymentMethod    models.PaymentMethod  `json:"payment_method" binding:"required,oneof=COD BANK"`
-	ShippingAddress  string                `json:"shipping_address" binding:"required"`
+	UserID        uint                 `json:"-"`
+	Total         float64              `json:"total" binding:"required"`
+	PaymentMethod models.PaymentMethod `json:"payment_method" binding:"required,oneof=COD BANK"`
+	// AddressID picks a saved address, the raw address fields are only needed without it
+	AddressID        *uint                 `json:"address_id"`
+	ShippingAddress  string                `json:"shipping_address" binding:"required_without=AddressID"`
+	RecipientName    string                `json:"recipient_name" binding:"max=255"`
 	ShippingFee      float64               `json:"shipping_fee" binding:"required"`
-	PhoneNumber      string                `json:"phone_number" binding:"required,len=10"`
+	PhoneNumber      string                `json:"phone_number" binding:"required_without=AddressID,omitempty,len=10"`
 	OrderItems       []CreateOrderItem     `json:"order_items" binding:"required,dive,required"`
 	ShippingFeeInput []ShippingFeeResponse `json:"shipping_fee_input" binding:"required,dive,required"`
-	Latitude         string                `json:"lat" binding:"required"`
-	Longitude        string                `json:"lon" binding:"required"`
+	Latitude         string                `json:"lat" binding:"required_without=AddressID"`
+	Longitude        string                `json:"lon" binding:"required_without=AddressID"`
+	// CouponCode is optional, Total must already be net of its discount
+	CouponCode string `json:"coupon_code" binding:"max=50"`
 }
 
 type CreateOrderItem struct {
@@ -16,6 +16,7 @@ type OrderData struct {
 	Status          string              `json:"status"`
 	PaymentMethod   string              `json:"payment_method"`
 	ShippingAddress string              `json:"shipping_address"`
+	RecipientName   string              `json:"recipient_name"`
 	PhoneNumber     string              `json:"phone_number"`
 	DeliveryMode    models.DeliveryMode `json:"delivery_mode"`
 	PaymentInfo     PaymentInfoResponse `json:"payment_info"`
@@ -30,18 +31,23 @@ type PaymentInfoResponse struct {
 	ID                 int64                `json:"id"`
 	Total              float64              `json:"amount"`
 	ShippingFee        float64              `json:"shipping_fee"`
+	Discount           float64              `json:"discount"`
+	ShippingDiscount   float64              `json:"shipping_discount"`
+	CouponCode         string               `json:"coupon_code,omitempty"`
 	Status             models.PaymentStatus `json:"status"`
 	PaymentLink        string               `json:"payment_link"`
 	CancellationReason string               `json:"cancellation_reason"`
 }
 
 type OrderItemResponse struct {
-	ID               uint             `json:"id"`
-	OrderID          uint             `json:"order_id"`
-	OrderType        models.OrderType `json:"order_type"`
-	ProductVariantID uint             `json:"product_variant_id"`
-	Quantity         uint             `json:"quantity"`
-	Price            float64          `json:"price"`
-	TotalPrice       float64          `json:"total_price"`
-	ProductName      string           `json:"product_name"`
+	ID               uint                   `json:"id"`
+	OrderID          uint                   `json:"order_id"`
+	OrderType        models.OrderType       `json:"order_type"`
+	ProductVariantID uint                   `json:"product_variant_id"`
+	Quantity         uint                   `json:"quantity"`
+	Price            float64                `json:"price"`
+	Discount         float64                `json:"discount"`
+	TotalPrice       float64                `json:"total_price"`
+	ProductName      string                 `json:"product_name"`
+	Status           models.OrderItemStatus `json:"status,omitempty"`
 }
@@ -0,0 +1,12 @@
+package dto
+
+// CreateRefundRequest refunds every item not refunded yet when Items is empty
+type CreateRefundRequest struct {
+	OrderID uint                     `json:"order_id" binding:"required"`
+	Items   []OrderItemQuantityInput `json:"items" binding:"dive"`
+	Reason  string                   `json:"reason" binding:"max=255"`
+}
+
+type RejectRefundRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
@@ -3,4 +3,5 @@ package dto
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	ClientInfo
 }
@@ -6,4 +6,5 @@ import (
 
 type OrderEventRequest struct {
 	Event utils.OrderEvent `json:"event" binding:"required"`
+	Note  string           `json:"note" binding:"max=255"`
 }
@@ -0,0 +1,12 @@
+package dto
+
+type OrderItemQuantityInput struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    uint `json:"quantity" binding:"required,min=1"`
+}
+
+// OrderItemsRequest cancels or returns part of an order, item by item
+type OrderItemsRequest struct {
+	Items  []OrderItemQuantityInput `json:"items" binding:"required,min=1,dive"`
+	Reason string                   `json:"reason" binding:"max=255"`
+}
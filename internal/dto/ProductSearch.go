@@ -0,0 +1,77 @@
+package dto
+
+import "github.com/minh6824pro/nxrGO/internal/models/CacheModel"
+
+// ProductSearchQuery is the /products/query request, filters of the same facet are OR-ed and different facets AND-ed
+type ProductSearchQuery struct {
+	Name               string
+	PriceMin, PriceMax *float64
+	PriceAsc           *bool
+	TotalBuyDesc       *bool
+	Page, PageSize     int
+	Lat, Lon           *float64
+
+	Brands     []string
+	Categories []string
+	Merchants  []string
+	// MinRating keeps products whose average rating is at least the value
+	MinRating *float64
+	InStock   bool
+	// Options maps an option name to the accepted values, e.g. Color -> [Red, Blue]
+	Options map[string][]string
+	// PriceInterval is the width of the price histogram buckets
+	PriceInterval float64
+}
+
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type PriceBucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int64   `json:"count"`
+}
+
+// ProductFacets counts the products of each facet value, a facet is counted with every filter applied except its own
+type ProductFacets struct {
+	Brands     []FacetBucket            `json:"brands"`
+	Categories []FacetBucket            `json:"categories"`
+	Merchants  []FacetBucket            `json:"merchants"`
+	Options    map[string][]FacetBucket `json:"options"`
+	// Ratings counts products rated at least 1 to 4 stars
+	Ratings []FacetBucket `json:"ratings"`
+	InStock int64         `json:"in_stock"`
+	Prices  []PriceBucket `json:"prices"`
+}
+
+type AutocompleteProduct struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type AutocompleteResponse struct {
+	Products   []AutocompleteProduct `json:"products"`
+	Brands     []string              `json:"brands"`
+	Categories []string              `json:"categories"`
+}
+
+// ProductSearchItem is a product of the search results, VariantId and Price are of the variant matching the filters best
+type ProductSearchItem struct {
+	CacheModel.ProductMiniCache
+	// Score is the relevance to the keyword
+	Score      *float64 `json:"score,omitempty"`
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+	// Highlights maps name or description to fragments with the matched words in <mark>
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// ProductSearchResponse is the /products/query response, Total is the number of pages
+type ProductSearchResponse struct {
+	Data   []*ProductSearchItem `json:"data"`
+	Total  int                  `json:"total"`
+	Facets *ProductFacets       `json:"facets"`
+	// DidYouMean is a corrected keyword when the search has no hit
+	DidYouMean string `json:"did_you_mean,omitempty"`
+}
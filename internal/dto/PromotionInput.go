@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"time"
+)
+
+type CreatePromotionInput struct {
+	Code          string               `json:"code" binding:"required,max=50"`
+	Name          string               `json:"name" binding:"required,max=255"`
+	Description   string               `json:"description" binding:"max=255"`
+	Type          models.PromotionType `json:"type" binding:"required,oneof=PERCENTAGE FIXED FREE_SHIPPING"`
+	Value         float64              `json:"value" binding:"gte=0"`
+	MaxDiscount   float64              `json:"max_discount" binding:"gte=0"`
+	MinOrderValue float64              `json:"min_order_value" binding:"gte=0"`
+	MerchantID    *uint                `json:"merchant_id"`
+	CategoryID    *uint                `json:"category_id"`
+	BrandID       *uint                `json:"brand_id"`
+	UsageLimit    uint                 `json:"usage_limit"`
+	PerUserLimit  uint                 `json:"per_user_limit"`
+	StartsAt      *time.Time           `json:"starts_at"`
+	EndsAt        *time.Time           `json:"ends_at"`
+	Active        *bool                `json:"active"`
+}
+
+type UpdatePromotionInput struct {
+	Name          *string    `json:"name" binding:"omitempty,max=255"`
+	Description   *string    `json:"description" binding:"omitempty,max=255"`
+	Value         *float64   `json:"value" binding:"omitempty,gte=0"`
+	MaxDiscount   *float64   `json:"max_discount" binding:"omitempty,gte=0"`
+	MinOrderValue *float64   `json:"min_order_value" binding:"omitempty,gte=0"`
+	UsageLimit    *uint      `json:"usage_limit"`
+	PerUserLimit  *uint      `json:"per_user_limit"`
+	StartsAt      *time.Time `json:"starts_at"`
+	EndsAt        *time.Time `json:"ends_at"`
+	Active        *bool      `json:"active"`
+}
+
+type MerchantShippingFee struct {
+	MerchantID uint    `json:"merchant_id" binding:"required"`
+	Fee        float64 `json:"fee" binding:"gte=0"`
+}
+
+// QuotePromotionInput previews a coupon against items at their current price
+type QuotePromotionInput struct {
+	Code         string                `json:"code" binding:"required"`
+	Items        []CartItemInput       `json:"items" binding:"required,dive"`
+	ShippingFees []MerchantShippingFee `json:"shipping_fees" binding:"dive"`
+}
+
+// PromotionLine is an order line the promotion is evaluated against
+type PromotionLine struct {
+	ProductVariantID uint
+	MerchantID       uint
+	Price            float64
+	Quantity         uint
+}
@@ -0,0 +1,54 @@
+package dto
+
+import "github.com/minh6824pro/nxrGO/internal/models"
+
+// PromotionQuote is the discount of a coupon, Items follows the order of the evaluated lines
+type PromotionQuote struct {
+	PromotionID      uint                        `json:"promotion_id"`
+	Code             string                      `json:"code"`
+	Type             models.PromotionType        `json:"type"`
+	UserID           uint                        `json:"-"`
+	Discount         float64                     `json:"discount"`
+	ShippingDiscount float64                     `json:"shipping_discount"`
+	Items            []PromotionItemDiscount     `json:"items"`
+	Merchants        []PromotionMerchantDiscount `json:"merchants"`
+}
+
+type PromotionItemDiscount struct {
+	ProductVariantID uint    `json:"product_variant_id"`
+	Discount         float64 `json:"discount"`
+}
+
+type PromotionMerchantDiscount struct {
+	MerchantID       uint    `json:"merchant_id"`
+	Discount         float64 `json:"discount"`
+	ShippingDiscount float64 `json:"shipping_discount"`
+}
+
+// TotalDiscount is the item and shipping discount, zero without a coupon
+func (q *PromotionQuote) TotalDiscount() float64 {
+	if q == nil {
+		return 0
+	}
+	return q.Discount + q.ShippingDiscount
+}
+
+// ItemDiscount is the discount of the i-th evaluated line
+func (q *PromotionQuote) ItemDiscount(i int) float64 {
+	if q == nil || i >= len(q.Items) {
+		return 0
+	}
+	return q.Items[i].Discount
+}
+
+// ShippingDiscounts maps merchant to the shipping discount of its sub order
+func (q *PromotionQuote) ShippingDiscounts() map[uint]float64 {
+	result := make(map[uint]float64)
+	if q == nil {
+		return result
+	}
+	for _, m := range q.Merchants {
+		result[m.MerchantID] = m.ShippingDiscount
+	}
+	return result
+}
@@ -0,0 +1,5 @@
+package dto
+
+type ReconcileStockInput struct {
+	ProductVariantIDs []uint `json:"product_variant_ids" binding:"required,min=1"`
+}
@@ -5,4 +5,5 @@ type RegisterRequest struct {
 	Email       string `json:"email" binding:"required,email"`
 	Password    string `json:"password" binding:"required,min=6"`
 	PhoneNumber string `json:"phone_number"`
+	ClientInfo
 }
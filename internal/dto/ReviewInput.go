@@ -0,0 +1,30 @@
+package dto
+
+import "github.com/minh6824pro/nxrGO/internal/models"
+
+type CreateReviewInput struct {
+	Rating  uint8    `json:"rating" binding:"required,min=1,max=5"`
+	Content string   `json:"content" binding:"max=2000"`
+	Images  []string `json:"images" binding:"max=5,dive,url,max=255"`
+}
+
+type UpdateReviewInput struct {
+	Rating  *uint8    `json:"rating" binding:"omitempty,min=1,max=5"`
+	Content *string   `json:"content" binding:"omitempty,max=2000"`
+	Images  *[]string `json:"images" binding:"omitempty,max=5,dive,url,max=255"`
+}
+
+type ReplyReviewInput struct {
+	Reply string `json:"reply" binding:"required,max=2000"`
+}
+
+type ModerateReviewInput struct {
+	Status models.ReviewStatus `json:"status" binding:"required,oneof=VISIBLE HIDDEN"`
+	Note   string              `json:"note" binding:"max=255"`
+}
+
+type ReviewFilter struct {
+	ProductID *uint
+	Status    models.ReviewStatus
+	Rating    *uint8
+}
@@ -0,0 +1,27 @@
+package dto
+
+import "time"
+
+// SearchSyncStatus reports how far the products index is behind the catalog
+type SearchSyncStatus struct {
+	// Index is the physical index behind the read alias
+	Index         string `json:"index"`
+	PendingEvents int64  `json:"pending_events"`
+	DeadEvents    int64  `json:"dead_events"`
+	// LagSeconds is the age of the oldest change not indexed yet, 0 when the index is up to date
+	LagSeconds      float64    `json:"lag_seconds"`
+	OldestPendingAt *time.Time `json:"oldest_pending_at,omitempty"`
+	// LastEventLagSeconds is the time between the last indexed change and its indexing
+	LastEventLagSeconds float64    `json:"last_event_lag_seconds"`
+	LastIndexedAt       *time.Time `json:"last_indexed_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// ReindexResult describes an alias swap done by a reindex or a rollback
+type ReindexResult struct {
+	PreviousIndex string `json:"previous_index"`
+	Index         string `json:"index"`
+	Documents     int64  `json:"documents"`
+	// ReplayedEvents counts the catalog changes made while the new index was built (or after the previous one was swapped out)
+	ReplayedEvents int `json:"replayed_events"`
+}
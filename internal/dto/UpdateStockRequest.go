@@ -3,3 +3,7 @@ package dto
 type UpdateStockRequest struct {
 	Quantity uint `json:"quantity" binding:"required"`
 }
+
+type UpdatePriceRequest struct {
+	Price float64 `json:"price" binding:"required,gt=0"`
+}
@@ -0,0 +1,16 @@
+package dto
+
+import "github.com/minh6824pro/nxrGO/internal/models"
+
+// UserFilter filters the admin user list, empty fields are ignored
+type UserFilter struct {
+	Search string
+	Role   models.Role
+	Active *uint8
+}
+
+type ChangeUserRoleInput struct {
+	Role models.Role `json:"role" binding:"required,oneof=ADMIN USER MERCHANT"`
+	// MerchantID is required for the MERCHANT role
+	MerchantID *uint `json:"merchant_id"`
+}
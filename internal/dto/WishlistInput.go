@@ -0,0 +1,7 @@
+package dto
+
+type WishlistItemInput struct {
+	ProductVariantID uint `json:"product_variant_id" binding:"required"`
+	// NotifyBackInStock defaults to true
+	NotifyBackInStock *bool `json:"notify_back_in_stock"`
+}
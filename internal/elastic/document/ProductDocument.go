@@ -1,5 +1,7 @@
 package document
 
+import "strings"
+
 type ProductDocument struct {
 	ID            string    `json:"id"`
 	Name          string    `json:"name"`
@@ -13,4 +15,26 @@ type ProductDocument struct {
 	Brand         string    `json:"brand"`
 	Category      string    `json:"category"`
 	Price         []float64 `json:"prices"`
+	InStock       bool      `json:"in_stock"`
+	// Options chứa các giá trị option của mọi variant dạng "Color=Red"
+	Options     []string `json:"options"`
+	Description string   `json:"description"`
+	// Variants chỉ lưu để chọn variant khớp nhất, không được index
+	Variants []VariantDocument `json:"variants"`
+}
+
+type VariantDocument struct {
+	ID      uint     `json:"id"`
+	Price   float64  `json:"price"`
+	InStock bool     `json:"in_stock"`
+	Options []string `json:"options"`
+}
+
+// OptionKey ghép tên option và giá trị thành "Name=Value"
+func OptionKey(name, value string) string {
+	return name + "=" + value
+}
+
+func ParseOptionKey(key string) (name, value string, ok bool) {
+	return strings.Cut(key, "=")
 }
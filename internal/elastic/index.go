@@ -1,18 +1,31 @@
 package elastic
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/minh6824pro/nxrGO/internal/config"
 	"log"
+	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type ElasticClient struct {
 	ES *elasticsearch.Client
 }
 
+// ProductIndex is one physical version of the products index
+type ProductIndex struct {
+	Name      string
+	Version   int
+	CreatedAt time.Time
+}
+
 func NewElasticClient() *ElasticClient {
 	ES, err := config.GetElasticClient()
 	if err != nil {
@@ -24,11 +37,23 @@ func ProductIndexMapping() string {
 	return `{
   "settings": {
     "analysis": {
+      "filter": {
+        "autocomplete_filter": {
+          "type": "edge_ngram",
+          "min_gram": 1,
+          "max_gram": 20
+        }
+      },
       "analyzer": {
         "name_analyzer": {
           "type": "custom",
           "tokenizer": "standard",
           "filter": ["lowercase", "asciifolding"]
+        },
+        "autocomplete_analyzer": {
+          "type": "custom",
+          "tokenizer": "standard",
+          "filter": ["lowercase", "asciifolding", "autocomplete_filter"]
         }
       }
     }
@@ -39,7 +64,10 @@ func ProductIndexMapping() string {
       "name": {
         "type": "text",
         "analyzer": "name_analyzer",
-        "search_analyzer": "name_analyzer"
+        "search_analyzer": "name_analyzer",
+        "fields": {
+          "autocomplete": { "type": "text", "analyzer": "autocomplete_analyzer", "search_analyzer": "name_analyzer" }
+        }
       },
       "average_rating":{ "type": "double" },
       "number_rating": { "type": "float" },
@@ -48,33 +76,109 @@ func ProductIndexMapping() string {
       "location":      { "type": "text" },
       "merchant":      { "type": "keyword" },
 		"geo_point":      { "type": "geo_point" },
-      "brand":         { "type": "keyword" },
-      "category":      { "type": "keyword" },
-      "prices":        { "type": "double" }
+      "brand": {
+        "type": "keyword",
+        "fields": {
+          "autocomplete": { "type": "text", "analyzer": "autocomplete_analyzer", "search_analyzer": "name_analyzer" }
+        }
+      },
+      "category": {
+        "type": "keyword",
+        "fields": {
+          "autocomplete": { "type": "text", "analyzer": "autocomplete_analyzer", "search_analyzer": "name_analyzer" }
+        }
+      },
+      "prices":        { "type": "double" },
+      "in_stock":      { "type": "boolean" },
+      "options":       { "type": "keyword" },
+      "description": {
+        "type": "text",
+        "analyzer": "name_analyzer",
+        "search_analyzer": "name_analyzer"
+      },
+      "variants":      { "type": "object", "enabled": false }
     }
   }
 }`
 }
 
-// EnsureProductIndex kiểm tra index, nếu chưa có thì tạo
+const (
+	// ProductReadAlias is searched, ProductWriteAlias receives the incremental updates,
+	// both point to one physical index products_v<N>
+	ProductReadAlias  = "products"
+	ProductWriteAlias = "products_write"
+
+	productIndexPrefix = "products_v"
+)
+
+// ProductIndexName trả về tên index vật lý của version
+func ProductIndexName(version int) string {
+	return fmt.Sprintf("%s%d", productIndexPrefix, version)
+}
+
+// ProductIndexVersion parses the version of a physical index name, ok is false for other names
+func ProductIndexVersion(name string) (version int, ok bool) {
+	if !strings.HasPrefix(name, productIndexPrefix) {
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(name, productIndexPrefix))
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// EnsureProductIndex kiểm tra alias, nếu chưa có thì tạo products_v1 và trỏ 2 alias vào.
+// Index "products" cũ (không phải alias) được copy sang products_v1 rồi thay bằng alias trong cùng 1 request
 func (c *ElasticClient) EnsureProductIndex(ctx context.Context) error {
-	// Check xem index đã tồn tại chưa
-	exists, err := c.ES.Indices.Exists([]string{"products"}, c.ES.Indices.Exists.WithContext(ctx))
+	// Check xem alias đã tồn tại chưa
+	current, err := c.AliasedIndex(ctx, ProductReadAlias)
 	if err != nil {
-		return fmt.Errorf("cannot check index existence: %w", err)
+		return err
 	}
-	defer exists.Body.Close()
-
-	if exists.StatusCode == 200 {
-		log.Println("Index 'products' already exists, skip creating.")
+	if current != "" {
+		log.Printf("Alias '%s' already points to '%s', skip creating.", ProductReadAlias, current)
+		// Field mới được thêm vào index đang dùng, thay đổi field cũ thì phải reindex
+		if err := c.putProductMapping(ctx, current); err != nil {
+			log.Printf("Cannot apply mapping to '%s', run cmd/reindex to build a new version: %v", current, err)
+		}
 		return nil
 	}
 
+	legacy, err := c.indexExists(ctx, ProductReadAlias)
+	if err != nil {
+		return err
+	}
+
 	// Nếu chưa tồn tại thì tạo mới
-	mapping := ProductIndexMapping()
+	target := ProductIndexName(1)
+	if err := c.CreateProductIndex(ctx, target); err != nil {
+		return err
+	}
+
+	actions := []map[string]interface{}{
+		{"add": map[string]interface{}{"index": target, "alias": ProductWriteAlias}},
+	}
+	if legacy {
+		if err := c.copyIndex(ctx, ProductReadAlias, target); err != nil {
+			return err
+		}
+		actions = append(actions, map[string]interface{}{"remove_index": map[string]interface{}{"index": ProductReadAlias}})
+	}
+	actions = append(actions, map[string]interface{}{"add": map[string]interface{}{"index": target, "alias": ProductReadAlias}})
+	if err := c.updateAliases(ctx, actions); err != nil {
+		return err
+	}
+
+	log.Printf("Index '%s' created behind aliases '%s' and '%s'", target, ProductReadAlias, ProductWriteAlias)
+	return nil
+}
+
+// CreateProductIndex tạo index vật lý với mapping hiện tại
+func (c *ElasticClient) CreateProductIndex(ctx context.Context, name string) error {
 	res, err := c.ES.Indices.Create(
-		"products",
-		c.ES.Indices.Create.WithBody(strings.NewReader(mapping)),
+		name,
+		c.ES.Indices.Create.WithBody(strings.NewReader(ProductIndexMapping())),
 		c.ES.Indices.Create.WithContext(ctx),
 	)
 	if err != nil {
@@ -83,9 +187,205 @@ func (c *ElasticClient) EnsureProductIndex(ctx context.Context) error {
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("error creating index: %s", res.String())
+		return fmt.Errorf("error creating index %s: %s", name, res.String())
+	}
+	return nil
+}
+
+// AliasedIndex returns the physical index behind the alias, empty if the alias does not exist
+func (c *ElasticClient) AliasedIndex(ctx context.Context, alias string) (string, error) {
+	res, err := c.ES.Indices.GetAlias(
+		c.ES.Indices.GetAlias.WithName(alias),
+		c.ES.Indices.GetAlias.WithContext(ctx),
+	)
+	if err != nil {
+		return "", fmt.Errorf("cannot get alias %s: %w", alias, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if res.IsError() {
+		return "", fmt.Errorf("error getting alias %s: %s", alias, res.String())
+	}
+	var indices map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return "", err
+	}
+	if len(indices) != 1 {
+		return "", fmt.Errorf("alias %s points to %d indices", alias, len(indices))
+	}
+	for name := range indices {
+		return name, nil
+	}
+	return "", nil
+}
+
+// ProductIndices lists the physical product indices with their creation time, oldest version first
+func (c *ElasticClient) ProductIndices(ctx context.Context) ([]ProductIndex, error) {
+	res, err := c.ES.Indices.GetSettings(
+		c.ES.Indices.GetSettings.WithIndex(productIndexPrefix+"*"),
+		c.ES.Indices.GetSettings.WithName("index.creation_date"),
+		c.ES.Indices.GetSettings.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list product indices: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error listing product indices: %s", res.String())
+	}
+	var settings map[string]struct {
+		Settings struct {
+			Index struct {
+				CreationDate string `json:"creation_date"`
+			} `json:"index"`
+		} `json:"settings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&settings); err != nil {
+		return nil, err
+	}
+
+	indices := make([]ProductIndex, 0, len(settings))
+	for name, s := range settings {
+		version, ok := ProductIndexVersion(name)
+		if !ok {
+			continue
+		}
+		millis, _ := strconv.ParseInt(s.Settings.Index.CreationDate, 10, 64)
+		indices = append(indices, ProductIndex{Name: name, Version: version, CreatedAt: time.UnixMilli(millis)})
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i].Version < indices[j].Version })
+	return indices, nil
+}
+
+// SwapProductAliases moves the read and write aliases to the index in one atomic request
+func (c *ElasticClient) SwapProductAliases(ctx context.Context, from, to string) error {
+	return c.updateAliases(ctx, []map[string]interface{}{
+		{"remove": map[string]interface{}{"index": from, "alias": ProductReadAlias}},
+		{"remove": map[string]interface{}{"index": from, "alias": ProductWriteAlias}},
+		{"add": map[string]interface{}{"index": to, "alias": ProductReadAlias}},
+		{"add": map[string]interface{}{"index": to, "alias": ProductWriteAlias}},
+	})
+}
+
+// CountDocuments refreshes the index then counts its documents
+func (c *ElasticClient) CountDocuments(ctx context.Context, name string) (int64, error) {
+	refresh, err := c.ES.Indices.Refresh(
+		c.ES.Indices.Refresh.WithIndex(name),
+		c.ES.Indices.Refresh.WithContext(ctx),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("cannot refresh index %s: %w", name, err)
+	}
+	refresh.Body.Close()
+	if refresh.IsError() {
+		return 0, fmt.Errorf("error refreshing index %s: %s", name, refresh.String())
+	}
+
+	res, err := c.ES.Count(
+		c.ES.Count.WithIndex(name),
+		c.ES.Count.WithContext(ctx),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("cannot count documents of %s: %w", name, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, fmt.Errorf("error counting documents of %s: %s", name, res.String())
+	}
+	var count struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&count); err != nil {
+		return 0, err
+	}
+	return count.Count, nil
+}
+
+// DeleteIndex xóa index vật lý
+func (c *ElasticClient) DeleteIndex(ctx context.Context, name string) error {
+	res, err := c.ES.Indices.Delete([]string{name}, c.ES.Indices.Delete.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("cannot delete index %s: %w", name, err)
 	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error deleting index %s: %s", name, res.String())
+	}
+	return nil
+}
 
-	log.Println("Index 'products' created successfully")
+func (c *ElasticClient) putProductMapping(ctx context.Context, name string) error {
+	var mapping struct {
+		Mappings json.RawMessage `json:"mappings"`
+	}
+	if err := json.Unmarshal([]byte(ProductIndexMapping()), &mapping); err != nil {
+		return err
+	}
+	res, err := c.ES.Indices.PutMapping(
+		[]string{name},
+		bytes.NewReader(mapping.Mappings),
+		c.ES.Indices.PutMapping.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("error updating mapping: %s", res.String())
+	}
+	return nil
+}
+
+func (c *ElasticClient) indexExists(ctx context.Context, name string) (bool, error) {
+	res, err := c.ES.Indices.Exists([]string{name}, c.ES.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("cannot check index existence: %w", err)
+	}
+	defer res.Body.Close()
+	return res.StatusCode == http.StatusOK, nil
+}
+
+func (c *ElasticClient) copyIndex(ctx context.Context, from, to string) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"source": map[string]string{"index": from},
+		"dest":   map[string]string{"index": to},
+	})
+	res, err := c.ES.Reindex(
+		bytes.NewReader(body),
+		c.ES.Reindex.WithWaitForCompletion(true),
+		c.ES.Reindex.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot copy index %s to %s: %w", from, to, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("error copying index %s to %s: %s", from, to, res.String())
+	}
+	return nil
+}
+
+func (c *ElasticClient) updateAliases(ctx context.Context, actions []map[string]interface{}) error {
+	body, _ := json.Marshal(map[string]interface{}{"actions": actions})
+	res, err := c.ES.Indices.UpdateAliases(
+		bytes.NewReader(body),
+		c.ES.Indices.UpdateAliases.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot update aliases: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("error updating aliases: %s", res.String())
+	}
 	return nil
 }
@@ -2,6 +2,7 @@ package elastic
 
 import (
 	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
 	"github.com/minh6824pro/nxrGO/internal/elastic/document"
 )
 
@@ -9,11 +10,40 @@ type ProductElasticRepository interface {
 	Insert(ctx context.Context, p document.ProductDocument)
 	BulkInsert(ctx context.Context, products []document.ProductDocument)
 	DBToElastic(ctx context.Context)
+	UpdateRating(ctx context.Context, productID uint, averageRating float64, numberRating float32) error
+	// SyncProducts writes the current DB state of the products, products no longer in DB are deleted from the index
+	SyncProducts(ctx context.Context, productIDs []uint) error
+	// UpdateProductFields applies the same partial update to the documents, missing documents are skipped
+	UpdateProductFields(ctx context.Context, productIDs []uint, fields map[string]interface{}) error
+	// IndexAllProducts loads the whole catalog into a physical index, used to build a new index version
+	IndexAllProducts(ctx context.Context, indexName string) (int64, error)
+	// GetProductList searches the products and counts the facets of the results
 	GetProductList(
 		ctx context.Context,
-		name string,
-		priceMin, priceMax *float64,
-		priceAsc, totalBuyDesc *bool,
-		page, pageSize int, lat, lon *float64,
-	) (products []document.ProductDocument, totalPages, currentPage int, err error)
+		q dto.ProductSearchQuery,
+	) (*ProductSearchResult, error)
+	// Autocomplete gợi ý sản phẩm, brand và category theo tiền tố đang gõ, không phân biệt dấu
+	Autocomplete(ctx context.Context, prefix string, size int) (*dto.AutocompleteResponse, error)
+}
+
+type ProductSearchResult struct {
+	Hits        []ProductHit
+	Facets      *dto.ProductFacets
+	TotalPages  int
+	CurrentPage int
+	// Suggestion is the corrected keyword when the search has no hit
+	Suggestion string
+}
+
+// ProductHit is a found document with its relevance metadata
+type ProductHit struct {
+	Document document.ProductDocument
+	// Score is set when the search has a keyword
+	Score *float64
+	// DistanceKm is set when the search sorts by distance
+	DistanceKm *float64
+	// Highlights maps a field to its fragments with the matched words in <mark>
+	Highlights map[string][]string
+	// Variant best matches the price and option filters, nil for a product without variant
+	Variant *document.VariantDocument
 }
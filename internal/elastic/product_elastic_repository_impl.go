@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"github.com/minh6824pro/nxrGO/internal/config"
 	"github.com/minh6824pro/nxrGO/internal/database"
+	"github.com/minh6824pro/nxrGO/internal/dto"
 	"github.com/minh6824pro/nxrGO/internal/elastic/document"
 	"github.com/minh6824pro/nxrGO/internal/models"
 	"gorm.io/gorm"
 	"log"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -22,8 +24,6 @@ type ProductElasticRepo struct {
 	db *gorm.DB
 }
 
-var index = "products"
-
 func NewProductElasticRepo() ProductElasticRepository {
 	ES, err := config.GetElasticClient()
 	if err != nil {
@@ -37,7 +37,7 @@ func NewProductElasticRepo() ProductElasticRepository {
 func (r *ProductElasticRepo) Insert(ctx context.Context, p document.ProductDocument) {
 	body, _ := json.Marshal(p)
 	res, err := r.es.Index(
-		"products",
+		ProductWriteAlias,
 		strings.NewReader(string(body)),
 		r.es.Index.WithDocumentID(p.ID),
 		r.es.Index.WithContext(ctx),
@@ -61,7 +61,7 @@ func (r *ProductElasticRepo) BulkInsert(ctx context.Context, products []document
 	for _, p := range products {
 		// Action line cho Bulk API
 		meta := map[string]map[string]string{
-			"index": {"_index": "products", "_id": p.ID},
+			"index": {"_index": ProductWriteAlias, "_id": p.ID},
 		}
 		metaLine, _ := json.Marshal(meta)
 		b.Write(metaLine)
@@ -97,7 +97,7 @@ func (r *ProductElasticRepo) DBToElastic(ctx context.Context) {
 		Preload("Brand").
 		Preload("Category").
 		Preload("Variants").
-		Preload("Variants.OptionValues").
+		Preload("Variants.OptionValues.Option").
 		Find(&product).Error
 	if err != nil {
 		log.Fatal(err)
@@ -105,13 +105,59 @@ func (r *ProductElasticRepo) DBToElastic(ctx context.Context) {
 	r.BulkInsert(ctx, MapProductToProductDocument(product))
 }
 
+// UpdateRating chỉ cập nhật phần rating của document
+func (r *ProductElasticRepo) UpdateRating(ctx context.Context, productID uint, averageRating float64, numberRating float32) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"doc": map[string]interface{}{
+			"average_rating": averageRating,
+			"number_rating":  numberRating,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := r.es.Update(
+		ProductWriteAlias,
+		strconv.Itoa(int(productID)),
+		bytes.NewReader(body),
+		r.es.Update.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("update rating of product %d failed: %s", productID, res.String())
+	}
+	return nil
+}
+
 func MapProductToProductDocument(products []models.Product) []document.ProductDocument {
 	var docs []document.ProductDocument
 	for _, product := range products {
 		var priceArray []float64
+		var options []string
+		var variants []document.VariantDocument
+		inStock := false
+		seen := map[string]bool{}
 		for _, variant := range product.Variants {
 			price := variant.Price
 			priceArray = append(priceArray, price)
+			if variant.Quantity > 0 {
+				inStock = true
+			}
+			vd := document.VariantDocument{ID: variant.ID, Price: variant.Price, InStock: variant.Quantity > 0}
+			for _, ov := range variant.OptionValues {
+				key := document.OptionKey(ov.Option.Name, ov.Value)
+				vd.Options = append(vd.Options, key)
+				if !seen[key] {
+					seen[key] = true
+					options = append(options, key)
+				}
+			}
+			variants = append(variants, vd)
 		}
 		var doc = document.ProductDocument{
 			ID:            strconv.Itoa(int(product.ID)),
@@ -126,6 +172,10 @@ func MapProductToProductDocument(products []models.Product) []document.ProductDo
 			Brand:         product.Brand.Name,
 			Category:      product.Category.Name,
 			Price:         priceArray,
+			InStock:       inStock,
+			Options:       options,
+			Description:   product.Description,
+			Variants:      variants,
 		}
 
 		docs = append(docs, doc)
@@ -135,11 +185,10 @@ func MapProductToProductDocument(products []models.Product) []document.ProductDo
 
 func (r *ProductElasticRepo) GetProductList(
 	ctx context.Context,
-	name string,
-	priceMin, priceMax *float64,
-	priceAsc, totalBuyDesc *bool,
-	page, pageSize int, lat, lon *float64,
-) (products []document.ProductDocument, totalPages, currentPage int, err error) {
+	q dto.ProductSearchQuery,
+) (*ProductSearchResult, error) {
+	name, lat, lon := q.Name, q.Lat, q.Lon
+	page, pageSize := q.Page, q.PageSize
 
 	// --- Build Query DSL ---
 	boolQuery := map[string]interface{}{"must": []interface{}{}}
@@ -151,9 +200,10 @@ func (r *ProductElasticRepo) GetProductList(
 			"multi_match": map[string]interface{}{
 				"query": name,
 				"fields": []string{
-					"name^3",     // boost name field (quan trọng hơn)
-					"brand^1",    // search cả trong description
-					"category^2", // search trong category
+					"name^3",        // boost name field (quan trọng hơn)
+					"brand^1",       // search cả trong brand
+					"category^2",    // search trong category
+					"description^1", // search cả trong description
 				},
 				"type":                 "best_fields",
 				"fuzziness":            "AUTO", // cho phép typo
@@ -164,27 +214,27 @@ func (r *ProductElasticRepo) GetProductList(
 		boolQuery["must"] = append(boolQuery["must"].([]interface{}), nameQuery)
 	}
 
-	// --- Price Range ---
-	if priceMin != nil || priceMax != nil {
-		priceRange := map[string]interface{}{"range": map[string]interface{}{"prices": map[string]interface{}{}}}
-		if priceMin != nil {
-			priceRange["range"].(map[string]interface{})["prices"].(map[string]interface{})["gte"] = *priceMin
-		}
-		if priceMax != nil {
-			priceRange["range"].(map[string]interface{})["prices"].(map[string]interface{})["lte"] = *priceMax
-		}
-		boolQuery["must"] = append(boolQuery["must"].([]interface{}), priceRange)
-	}
+	// --- Facet filters ---
+	// Lọc bằng post_filter để aggregation của mỗi facet bỏ qua filter của chính nó (multi-select)
+	filters := productFacetFilters(q)
 
 	query := map[string]interface{}{
-		"from":  page * pageSize,
-		"size":  pageSize,
-		"query": map[string]interface{}{"bool": boolQuery},
+		"from":         page * pageSize,
+		"size":         pageSize,
+		"query":        map[string]interface{}{"bool": boolQuery},
+		"post_filter":  filters.except(""),
+		"aggregations": productFacetAggregations(q, filters),
+	}
+	// Sort theo field khác thì ES bỏ qua score, cần track_scores để trả về relevance
+	if name != "" {
+		query["track_scores"] = true
 	}
 
 	// --- Highlight để show matched text ---
 	if name != "" {
 		query["highlight"] = map[string]interface{}{
+			// Escape HTML trong nội dung sản phẩm, chỉ thẻ <mark> được giữ nguyên
+			"encoder": "html",
 			"fields": map[string]interface{}{
 				"name": map[string]interface{}{
 					"pre_tags":  []string{"<mark>"},
@@ -206,18 +256,20 @@ func (r *ProductElasticRepo) GetProductList(
 		sorts = append(sorts, map[string]interface{}{"_score": map[string]interface{}{"order": "desc"}})
 	}
 
-	if priceAsc != nil {
+	if q.PriceAsc != nil {
 		order := "desc"
-		if *priceAsc {
+		if *q.PriceAsc {
 			order = "asc"
 		}
 		sorts = append(sorts, map[string]interface{}{"prices": map[string]interface{}{"order": order}})
-	} else if totalBuyDesc != nil && *totalBuyDesc {
+	} else if q.TotalBuyDesc != nil && *q.TotalBuyDesc {
 		sorts = append(sorts, map[string]interface{}{"total_buy": map[string]interface{}{"order": "desc"}})
 	}
 
-	// Nếu có lat,long thì sort theo khoảng cách
+	// Nếu có lat,long thì sort theo khoảng cách, giá trị sort của hit là khoảng cách (km)
+	distanceSort := -1
 	if lat != nil && lon != nil {
+		distanceSort = len(sorts)
 		sorts = append(sorts, map[string]interface{}{
 			"_geo_distance": map[string]interface{}{
 				"geo_point": map[string]interface{}{
@@ -239,53 +291,59 @@ func (r *ProductElasticRepo) GetProductList(
 	// --- Encode query ---
 	body, err := json.Marshal(query)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, err
 	}
 
 	// --- Execute Search ---
 	res, err := r.es.Search(
 		r.es.Search.WithContext(ctx),
-		r.es.Search.WithIndex("products"), // dùng index thật
+		r.es.Search.WithIndex(ProductReadAlias), // alias trỏ tới index đang dùng
 		r.es.Search.WithBody(bytes.NewReader(body)),
 	)
 	if err != nil {
-		return nil, 0, 0, err
+		return nil, err
 	}
 	defer res.Body.Close()
 
 	// --- Parse response ---
 	var resp map[string]interface{}
 	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
-		return nil, 0, 0, err
+		return nil, err
 	}
 
 	// --- Total hits và page info ---
 	hitsRaw, ok := resp["hits"].(map[string]interface{})
 	if !ok {
-		return nil, 0, 0, fmt.Errorf("resp[\"hits\"] không phải map: %+v", resp)
+		return nil, fmt.Errorf("resp[\"hits\"] không phải map: %+v", resp)
 	}
 
 	totalRaw, ok := hitsRaw["total"].(map[string]interface{})
 	if !ok {
-		return nil, 0, 0, fmt.Errorf("hits[\"total\"] không phải map: %+v", hitsRaw)
+		return nil, fmt.Errorf("hits[\"total\"] không phải map: %+v", hitsRaw)
 	}
 
 	value, ok := totalRaw["value"].(float64)
 	if !ok {
-		return nil, 0, 0, fmt.Errorf("total[\"value\"] không phải float64: %+v", totalRaw)
+		return nil, fmt.Errorf("total[\"value\"] không phải float64: %+v", totalRaw)
 	}
 
 	totalHits := int(value)
-	totalPages = (totalHits + pageSize - 1) / pageSize
-	currentPage = page
+	totalPages := (totalHits + pageSize - 1) / pageSize
+	currentPage := page
+
+	// --- Parse facets ---
+	facets, err := parseProductFacets(resp["aggregations"], priceInterval(q))
+	if err != nil {
+		return nil, err
+	}
 
 	// --- Parse hits ---
 	rawHits, ok := hitsRaw["hits"].([]interface{})
 	if !ok {
-		return nil, totalPages, currentPage, fmt.Errorf("hits[\"hits\"] không phải array: %+v", hitsRaw)
+		return nil, fmt.Errorf("hits[\"hits\"] không phải array: %+v", hitsRaw)
 	}
 
-	products = make([]document.ProductDocument, 0, len(rawHits))
+	hits := make([]ProductHit, 0, len(rawHits))
 	for _, h := range rawHits {
 		hMap, ok := h.(map[string]interface{})
 		if !ok {
@@ -303,15 +361,545 @@ func (r *ProductElasticRepo) GetProductList(
 			continue // skip nếu unmarshal lỗi
 		}
 
+		hit := ProductHit{Document: p, Variant: bestVariant(p, q)}
+		if score, ok := hMap["_score"].(float64); ok && name != "" {
+			hit.Score = &score
+		}
+		if sortValues, ok := hMap["sort"].([]interface{}); ok && distanceSort >= 0 && distanceSort < len(sortValues) {
+			if distance, ok := sortValues[distanceSort].(float64); ok {
+				hit.DistanceKm = &distance
+			}
+		}
+
 		// Thêm highlight info nếu có
-		if highlight, exists := hMap["highlight"]; exists {
-			// Bạn có thể thêm highlight vào struct ProductDocument
-			// hoặc xử lý highlight ở đây
-			fmt.Printf("Highlight for product %s: %+v\n", p.Name, highlight)
+		if highlight, exists := hMap["highlight"].(map[string]interface{}); exists {
+			hit.Highlights = make(map[string][]string, len(highlight))
+			for field, fragments := range highlight {
+				list, _ := fragments.([]interface{})
+				for _, fragment := range list {
+					if text, ok := fragment.(string); ok {
+						hit.Highlights[field] = append(hit.Highlights[field], text)
+					}
+				}
+			}
+		}
+
+		hits = append(hits, hit)
+	}
+
+	result := &ProductSearchResult{
+		Hits:        hits,
+		Facets:      facets,
+		TotalPages:  totalPages,
+		CurrentPage: currentPage,
+	}
+
+	// Không có kết quả thì gợi ý từ khóa gần đúng
+	if totalHits == 0 && name != "" {
+		suggestion, err := r.suggestName(ctx, name)
+		if err != nil {
+			log.Println("Did you mean suggestion failed:", err)
+		}
+		result.Suggestion = suggestion
+	}
+	return result, nil
+}
+
+// bestVariant chọn variant khớp khoảng giá và option đang lọc, ưu tiên còn hàng rồi đến giá theo chiều sort
+func bestVariant(p document.ProductDocument, q dto.ProductSearchQuery) *document.VariantDocument {
+	matches := func(v document.VariantDocument) bool {
+		if (q.PriceMin != nil && v.Price < *q.PriceMin) || (q.PriceMax != nil && v.Price > *q.PriceMax) {
+			return false
+		}
+		for name, values := range q.Options {
+			found := false
+			for _, value := range values {
+				if slices.Contains(v.Options, document.OptionKey(name, value)) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	better := func(a, b document.VariantDocument) bool {
+		if a.InStock != b.InStock {
+			return a.InStock
+		}
+		if q.PriceAsc != nil && !*q.PriceAsc {
+			return a.Price > b.Price
+		}
+		return a.Price < b.Price
+	}
+
+	var best *document.VariantDocument
+	bestMatches := false
+	for i := range p.Variants {
+		v := p.Variants[i]
+		ok := matches(v)
+		if best == nil || (ok && !bestMatches) || (ok == bestMatches && better(v, *best)) {
+			best, bestMatches = &p.Variants[i], ok
+		}
+	}
+	return best
+}
+
+// suggestName sửa lỗi chính tả của từ khóa, chỉ trả về cụm từ có sản phẩm khớp
+func (r *ProductElasticRepo) suggestName(ctx context.Context, text string) (string, error) {
+	query := map[string]interface{}{
+		"size": 0,
+		"suggest": map[string]interface{}{
+			"did_you_mean": map[string]interface{}{
+				"text": text,
+				"phrase": map[string]interface{}{
+					"field":      "name",
+					"size":       1,
+					"max_errors": 2,
+					"direct_generator": []map[string]interface{}{
+						{"field": "name", "suggest_mode": "always", "min_word_length": 2},
+					},
+					"collate": map[string]interface{}{
+						"query": map[string]interface{}{
+							"source": map[string]interface{}{
+								"match": map[string]interface{}{
+									"name": map[string]interface{}{"query": "{{suggestion}}", "operator": "and"},
+								},
+							},
+						},
+						"prune": false,
+					},
+				},
+			},
+		},
+	}
+	var resp struct {
+		Suggest map[string][]struct {
+			Options []struct {
+				Text string `json:"text"`
+			} `json:"options"`
+		} `json:"suggest"`
+	}
+	if err := r.search(ctx, query, &resp); err != nil {
+		return "", err
+	}
+	for _, entry := range resp.Suggest["did_you_mean"] {
+		if len(entry.Options) > 0 {
+			return entry.Options[0].Text, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *ProductElasticRepo) Autocomplete(ctx context.Context, prefix string, size int) (*dto.AutocompleteResponse, error) {
+	match := func(field string) map[string]interface{} {
+		return map[string]interface{}{
+			"match": map[string]interface{}{
+				field + ".autocomplete": map[string]interface{}{"query": prefix, "operator": "and"},
+			},
+		}
+	}
+	suggestTerms := func(field string) map[string]interface{} {
+		return map[string]interface{}{
+			"filter": match(field),
+			"aggregations": map[string]interface{}{
+				"values": map[string]interface{}{"terms": map[string]interface{}{"field": field, "size": size}},
+			},
+		}
+	}
+
+	// Hits chỉ lấy sản phẩm khớp tên, brand và category được gợi ý qua aggregation
+	query := map[string]interface{}{
+		"size":    size,
+		"_source": []string{"id", "name"},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should":               []interface{}{match("name"), match("brand"), match("category")},
+				"minimum_should_match": 1,
+			},
+		},
+		"post_filter": match("name"),
+		"sort": []interface{}{
+			map[string]interface{}{"_score": map[string]interface{}{"order": "desc"}},
+			map[string]interface{}{"total_buy": map[string]interface{}{"order": "desc"}},
+		},
+		"aggregations": map[string]interface{}{
+			"brands":     suggestTerms("brand"),
+			"categories": suggestTerms("category"),
+		},
+	}
+
+	type termsFacet struct {
+		Values struct {
+			Buckets []struct {
+				Key string `json:"key"`
+			} `json:"buckets"`
+		} `json:"values"`
+	}
+	var resp struct {
+		Hits struct {
+			Hits []struct {
+				Source document.ProductDocument `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations struct {
+			Brands     termsFacet `json:"brands"`
+			Categories termsFacet `json:"categories"`
+		} `json:"aggregations"`
+	}
+	if err := r.search(ctx, query, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &dto.AutocompleteResponse{
+		Products:   make([]dto.AutocompleteProduct, 0, len(resp.Hits.Hits)),
+		Brands:     make([]string, 0),
+		Categories: make([]string, 0),
+	}
+	for _, hit := range resp.Hits.Hits {
+		id, _ := strconv.ParseUint(hit.Source.ID, 10, 64)
+		result.Products = append(result.Products, dto.AutocompleteProduct{ID: uint(id), Name: hit.Source.Name})
+	}
+	for _, b := range resp.Aggregations.Brands.Values.Buckets {
+		result.Brands = append(result.Brands, b.Key)
+	}
+	for _, b := range resp.Aggregations.Categories.Values.Buckets {
+		result.Categories = append(result.Categories, b.Key)
+	}
+	return result, nil
+}
+
+// search chạy query trên read alias và decode response
+func (r *ProductElasticRepo) search(ctx context.Context, query map[string]interface{}, out interface{}) error {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	res, err := r.es.Search(
+		r.es.Search.WithContext(ctx),
+		r.es.Search.WithIndex(ProductReadAlias),
+		r.es.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("search failed: %s", res.String())
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+const (
+	defaultPriceInterval = 100000
+	facetSize            = 50
+	optionFacetSize      = 200
+)
+
+// facetFilters holds the filter clause of each selected facet, keyed by facet
+type facetFilters map[string]interface{}
+
+// except combines every filter but the one of the facet
+func (f facetFilters) except(facet string) map[string]interface{} {
+	clauses := []interface{}{}
+	for key, clause := range f {
+		if key != facet {
+			clauses = append(clauses, clause)
+		}
+	}
+	return map[string]interface{}{"bool": map[string]interface{}{"filter": clauses}}
+}
+
+func optionFacetKey(name string) string {
+	return "option:" + name
+}
+
+func productFacetFilters(q dto.ProductSearchQuery) facetFilters {
+	filters := facetFilters{}
+	if len(q.Brands) > 0 {
+		filters["brand"] = map[string]interface{}{"terms": map[string]interface{}{"brand": q.Brands}}
+	}
+	if len(q.Categories) > 0 {
+		filters["category"] = map[string]interface{}{"terms": map[string]interface{}{"category": q.Categories}}
+	}
+	if len(q.Merchants) > 0 {
+		filters["merchant"] = map[string]interface{}{"terms": map[string]interface{}{"merchant": q.Merchants}}
+	}
+	if q.PriceMin != nil || q.PriceMax != nil {
+		prices := map[string]interface{}{}
+		if q.PriceMin != nil {
+			prices["gte"] = *q.PriceMin
+		}
+		if q.PriceMax != nil {
+			prices["lte"] = *q.PriceMax
 		}
+		filters["price"] = map[string]interface{}{"range": map[string]interface{}{"prices": prices}}
+	}
+	if q.MinRating != nil {
+		filters["rating"] = map[string]interface{}{"range": map[string]interface{}{"average_rating": map[string]interface{}{"gte": *q.MinRating}}}
+	}
+	if q.InStock {
+		filters["in_stock"] = map[string]interface{}{"term": map[string]interface{}{"in_stock": true}}
+	}
+	for name, values := range q.Options {
+		keys := make([]string, 0, len(values))
+		for _, value := range values {
+			keys = append(keys, document.OptionKey(name, value))
+		}
+		filters[optionFacetKey(name)] = map[string]interface{}{"terms": map[string]interface{}{"options": keys}}
+	}
+	return filters
+}
 
-		products = append(products, p)
+// productFacetAggregations counts each facet under every filter except its own,
+// a selected option gets its own aggregation so its other values keep their counts
+func productFacetAggregations(q dto.ProductSearchQuery, filters facetFilters) map[string]interface{} {
+	facet := func(exclude string, agg map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"filter":       filters.except(exclude),
+			"aggregations": map[string]interface{}{"buckets": agg},
+		}
+	}
+	terms := func(field string, size int) map[string]interface{} {
+		return map[string]interface{}{"terms": map[string]interface{}{"field": field, "size": size}}
+	}
+
+	aggs := map[string]interface{}{
+		"brands":     facet("brand", terms("brand", facetSize)),
+		"categories": facet("category", terms("category", facetSize)),
+		"merchants":  facet("merchant", terms("merchant", facetSize)),
+		"options":    facet("", terms("options", optionFacetSize)),
+		"in_stock":   facet("in_stock", map[string]interface{}{"filter": map[string]interface{}{"term": map[string]interface{}{"in_stock": true}}}),
+		"prices": facet("price", map[string]interface{}{"histogram": map[string]interface{}{
+			"field": "prices", "interval": priceInterval(q), "min_doc_count": 1,
+		}}),
+		"ratings": facet("rating", map[string]interface{}{"range": map[string]interface{}{
+			"field": "average_rating",
+			"ranges": []map[string]interface{}{
+				{"key": "4", "from": 4}, {"key": "3", "from": 3}, {"key": "2", "from": 2}, {"key": "1", "from": 1},
+			},
+		}}),
+	}
+	for name := range q.Options {
+		aggs[optionFacetKey(name)] = facet(optionFacetKey(name), terms("options", optionFacetSize))
+	}
+	return aggs
+}
+
+type facetAggregation struct {
+	Buckets struct {
+		DocCount int64 `json:"doc_count"`
+		Buckets  []struct {
+			Key      interface{} `json:"key"`
+			DocCount int64       `json:"doc_count"`
+		} `json:"buckets"`
+	} `json:"buckets"`
+}
+
+func (a facetAggregation) values() []dto.FacetBucket {
+	buckets := make([]dto.FacetBucket, 0, len(a.Buckets.Buckets))
+	for _, b := range a.Buckets.Buckets {
+		if b.DocCount == 0 {
+			continue
+		}
+		buckets = append(buckets, dto.FacetBucket{Value: fmt.Sprint(b.Key), Count: b.DocCount})
+	}
+	return buckets
+}
+
+func priceInterval(q dto.ProductSearchQuery) float64 {
+	if q.PriceInterval <= 0 {
+		return defaultPriceInterval
+	}
+	return q.PriceInterval
+}
+
+func parseProductFacets(raw interface{}, interval float64) (*dto.ProductFacets, error) {
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var aggs map[string]facetAggregation
+	if err := json.Unmarshal(b, &aggs); err != nil {
+		return nil, fmt.Errorf("cannot parse aggregations: %w", err)
+	}
+
+	facets := &dto.ProductFacets{
+		Brands:     aggs["brands"].values(),
+		Categories: aggs["categories"].values(),
+		Merchants:  aggs["merchants"].values(),
+		Ratings:    aggs["ratings"].values(),
+		InStock:    aggs["in_stock"].Buckets.DocCount,
+		Options:    map[string][]dto.FacetBucket{},
+	}
+	for _, bucket := range aggs["prices"].Buckets.Buckets {
+		from, _ := bucket.Key.(float64)
+		facets.Prices = append(facets.Prices, dto.PriceBucket{From: from, To: from + interval, Count: bucket.DocCount})
+	}
+
+	// Option có aggregation riêng (đang được chọn) thì dùng số đếm của aggregation đó
+	selected := map[string]bool{}
+	for key, agg := range aggs {
+		name, ok := strings.CutPrefix(key, "option:")
+		if !ok {
+			continue
+		}
+		selected[name] = true
+		facets.Options[name] = optionBuckets(agg, name)[name]
+	}
+	for name, buckets := range optionBuckets(aggs["options"], "") {
+		if !selected[name] {
+			facets.Options[name] = buckets
+		}
+	}
+	return facets, nil
+}
+
+// optionBuckets splits "Name=Value" buckets by option name, only the named option when name is not empty
+func optionBuckets(agg facetAggregation, only string) map[string][]dto.FacetBucket {
+	options := map[string][]dto.FacetBucket{}
+	for _, bucket := range agg.values() {
+		name, value, ok := document.ParseOptionKey(bucket.Value)
+		if !ok || (only != "" && name != only) {
+			continue
+		}
+		options[name] = append(options[name], dto.FacetBucket{Value: value, Count: bucket.Count})
 	}
+	return options
+}
+
+const syncBatchSize = 500
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string          `json:"_id"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+func (r *ProductElasticRepo) SyncProducts(ctx context.Context, productIDs []uint) error {
+	for start := 0; start < len(productIDs); start += syncBatchSize {
+		end := min(start+syncBatchSize, len(productIDs))
+		if err := r.syncBatch(ctx, productIDs[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ProductElasticRepo) syncBatch(ctx context.Context, productIDs []uint) error {
+	var products []models.Product
+	if err := r.db.WithContext(ctx).
+		Preload("Merchant").
+		Preload("Brand").
+		Preload("Category").
+		Preload("Variants.OptionValues.Option").
+		Where("id IN ?", productIDs).
+		Find(&products).Error; err != nil {
+		return err
+	}
+
+	found := make(map[string]bool, len(products))
+	var b strings.Builder
+	for _, doc := range MapProductToProductDocument(products) {
+		found[doc.ID] = true
+		writeBulkLine(&b, map[string]interface{}{"update": map[string]string{"_index": ProductWriteAlias, "_id": doc.ID}})
+		writeBulkLine(&b, map[string]interface{}{"doc": doc, "doc_as_upsert": true})
+	}
+	for _, id := range productIDs {
+		docID := strconv.Itoa(int(id))
+		if !found[docID] {
+			writeBulkLine(&b, map[string]interface{}{"delete": map[string]string{"_index": ProductWriteAlias, "_id": docID}})
+		}
+	}
+	return r.bulk(ctx, b.String())
+}
+
+// IndexAllProducts reads every product from DB in batches into the physical index, returns the number of products read
+func (r *ProductElasticRepo) IndexAllProducts(ctx context.Context, indexName string) (int64, error) {
+	var total int64
+	var products []models.Product
+	result := r.db.WithContext(ctx).
+		Preload("Merchant").
+		Preload("Brand").
+		Preload("Category").
+		Preload("Variants.OptionValues.Option").
+		FindInBatches(&products, syncBatchSize, func(tx *gorm.DB, batch int) error {
+			var b strings.Builder
+			for _, doc := range MapProductToProductDocument(products) {
+				writeBulkLine(&b, map[string]interface{}{"index": map[string]string{"_index": indexName, "_id": doc.ID}})
+				writeBulkLine(&b, doc)
+			}
+			if err := r.bulk(ctx, b.String()); err != nil {
+				return err
+			}
+			total += int64(len(products))
+			log.Printf("Indexed %d products into %s", total, indexName)
+			return nil
+		})
+	if result.Error != nil {
+		return total, result.Error
+	}
+	return total, nil
+}
+
+func (r *ProductElasticRepo) UpdateProductFields(ctx context.Context, productIDs []uint, fields map[string]interface{}) error {
+	for start := 0; start < len(productIDs); start += syncBatchSize {
+		end := min(start+syncBatchSize, len(productIDs))
+		var b strings.Builder
+		for _, id := range productIDs[start:end] {
+			writeBulkLine(&b, map[string]interface{}{"update": map[string]string{"_index": ProductWriteAlias, "_id": strconv.Itoa(int(id))}})
+			writeBulkLine(&b, map[string]interface{}{"doc": fields})
+		}
+		if err := r.bulk(ctx, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bulk sends the actions and fails if any of them failed, a missing document is not a failure
+func (r *ProductElasticRepo) bulk(ctx context.Context, body string) error {
+	if body == "" {
+		return nil
+	}
+	res, err := r.es.Bulk(
+		strings.NewReader(body),
+		r.es.Bulk.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("bulk request failed: %s", res.String())
+	}
+	var resp bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return err
+	}
+	if !resp.Errors {
+		return nil
+	}
+	for _, item := range resp.Items {
+		for action, result := range item {
+			if result.Status == 404 || result.Status < 300 {
+				continue
+			}
+			return fmt.Errorf("bulk %s of product %s failed with status %d: %s", action, result.ID, result.Status, result.Error)
+		}
+	}
+	return nil
+}
 
-	return products, totalPages, currentPage, nil
+func writeBulkLine(b *strings.Builder, v interface{}) {
+	line, _ := json.Marshal(v)
+	b.Write(line)
+	b.WriteByte('\n')
 }
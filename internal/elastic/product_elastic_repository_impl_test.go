@@ -0,0 +1,55 @@
+package elastic
+
+import (
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/elastic/document"
+	"testing"
+)
+
+func testProduct() document.ProductDocument {
+	return document.ProductDocument{Variants: []document.VariantDocument{
+		{ID: 1, Price: 300, InStock: true, Options: []string{"Color=Red", "Size=M"}},
+		{ID: 2, Price: 100, InStock: false, Options: []string{"Color=Red", "Size=S"}},
+		{ID: 3, Price: 200, InStock: true, Options: []string{"Color=Blue", "Size=M"}},
+		{ID: 4, Price: 150, InStock: true, Options: []string{"Color=Blue", "Size=S"}},
+	}}
+}
+
+func TestBestVariant(t *testing.T) {
+	price := func(v float64) *float64 { return &v }
+	desc := false
+	cases := []struct {
+		name string
+		q    dto.ProductSearchQuery
+		want uint
+	}{
+		{name: "cheapest in stock without filters", q: dto.ProductSearchQuery{}, want: 4},
+		{name: "most expensive in stock when sorted by price desc", q: dto.ProductSearchQuery{PriceAsc: &desc}, want: 1},
+		{name: "out of stock match is kept when nothing else matches", q: dto.ProductSearchQuery{PriceMax: price(120)}, want: 2},
+		{name: "price range", q: dto.ProductSearchQuery{PriceMin: price(160), PriceMax: price(250)}, want: 3},
+		{name: "single option", q: dto.ProductSearchQuery{Options: map[string][]string{"Color": {"Red"}}}, want: 1},
+		{name: "any value of an option", q: dto.ProductSearchQuery{Options: map[string][]string{"Size": {"M", "L"}}}, want: 3},
+		{name: "every option must match", q: dto.ProductSearchQuery{Options: map[string][]string{"Color": {"Red"}, "Size": {"S"}}}, want: 2},
+		{name: "best overall when nothing matches", q: dto.ProductSearchQuery{Options: map[string][]string{"Color": {"Green"}}}, want: 4},
+	}
+	for _, c := range cases {
+		got := bestVariant(testProduct(), c.q)
+		if got == nil || got.ID != c.want {
+			t.Errorf("%s: bestVariant = %+v, want variant %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestBestVariantWithoutVariants(t *testing.T) {
+	if got := bestVariant(document.ProductDocument{}, dto.ProductSearchQuery{}); got != nil {
+		t.Fatalf("bestVariant = %+v, want nil", got)
+	}
+}
+
+func TestBestVariantPointsIntoDocument(t *testing.T) {
+	p := testProduct()
+	got := bestVariant(p, dto.ProductSearchQuery{})
+	if got != &p.Variants[3] {
+		t.Fatal("bestVariant must return the variant stored in the document")
+	}
+}
@@ -0,0 +1,34 @@
+package event
+
+import "time"
+
+const CatalogChangedEventType = "catalog.changed"
+
+type CatalogEntity string
+
+const (
+	CatalogProduct  CatalogEntity = "product"
+	CatalogMerchant CatalogEntity = "merchant"
+	CatalogBrand    CatalogEntity = "brand"
+	CatalogCategory CatalogEntity = "category"
+	// CatalogOrder is a finished order, the total_buy of its products changed
+	CatalogOrder CatalogEntity = "order"
+)
+
+// CatalogChangedEvent tells the search index that rows read by product documents changed,
+// variant writes are sent as a change of their product
+type CatalogChangedEvent struct {
+	Entity     CatalogEntity
+	ID         uint
+	Deleted    bool
+	OccurredAt time.Time
+}
+
+func NewCatalogChangedEvent(entity CatalogEntity, id uint, deleted bool) CatalogChangedEvent {
+	return CatalogChangedEvent{
+		Entity:     entity,
+		ID:         id,
+		Deleted:    deleted,
+		OccurredAt: time.Now(),
+	}
+}
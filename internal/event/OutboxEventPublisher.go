@@ -0,0 +1,231 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"gorm.io/gorm"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	outboxPollInterval = 2 * time.Second
+	outboxWorkers      = 32
+	// Handlers only schedule a payment check or sync the changed catalog rows, both are quick and safe to repeat,
+	// so a short lease lets events claimed by a crashed relay be retried soon
+	outboxLease       = 1 * time.Minute
+	outboxMaxAttempts = 8
+	outboxBaseBackoff = 5 * time.Second
+	outboxMaxBackoff  = 30 * time.Minute
+)
+
+// OutboxEventPublisher stores events in the outbox table and a relay delivers them to subscribers
+type OutboxEventPublisher struct {
+	outboxRepo repositories.OutboxRepository
+
+	mu                     sync.RWMutex
+	paymentCreatedHandlers []func(PayOSPaymentCreatedEvent) error
+	catalogChangedHandlers []func(CatalogChangedEvent) error
+
+	slots chan struct{}
+	wake  chan struct{}
+}
+
+func NewOutboxEventPublisher(outboxRepo repositories.OutboxRepository) *OutboxEventPublisher {
+	return &OutboxEventPublisher{
+		outboxRepo: outboxRepo,
+		slots:      make(chan struct{}, outboxWorkers),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+func (p *OutboxEventPublisher) PublishPaymentCreated(event PayOSPaymentCreatedEvent) error {
+	outboxEvent, err := newOutboxEvent(PaymentCreatedEventType, event)
+	if err != nil {
+		return err
+	}
+	if err := p.outboxRepo.Create(context.Background(), outboxEvent); err != nil {
+		return err
+	}
+	p.notify()
+	return nil
+}
+
+func (p *OutboxEventPublisher) PublishPaymentCreatedTx(tx *gorm.DB, event PayOSPaymentCreatedEvent) error {
+	outboxEvent, err := newOutboxEvent(PaymentCreatedEventType, event)
+	if err != nil {
+		return err
+	}
+	// Relay picks the row up on its next poll, after the caller commits
+	return p.outboxRepo.CreateTx(tx.Statement.Context, tx, outboxEvent)
+}
+
+func (p *OutboxEventPublisher) Subscribe(handler func(PayOSPaymentCreatedEvent) error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paymentCreatedHandlers = append(p.paymentCreatedHandlers, handler)
+}
+
+func (p *OutboxEventPublisher) PublishCatalogChanged(event CatalogChangedEvent) error {
+	outboxEvent, err := newOutboxEvent(CatalogChangedEventType, event)
+	if err != nil {
+		return err
+	}
+	if err := p.outboxRepo.Create(context.Background(), outboxEvent); err != nil {
+		return err
+	}
+	p.notify()
+	return nil
+}
+
+func (p *OutboxEventPublisher) PublishCatalogChangedTx(tx *gorm.DB, event CatalogChangedEvent) error {
+	outboxEvent, err := newOutboxEvent(CatalogChangedEventType, event)
+	if err != nil {
+		return err
+	}
+	return p.outboxRepo.CreateTx(tx.Statement.Context, tx, outboxEvent)
+}
+
+func (p *OutboxEventPublisher) SubscribeCatalogChanged(handler func(CatalogChangedEvent) error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.catalogChangedHandlers = append(p.catalogChangedHandlers, handler)
+}
+
+// Start runs the relay until ctx is cancelled. Register subscribers before starting.
+func (p *OutboxEventPublisher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(outboxPollInterval)
+		defer ticker.Stop()
+
+		for {
+			p.relay(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-p.wake:
+			}
+		}
+	}()
+}
+
+func (p *OutboxEventPublisher) relay(ctx context.Context) {
+	free := cap(p.slots) - len(p.slots)
+	if free == 0 {
+		return
+	}
+	events, err := p.outboxRepo.ClaimDue(ctx, free, outboxLease)
+	if err != nil {
+		log.Printf("Error claiming outbox events: %v", err)
+		return
+	}
+	for _, e := range events {
+		e := e
+		p.slots <- struct{}{}
+		go func() {
+			defer func() { <-p.slots }()
+			p.deliver(ctx, e)
+		}()
+	}
+}
+
+func (p *OutboxEventPublisher) deliver(ctx context.Context, e models.OutboxEvent) {
+	err := p.dispatch(e)
+	if err == nil {
+		if err := p.outboxRepo.MarkDelivered(ctx, e.ID); err != nil {
+			log.Printf("Error marking outbox event %d delivered: %v", e.ID, err)
+		}
+		return
+	}
+
+	if e.Attempts >= outboxMaxAttempts {
+		log.Printf("Outbox event %d (%s) moved to dead letter after %d attempts: %v", e.ID, e.EventType, e.Attempts, err)
+		if err := p.outboxRepo.MarkDead(ctx, e.ID, err.Error()); err != nil {
+			log.Printf("Error marking outbox event %d dead: %v", e.ID, err)
+		}
+		return
+	}
+
+	next := time.Now().Add(backoff(e.Attempts))
+	log.Printf("Outbox event %d (%s) attempt %d failed, retry at %s: %v", e.ID, e.EventType, e.Attempts, next.Format(time.RFC3339), err)
+	if err := p.outboxRepo.MarkRetry(ctx, e.ID, next, err.Error()); err != nil {
+		log.Printf("Error scheduling retry of outbox event %d: %v", e.ID, err)
+	}
+}
+
+func (p *OutboxEventPublisher) dispatch(e models.OutboxEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+
+	switch e.EventType {
+	case PaymentCreatedEventType:
+		var payload PayOSPaymentCreatedEvent
+		if err := json.Unmarshal([]byte(e.Payload), &payload); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		p.mu.RLock()
+		handlers := append([]func(PayOSPaymentCreatedEvent) error(nil), p.paymentCreatedHandlers...)
+		p.mu.RUnlock()
+		for _, handler := range handlers {
+			if err := handler(payload); err != nil {
+				return err
+			}
+		}
+		return nil
+	case CatalogChangedEventType:
+		var payload CatalogChangedEvent
+		if err := json.Unmarshal([]byte(e.Payload), &payload); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		p.mu.RLock()
+		handlers := append([]func(CatalogChangedEvent) error(nil), p.catalogChangedHandlers...)
+		p.mu.RUnlock()
+		for _, handler := range handlers {
+			if err := handler(payload); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown event type %s", e.EventType)
+	}
+}
+
+func (p *OutboxEventPublisher) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func newOutboxEvent(eventType string, payload interface{}) (*models.OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	return &models.OutboxEvent{
+		EventType: eventType,
+		Payload:   string(data),
+		Status:    models.OutboxPending,
+	}, nil
+}
+
+// backoff doubles the delay after every failed attempt, capped at outboxMaxBackoff
+func backoff(attempts int) time.Duration {
+	delay := outboxBaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= outboxMaxBackoff {
+			return outboxMaxBackoff
+		}
+	}
+	return delay
+}
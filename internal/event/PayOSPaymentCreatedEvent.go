@@ -1,9 +1,13 @@
 package event
 
 import (
+	"gorm.io/gorm"
+	"log"
 	"time"
 )
 
+const PaymentCreatedEventType = "payos.payment_created"
+
 type PayOSPaymentCreatedEvent struct {
 	Id            int64
 	OrderID       uint
@@ -13,17 +17,29 @@ type PayOSPaymentCreatedEvent struct {
 	CreatedAt     time.Time
 }
 
+// EventPublisher handlers may receive the same event more than once and must be idempotent,
+// a returned error asks the publisher to deliver the event again later.
 type EventPublisher interface {
 	PublishPaymentCreated(event PayOSPaymentCreatedEvent) error
-	Subscribe(handler func(PayOSPaymentCreatedEvent))
+	// PublishPaymentCreatedTx records the event in the caller's transaction
+	PublishPaymentCreatedTx(tx *gorm.DB, event PayOSPaymentCreatedEvent) error
+	Subscribe(handler func(PayOSPaymentCreatedEvent) error)
+	PublishCatalogChanged(event CatalogChangedEvent) error
+	PublishCatalogChangedTx(tx *gorm.DB, event CatalogChangedEvent) error
+	SubscribeCatalogChanged(handler func(CatalogChangedEvent) error)
 }
 
+// ChannelEventPublisher is an in-process publisher without persistence or retry, kept for tests
 type ChannelEventPublisher struct {
-	ch chan PayOSPaymentCreatedEvent
+	ch        chan PayOSPaymentCreatedEvent
+	catalogCh chan CatalogChangedEvent
 }
 
 func NewChannelEventPublisher() *ChannelEventPublisher {
-	return &ChannelEventPublisher{ch: make(chan PayOSPaymentCreatedEvent, 10)}
+	return &ChannelEventPublisher{
+		ch:        make(chan PayOSPaymentCreatedEvent, 10),
+		catalogCh: make(chan CatalogChangedEvent, 100),
+	}
 }
 
 func (p *ChannelEventPublisher) PublishPaymentCreated(event PayOSPaymentCreatedEvent) error {
@@ -31,10 +47,35 @@ func (p *ChannelEventPublisher) PublishPaymentCreated(event PayOSPaymentCreatedE
 	return nil
 }
 
-func (p *ChannelEventPublisher) Subscribe(handler func(PayOSPaymentCreatedEvent)) {
+func (p *ChannelEventPublisher) PublishPaymentCreatedTx(tx *gorm.DB, event PayOSPaymentCreatedEvent) error {
+	return p.PublishPaymentCreated(event)
+}
+
+func (p *ChannelEventPublisher) Subscribe(handler func(PayOSPaymentCreatedEvent) error) {
 	go func() {
 		for e := range p.ch {
-			handler(e)
+			if err := handler(e); err != nil {
+				log.Printf("Error handling payment created event %d: %v", e.Id, err)
+			}
+		}
+	}()
+}
+
+func (p *ChannelEventPublisher) PublishCatalogChanged(event CatalogChangedEvent) error {
+	p.catalogCh <- event
+	return nil
+}
+
+func (p *ChannelEventPublisher) PublishCatalogChangedTx(tx *gorm.DB, event CatalogChangedEvent) error {
+	return p.PublishCatalogChanged(event)
+}
+
+func (p *ChannelEventPublisher) SubscribeCatalogChanged(handler func(CatalogChangedEvent) error) {
+	go func() {
+		for e := range p.catalogCh {
+			if err := handler(e); err != nil {
+				log.Printf("Error handling catalog changed event %s %d: %v", e.Entity, e.ID, err)
+			}
 		}
 	}()
 }
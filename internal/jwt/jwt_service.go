@@ -1,85 +1,165 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/pkg/errors"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+
+	AccessTokenTTL  = 24 * time.Hour
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
 
 type JWTClaims struct {
 	UserID uint        `json:"user_id"`
 	Email  string      `json:"email"`
 	Role   models.Role `json:"role"`
+	// MerchantID is set for MERCHANT users
+	MerchantID uint `json:"merchant_id,omitempty"`
+	// TokenType keeps refresh tokens from being used as access tokens and the other way round
+	TokenType string `json:"typ"`
+	// SessionID is shared by the access and refresh tokens of one login
+	SessionID string `json:"sid"`
+	// IssuedAtMs is iat in milliseconds, a token issued right after a user-wide revocation stays valid
+	IssuedAtMs int64 `json:"iat_ms,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// IssuedTime is the issue time with millisecond precision, tokens without iat_ms fall back to iat
+func (c *JWTClaims) IssuedTime() time.Time {
+	if c.IssuedAtMs > 0 {
+		return time.UnixMilli(c.IssuedAtMs)
+	}
+	if c.IssuedAt == nil {
+		return time.Time{}
+	}
+	return c.IssuedAt.Time
+}
+
 type JWTService struct{}
 
 func NewJWTService() *JWTService {
 	return &JWTService{}
 }
 
-// GenerateToken tạo JWT token
-func (j *JWTService) GenerateToken(user *models.User) (string, error) {
-	claims := JWTClaims{
-		UserID: user.UserID,
-		Email:  user.Email,
-		Role:   user.Role,
+// GenerateToken tạo access token cho session
+func (j *JWTService) GenerateToken(user *models.User, sessionID string) (string, error) {
+	token, _, err := j.sign(user, TokenTypeAccess, sessionID, AccessTokenTTL)
+	return token, err
+}
+
+// GenerateRefreshToken tạo refresh token cho session, claims trả về để lưu lại token
+func (j *JWTService) GenerateRefreshToken(user *models.User, sessionID string) (string, *JWTClaims, error) {
+	return j.sign(user, TokenTypeRefresh, sessionID, RefreshTokenTTL)
+}
+
+// ValidateToken xác thực và parse access token
+func (j *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
+	return j.parse(tokenString, TokenTypeAccess)
+}
+
+// ValidateRefreshToken xác thực và parse refresh token
+func (j *JWTService) ValidateRefreshToken(tokenString string) (*JWTClaims, error) {
+	return j.parse(tokenString, TokenTypeRefresh)
+}
+
+func (j *JWTService) sign(user *models.User, tokenType string, sessionID string, ttl time.Duration) (string, *JWTClaims, error) {
+	now := time.Now()
+	claims := &JWTClaims{
+		UserID:     user.UserID,
+		Email:      user.Email,
+		Role:       user.Role,
+		MerchantID: merchantIDOf(user),
+		TokenType:  tokenType,
+		SessionID:  sessionID,
+		IssuedAtMs: now.UnixMilli(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24h
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        NewTokenID(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "nxrGO",
-			Subject:   string(rune(user.UserID)),
+			Subject:   strconv.FormatUint(uint64(user.UserID), 10),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	keyring := getKeyring()
+	if keyring == nil {
+		return "", nil, errors.NewError(errors.INTERNAL_ERROR, "JWT keyring is not initialized", http.StatusInternalServerError, nil)
+	}
+	key := keyring.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(key.Private)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
-// ValidateToken xác thực và parse JWT token
-func (j *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
+func (j *JWTService) parse(tokenString string, tokenType string) (*JWTClaims, error) {
+	keyring := getKeyring()
+	if keyring == nil {
+		return nil, errors.NewError(errors.INTERNAL_ERROR, "JWT keyring is not initialized", http.StatusInternalServerError, nil)
+	}
+
+	// Chọn key theo kid, key đã retire vẫn verify được token cũ
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.NewError(errors.INTERNAL_ERROR, "Unexpected signing method", http.StatusInternalServerError, nil)
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keyring.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
 		}
-		return jwtSecret, nil
-	})
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+		}
+		return key.Public, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
 
 	if err != nil {
-		return nil, errors.NewError(errors.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, nil)
+		return nil, errors.NewError(errors.UNAUTHORIZED, "Invalid token", http.StatusUnauthorized, err)
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !token.Valid || claims.TokenType != tokenType || claims.SessionID == "" {
+		return nil, errors.NewError(errors.UNAUTHORIZED, "Invalid token", http.StatusUnauthorized, nil)
 	}
+	return claims, nil
+}
 
-	return nil, errors.NewError(errors.INTERNAL_ERROR, "Invalid token", http.StatusInternalServerError, nil)
-
+// JWKS returns the public keys tokens can be verified with
+func (j *JWTService) JWKS() JWKSet {
+	keyring := getKeyring()
+	if keyring == nil {
+		return JWKSet{Keys: []JWK{}}
+	}
+	return keyring.JWKS()
 }
 
-// GenerateRefreshToken tạo refresh token
-func (j *JWTService) GenerateRefreshToken(user *models.User) (string, error) {
-	claims := JWTClaims{
-		UserID: user.UserID,
-		Email:  user.Email,
-		Role:   user.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7 days
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "nxrGO",
-			Subject:   string(rune(user.UserID)),
-		},
+// NewTokenID returns a random id for token and session ids
+func NewTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
 	}
+	return hex.EncodeToString(b)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+func merchantIDOf(user *models.User) uint {
+	if user.MerchantID == nil {
+		return 0
+	}
+	return *user.MerchantID
 }
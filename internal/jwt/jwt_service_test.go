@@ -0,0 +1,53 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+func useTestKeyring(t *testing.T) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyring, err := NewKeyring("test", &SigningKey{ID: "test", Method: jwtlib.SigningMethodEdDSA, Private: private, Public: public})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	SetKeyring(keyring)
+	t.Cleanup(func() { SetKeyring(nil) })
+}
+
+func TestIssuedTimeKeepsMilliseconds(t *testing.T) {
+	useTestKeyring(t)
+	service := NewJWTService()
+
+	before := time.Now().Truncate(time.Millisecond)
+	token, err := service.GenerateToken(&models.User{UserID: 1, Role: models.RoleUser}, "session")
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	claims, err := service.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("validate token: %v", err)
+	}
+	// iat alone is truncated to the second and would fall before the start of the test
+	issued := claims.IssuedTime()
+	if issued.Before(before) || issued.After(time.Now()) {
+		t.Fatalf("IssuedTime = %v, want between %v and now", issued, before)
+	}
+}
+
+func TestIssuedTimeFallsBackToIat(t *testing.T) {
+	iat := time.Unix(1700000000, 0)
+	claims := &JWTClaims{RegisteredClaims: jwtlib.RegisteredClaims{IssuedAt: jwtlib.NewNumericDate(iat)}}
+	if got := claims.IssuedTime(); !got.Equal(iat) {
+		t.Fatalf("IssuedTime = %v, want %v", got, iat)
+	}
+}
@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileSender writes every message to an outbox directory instead of sending it, for local testing
+type fileSender struct {
+	dir  string
+	from string
+}
+
+func NewFileSender(dir string, from string) Sender {
+	return &fileSender{dir: dir, from: from}
+}
+
+func (f *fileSender) Name() string {
+	return "file"
+}
+
+func (f *fileSender) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), sanitize(msg.To))
+	return os.WriteFile(filepath.Join(f.dir, name), buildMessage(f.from, msg), 0o644)
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
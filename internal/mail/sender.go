@@ -0,0 +1,15 @@
+package mail
+
+import "context"
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers transactional emails
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Address is a saved shipping address of a user, orders copy it so editing it later
+// does not change past orders
+type Address struct {
+	ID            uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID        uint   `gorm:"not null;index" json:"user_id"`
+	Label         string `gorm:"type:varchar(50)" json:"label"`
+	RecipientName string `gorm:"type:varchar(255);not null" json:"recipient_name"`
+	PhoneNumber   string `gorm:"type:varchar(10);not null" json:"phone_number"`
+	Street        string `gorm:"type:varchar(255);not null" json:"street"`
+	Ward          string `gorm:"type:varchar(100)" json:"ward"`
+	District      string `gorm:"type:varchar(100)" json:"district"`
+	Province      string `gorm:"type:varchar(100);not null" json:"province"`
+	Latitude      string `gorm:"type:varchar(20);not null" json:"lat"`
+	Longitude     string `gorm:"type:varchar(20);not null" json:"lon"`
+	IsDefault     bool   `gorm:"not null;default:false" json:"is_default"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// FullAddress is the one line address stored on orders
+func (a *Address) FullAddress() string {
+	parts := make([]string, 0, 4)
+	for _, p := range []string{a.Street, a.Ward, a.District, a.Province} {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
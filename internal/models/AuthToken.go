@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type AuthTokenPurpose string
+
+const (
+	AuthTokenEmailVerification AuthTokenPurpose = "EMAIL_VERIFICATION"
+	AuthTokenPasswordReset     AuthTokenPurpose = "PASSWORD_RESET"
+)
+
+// AuthToken is a single-use token mailed to the user, only the sha256 of the token is stored
+type AuthToken struct {
+	ID        uint             `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint             `gorm:"not null;index" json:"user_id"`
+	Purpose   AuthTokenPurpose `gorm:"type:varchar(30);not null" json:"purpose"`
+	TokenHash string           `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time        `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time       `json:"used_at"`
+	CreatedAt time.Time        `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// CartItem is a line of the server side cart of a user
+type CartItem struct {
+	ID               uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID           uint `gorm:"not null;uniqueIndex:idx_cart_user_variant" json:"user_id"`
+	ProductVariantID uint `gorm:"not null;uniqueIndex:idx_cart_user_variant" json:"product_variant_id"`
+	Quantity         uint `gorm:"not null" json:"quantity"`
+	// PriceAtAdd is the price when the line was last added, to tell the user about price changes
+	PriceAtAdd float64 `gorm:"type:decimal(12,2)" json:"price_at_add"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
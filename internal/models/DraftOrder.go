@@ -9,6 +9,7 @@ type DraftOrder struct {
 	Status          OrderStatus   `gorm:"type:varchar(20)" json:"status"`
 	PaymentMethod   PaymentMethod `gorm:"type:varchar(20)" json:"payment_method"`
 	ShippingAddress string        `gorm:"type:varchar(255)" json:"shipping_address"`
+	RecipientName   string        `gorm:"type:varchar(255)" json:"recipient_name"`
 	PhoneNumber     string        `gorm:"type:varchar(10)" json:"phone_number"`
 	Latitude        string        `gorm:"type:varchar(20)" json:"latitude"`
 	Longitude       string        `gorm:"type:varchar(20)" json:"longitude"`
@@ -19,7 +20,13 @@ type DraftOrder struct {
 
 	Delivery DeliveryDetail `gorm:"polymorphic:Order;polymorphicValue:draft_order" json:"delivery,omitempty"`
 
-	ParentID  *uint `gorm:"column:parent_id" json:"parent_id,omitempty"`
+	ParentID *uint `gorm:"column:parent_id" json:"parent_id,omitempty"`
+
+	// ReservationExpiresAt is when stock held for an unpaid draft is given back
+	ReservationExpiresAt *time.Time `gorm:"index" json:"reservation_expires_at,omitempty"`
+	ExpiredAt            *time.Time `json:"expired_at,omitempty"`
+	ExpiryReason         string     `gorm:"type:varchar(255)" json:"expiry_reason,omitempty"`
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type IdempotencyStatus string
+
+const (
+	IdempotencyInProgress IdempotencyStatus = "IN_PROGRESS"
+	IdempotencyCompleted  IdempotencyStatus = "COMPLETED"
+)
+
+// IdempotencyKey stores the response of a request sent with an Idempotency-Key header
+type IdempotencyKey struct {
+	ID     uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID uint   `gorm:"not null;uniqueIndex:idx_idempotency_user_key" json:"user_id"`
+	Key    string `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_user_key" json:"key"`
+	// Fingerprint is the sha256 of method, path and body of the first request
+	Fingerprint  string            `gorm:"type:char(64);not null" json:"fingerprint"`
+	Status       IdempotencyStatus `gorm:"type:varchar(20);not null" json:"status"`
+	ResponseCode int               `json:"response_code"`
+	ResponseBody string            `gorm:"type:mediumtext" json:"-"`
+	// ExpiresAt ends the lease of an in-progress request, then the retention of the stored response
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -16,7 +16,9 @@ const (
 	OrderStateReturnRequested OrderStatus = "RETURN_REQUESTED"
 	OrderStateReturnShipping  OrderStatus = "RETURN_SHIPPING"
 	OrderStateReturned        OrderStatus = "RETURNED"
-	OrderStateCancelled       OrderStatus = "CANCELLED"
+	// OrderStatePartiallyReturned is a delivered order where some of the items came back
+	OrderStatePartiallyReturned OrderStatus = "PARTIALLY_RETURNED"
+	OrderStateCancelled         OrderStatus = "CANCELLED"
 )
 
 type PaymentMethod string
@@ -26,6 +28,11 @@ const (
 	PaymentMethodBank PaymentMethod = "BANK"
 )
 
+// IsOnline reports whether the method is paid through a payment provider link
+func (m PaymentMethod) IsOnline() bool {
+	return m != PaymentMethodCOD
+}
+
 type Order struct {
 	ID              uint           `gorm:"primaryKey" json:"id"`    //
 	UserID          uint           `gorm:"not null" json:"user_id"` //
@@ -34,6 +41,7 @@ type Order struct {
 	PaymentMethod   PaymentMethod  `gorm:"type:varchar(20)" json:"payment_method"`
 	DeliveryMode    DeliveryMode   `gorm:"type:varchar(20)" json:"delivery_mode"`
 	ShippingAddress string         `gorm:"type:varchar(255)" json:"shipping_address"`
+	RecipientName   string         `gorm:"type:varchar(255)" json:"recipient_name"`
 	PhoneNumber     string         `gorm:"type:varchar(10)" json:"phone_number"`
 	ParentID        *uint          `gorm:"column:parent_id" json:"parent_id,omitempty"`
 	Latitude        string         `gorm:"type:varchar(20)" json:"latitude"`
@@ -47,15 +55,16 @@ type Order struct {
 }
 
 var orderStatusOrder = map[OrderStatus]int{
-	OrderStatePending:         1,
-	OrderStateConfirmed:       2,
-	OrderStateProcessing:      3,
-	OrderStateShipped:         4,
-	OrderStateDelivered:       5,
-	OrderStateReturnRequested: 6,
-	OrderStateReturnShipping:  7,
-	OrderStateReturned:        8,
-	OrderStateCancelled:       9,
+	OrderStatePending:           1,
+	OrderStateConfirmed:         2,
+	OrderStateProcessing:        3,
+	OrderStateShipped:           4,
+	OrderStateDelivered:         5,
+	OrderStateReturnRequested:   6,
+	OrderStateReturnShipping:    7,
+	OrderStatePartiallyReturned: 8,
+	OrderStateReturned:          9,
+	OrderStateCancelled:         10,
 }
 
 func IsBeforeOrderStatus(s1, s2 OrderStatus) bool {
@@ -67,3 +76,35 @@ func IsBeforeOrderStatus(s1, s2 OrderStatus) bool {
 	}
 	return order1 <= order2
 }
+
+// AggregateItemStatus derives the order status from item level cancels and returns,
+// ok is false when the items leave the current status unchanged.
+func (o *Order) AggregateItemStatus() (OrderStatus, bool) {
+	if len(o.OrderItems) == 0 {
+		return "", false
+	}
+	var outstanding, returned uint
+	for i := range o.OrderItems {
+		outstanding += o.OrderItems[i].OutstandingQuantity()
+		returned += o.OrderItems[i].ReturnedQuantity
+	}
+	switch {
+	case outstanding == 0 && returned == 0:
+		return OrderStateCancelled, true
+	case outstanding == 0:
+		return OrderStateReturned, true
+	case returned > 0:
+		return OrderStatePartiallyReturned, true
+	}
+	return "", false
+}
+
+// HasReturnInProgress reports whether an item return was requested and not received yet
+func (o *Order) HasReturnInProgress() bool {
+	for i := range o.OrderItems {
+		if o.OrderItems[i].ReturnRequestedQuantity > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -17,6 +17,17 @@ const (
 	OrderTypeDraftOrder OrderType = "draft_order"
 )
 
+type OrderItemStatus string
+
+const (
+	OrderItemActive             OrderItemStatus = "ACTIVE"
+	OrderItemPartiallyCancelled OrderItemStatus = "PARTIALLY_CANCELLED"
+	OrderItemCancelled          OrderItemStatus = "CANCELLED"
+	OrderItemReturnRequested    OrderItemStatus = "RETURN_REQUESTED"
+	OrderItemPartiallyReturned  OrderItemStatus = "PARTIALLY_RETURNED"
+	OrderItemReturned           OrderItemStatus = "RETURNED"
+)
+
 type OrderItem struct {
 	ID               uint           `gorm:"primaryKey" json:"id"`
 	OrderID          uint           `gorm:"not null" json:"order_id"`
@@ -25,8 +36,49 @@ type OrderItem struct {
 	Variant          ProductVariant `gorm:"foreignKey:ProductVariantID" json:"-"`
 	Quantity         uint           `json:"quantity"`
 	Price            float64        `gorm:"type:decimal(10,2)" json:"price"`
+	Discount         float64        `gorm:"type:decimal(10,2);not null;default:0" json:"discount"`
 	TotalPrice       float64        `gorm:"type:decimal(10,2)" json:"total_price"`
 	MerchantID       uint           `gorm:"-" json:"-"`
+	// Quantities of the item cancelled, waiting to come back and already returned
+	Status                  OrderItemStatus `gorm:"type:varchar(20);not null;default:'ACTIVE'" json:"status"`
+	CancelledQuantity       uint            `gorm:"not null;default:0" json:"cancelled_quantity"`
+	ReturnRequestedQuantity uint            `gorm:"not null;default:0" json:"return_requested_quantity"`
+	ReturnedQuantity        uint            `gorm:"not null;default:0" json:"returned_quantity"`
+}
+
+// OutstandingQuantity is the quantity neither cancelled nor returned
+func (oi *OrderItem) OutstandingQuantity() uint {
+	return oi.Quantity - min(oi.CancelledQuantity+oi.ReturnedQuantity, oi.Quantity)
+}
+
+// ReturnableQuantity is the outstanding quantity without a return already requested
+func (oi *OrderItem) ReturnableQuantity() uint {
+	return oi.OutstandingQuantity() - min(oi.ReturnRequestedQuantity, oi.OutstandingQuantity())
+}
+
+// AmountFor is the part of TotalPrice paid for quantity units of the item
+func (oi *OrderItem) AmountFor(quantity uint) float64 {
+	if oi.Quantity == 0 {
+		return 0
+	}
+	return oi.TotalPrice * float64(quantity) / float64(oi.Quantity)
+}
+
+func (oi *OrderItem) RecomputeStatus() {
+	switch {
+	case oi.ReturnRequestedQuantity > 0:
+		oi.Status = OrderItemReturnRequested
+	case oi.CancelledQuantity >= oi.Quantity:
+		oi.Status = OrderItemCancelled
+	case oi.ReturnedQuantity > 0 && oi.OutstandingQuantity() == 0:
+		oi.Status = OrderItemReturned
+	case oi.ReturnedQuantity > 0:
+		oi.Status = OrderItemPartiallyReturned
+	case oi.CancelledQuantity > 0:
+		oi.Status = OrderItemPartiallyCancelled
+	default:
+		oi.Status = OrderItemActive
+	}
 }
 
 /*
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// RoleSystem marks changes made by background jobs and provider callbacks, it is never a user role
+const RoleSystem Role = "SYSTEM"
+
+// History events that are not transitions of the order state machine
+const (
+	HistoryEventCreate        = "create"
+	HistoryEventSplit         = "split"
+	HistoryEventReceiveReturn = "receive_return"
+)
+
+// Actor is who caused a change, UserID is 0 for the system
+type Actor struct {
+	UserID     uint
+	Role       Role
+	MerchantID uint
+}
+
+var SystemActor = Actor{Role: RoleSystem}
+
+type OrderStatusHistory struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	OrderID    uint        `gorm:"not null;index" json:"order_id"`
+	ActorID    uint        `gorm:"not null;default:0" json:"actor_id"`
+	ActorRole  Role        `gorm:"type:varchar(20)" json:"actor_role"`
+	Event      string      `gorm:"type:varchar(30)" json:"event"`
+	FromStatus OrderStatus `gorm:"type:varchar(20)" json:"from_status"`
+	ToStatus   OrderStatus `gorm:"type:varchar(20)" json:"to_status"`
+	Note       string      `gorm:"type:varchar(255)" json:"note,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+type OutboxStatus string
+
+const (
+	OutboxPending    OutboxStatus = "PENDING"
+	OutboxProcessing OutboxStatus = "PROCESSING"
+	OutboxDelivered  OutboxStatus = "DELIVERED"
+	OutboxDead       OutboxStatus = "DEAD"
+)
+
+// OutboxEvent is an event written in the same transaction as the rows it describes,
+// the relay delivers it to subscribers at least once.
+// Delivered rows are kept, search reindex and rollback replay catalog changes from them,
+// so a cleanup job must not delete rows younger than the oldest index version kept for a rollback.
+type OutboxEvent struct {
+	ID        uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	EventType string       `gorm:"type:varchar(100);not null" json:"event_type"`
+	Payload   string       `gorm:"type:text;not null" json:"payload"`
+	Status    OutboxStatus `gorm:"type:varchar(20);not null;index:idx_outbox_status_next_attempt" json:"status"`
+	Attempts  int          `gorm:"not null;default:0" json:"attempts"`
+	// NextAttemptAt is the retry time of a PENDING event and the lease expiry of a PROCESSING one
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_status_next_attempt" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:varchar(500)" json:"last_error,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OutboxStats summarises the events of one type, Pending counts events not delivered yet
+type OutboxStats struct {
+	Pending         int64
+	Dead            int64
+	OldestPendingAt *time.Time
+}
@@ -24,6 +24,15 @@ type PaymentInfo struct {
 	CancellationReason string        `gorm:"type:varchar(255)" json:"cancellation_reason"`
 	ParentID           *int64        `gorm:"column:parent_id" json:"parent_id,omitempty"`
 	CancellationAt     *time.Time    `json:"cancellation_at,omitempty"`
+	// NextCheckAt is when the reconciliation worker polls the provider for a still pending payment
+	NextCheckAt   *time.Time `gorm:"index" json:"-"`
+	CheckAttempts int        `gorm:"not null;default:0" json:"-"`
+	// RefundedAmount is the sum of completed refunds, the payment becomes REFUND once it reaches Total
+	RefundedAmount float64 `gorm:"type:decimal(10,2);not null;default:0" json:"refunded_amount"`
+	// Discount and ShippingDiscount of the coupon are already taken off Total
+	Discount         float64 `gorm:"type:decimal(10,2);not null;default:0" json:"discount"`
+	ShippingDiscount float64 `gorm:"type:decimal(10,2);not null;default:0" json:"shipping_discount"`
+	CouponCode       string  `gorm:"type:varchar(50)" json:"coupon_code,omitempty"`
 
 	CreatedAt time.Time `json:"-"`
 	UpdatedAt time.Time `json:"-"`
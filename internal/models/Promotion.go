@@ -0,0 +1,70 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+type PromotionType string
+
+const (
+	PromotionPercentage   PromotionType = "PERCENTAGE"
+	PromotionFixed        PromotionType = "FIXED"
+	PromotionFreeShipping PromotionType = "FREE_SHIPPING"
+)
+
+// Promotion is a coupon code. MerchantID, CategoryID and BrandID narrow the items it applies to,
+// zero limits mean unlimited.
+type Promotion struct {
+	ID          uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code        string        `gorm:"type:varchar(50);not null;uniqueIndex" json:"code"`
+	Name        string        `gorm:"type:varchar(255);not null" json:"name"`
+	Description string        `gorm:"type:varchar(255)" json:"description"`
+	Type        PromotionType `gorm:"type:varchar(20);not null" json:"type"`
+	// Value is the percent for PERCENTAGE and the amount for FIXED
+	Value float64 `gorm:"type:decimal(12,2);not null;default:0" json:"value"`
+	// MaxDiscount caps PERCENTAGE and FREE_SHIPPING discounts
+	MaxDiscount   float64 `gorm:"type:decimal(12,2);not null;default:0" json:"max_discount"`
+	MinOrderValue float64 `gorm:"type:decimal(12,2);not null;default:0" json:"min_order_value"`
+
+	MerchantID *uint `gorm:"index" json:"merchant_id,omitempty"`
+	CategoryID *uint `json:"category_id,omitempty"`
+	BrandID    *uint `json:"brand_id,omitempty"`
+
+	UsageLimit   uint `gorm:"not null;default:0" json:"usage_limit"`
+	PerUserLimit uint `gorm:"not null;default:0" json:"per_user_limit"`
+
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Active   bool       `gorm:"not null;default:true" json:"active"`
+
+	CreatedBy uint           `json:"created_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// InWindow reports whether the promotion can be used at t
+func (p *Promotion) InWindow(t time.Time) bool {
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !t.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// PromotionUsage is the discount a promotion gave to the items of one merchant in a draft order.
+// The usage no longer counts against limits once released.
+type PromotionUsage struct {
+	ID               uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	PromotionID      uint       `gorm:"not null;index" json:"promotion_id"`
+	UserID           uint       `gorm:"not null;index" json:"user_id"`
+	OrderID          uint       `gorm:"not null;index" json:"order_id"`
+	MerchantID       uint       `gorm:"not null" json:"merchant_id"`
+	Discount         float64    `gorm:"type:decimal(12,2);not null;default:0" json:"discount"`
+	ShippingDiscount float64    `gorm:"type:decimal(12,2);not null;default:0" json:"shipping_discount"`
+	ReleasedAt       *time.Time `json:"released_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// RefreshToken is a refresh token issued to a session. Every refresh rotates it, the
+// tokens of one session share SessionID so a reused token can revoke the whole session.
+type RefreshToken struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	TokenID   string `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	SessionID string `gorm:"type:varchar(64);not null;index" json:"session_id"`
+	UserID    uint   `gorm:"not null;index" json:"user_id"`
+	Device    string `gorm:"type:varchar(255)" json:"device"`
+	UserAgent string `gorm:"type:varchar(255)" json:"user_agent"`
+	IP        string `gorm:"type:varchar(64)" json:"ip"`
+
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+type RefundStatus string
+
+const (
+	RefundRequested RefundStatus = "REQUESTED"
+	// RefundApproved is waiting for the provider, or for a manual transfer when the provider cannot refund
+	RefundApproved  RefundStatus = "APPROVED"
+	RefundCompleted RefundStatus = "COMPLETED"
+	RefundRejected  RefundStatus = "REJECTED"
+	RefundFailed    RefundStatus = "FAILED"
+)
+
+// ActiveRefundStatuses hold refundable money and item quantity, rejected and failed refunds release it
+var ActiveRefundStatuses = []RefundStatus{RefundRequested, RefundApproved, RefundCompleted}
+
+type Refund struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	OrderID       uint         `gorm:"not null;index" json:"order_id"`
+	PaymentInfoID int64        `gorm:"not null;index" json:"payment_info_id"`
+	UserID        uint         `gorm:"not null;index" json:"user_id"`
+	Amount        float64      `gorm:"type:decimal(10,2)" json:"amount"`
+	ShippingFee   float64      `gorm:"type:decimal(10,2)" json:"shipping_fee"`
+	Reason        string       `gorm:"type:varchar(255)" json:"reason"`
+	Status        RefundStatus `gorm:"type:varchar(20);index" json:"status"`
+	Provider      string       `gorm:"type:varchar(20)" json:"provider"`
+	// Manual is set when the provider cannot refund and staff transfer the money themselves
+	Manual        bool         `gorm:"not null;default:false" json:"manual"`
+	RejectReason  string       `gorm:"type:varchar(255)" json:"reject_reason,omitempty"`
+	FailureReason string       `gorm:"type:varchar(255)" json:"failure_reason,omitempty"`
+	ReviewedBy    *uint        `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time   `json:"reviewed_at,omitempty"`
+	CompletedAt   *time.Time   `json:"completed_at,omitempty"`
+	Items         []RefundItem `gorm:"foreignKey:RefundID" json:"items"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RefundItem struct {
+	ID               uint    `gorm:"primaryKey" json:"id"`
+	RefundID         uint    `gorm:"not null;index" json:"refund_id"`
+	OrderItemID      uint    `gorm:"not null;index" json:"order_item_id"`
+	ProductVariantID uint    `gorm:"not null" json:"product_variant_id"`
+	Quantity         uint    `json:"quantity"`
+	Amount           float64 `gorm:"type:decimal(10,2)" json:"amount"`
+}
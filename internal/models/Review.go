@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+type ReviewStatus string
+
+const (
+	ReviewVisible ReviewStatus = "VISIBLE"
+	ReviewHidden  ReviewStatus = "HIDDEN"
+)
+
+// Review is the rating of a product by a user who received it, hidden reviews do not count in the product rating
+type Review struct {
+	ID        uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProductID uint          `gorm:"not null;uniqueIndex:idx_review_product_user" json:"product_id"`
+	UserID    uint          `gorm:"not null;uniqueIndex:idx_review_product_user" json:"user_id"`
+	OrderID   uint          `gorm:"not null" json:"order_id"`
+	Rating    uint8         `gorm:"not null" json:"rating"`
+	Content   string        `gorm:"type:text" json:"content"`
+	Images    []ReviewImage `gorm:"foreignKey:ReviewID;constraint:OnDelete:CASCADE" json:"images"`
+	Status    ReviewStatus  `gorm:"type:varchar(20);not null;default:'VISIBLE';index" json:"status"`
+
+	MerchantReply string     `gorm:"type:text" json:"merchant_reply,omitempty"`
+	RepliedBy     *uint      `json:"replied_by,omitempty"`
+	RepliedAt     *time.Time `json:"replied_at,omitempty"`
+
+	ModeratedBy    *uint      `json:"moderated_by,omitempty"`
+	ModeratedAt    *time.Time `json:"moderated_at,omitempty"`
+	ModerationNote string     `gorm:"type:varchar(255)" json:"moderation_note,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ReviewImage struct {
+	ID       uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReviewID uint   `gorm:"not null;index" json:"-"`
+	URL      string `gorm:"type:varchar(255);not null" json:"url"`
+}
+
+// Counted reports whether the review is part of the product rating
+func (r *Review) Counted() bool {
+	return r.Status == ReviewVisible
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+type StockMovementReason string
+
+const (
+	StockReasonReserve    StockMovementReason = "RESERVE"
+	StockReasonRelease    StockMovementReason = "RELEASE"
+	StockReasonSale       StockMovementReason = "SALE"
+	StockReasonReturn     StockMovementReason = "RETURN"
+	StockReasonAdjustment StockMovementReason = "ADJUSTMENT"
+)
+
+// OnHandStockReasons are the reasons that change product_variants.quantity.
+// RESERVE/RELEASE only hold or free stock for a draft order, so
+// available stock = SUM(delta) of every row, on hand = SUM(delta) of these rows.
+var OnHandStockReasons = []StockMovementReason{
+	StockReasonSale,
+	StockReasonReturn,
+	StockReasonAdjustment,
+}
+
+// StockMovement is one row of the stock ledger
+type StockMovement struct {
+	ID               uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProductVariantID uint                `gorm:"not null;index" json:"product_variant_id"`
+	Delta            int                 `gorm:"not null" json:"delta"`
+	Reason           StockMovementReason `gorm:"type:varchar(20);not null;index" json:"reason"`
+	OrderID          uint                `gorm:"index" json:"order_id"`
+	OrderType        OrderType           `gorm:"type:varchar(20)" json:"order_type"`
+	// AppliedAt is set once the delta has been folded into product_variants.quantity
+	AppliedAt *time.Time `gorm:"index" json:"applied_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StockLevelChange is the on-hand quantity of a variant before and after a stock write
+type StockLevelChange struct {
+	ProductVariantID uint
+	ProductID        uint
+	Before           int
+	After            int
+}
+
+// CrossedZero reports a variant that sold out or came back in stock
+func (c StockLevelChange) CrossedZero() bool {
+	return (c.Before > 0) != (c.After > 0)
+}
+
+func (r StockMovementReason) AffectsOnHand() bool {
+	for _, reason := range OnHandStockReasons {
+		if reason == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package models
+
+import "testing"
+
+func TestStockLevelChangeCrossedZero(t *testing.T) {
+	cases := []struct {
+		before, after int
+		want          bool
+	}{
+		{before: 5, after: 0, want: true},
+		{before: 1, after: -2, want: true},
+		{before: 0, after: 3, want: true},
+		{before: -1, after: 1, want: true},
+		{before: 5, after: 2, want: false},
+		{before: 0, after: -1, want: false},
+		{before: 0, after: 0, want: false},
+	}
+	for _, c := range cases {
+		change := StockLevelChange{Before: c.before, After: c.after}
+		if got := change.CrossedZero(); got != c.want {
+			t.Errorf("CrossedZero(%d -> %d) = %v, want %v", c.before, c.after, got, c.want)
+		}
+	}
+}
@@ -11,6 +11,8 @@ type Role string
 const (
 	RoleAdmin Role = "ADMIN"
 	RoleUser  Role = "USER"
+	// RoleMerchant manages the products and orders of User.MerchantID
+	RoleMerchant Role = "MERCHANT"
 )
 
 type User struct {
@@ -19,8 +21,18 @@ type User struct {
 	Email       string `gorm:"type:varchar(255);unique;notnull" json:"email"`
 	Password    string `gorm:"type:varchar(255);not null" json:"-"`
 	PhoneNumber string `gorm:"type:varchar(255)" json:"phone_number"`
-	Role        Role   `gorm:"type:enum('ADMIN','USER');default:'USER'" json:"role"`
+	Role        Role   `gorm:"type:enum('ADMIN','USER','MERCHANT');default:'USER'" json:"role"`
+	MerchantID  *uint  `gorm:"index" json:"merchant_id,omitempty"`
 	Active      uint8  `gorm:"type:TINYINT;default:1" json:"active"`
+	// EmailVerifiedAt is nil until the user confirms the verification email
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	// Login lockout after too many failed attempts
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+	LockCount      uint       `gorm:"default:0" json:"lock_count"`
+	LastLockedAt   *time.Time `json:"last_locked_at,omitempty"`
+	LastUnlockedAt *time.Time `json:"last_unlocked_at,omitempty"`
+	UnlockedBy     *uint      `json:"unlocked_by,omitempty"`
 
 	// GORM default fields
 	LastLogin *time.Time     `json:"-"`
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// WishlistItem is a product variant saved by a user, NotifyBackInStock asks for a notification when it is restocked
+type WishlistItem struct {
+	ID                uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID            uint           `gorm:"not null;uniqueIndex:idx_wishlist_user_variant" json:"user_id"`
+	ProductVariantID  uint           `gorm:"not null;uniqueIndex:idx_wishlist_user_variant;index" json:"product_variant_id"`
+	Variant           ProductVariant `gorm:"foreignKey:ProductVariantID" json:"variant"`
+	User              User           `gorm:"foreignKey:UserID" json:"-"`
+	NotifyBackInStock bool           `gorm:"not null;default:true" json:"notify_back_in_stock"`
+	// LastNotifiedAt is when the last back in stock notification was sent, used to drop duplicates
+	LastNotifiedAt *time.Time `json:"last_notified_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,11 @@
+package modules
+
+import (
+	"github.com/minh6824pro/nxrGO/api/handler/controllers"
+	"github.com/minh6824pro/nxrGO/api/middleware"
+)
+
+type AddressModule struct {
+	Controller     *controllers.AddressController
+	AuthMiddleware *middleware.AuthMiddleware
+}
@@ -8,4 +8,6 @@ import (
 type AuthModule struct {
 	AuthController *controllers.AuthController
 	AuthMiddleware *middleware.AuthMiddleware
+	JWKSController *controllers.JWKSController
+	UserController *controllers.UserController
 }
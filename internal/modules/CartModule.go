@@ -0,0 +1,12 @@
+package modules
+
+import (
+	"github.com/minh6824pro/nxrGO/api/handler/controllers"
+	"github.com/minh6824pro/nxrGO/api/middleware"
+)
+
+type CartModule struct {
+	Controller            *controllers.CartController
+	AuthMiddleware        *middleware.AuthMiddleware
+	IdempotencyMiddleware *middleware.IdempotencyMiddleware
+}
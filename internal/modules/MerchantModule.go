@@ -2,8 +2,10 @@ package modules
 
 import (
 	"github.com/minh6824pro/nxrGO/api/handler/controllers"
+	"github.com/minh6824pro/nxrGO/api/middleware"
 )
 
 type MerchantModule struct {
-	Controller *controllers.MerchantController
+	Controller     *controllers.MerchantController
+	AuthMiddleware *middleware.AuthMiddleware
 }
@@ -12,4 +12,8 @@ type OrderModule struct {
 	Service                    services.OrderService
 	AuthMiddleware             *middleware.AuthMiddleware
 	ProductVariantRedisService cache.ProductVariantRedis
+	StockLedger                services.StockLedgerService
+	IdempotencyMiddleware      *middleware.IdempotencyMiddleware
+	Idempotency                services.IdempotencyService
+	MerchantScope              *middleware.MerchantScopeMiddleware
 }
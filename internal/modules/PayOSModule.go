@@ -2,8 +2,10 @@ package modules
 
 import (
 	"github.com/minh6824pro/nxrGO/api/handler/controllers"
+	"github.com/minh6824pro/nxrGO/internal/services"
 )
 
 type PayOsModule struct {
 	Controller *controllers.WebhookController
+	Reconciler services.PaymentReconciler
 }
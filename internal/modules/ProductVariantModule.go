@@ -2,8 +2,11 @@ package modules
 
 import (
 	"github.com/minh6824pro/nxrGO/api/handler/controllers"
+	"github.com/minh6824pro/nxrGO/api/middleware"
 )
 
 type ProductVariantModule struct {
-	Controller *controllers.ProductVariantController
+	Controller     *controllers.ProductVariantController
+	AuthMiddleware *middleware.AuthMiddleware
+	MerchantScope  *middleware.MerchantScopeMiddleware
 }
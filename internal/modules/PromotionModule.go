@@ -0,0 +1,11 @@
+package modules
+
+import (
+	"github.com/minh6824pro/nxrGO/api/handler/controllers"
+	"github.com/minh6824pro/nxrGO/api/middleware"
+)
+
+type PromotionModule struct {
+	Controller     *controllers.PromotionController
+	AuthMiddleware *middleware.AuthMiddleware
+}
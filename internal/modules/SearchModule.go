@@ -0,0 +1,13 @@
+package modules
+
+import (
+	"github.com/minh6824pro/nxrGO/api/handler/controllers"
+	"github.com/minh6824pro/nxrGO/api/middleware"
+	"github.com/minh6824pro/nxrGO/internal/services"
+)
+
+type SearchModule struct {
+	Controller     *controllers.SearchController
+	Service        services.SearchIndexService
+	AuthMiddleware *middleware.AuthMiddleware
+}
@@ -0,0 +1,22 @@
+package notification
+
+import (
+	"context"
+	"log"
+)
+
+// logNotifier only writes notifications to the log, for local testing
+type logNotifier struct{}
+
+func NewLogNotifier() Notifier {
+	return &logNotifier{}
+}
+
+func (l *logNotifier) Name() string {
+	return "log"
+}
+
+func (l *logNotifier) Notify(ctx context.Context, n Notification) error {
+	log.Printf("Notification to user %d: %s - %s", n.UserID, n.Subject, n.Body)
+	return nil
+}
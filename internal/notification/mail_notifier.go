@@ -0,0 +1,31 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/mail"
+)
+
+// mailNotifier sends notifications as email through the configured mail sender
+type mailNotifier struct {
+	sender mail.Sender
+}
+
+func NewMailNotifier(sender mail.Sender) Notifier {
+	return &mailNotifier{sender: sender}
+}
+
+func (m *mailNotifier) Name() string {
+	return "mail:" + m.sender.Name()
+}
+
+func (m *mailNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Email == "" {
+		return fmt.Errorf("user %d has no email", n.UserID)
+	}
+	return m.sender.Send(ctx, mail.Message{
+		To:      n.Email,
+		Subject: n.Subject,
+		Body:    n.Body,
+	})
+}
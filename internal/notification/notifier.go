@@ -0,0 +1,16 @@
+package notification
+
+import "context"
+
+type Notification struct {
+	UserID  uint
+	Email   string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers notifications to users, the channel is chosen at startup
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
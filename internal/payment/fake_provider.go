@@ -0,0 +1,235 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// fakeWebhook is the body the fake provider posts to the webhook url
+type fakeWebhook struct {
+	PaymentID int64      `json:"paymentId"`
+	Status    LinkStatus `json:"status"`
+	Code      string     `json:"code"`
+	Reference string     `json:"reference"`
+	Signature string     `json:"signature"`
+}
+
+type fakePayment struct {
+	amount    int
+	refunded  int
+	status    LinkStatus
+	reason    string
+	expiresAt time.Time
+}
+
+// FakeProvider is a local provider without network access, outcomes are triggered with Simulate
+// and reported through HMAC signed webhooks like a real gateway.
+type FakeProvider struct {
+	secret          []byte
+	checkoutBaseURL string
+
+	mu         sync.Mutex
+	payments   map[int64]*fakePayment
+	webhookURL string
+	sink       func(ctx context.Context, body []byte) error
+	client     *http.Client
+}
+
+func NewFakeProvider(secret string, checkoutBaseURL string) *FakeProvider {
+	return &FakeProvider{
+		secret:          []byte(secret),
+		checkoutBaseURL: checkoutBaseURL,
+		payments:        make(map[int64]*fakePayment),
+		client:          &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (f *FakeProvider) Name() string {
+	return "fake"
+}
+
+// SetWebhookSink delivers webhooks to sink instead of posting them to the registered url
+func (f *FakeProvider) SetWebhookSink(sink func(ctx context.Context, body []byte) error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sink = sink
+}
+
+func (f *FakeProvider) RegisterWebhook(ctx context.Context, url string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.webhookURL = url
+	return nil
+}
+
+func (f *FakeProvider) CreateLink(ctx context.Context, req CreateLinkRequest) (*Link, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.payments[req.PaymentID]; ok {
+		return nil, fmt.Errorf("payment %d already has a link", req.PaymentID)
+	}
+	f.payments[req.PaymentID] = &fakePayment{
+		amount:    req.Amount,
+		status:    LinkPending,
+		expiresAt: req.ExpiresAt,
+	}
+	return &Link{
+		CheckoutURL: f.checkoutBaseURL + "/" + strconv.FormatInt(req.PaymentID, 10),
+		ExpiresAt:   req.ExpiresAt,
+	}, nil
+}
+
+func (f *FakeProvider) GetStatus(ctx context.Context, paymentID int64) (*Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, err := f.get(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Status: p.status, CancellationReason: p.reason}
+	if p.status == LinkPaid {
+		status.AmountPaid = p.amount
+	}
+	return status, nil
+}
+
+func (f *FakeProvider) CancelLink(ctx context.Context, paymentID int64, reason string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, err := f.get(paymentID)
+	if err != nil {
+		return err
+	}
+	if p.status != LinkPending {
+		return fmt.Errorf("payment %d is %s", paymentID, p.status)
+	}
+	p.status = LinkCancelled
+	p.reason = reason
+	return nil
+}
+
+func (f *FakeProvider) Refund(ctx context.Context, paymentID int64, amount int, reason string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, err := f.get(paymentID)
+	if err != nil {
+		return err
+	}
+	if p.status != LinkPaid {
+		return fmt.Errorf("payment %d is %s, only paid payments can be refunded", paymentID, p.status)
+	}
+	if amount <= 0 || p.refunded+amount > p.amount {
+		return fmt.Errorf("refund amount %d exceeds refundable amount %d", amount, p.amount-p.refunded)
+	}
+	p.refunded += amount
+	return nil
+}
+
+func (f *FakeProvider) VerifyWebhook(ctx context.Context, body []byte) (*WebhookResult, error) {
+	var webhook fakeWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	expected, err := hex.DecodeString(f.sign(webhook))
+	if err != nil {
+		return nil, err
+	}
+	got, err := hex.DecodeString(webhook.Signature)
+	if err != nil || !hmac.Equal(expected, got) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidWebhook)
+	}
+	return &WebhookResult{
+		PaymentID: webhook.PaymentID,
+		Success:   webhook.Code == "00",
+		Reference: webhook.Reference,
+	}, nil
+}
+
+// Simulate finishes a pending payment with outcome (PAID, CANCELLED or EXPIRED) and sends the webhook
+func (f *FakeProvider) Simulate(ctx context.Context, paymentID int64, outcome LinkStatus) error {
+	if outcome != LinkPaid && outcome != LinkCancelled && outcome != LinkExpired {
+		return fmt.Errorf("unknown outcome %s", outcome)
+	}
+
+	f.mu.Lock()
+	p, err := f.get(paymentID)
+	if err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	if p.status != LinkPending {
+		f.mu.Unlock()
+		return fmt.Errorf("payment %d is already %s", paymentID, p.status)
+	}
+	p.status = outcome
+	if outcome != LinkPaid {
+		p.reason = "Simulated " + string(outcome)
+	}
+	webhookURL, sink := f.webhookURL, f.sink
+	f.mu.Unlock()
+
+	webhook := fakeWebhook{
+		PaymentID: paymentID,
+		Status:    outcome,
+		Code:      "01",
+		Reference: fmt.Sprintf("FAKE%d", time.Now().UnixNano()),
+	}
+	if outcome == LinkPaid {
+		webhook.Code = "00"
+	}
+	webhook.Signature = f.sign(webhook)
+	body, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+
+	if sink != nil {
+		return sink(ctx, body)
+	}
+	if webhookURL == "" {
+		return fmt.Errorf("webhook url is not registered")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// get must be called with f.mu held, a pending link past its expiry is reported expired
+func (f *FakeProvider) get(paymentID int64) (*fakePayment, error) {
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	if p.status == LinkPending && !p.expiresAt.IsZero() && time.Now().After(p.expiresAt) {
+		p.status = LinkExpired
+		p.reason = "Payment link expired"
+	}
+	return p, nil
+}
+
+func (f *FakeProvider) sign(webhook fakeWebhook) string {
+	data := fmt.Sprintf("code=%s&paymentId=%d&reference=%s&status=%s", webhook.Code, webhook.PaymentID, webhook.Reference, webhook.Status)
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,124 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestFakeProvider(t *testing.T) (*FakeProvider, *[][]byte) {
+	t.Helper()
+	fake := NewFakeProvider("secret", "http://localhost/api/payments/fake")
+	var delivered [][]byte
+	fake.SetWebhookSink(func(ctx context.Context, body []byte) error {
+		delivered = append(delivered, body)
+		return nil
+	})
+	return fake, &delivered
+}
+
+func TestFakeProviderPaidWebhookVerifies(t *testing.T) {
+	ctx := context.Background()
+	fake, delivered := newTestFakeProvider(t)
+	if _, err := fake.CreateLink(ctx, CreateLinkRequest{PaymentID: 1, Amount: 10000, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+	if err := fake.Simulate(ctx, 1, LinkPaid); err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	if len(*delivered) != 1 {
+		t.Fatalf("expected 1 webhook, got %d", len(*delivered))
+	}
+
+	result, err := fake.VerifyWebhook(ctx, (*delivered)[0])
+	if err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+	if result.PaymentID != 1 || !result.Success {
+		t.Fatalf("unexpected webhook result %+v", result)
+	}
+	status, err := fake.GetStatus(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetStatus: %v", err)
+	}
+	if status.Status != LinkPaid || status.AmountPaid != 10000 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestFakeProviderRejectsWebhookSignedWithAnotherSecret(t *testing.T) {
+	ctx := context.Background()
+	other, delivered := newTestFakeProvider(t)
+	other.secret = []byte("other")
+	if _, err := other.CreateLink(ctx, CreateLinkRequest{PaymentID: 1, Amount: 10000}); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+	if err := other.Simulate(ctx, 1, LinkPaid); err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+
+	fake := NewFakeProvider("secret", "")
+	if _, err := fake.VerifyWebhook(ctx, (*delivered)[0]); !errors.Is(err, ErrInvalidWebhook) {
+		t.Fatalf("expected ErrInvalidWebhook, got %v", err)
+	}
+}
+
+func TestFakeProviderCancelledWebhookIsNotSuccess(t *testing.T) {
+	ctx := context.Background()
+	fake, delivered := newTestFakeProvider(t)
+	if _, err := fake.CreateLink(ctx, CreateLinkRequest{PaymentID: 2, Amount: 10000}); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+	if err := fake.Simulate(ctx, 2, LinkCancelled); err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	result, err := fake.VerifyWebhook(ctx, (*delivered)[0])
+	if err != nil {
+		t.Fatalf("VerifyWebhook: %v", err)
+	}
+	if result.Success {
+		t.Fatal("a cancelled payment must not be reported as success")
+	}
+	if err := fake.Simulate(ctx, 2, LinkPaid); err == nil {
+		t.Fatal("a finished payment can not be simulated again")
+	}
+}
+
+func TestFakeProviderExpiresPendingLinks(t *testing.T) {
+	ctx := context.Background()
+	fake, _ := newTestFakeProvider(t)
+	if _, err := fake.CreateLink(ctx, CreateLinkRequest{PaymentID: 3, Amount: 10000, ExpiresAt: time.Now().Add(-time.Second)}); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+	status, err := fake.GetStatus(ctx, 3)
+	if err != nil {
+		t.Fatalf("GetStatus: %v", err)
+	}
+	if status.Status != LinkExpired {
+		t.Fatalf("expected EXPIRED, got %s", status.Status)
+	}
+}
+
+func TestFakeProviderRefundIsCappedAtPaidAmount(t *testing.T) {
+	ctx := context.Background()
+	fake, _ := newTestFakeProvider(t)
+	if _, err := fake.CreateLink(ctx, CreateLinkRequest{PaymentID: 4, Amount: 10000}); err != nil {
+		t.Fatalf("CreateLink: %v", err)
+	}
+	if err := fake.Refund(ctx, 4, 1000, "before paid"); err == nil {
+		t.Fatal("a pending payment can not be refunded")
+	}
+	if err := fake.Simulate(ctx, 4, LinkPaid); err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	if err := fake.Refund(ctx, 4, 6000, "first"); err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if err := fake.Refund(ctx, 4, 5000, "second"); err == nil {
+		t.Fatal("refunds above the paid amount must fail")
+	}
+	if err := fake.Refund(ctx, 4, 4000, "rest"); err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+}
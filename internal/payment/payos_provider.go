@@ -0,0 +1,105 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/payOSHQ/payos-lib-golang"
+	"strconv"
+	"time"
+)
+
+// payOSTestReference is the reference of the webhook PayOS sends when the url is confirmed
+const payOSTestReference = "TF230204212323"
+
+type PayOSProvider struct{}
+
+func NewPayOSProvider(clientID, apiKey, checksumKey string) *PayOSProvider {
+	payos.Key(clientID, apiKey, checksumKey)
+	return &PayOSProvider{}
+}
+
+func (p *PayOSProvider) Name() string {
+	return "payos"
+}
+
+func (p *PayOSProvider) CreateLink(ctx context.Context, req CreateLinkRequest) (*Link, error) {
+	expiredAt := int(req.ExpiresAt.Unix())
+	items := make([]payos.Item, 0, len(req.Items))
+	for _, item := range req.Items {
+		items = append(items, payos.Item{Name: item.Name, Price: item.Price, Quantity: item.Quantity})
+	}
+
+	resp, err := payos.CreatePaymentLink(payos.CheckoutRequestType{
+		OrderCode:   req.PaymentID,
+		Amount:      req.Amount,
+		Items:       items,
+		Description: req.Description,
+		ReturnUrl:   req.ReturnURL,
+		CancelUrl:   req.CancelURL,
+		ExpiredAt:   &expiredAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	link := &Link{CheckoutURL: resp.CheckoutUrl, ExpiresAt: req.ExpiresAt}
+	if resp.ExpiredAt != nil {
+		link.ExpiresAt = time.Unix(int64(*resp.ExpiredAt), 0)
+	}
+	return link, nil
+}
+
+func (p *PayOSProvider) GetStatus(ctx context.Context, paymentID int64) (*Status, error) {
+	data, err := payos.GetPaymentLinkInformation(strconv.FormatInt(paymentID, 10))
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{AmountPaid: data.AmountPaid}
+	switch data.Status {
+	case "PAID":
+		status.Status = LinkPaid
+	case "CANCELLED":
+		status.Status = LinkCancelled
+	case "EXPIRED":
+		status.Status = LinkExpired
+	default:
+		status.Status = LinkPending
+	}
+	if data.CancellationReason != nil {
+		status.CancellationReason = *data.CancellationReason
+	}
+	return status, nil
+}
+
+func (p *PayOSProvider) CancelLink(ctx context.Context, paymentID int64, reason string) error {
+	_, err := payos.CancelPaymentLink(strconv.FormatInt(paymentID, 10), &reason)
+	return err
+}
+
+// Refund PayOS has no refund API, money is sent back manually from the merchant account
+func (p *PayOSProvider) Refund(ctx context.Context, paymentID int64, amount int, reason string) error {
+	return ErrRefundNotSupported
+}
+
+func (p *PayOSProvider) VerifyWebhook(ctx context.Context, body []byte) (*WebhookResult, error) {
+	var webhookBody payos.WebhookType
+	if err := json.Unmarshal(body, &webhookBody); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	data, err := payos.VerifyPaymentWebhookData(webhookBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	return &WebhookResult{
+		PaymentID: data.OrderCode,
+		Success:   data.Code == "00",
+		Reference: data.Reference,
+		Test:      data.Reference == payOSTestReference,
+	}, nil
+}
+
+func (p *PayOSProvider) RegisterWebhook(ctx context.Context, url string) error {
+	_, err := payos.ConfirmWebhook(url)
+	return err
+}
@@ -0,0 +1,73 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type LinkStatus string
+
+const (
+	LinkPending   LinkStatus = "PENDING"
+	LinkPaid      LinkStatus = "PAID"
+	LinkCancelled LinkStatus = "CANCELLED"
+	LinkExpired   LinkStatus = "EXPIRED"
+)
+
+var (
+	ErrRefundNotSupported = errors.New("refund is not supported by payment provider")
+	ErrInvalidWebhook     = errors.New("invalid webhook payload")
+	ErrPaymentNotFound    = errors.New("payment not found at provider")
+)
+
+type Item struct {
+	Name     string
+	Price    int
+	Quantity int
+}
+
+// CreateLinkRequest uses PaymentInfo.ID as the payment id known by the provider
+type CreateLinkRequest struct {
+	PaymentID   int64
+	Amount      int
+	Items       []Item
+	Description string
+	ReturnURL   string
+	CancelURL   string
+	ExpiresAt   time.Time
+}
+
+type Link struct {
+	CheckoutURL string
+	ExpiresAt   time.Time
+}
+
+type Status struct {
+	Status             LinkStatus
+	AmountPaid         int
+	CancellationReason string
+}
+
+type WebhookResult struct {
+	PaymentID int64
+	Success   bool
+	Reference string
+	// Test is set for the ping a provider sends when the webhook url is registered
+	Test bool
+}
+
+// PaymentProvider is an online payment gateway, PaymentInfo.ID identifies the payment on both sides
+type PaymentProvider interface {
+	Name() string
+	CreateLink(ctx context.Context, req CreateLinkRequest) (*Link, error)
+	GetStatus(ctx context.Context, paymentID int64) (*Status, error)
+	CancelLink(ctx context.Context, paymentID int64, reason string) error
+	Refund(ctx context.Context, paymentID int64, amount int, reason string) error
+	VerifyWebhook(ctx context.Context, body []byte) (*WebhookResult, error)
+}
+
+// WebhookRegistrar is implemented by providers that need the webhook url registered on their side
+type WebhookRegistrar interface {
+	RegisterWebhook(ctx context.Context, url string) error
+}
@@ -0,0 +1,15 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type AddressRepository interface {
+	Create(ctx context.Context, address *models.Address) error
+	GetByID(ctx context.Context, id uint) (*models.Address, error)
+	ListByUserId(ctx context.Context, userID uint) ([]*models.Address, error)
+	Update(ctx context.Context, address *models.Address) error
+	Delete(ctx context.Context, address *models.Address) error
+	SetDefault(ctx context.Context, userID uint, id uint) error
+}
@@ -10,4 +10,5 @@ type AuthRepository interface {
 	Create(user *models.User) error
 	Update(user *models.User) error
 	IsEmailExists(email string) bool
+	ListLocked() ([]*models.User, error)
 }
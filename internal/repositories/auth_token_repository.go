@@ -0,0 +1,13 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type AuthTokenRepository interface {
+	Create(ctx context.Context, token *models.AuthToken) error
+	GetByHash(ctx context.Context, purpose models.AuthTokenPurpose, tokenHash string) (*models.AuthToken, error)
+	Consume(ctx context.Context, id uint) (bool, error)
+	InvalidateByUser(ctx context.Context, userID uint, purpose models.AuthTokenPurpose) error
+}
@@ -11,7 +11,9 @@ type BrandRepository interface {
 	GetByID(ctx context.Context, id uint) (*models.Brand, error)
 	GetByIDTx(ctx context.Context, tx *gorm.DB, id uint) (*models.Brand, error)
 	Update(ctx context.Context, brand *models.Brand) error
+	UpdateTx(ctx context.Context, tx *gorm.DB, brand *models.Brand) error
 	Delete(ctx context.Context, id uint) error
+	DeleteTx(ctx context.Context, tx *gorm.DB, id uint) error
 	List(ctx context.Context) ([]models.Brand, error)
 	GetByName(ctx context.Context, name string) (*models.Brand, error)
 	CreateTx(ctx context.Context, tx *gorm.DB, brand *models.Brand) (*models.Brand, error)
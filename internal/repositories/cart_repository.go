@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type CartRepository interface {
+	ListByUserId(ctx context.Context, userID uint) ([]models.CartItem, error)
+	Upsert(ctx context.Context, item *models.CartItem) error
+	Delete(ctx context.Context, userID uint, productVariantID uint) error
+	DeleteVariants(ctx context.Context, userID uint, productVariantIDs []uint) error
+	DeleteByUserId(ctx context.Context, userID uint) error
+}
@@ -12,7 +12,9 @@ type CategoryRepository interface {
 	GetByID(ctx context.Context, id uint) (*models.Category, error)
 	GetByIDTx(ctx context.Context, tx *gorm.DB, id uint) (*models.Category, error)
 	Update(ctx context.Context, c *models.Category) error
+	UpdateTx(ctx context.Context, tx *gorm.DB, c *models.Category) error
 	Delete(ctx context.Context, id uint) error
+	DeleteTx(ctx context.Context, tx *gorm.DB, id uint) error
 	List(ctx context.Context) ([]models.Category, error)
 	GetByName(ctx context.Context, name string) (*models.Category, error)
 	GetByNameTx(ctx context.Context, tx *gorm.DB, name string) (*models.Category, error)
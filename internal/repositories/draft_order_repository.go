@@ -6,6 +6,7 @@ import (
 	"github.com/minh6824pro/nxrGO/internal/models"
 
 	"gorm.io/gorm"
+	"time"
 )
 
 type DraftOrderRepository interface {
@@ -14,8 +15,14 @@ type DraftOrderRepository interface {
 	Delete(ctx context.Context, id uint) error
 	GetById(ctx context.Context, orderID uint) (*models.DraftOrder, error)
 	Save(ctx context.Context, order *models.DraftOrder) error
+	SaveTx(ctx context.Context, tx *gorm.DB, order *models.DraftOrder) error
 	GetsForDbUpdate(ctx context.Context) ([]models.DraftOrder, error)
 	CleanDraft(ctx context.Context) error
+	ListExpiredReservations(ctx context.Context, now time.Time, legacyCreatedBefore time.Time, limit int) ([]models.DraftOrder, error)
+	ExpireReservationTx(ctx context.Context, tx *gorm.DB, draftOrderID uint, reason string) (bool, error)
+	// LockOpenTx locks the draft until tx ends, false when it is already converted or cancelled
+	LockOpenTx(ctx context.Context, tx *gorm.DB, draftOrderID uint) (bool, error)
+	CloseTx(ctx context.Context, tx *gorm.DB, draftOrderID uint) error
 	ListByUserIdToOrderNull(ctx context.Context, draftOrderID uint) ([]*models.DraftOrder, error)
 	ListByAdmin(ctx context.Context) ([]*models.DraftOrder, error)
 	GetByParentId(ctx context.Context, parentId uint) ([]models.DraftOrder, error)
@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"time"
+)
+
+type IdempotencyKeyRepository interface {
+	CreateIfAbsent(ctx context.Context, record *models.IdempotencyKey) (bool, error)
+	GetByUserIdAndKey(ctx context.Context, userID uint, key string) (*models.IdempotencyKey, error)
+	SaveResponse(ctx context.Context, id uint, responseCode int, responseBody string, expiresAt time.Time) error
+	ExtendLease(ctx context.Context, id uint, expiresAt time.Time) (bool, error)
+	Delete(ctx context.Context, id uint) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,100 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type addressGormRepository struct {
+	db *gorm.DB
+}
+
+func NewAddressGormRepository(db *gorm.DB) repositories.AddressRepository {
+	return &addressGormRepository{db}
+}
+
+func (a *addressGormRepository) Create(ctx context.Context, address *models.Address) error {
+	if err := a.db.WithContext(ctx).Create(address).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while saving address", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (a *addressGormRepository) GetByID(ctx context.Context, id uint) (*models.Address, error) {
+	var address models.Address
+	if err := a.db.WithContext(ctx).First(&address, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Address not found", http.StatusNotFound, nil)
+		}
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &address, nil
+}
+
+// ListByUserId lists the default address first
+func (a *addressGormRepository) ListByUserId(ctx context.Context, userID uint) ([]*models.Address, error) {
+	var addresses []*models.Address
+	if err := a.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("is_default DESC, updated_at DESC").
+		Find(&addresses).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return addresses, nil
+}
+
+func (a *addressGormRepository) Update(ctx context.Context, address *models.Address) error {
+	if err := a.db.WithContext(ctx).Save(address).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while saving address", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// Delete removes the address, a deleted default address hands the flag to the latest updated one
+func (a *addressGormRepository) Delete(ctx context.Context, address *models.Address) error {
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Address{}, address.ID).Error; err != nil {
+			return err
+		}
+		if !address.IsDefault {
+			return nil
+		}
+		var next models.Address
+		if err := tx.Where("user_id = ?", address.UserID).
+			Order("updated_at DESC").
+			First(&next).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		return tx.Model(&next).Update("is_default", true).Error
+	})
+	if err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while deleting address", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// SetDefault makes the address the only default address of the user
+func (a *addressGormRepository) SetDefault(ctx context.Context, userID uint, id uint) error {
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Address{}).
+			Where("user_id = ? AND is_default = ? AND id <> ?", userID, true, id).
+			Update("is_default", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Address{}).
+			Where("id = ? AND user_id = ?", id, userID).
+			Update("is_default", true).Error
+	})
+	if err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/repositories"
 	"gorm.io/gorm"
+	"time"
 )
 
 type authRepository struct {
@@ -43,3 +44,14 @@ func (r *authRepository) IsEmailExists(email string) bool {
 	r.db.Model(&models.User{}).Where("email = ?", email).Count(&count)
 	return count > 0
 }
+
+// ListLocked returns users whose login lockout has not expired yet
+func (r *authRepository) ListLocked() ([]*models.User, error) {
+	var users []*models.User
+	if err := r.db.Where("locked_until > ?", time.Now()).
+		Order("locked_until DESC").
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
@@ -0,0 +1,63 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+type authTokenGormRepository struct {
+	db *gorm.DB
+}
+
+func NewAuthTokenGormRepository(db *gorm.DB) repositories.AuthTokenRepository {
+	return &authTokenGormRepository{db}
+}
+
+func (a *authTokenGormRepository) Create(ctx context.Context, token *models.AuthToken) error {
+	if err := a.db.WithContext(ctx).Create(token).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while saving token", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (a *authTokenGormRepository) GetByHash(ctx context.Context, purpose models.AuthTokenPurpose, tokenHash string) (*models.AuthToken, error) {
+	var token models.AuthToken
+	if err := a.db.WithContext(ctx).
+		Where("purpose = ? AND token_hash = ?", purpose, tokenHash).
+		First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.INVALID_INPUT, "Invalid or expired token", http.StatusBadRequest, nil)
+		}
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &token, nil
+}
+
+// Consume marks the token used, false means it was used or expired in the meantime
+func (a *authTokenGormRepository) Consume(ctx context.Context, id uint) (bool, error) {
+	res := a.db.WithContext(ctx).
+		Model(&models.AuthToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, time.Now()).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return false, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// InvalidateByUser burns the unused tokens of the user, only the latest mail stays valid
+func (a *authTokenGormRepository) InvalidateByUser(ctx context.Context, userID uint, purpose models.AuthTokenPurpose) error {
+	if err := a.db.WithContext(ctx).
+		Model(&models.AuthToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
@@ -68,7 +68,11 @@ func (r *brandGormRepository) GetByIDTx(ctx context.Context, tx *gorm.DB, id uin
 }
 
 func (r *brandGormRepository) Update(ctx context.Context, b *models.Brand) error {
-	if err := r.db.WithContext(ctx).Save(b).Error; err != nil {
+	return r.UpdateTx(ctx, r.db, b)
+}
+
+func (r *brandGormRepository) UpdateTx(ctx context.Context, tx *gorm.DB, b *models.Brand) error {
+	if err := tx.WithContext(ctx).Save(b).Error; err != nil {
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) {
 			if mysqlErr.Number == 1062 {
@@ -81,7 +85,11 @@ func (r *brandGormRepository) Update(ctx context.Context, b *models.Brand) error
 }
 
 func (r *brandGormRepository) Delete(ctx context.Context, id uint) error {
-	if err := r.db.WithContext(ctx).Delete(&models.Merchant{}, id).Error; err != nil {
+	return r.DeleteTx(ctx, r.db, id)
+}
+
+func (r *brandGormRepository) DeleteTx(ctx context.Context, tx *gorm.DB, id uint) error {
+	if err := tx.WithContext(ctx).Delete(&models.Brand{}, id).Error; err != nil {
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) {
 			if mysqlErr.Number == 1451 {
@@ -0,0 +1,73 @@
+package impl
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+)
+
+type cartGormRepository struct {
+	db *gorm.DB
+}
+
+func NewCartGormRepository(db *gorm.DB) repositories.CartRepository {
+	return &cartGormRepository{db}
+}
+
+func (c *cartGormRepository) ListByUserId(ctx context.Context, userID uint) ([]models.CartItem, error) {
+	var items []models.CartItem
+	if err := c.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&items).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return items, nil
+}
+
+// Upsert sets quantity and price of the line, creating it if the variant is not in the cart yet
+func (c *cartGormRepository) Upsert(ctx context.Context, item *models.CartItem) error {
+	if err := c.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "product_variant_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"quantity", "price_at_add", "updated_at"}),
+		}).
+		Create(item).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while saving cart", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (c *cartGormRepository) Delete(ctx context.Context, userID uint, productVariantID uint) error {
+	if err := c.db.WithContext(ctx).
+		Where("user_id = ? AND product_variant_id = ?", userID, productVariantID).
+		Delete(&models.CartItem{}).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (c *cartGormRepository) DeleteVariants(ctx context.Context, userID uint, productVariantIDs []uint) error {
+	if len(productVariantIDs) == 0 {
+		return nil
+	}
+	if err := c.db.WithContext(ctx).
+		Where("user_id = ? AND product_variant_id IN ?", userID, productVariantIDs).
+		Delete(&models.CartItem{}).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (c *cartGormRepository) DeleteByUserId(ctx context.Context, userID uint) error {
+	if err := c.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Delete(&models.CartItem{}).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
@@ -69,7 +69,11 @@ func (r *categoryGormRepository) GetByIDTx(ctx context.Context, tx *gorm.DB, id
 }
 
 func (r *categoryGormRepository) Update(ctx context.Context, c *models.Category) error {
-	if err := r.db.WithContext(ctx).Save(c).Error; err != nil {
+	return r.UpdateTx(ctx, r.db, c)
+}
+
+func (r *categoryGormRepository) UpdateTx(ctx context.Context, tx *gorm.DB, c *models.Category) error {
+	if err := tx.WithContext(ctx).Save(c).Error; err != nil {
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
 			return customErr.NewError(customErr.DUPLICATED_ERROR, "Category already exists", http.StatusBadRequest, nil)
@@ -80,7 +84,11 @@ func (r *categoryGormRepository) Update(ctx context.Context, c *models.Category)
 }
 
 func (r *categoryGormRepository) Delete(ctx context.Context, id uint) error {
-	if err := r.db.WithContext(ctx).Delete(&models.Category{}, id).Error; err != nil {
+	return r.DeleteTx(ctx, r.db, id)
+}
+
+func (r *categoryGormRepository) DeleteTx(ctx context.Context, tx *gorm.DB, id uint) error {
+	if err := tx.WithContext(ctx).Delete(&models.Category{}, id).Error; err != nil {
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) {
 			if mysqlErr.Number == 1451 {
@@ -9,7 +9,9 @@ import (
 	"github.com/minh6824pro/nxrGO/internal/repositories"
 	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"net/http"
+	"time"
 )
 
 type draftOrderGormRepository struct {
@@ -35,7 +37,11 @@ func (d draftOrderGormRepository) Create(ctx context.Context, order *models.Draf
 }
 
 func (d draftOrderGormRepository) Save(ctx context.Context, order *models.DraftOrder) error {
-	if err := d.db.Save(order).Error; err != nil {
+	return d.SaveTx(ctx, d.db, order)
+}
+
+func (d draftOrderGormRepository) SaveTx(ctx context.Context, tx *gorm.DB, order *models.DraftOrder) error {
+	if err := tx.Save(order).Error; err != nil {
 		return customErr.NewError(
 			customErr.INTERNAL_ERROR,
 			"Unexpected error while save order",
@@ -98,6 +104,107 @@ func (d draftOrderGormRepository) GetsForDbUpdate(ctx context.Context) ([]models
 	return draftOrders, nil
 }
 
+// ListExpiredReservations returns unconverted drafts whose reservation passed its expiry.
+// BANK drafts created before reservations had an expiry are picked once older than legacyCreatedBefore.
+func (d draftOrderGormRepository) ListExpiredReservations(ctx context.Context, now time.Time, legacyCreatedBefore time.Time, limit int) ([]models.DraftOrder, error) {
+	var drafts []models.DraftOrder
+	err := d.db.WithContext(ctx).
+		Where("to_order IS NULL").
+		Where("reservation_expires_at <= ? OR (reservation_expires_at IS NULL AND payment_method = ? AND created_at <= ?)",
+			now, models.PaymentMethodBank, legacyCreatedBefore).
+		Preload("OrderItems").
+		Order("id ASC").
+		Limit(limit).
+		Find(&drafts).Error
+	if err != nil {
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return drafts, nil
+}
+
+// ExpireReservationTx marks the draft expired and cancels its pending payments.
+// Rows are read with FOR UPDATE so a payment finalized concurrently is seen: if any payment
+// already succeeded, or the draft was converted/cancelled, nothing is changed and false is returned.
+func (d draftOrderGormRepository) ExpireReservationTx(ctx context.Context, tx *gorm.DB, draftOrderID uint, reason string) (bool, error) {
+	tx = tx.WithContext(ctx)
+
+	var draft models.DraftOrder
+	res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND to_order IS NULL", draftOrderID).
+		Limit(1).
+		Find(&draft)
+	if res.Error != nil {
+		return false, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+
+	var payments []models.PaymentInfo
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND order_type = ?", draftOrderID, models.OrderTypeDraftOrder).
+		Find(&payments).Error; err != nil {
+		return false, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	for _, p := range payments {
+		if p.Status == models.PaymentSuccess {
+			return false, nil
+		}
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.PaymentInfo{}).
+		Where("order_id = ? AND order_type = ? AND status = ?", draftOrderID, models.OrderTypeDraftOrder, models.PaymentPending).
+		Updates(map[string]interface{}{
+			"status":              models.PaymentCanceled,
+			"cancellation_reason": reason,
+			"cancellation_at":     now,
+			"next_check_at":       nil,
+		}).Error; err != nil {
+		return false, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+
+	val := uint(0)
+	if err := tx.Model(&models.DraftOrder{}).
+		Where("id = ?", draftOrderID).
+		Updates(map[string]interface{}{
+			"to_order":      &val,
+			"status":        models.OrderStateCancelled,
+			"expired_at":    now,
+			"expiry_reason": reason,
+		}).Error; err != nil {
+		return false, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return true, nil
+}
+
+// LockOpenTx reads the draft with FOR UPDATE and to_order IS NULL, converting or cancelling a draft
+// takes this lock first so only one of them closes it
+func (d draftOrderGormRepository) LockOpenTx(ctx context.Context, tx *gorm.DB, draftOrderID uint) (bool, error) {
+	var draft models.DraftOrder
+	res := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("id = ? AND to_order IS NULL", draftOrderID).
+		Limit(1).
+		Find(&draft)
+	if res.Error != nil {
+		return false, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// CloseTx marks the draft as never becoming an order, callers hold the lock taken by LockOpenTx
+func (d draftOrderGormRepository) CloseTx(ctx context.Context, tx *gorm.DB, draftOrderID uint) error {
+	if err := tx.WithContext(ctx).
+		Model(&models.DraftOrder{}).
+		Where("id = ?", draftOrderID).
+		Update("to_order", 0).Error; err != nil {
+		return customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
 func (d draftOrderGormRepository) CleanDraft(ctx context.Context) error {
 	// Get drafts to remove
 	var drafts []models.DraftOrder
@@ -0,0 +1,89 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"time"
+)
+
+type idempotencyKeyGormRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyGormRepository(db *gorm.DB) repositories.IdempotencyKeyRepository {
+	return &idempotencyKeyGormRepository{db}
+}
+
+// CreateIfAbsent inserts the record, false means the user already used this key
+func (i *idempotencyKeyGormRepository) CreateIfAbsent(ctx context.Context, record *models.IdempotencyKey) (bool, error) {
+	res := i.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(record)
+	if res.Error != nil {
+		return false, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while saving idempotency key", http.StatusInternalServerError, res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (i *idempotencyKeyGormRepository) GetByUserIdAndKey(ctx context.Context, userID uint, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	if err := i.db.WithContext(ctx).
+		Where("user_id = ? AND `key` = ?", userID, key).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Idempotency key not found", http.StatusNotFound, nil)
+		}
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &record, nil
+}
+
+func (i *idempotencyKeyGormRepository) SaveResponse(ctx context.Context, id uint, responseCode int, responseBody string, expiresAt time.Time) error {
+	if err := i.db.WithContext(ctx).
+		Model(&models.IdempotencyKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":        models.IdempotencyCompleted,
+			"response_code": responseCode,
+			"response_body": responseBody,
+			"expires_at":    expiresAt,
+		}).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while saving idempotent response", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// ExtendLease pushes the lease of an in-progress key forward, false means the key was completed or taken over
+func (i *idempotencyKeyGormRepository) ExtendLease(ctx context.Context, id uint, expiresAt time.Time) (bool, error) {
+	res := i.db.WithContext(ctx).
+		Model(&models.IdempotencyKey{}).
+		Where("id = ? AND status = ?", id, models.IdempotencyInProgress).
+		Update("expires_at", expiresAt)
+	if res.Error != nil {
+		return false, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while extending idempotency lease", http.StatusInternalServerError, res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (i *idempotencyKeyGormRepository) Delete(ctx context.Context, id uint) error {
+	if err := i.db.WithContext(ctx).Delete(&models.IdempotencyKey{}, id).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (i *idempotencyKeyGormRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	res := i.db.WithContext(ctx).
+		Where("expires_at <= ?", time.Now()).
+		Delete(&models.IdempotencyKey{})
+	if res.Error != nil {
+		return 0, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, res.Error)
+	}
+	return res.RowsAffected, nil
+}
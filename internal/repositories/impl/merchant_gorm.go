@@ -70,8 +70,11 @@ func (r *merchantGormRepository) GetByIDTx(ctx context.Context, tx *gorm.DB, id
 }
 
 func (r *merchantGormRepository) Update(ctx context.Context, m *models.Merchant) error {
+	return r.UpdateTx(ctx, r.db, m)
+}
 
-	if err := r.db.WithContext(ctx).Save(m).Error; err != nil {
+func (r *merchantGormRepository) UpdateTx(ctx context.Context, tx *gorm.DB, m *models.Merchant) error {
+	if err := tx.WithContext(ctx).Save(m).Error; err != nil {
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) {
 			if mysqlErr.Number == 1062 {
@@ -84,7 +87,11 @@ func (r *merchantGormRepository) Update(ctx context.Context, m *models.Merchant)
 }
 
 func (r *merchantGormRepository) Delete(ctx context.Context, id uint) error {
-	if err := r.db.WithContext(ctx).Delete(&models.Merchant{}, id).Error; err != nil {
+	return r.DeleteTx(ctx, r.db, id)
+}
+
+func (r *merchantGormRepository) DeleteTx(ctx context.Context, tx *gorm.DB, id uint) error {
+	if err := tx.WithContext(ctx).Delete(&models.Merchant{}, id).Error; err != nil {
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) {
 			if mysqlErr.Number == 1451 {
@@ -8,6 +8,7 @@ import (
 	"github.com/minh6824pro/nxrGO/internal/repositories"
 	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"net/http"
 )
 
@@ -87,7 +88,11 @@ func (o orderGormRepository) GetById(ctx context.Context, orderID uint) (*models
 }
 
 func (o orderGormRepository) Update(ctx context.Context, order *models.Order) error {
-	if err := o.db.WithContext(ctx).Save(order).Error; err != nil {
+	return o.UpdateTx(ctx, o.db, order)
+}
+
+func (o orderGormRepository) UpdateTx(ctx context.Context, tx *gorm.DB, order *models.Order) error {
+	if err := tx.WithContext(ctx).Save(order).Error; err != nil {
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) {
 			if mysqlErr.Number == 1062 {
@@ -99,6 +104,36 @@ func (o orderGormRepository) Update(ctx context.Context, order *models.Order) er
 	return nil
 }
 
+// GetByIdForUpdateTx locks the order row so item cancels and returns of the same order are serialized
+func (o orderGormRepository) GetByIdForUpdateTx(ctx context.Context, tx *gorm.DB, orderID uint) (*models.Order, error) {
+	var m models.Order
+	if err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", orderID).
+		Preload("OrderItems").
+		Preload("PaymentInfos", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at DESC")
+		}).First(&m).Error; err != nil {
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Order not found", http.StatusNotFound, nil)
+		}
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+
+	return &m, nil
+}
+
+func (o orderGormRepository) UpdateStatusTx(ctx context.Context, tx *gorm.DB, orderID uint, status models.OrderStatus) error {
+	if err := tx.WithContext(ctx).
+		Model(&models.Order{}).
+		Where("id = ?", orderID).
+		Update("status", status).Error; err != nil {
+		return customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
 func (o orderGormRepository) GetsByStatusAndUserId(ctx context.Context, status models.OrderStatus, userId uint) ([]*models.Order, error) {
 	var orders []*models.Order
 	err := o.db.WithContext(ctx).
@@ -145,6 +180,47 @@ func (o orderGormRepository) ListByAdmin(ctx context.Context) ([]*models.Order,
 	return orders, nil
 }
 
+// merchantOrderItems selects order items of orders with the products of a merchant
+const merchantOrderItems = `SELECT order_items.order_id FROM order_items
+JOIN product_variants ON product_variants.id = order_items.product_variant_id
+JOIN products ON products.id = product_variants.product_id
+WHERE order_items.order_type = ? AND products.merchant_id = ?`
+
+// ListByMerchant lists orders holding items of the merchant. Split parents keep no items,
+// so a merchant only sees the sub orders created for it.
+func (o orderGormRepository) ListByMerchant(ctx context.Context, merchantID uint) ([]*models.Order, error) {
+	var orders []*models.Order
+	err := o.db.WithContext(ctx).
+		Preload("OrderItems").
+		Preload("OrderItems.Variant").
+		Preload("OrderItems.Variant.OptionValues").
+		Preload("PaymentInfos", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at DESC")
+		}).
+		Where("id IN ("+merchantOrderItems+")", models.OrderTypeOrder, merchantID).
+		Order("created_at DESC").
+		Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ListMerchantIDs returns the distinct merchants of the items of an order
+func (o orderGormRepository) ListMerchantIDs(ctx context.Context, orderID uint) ([]uint, error) {
+	var merchantIDs []uint
+	if err := o.db.WithContext(ctx).
+		Table("order_items").
+		Joins("JOIN product_variants ON product_variants.id = order_items.product_variant_id").
+		Joins("JOIN products ON products.id = product_variants.product_id").
+		Where("order_items.order_id = ? AND order_items.order_type = ?", orderID, models.OrderTypeOrder).
+		Distinct().
+		Pluck("products.merchant_id", &merchantIDs).Error; err != nil {
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return merchantIDs, nil
+}
+
 func (o orderGormRepository) Save(ctx context.Context, order *models.Order) error {
 	// Nếu muốn vừa insert vừa update: GORM Save() sẽ tự xử lý dựa vào PK
 	if err := o.db.WithContext(ctx).Save(order).Error; err != nil {
@@ -33,6 +33,25 @@ func (o *orderItemGormRepository) Create(ctx context.Context, orderItem *models.
 	return nil
 }
 func (o *orderItemGormRepository) Save(ctx context.Context, orderItem *models.OrderItem) error {
-	return o.db.WithContext(ctx).Save(orderItem).Error
+	return o.SaveTx(ctx, o.db, orderItem)
+}
+
+func (o *orderItemGormRepository) SaveTx(ctx context.Context, tx *gorm.DB, orderItem *models.OrderItem) error {
+	return tx.WithContext(ctx).Save(orderItem).Error
+}
 
+// UpdateStatusTx writes the status and the cancelled and returned quantities of the item
+func (o *orderItemGormRepository) UpdateStatusTx(ctx context.Context, tx *gorm.DB, orderItem *models.OrderItem) error {
+	if err := tx.WithContext(ctx).
+		Model(&models.OrderItem{}).
+		Where("id = ?", orderItem.ID).
+		Updates(map[string]interface{}{
+			"status":                    orderItem.Status,
+			"cancelled_quantity":        orderItem.CancelledQuantity,
+			"return_requested_quantity": orderItem.ReturnRequestedQuantity,
+			"returned_quantity":         orderItem.ReturnedQuantity,
+		}).Error; err != nil {
+		return errors.NewError(errors.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
 }
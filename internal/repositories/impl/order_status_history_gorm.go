@@ -0,0 +1,40 @@
+package impl
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type orderStatusHistoryGormRepository struct {
+	db *gorm.DB
+}
+
+func NewOrderStatusHistoryGormRepository(db *gorm.DB) repositories.OrderStatusHistoryRepository {
+	return &orderStatusHistoryGormRepository{db}
+}
+
+func (o *orderStatusHistoryGormRepository) Create(ctx context.Context, history *models.OrderStatusHistory) error {
+	return o.CreateTx(ctx, o.db, history)
+}
+
+func (o *orderStatusHistoryGormRepository) CreateTx(ctx context.Context, tx *gorm.DB, history *models.OrderStatusHistory) error {
+	if err := tx.WithContext(ctx).Create(history).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while recording order history", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (o *orderStatusHistoryGormRepository) ListByOrderId(ctx context.Context, orderID uint) ([]models.OrderStatusHistory, error) {
+	var history []models.OrderStatusHistory
+	if err := o.db.WithContext(ctx).
+		Where("order_id = ?", orderID).
+		Order("created_at ASC, id ASC").
+		Find(&history).Error; err != nil {
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return history, nil
+}
@@ -0,0 +1,159 @@
+package impl
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"time"
+)
+
+const outboxLastErrorMaxLen = 500
+
+type outboxGormRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxGormRepository(db *gorm.DB) repositories.OutboxRepository {
+	return &outboxGormRepository{db}
+}
+
+func (o *outboxGormRepository) Create(ctx context.Context, event *models.OutboxEvent) error {
+	return o.CreateTx(ctx, o.db, event)
+}
+
+func (o *outboxGormRepository) CreateTx(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error {
+	if event.Status == "" {
+		event.Status = models.OutboxPending
+	}
+	if event.NextAttemptAt.IsZero() {
+		event.NextAttemptAt = time.Now()
+	}
+	if err := tx.WithContext(ctx).Create(event).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while writing outbox event", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// ClaimDue locks due events, moves them to PROCESSING with a lease and counts the attempt.
+// A PROCESSING event whose lease expired (relay crashed mid delivery) is claimed again.
+func (o *outboxGormRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	if limit <= 0 {
+		return events, nil
+	}
+
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= ?", []models.OutboxStatus{models.OutboxPending, models.OutboxProcessing}, now).
+			Order("id ASC").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, 0, len(events))
+		for i := range events {
+			ids = append(ids, events[i].ID)
+			events[i].Status = models.OutboxProcessing
+			events[i].Attempts++
+			events[i].NextAttemptAt = now.Add(lease)
+		}
+
+		return tx.Model(&models.OutboxEvent{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":          models.OutboxProcessing,
+				"attempts":        gorm.Expr("attempts + 1"),
+				"next_attempt_at": now.Add(lease),
+			}).Error
+	})
+	if err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Claim outbox events failed", http.StatusInternalServerError, err)
+	}
+	return events, nil
+}
+
+func (o *outboxGormRepository) MarkDelivered(ctx context.Context, id uint) error {
+	now := time.Now()
+	return o.update(ctx, id, map[string]interface{}{
+		"status":       models.OutboxDelivered,
+		"delivered_at": &now,
+		"last_error":   "",
+	})
+}
+
+func (o *outboxGormRepository) MarkRetry(ctx context.Context, id uint, nextAttemptAt time.Time, lastErr string) error {
+	return o.update(ctx, id, map[string]interface{}{
+		"status":          models.OutboxPending,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      truncate(lastErr, outboxLastErrorMaxLen),
+	})
+}
+
+func (o *outboxGormRepository) MarkDead(ctx context.Context, id uint, lastErr string) error {
+	return o.update(ctx, id, map[string]interface{}{
+		"status":     models.OutboxDead,
+		"last_error": truncate(lastErr, outboxLastErrorMaxLen),
+	})
+}
+
+func (o *outboxGormRepository) Stats(ctx context.Context, eventType string) (*models.OutboxStats, error) {
+	var row struct {
+		Pending         int64
+		Dead            int64
+		OldestPendingAt *time.Time
+	}
+	waiting := []models.OutboxStatus{models.OutboxPending, models.OutboxProcessing}
+	if err := o.db.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Select("COUNT(CASE WHEN status IN ? THEN 1 END) AS pending, "+
+			"COUNT(CASE WHEN status = ? THEN 1 END) AS dead, "+
+			"MIN(CASE WHEN status IN ? THEN created_at END) AS oldest_pending_at",
+			waiting, models.OutboxDead, waiting).
+		Where("event_type = ?", eventType).
+		Scan(&row).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &models.OutboxStats{
+		Pending:         row.Pending,
+		Dead:            row.Dead,
+		OldestPendingAt: row.OldestPendingAt,
+	}, nil
+}
+
+func (o *outboxGormRepository) ListSince(ctx context.Context, eventType string, since time.Time) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	if err := o.db.WithContext(ctx).
+		Where("event_type = ? AND created_at >= ?", eventType, since).
+		Order("id ASC").
+		Find(&events).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return events, nil
+}
+
+func (o *outboxGormRepository) update(ctx context.Context, id uint, fields map[string]interface{}) error {
+	if err := o.db.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(fields).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while updating outbox event", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/minh6824pro/nxrGO/internal/repositories"
 	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"net/http"
+	"time"
 )
 
 type paymentInfoGormRepository struct {
@@ -22,14 +24,22 @@ func NewPaymentInfoGormImpl(db *gorm.DB) repositories.PaymentInfoRepository {
 }
 
 func (p paymentInfoGormRepository) Create(ctx context.Context, payment *models.PaymentInfo) error {
-	if err := p.db.Create(&payment).Error; err != nil {
+	return p.CreateTx(ctx, p.db, payment)
+}
+
+func (p paymentInfoGormRepository) CreateTx(ctx context.Context, tx *gorm.DB, payment *models.PaymentInfo) error {
+	if err := tx.Create(&payment).Error; err != nil {
 		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected Error", http.StatusInternalServerError, err)
 	}
 	return nil
 }
 
 func (p paymentInfoGormRepository) Save(ctx context.Context, payment *models.PaymentInfo) error {
-	if err := p.db.WithContext(ctx).Save(payment).Error; err != nil {
+	return p.SaveTx(ctx, p.db, payment)
+}
+
+func (p paymentInfoGormRepository) SaveTx(ctx context.Context, tx *gorm.DB, payment *models.PaymentInfo) error {
+	if err := tx.WithContext(ctx).Save(payment).Error; err != nil {
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) {
 			if mysqlErr.Number == 1062 {
@@ -53,6 +63,96 @@ func (p paymentInfoGormRepository) GetByID(ctx context.Context, paymentInfoID in
 	return &pm, nil
 }
 
+// GetByIDForUpdateTx locks the payment row so refunds of the same payment are serialized
+func (p paymentInfoGormRepository) GetByIDForUpdateTx(ctx context.Context, tx *gorm.DB, paymentInfoID int64) (*models.PaymentInfo, error) {
+	var pm models.PaymentInfo
+	if err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&pm, paymentInfoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Payment not found", http.StatusNotFound, nil)
+		}
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &pm, nil
+}
+
+// CompareAndSetStatus writes the new status only if the stored one is still `from`,
+// false means another caller (webhook or reconciliation poll) already finalized the payment.
+func (p paymentInfoGormRepository) CompareAndSetStatus(ctx context.Context, payment *models.PaymentInfo, from models.PaymentStatus) (bool, error) {
+	return p.CompareAndSetStatusTx(ctx, p.db, payment, from)
+}
+
+func (p paymentInfoGormRepository) CompareAndSetStatusTx(ctx context.Context, tx *gorm.DB, payment *models.PaymentInfo, from models.PaymentStatus) (bool, error) {
+	res := tx.WithContext(ctx).
+		Model(&models.PaymentInfo{}).
+		Where("id = ? AND status = ?", payment.ID, from).
+		Updates(map[string]interface{}{
+			"status":              payment.Status,
+			"cancellation_reason": payment.CancellationReason,
+			"cancellation_at":     payment.CancellationAt,
+			"next_check_at":       nil,
+		})
+	if res.Error != nil {
+		return false, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	payment.NextCheckAt = nil
+	return true, nil
+}
+
+// ClaimDueForCheck returns pending provider payments whose check time has passed and pushes
+// their check time forward by lease so they are not picked again while being checked.
+// Payments never scheduled are picked once they are older than createdBefore.
+func (p paymentInfoGormRepository) ClaimDueForCheck(ctx context.Context, limit int, lease time.Duration, createdBefore time.Time) ([]models.PaymentInfo, error) {
+	var payments []models.PaymentInfo
+	if limit <= 0 {
+		return payments, nil
+	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND payment_link <> ''", models.PaymentPending).
+			Where("next_check_at <= ? OR (next_check_at IS NULL AND created_at <= ?)", now, createdBefore).
+			Order("next_check_at ASC").
+			Limit(limit).
+			Find(&payments).Error; err != nil {
+			return err
+		}
+		if len(payments) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, 0, len(payments))
+		for i := range payments {
+			ids = append(ids, payments[i].ID)
+			payments[i].CheckAttempts++
+		}
+		return tx.Model(&models.PaymentInfo{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"next_check_at":  now.Add(lease),
+				"check_attempts": gorm.Expr("check_attempts + 1"),
+			}).Error
+	})
+	if err != nil {
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return payments, nil
+}
+
+func (p paymentInfoGormRepository) ScheduleCheck(ctx context.Context, paymentInfoID int64, at time.Time) error {
+	if err := p.db.WithContext(ctx).
+		Model(&models.PaymentInfo{}).
+		Where("id = ? AND status = ?", paymentInfoID, models.PaymentPending).
+		Update("next_check_at", at).Error; err != nil {
+		return customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
 func (p paymentInfoGormRepository) GetByIdAndUserIdAndOrderId(
 	c *gin.Context,
 	paymentId int64, userId, orderId uint,
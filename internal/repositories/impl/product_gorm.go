@@ -67,7 +67,11 @@ func (r *productGormRepository) Update(ctx context.Context, p *models.Product) e
 }
 
 func (r *productGormRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&models.Product{}, id).Error
+	return r.DeleteTx(ctx, r.db, id)
+}
+
+func (r *productGormRepository) DeleteTx(ctx context.Context, tx *gorm.DB, id uint) error {
+	return tx.WithContext(ctx).Delete(&models.Product{}, id).Error
 }
 
 func (r *productGormRepository) List(ctx context.Context) ([]models.Product, error) {
@@ -122,6 +126,30 @@ func (r *productGormRepository) GetAllProductId(ctx context.Context) ([]uint, er
 	}
 	return ids, nil
 }
+func (r *productGormRepository) GetIdsByReference(ctx context.Context, column string, id uint) ([]uint, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).
+		Model(&models.Product{}).
+		Where(column+" = ?", id).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *productGormRepository) GetIdsByOrder(ctx context.Context, orderID uint) ([]uint, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).
+		Table("order_items oi").
+		Distinct("pv.product_id").
+		Joins("JOIN product_variants pv ON pv.id = oi.product_variant_id").
+		Where("oi.order_id = ? AND oi.order_type = ?", orderID, models.OrderTypeOrder).
+		Pluck("pv.product_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 func (r *productGormRepository) GetByIdPreloadVariant(ctx context.Context, id uint) (*models.Product, error) {
 	var p models.Product
 	err := r.db.WithContext(ctx).
@@ -287,3 +315,18 @@ func (r *productGormRepository) GetProductListFilterOptimized(
 	totalPage := int((totalItem + int64(pageSize) - 1) / int64(pageSize))
 	return results, totalPage, nil
 }
+
+// GetMerchantID returns the merchant owning the product
+func (r *productGormRepository) GetMerchantID(ctx context.Context, id uint) (uint, error) {
+	var merchantIDs []uint
+	if err := r.db.WithContext(ctx).
+		Model(&models.Product{}).
+		Where("id = ?", id).
+		Pluck("merchant_id", &merchantIDs).Error; err != nil {
+		return 0, errors.NewError(errors.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	if len(merchantIDs) == 0 {
+		return 0, errors.NewError(errors.ITEM_NOT_FOUND, fmt.Sprintf("Product id %d not found", id), http.StatusNotFound, nil)
+	}
+	return merchantIDs[0], nil
+}
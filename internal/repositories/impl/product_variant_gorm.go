@@ -24,7 +24,11 @@ func NewProductVariantGormRepository(db *gorm.DB) repositories.ProductVariantRep
 }
 
 func (r *productVariantRepository) Create(ctx context.Context, variant *models.ProductVariant) (*models.ProductVariant, error) {
-	if err := r.db.WithContext(ctx).Create(variant).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := r.CreateWithTx(ctx, tx, variant)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 	return variant, nil
@@ -34,6 +38,10 @@ func (r *productVariantRepository) CreateWithTx(ctx context.Context, tx *gorm.DB
 	if err := tx.WithContext(ctx).Create(variant).Error; err != nil {
 		return nil, err
 	}
+	// Opening balance of the stock ledger
+	if err := recordAdjustmentTx(tx.WithContext(ctx), variant.ID, int(variant.Quantity)); err != nil {
+		return nil, err
+	}
 	return variant, nil
 }
 func (r *productVariantRepository) GetByID(ctx context.Context, id uint) (*models.ProductVariant, error) {
@@ -65,6 +73,21 @@ func (r *productVariantRepository) Update(ctx context.Context, variant *models.P
 	return r.db.WithContext(ctx).Save(variant).Error
 }
 
+// UpdatePrice only writes the price, quantity is owned by the stock ledger
+func (r *productVariantRepository) UpdatePrice(ctx context.Context, id uint, price float64) error {
+	return r.UpdatePriceTx(ctx, r.db, id, price)
+}
+
+func (r *productVariantRepository) UpdatePriceTx(ctx context.Context, tx *gorm.DB, id uint, price float64) error {
+	if err := tx.WithContext(ctx).
+		Model(&models.ProductVariant{}).
+		Where("id = ?", id).
+		UpdateColumn("price", price).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Product Variant Update Failed", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
 func (r *productVariantRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(&models.ProductVariant{}, id).Error
 }
@@ -234,52 +257,36 @@ func (r *productVariantRepository) GetByIDForRedisCache(ctx context.Context, id
 	return &variants[0], nil
 }
 
-func (r *productVariantRepository) IncreaseQuantity(ctx context.Context, quantityMap map[uint]uint) error {
-	// Begin Transaction
-	tx := r.db.Begin()
-	if tx.Error != nil {
-		return tx.Error
-	}
-
-	for variantID, qty := range quantityMap {
-		// Update  quantity for each product variant
-		if err := tx.Model(&models.ProductVariant{}).
-			Where("id = ?", variantID).
-			UpdateColumn("quantity", gorm.Expr("quantity + ?", qty)).Error; err != nil {
-			tx.Rollback()
-			log.Print(err.Error())
-			return customErr.NewError(customErr.INTERNAL_ERROR, "Product Variant Update Failed", http.StatusBadRequest, err)
-		}
-	}
-
-	return tx.Commit().Error
+func (r *productVariantRepository) IncreaseQuantityTx(ctx context.Context, tx *gorm.DB, quantityMap map[uint]uint) ([]models.StockLevelChange, error) {
+	return adjustQuantitiesTx(tx.WithContext(ctx), quantityMap, 1)
 }
 
-func (r *productVariantRepository) DecreaseQuantity(ctx context.Context, quantityMap map[uint]uint) error {
-	// Begin Transaction
-	tx := r.db.Begin()
-	if tx.Error != nil {
-		return tx.Error
-	}
+func (r *productVariantRepository) DecreaseQuantityTx(ctx context.Context, tx *gorm.DB, quantityMap map[uint]uint) ([]models.StockLevelChange, error) {
+	return adjustQuantitiesTx(tx.WithContext(ctx), quantityMap, -1)
+}
 
+// adjustQuantitiesTx applies manual adjustments and records them in the ledger
+func adjustQuantitiesTx(tx *gorm.DB, quantityMap map[uint]uint, sign int) ([]models.StockLevelChange, error) {
+	changes := make([]models.StockLevelChange, 0, len(quantityMap))
 	for variantID, qty := range quantityMap {
 		// Update  quantity for each product variant
-		if err := tx.Model(&models.ProductVariant{}).
-			Where("id = ?", variantID).
-			UpdateColumn("quantity", gorm.Expr("quantity - ?", qty)).Error; err != nil {
-			tx.Rollback()
+		change, err := shiftQuantityTx(tx, variantID, sign*int(qty))
+		if err != nil {
 			log.Print(err.Error())
-			return customErr.NewError(customErr.INTERNAL_ERROR, "Product Variant Update Failed", http.StatusBadRequest, err)
+			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Product Variant Update Failed", http.StatusBadRequest, err)
+		}
+		if err := recordAdjustmentTx(tx, variantID, sign*int(qty)); err != nil {
+			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Stock ledger update failed", http.StatusInternalServerError, err)
 		}
+		changes = append(changes, change)
 	}
-
-	return tx.Commit().Error
+	return changes, nil
 }
 
-func (r *productVariantRepository) CheckAndDecreaseStock(ctx context.Context, pvID uint, quantity uint) (*models.ProductVariant, error) {
+func (r *productVariantRepository) CheckAndDecreaseStockTx(ctx context.Context, tx *gorm.DB, pvID uint, quantity uint) (*models.ProductVariant, error) {
 	var updatedVariant models.ProductVariant
 
-	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	err := tx.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// 1. Lock variant
 		var variant models.ProductVariant
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
@@ -320,6 +327,9 @@ func (r *productVariantRepository) CheckAndDecreaseStock(ctx context.Context, pv
 			Update("quantity", gorm.Expr("quantity - ?", quantity)).Error; err != nil {
 			return err
 		}
+		if err := recordAdjustmentTx(tx, pvID, -int(quantity)); err != nil {
+			return err
+		}
 
 		// 5. Load lại variant sau khi trừ để return
 		if err := tx.Where("id = ?", pvID).First(&updatedVariant).Error; err != nil {
@@ -408,3 +418,19 @@ func uintSliceToCSV(ids []uint) string {
 	}
 	return strings.Join(strIds, ",")
 }
+
+// GetMerchantID returns the merchant owning the product of the variant
+func (r *productVariantRepository) GetMerchantID(ctx context.Context, id uint) (uint, error) {
+	var merchantIDs []uint
+	if err := r.db.WithContext(ctx).
+		Table("product_variants").
+		Joins("JOIN products ON products.id = product_variants.product_id").
+		Where("product_variants.id = ?", id).
+		Pluck("products.merchant_id", &merchantIDs).Error; err != nil {
+		return 0, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	if len(merchantIDs) == 0 {
+		return 0, customErr.NewError(customErr.ITEM_NOT_FOUND, fmt.Sprintf("Product Variant id %d not found ", id), http.StatusNotFound, nil)
+	}
+	return merchantIDs[0], nil
+}
@@ -0,0 +1,168 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"time"
+)
+
+type promotionGormRepository struct {
+	db *gorm.DB
+}
+
+func NewPromotionGormRepository(db *gorm.DB) repositories.PromotionRepository {
+	return &promotionGormRepository{db}
+}
+
+func (p *promotionGormRepository) Create(ctx context.Context, promotion *models.Promotion) error {
+	if err := p.db.WithContext(ctx).Create(promotion).Error; err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			return customErr.NewError(customErr.DUPLICATED_ERROR, "Promotion code already exists", http.StatusBadRequest, nil)
+		}
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (p *promotionGormRepository) Save(ctx context.Context, promotion *models.Promotion) error {
+	if err := p.db.WithContext(ctx).Save(promotion).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (p *promotionGormRepository) Delete(ctx context.Context, promotion *models.Promotion) error {
+	if err := p.db.WithContext(ctx).Delete(promotion).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (p *promotionGormRepository) GetByID(ctx context.Context, id uint) (*models.Promotion, error) {
+	var promotion models.Promotion
+	if err := p.db.WithContext(ctx).First(&promotion, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Promotion not found", http.StatusNotFound, nil)
+		}
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &promotion, nil
+}
+
+func (p *promotionGormRepository) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	var promotion models.Promotion
+	if err := p.db.WithContext(ctx).Where("code = ?", code).First(&promotion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.INVALID_PROMOTION, "Promotion code not found", http.StatusNotFound, nil)
+		}
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &promotion, nil
+}
+
+// List returns one page of promotions, only those of the merchant when merchantID is set
+func (p *promotionGormRepository) List(ctx context.Context, merchantID *uint, page, pageSize int) ([]*models.Promotion, int, error) {
+	query := p.db.WithContext(ctx).Model(&models.Promotion{})
+	if merchantID != nil {
+		query = query.Where("merchant_id = ?", *merchantID)
+	}
+
+	var totalItem int64
+	if err := query.Count(&totalItem).Error; err != nil {
+		return nil, 0, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+
+	var promotions []*models.Promotion
+	if err := query.Order("id DESC").
+		Limit(pageSize).
+		Offset(page * pageSize).
+		Find(&promotions).Error; err != nil {
+		return nil, 0, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+
+	totalPage := int((totalItem + int64(pageSize) - 1) / int64(pageSize))
+	return promotions, totalPage, nil
+}
+
+// CountUsages counts the orders still holding the promotion, overall and for the user
+func (p *promotionGormRepository) CountUsages(ctx context.Context, promotionID uint, userID uint) (int64, int64, error) {
+	return countPromotionUsages(p.db.WithContext(ctx), promotionID, userID)
+}
+
+// Redeem records the usages, the promotion row is locked so concurrent orders can not exceed the limits
+func (p *promotionGormRepository) Redeem(ctx context.Context, promotionID uint, usages []models.PromotionUsage) error {
+	if len(usages) == 0 {
+		return nil
+	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var promotion models.Promotion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promotion, promotionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return customErr.NewError(customErr.INVALID_PROMOTION, "Promotion no longer available", http.StatusBadRequest, nil)
+			}
+			return err
+		}
+		total, byUser, err := countPromotionUsages(tx, promotionID, usages[0].UserID)
+		if err != nil {
+			return err
+		}
+		if promotion.UsageLimit > 0 && total >= int64(promotion.UsageLimit) {
+			return customErr.NewError(customErr.INVALID_PROMOTION, "Promotion usage limit reached", http.StatusBadRequest, nil)
+		}
+		if promotion.PerUserLimit > 0 && byUser >= int64(promotion.PerUserLimit) {
+			return customErr.NewError(customErr.INVALID_PROMOTION, "You have already used this promotion", http.StatusBadRequest, nil)
+		}
+		return tx.Create(&usages).Error
+	})
+	if err != nil {
+		var custom *customErr.Error
+		if errors.As(err, &custom) {
+			return err
+		}
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while redeeming promotion", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (p *promotionGormRepository) ListUsagesByOrder(ctx context.Context, orderID uint) ([]models.PromotionUsage, error) {
+	var usages []models.PromotionUsage
+	if err := p.db.WithContext(ctx).
+		Where("order_id = ? AND released_at IS NULL", orderID).
+		Find(&usages).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return usages, nil
+}
+
+// ReleaseByOrder gives the usage back when the order was never placed
+func (p *promotionGormRepository) ReleaseByOrder(ctx context.Context, orderID uint) error {
+	if err := p.db.WithContext(ctx).
+		Model(&models.PromotionUsage{}).
+		Where("order_id = ? AND released_at IS NULL", orderID).
+		Update("released_at", time.Now()).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func countPromotionUsages(db *gorm.DB, promotionID uint, userID uint) (int64, int64, error) {
+	var counts struct {
+		Total  int64
+		ByUser int64
+	}
+	if err := db.Model(&models.PromotionUsage{}).
+		Select("COUNT(DISTINCT order_id) AS total, COUNT(DISTINCT CASE WHEN user_id = ? THEN order_id END) AS by_user", userID).
+		Where("promotion_id = ? AND released_at IS NULL", promotionID).
+		Scan(&counts).Error; err != nil {
+		return 0, 0, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return counts.Total, counts.ByUser, nil
+}
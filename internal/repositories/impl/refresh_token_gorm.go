@@ -0,0 +1,86 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+type refreshTokenGormRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenGormRepository(db *gorm.DB) repositories.RefreshTokenRepository {
+	return &refreshTokenGormRepository{db}
+}
+
+func (r *refreshTokenGormRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while saving refresh token", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (r *refreshTokenGormRepository) GetByTokenID(ctx context.Context, tokenID string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.WithContext(ctx).
+		Where("token_id = ?", tokenID).
+		First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.UNAUTHORIZED, "Invalid refresh token", http.StatusUnauthorized, nil)
+		}
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &token, nil
+}
+
+// Rotate marks the token as used and saves its successor, false means the token was
+// already rotated or revoked
+func (r *refreshTokenGormRepository) Rotate(ctx context.Context, tokenID string, next *models.RefreshToken) (bool, error) {
+	rotated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.RefreshToken{}).
+			Where("token_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?", tokenID, time.Now()).
+			Update("rotated_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		rotated = true
+		return nil
+	})
+	if err != nil {
+		return false, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while rotating refresh token", http.StatusInternalServerError, err)
+	}
+	return rotated, nil
+}
+
+func (r *refreshTokenGormRepository) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("user_id = ? AND session_id = ? AND revoked_at IS NULL", userID, sessionID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (r *refreshTokenGormRepository) RevokeAllByUser(ctx context.Context, userID uint) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
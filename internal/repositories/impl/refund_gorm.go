@@ -0,0 +1,134 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type refundGormRepository struct {
+	db *gorm.DB
+}
+
+func NewRefundGormRepository(db *gorm.DB) repositories.RefundRepository {
+	return &refundGormRepository{db}
+}
+
+func (r *refundGormRepository) CreateTx(ctx context.Context, tx *gorm.DB, refund *models.Refund) error {
+	if err := tx.WithContext(ctx).Create(refund).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while creating refund", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (r *refundGormRepository) GetByID(ctx context.Context, refundID uint) (*models.Refund, error) {
+	var refund models.Refund
+	if err := r.db.WithContext(ctx).Preload("Items").First(&refund, refundID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Refund not found", http.StatusNotFound, nil)
+		}
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &refund, nil
+}
+
+func (r *refundGormRepository) ListByUserId(ctx context.Context, userID uint) ([]models.Refund, error) {
+	var refunds []models.Refund
+	if err := r.db.WithContext(ctx).
+		Preload("Items").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&refunds).Error; err != nil {
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return refunds, nil
+}
+
+// ListByStatus lists every refund when status is empty
+func (r *refundGormRepository) ListByStatus(ctx context.Context, status models.RefundStatus) ([]models.Refund, error) {
+	var refunds []models.Refund
+	query := r.db.WithContext(ctx).Preload("Items")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at ASC").Find(&refunds).Error; err != nil {
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return refunds, nil
+}
+
+func (r *refundGormRepository) UpdateStatus(ctx context.Context, refund *models.Refund, from ...models.RefundStatus) (bool, error) {
+	return r.UpdateStatusTx(ctx, r.db, refund, from...)
+}
+
+// UpdateStatusTx writes the review and outcome fields only if the stored status is still one of from,
+// false means another admin already moved the refund.
+func (r *refundGormRepository) UpdateStatusTx(ctx context.Context, tx *gorm.DB, refund *models.Refund, from ...models.RefundStatus) (bool, error) {
+	res := tx.WithContext(ctx).
+		Model(&models.Refund{}).
+		Where("id = ? AND status IN ?", refund.ID, from).
+		Updates(map[string]interface{}{
+			"status":         refund.Status,
+			"manual":         refund.Manual,
+			"reject_reason":  refund.RejectReason,
+			"failure_reason": refund.FailureReason,
+			"reviewed_by":    refund.ReviewedBy,
+			"reviewed_at":    refund.ReviewedAt,
+			"completed_at":   refund.CompletedAt,
+		})
+	if res.Error != nil {
+		return false, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// SumActiveQuantitiesTx returns, per order item, the quantity already held by requested, approved or completed refunds
+func (r *refundGormRepository) SumActiveQuantitiesTx(ctx context.Context, tx *gorm.DB, orderID uint) (map[uint]uint, error) {
+	var sums []struct {
+		OrderItemID uint
+		Total       uint
+	}
+	if err := tx.WithContext(ctx).
+		Table("refund_items").
+		Select("refund_items.order_item_id, COALESCE(SUM(refund_items.quantity), 0) as total").
+		Joins("JOIN refunds ON refunds.id = refund_items.refund_id").
+		Where("refunds.order_id = ? AND refunds.status IN ?", orderID, models.ActiveRefundStatuses).
+		Group("refund_items.order_item_id").
+		Scan(&sums).Error; err != nil {
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+
+	result := make(map[uint]uint, len(sums))
+	for _, sum := range sums {
+		result[sum.OrderItemID] = sum.Total
+	}
+	return result, nil
+}
+
+func (r *refundGormRepository) SumActiveShippingFeeTx(ctx context.Context, tx *gorm.DB, paymentInfoID int64) (float64, error) {
+	var total float64
+	if err := tx.WithContext(ctx).
+		Model(&models.Refund{}).
+		Select("COALESCE(SUM(shipping_fee), 0)").
+		Where("payment_info_id = ? AND status IN ?", paymentInfoID, models.ActiveRefundStatuses).
+		Scan(&total).Error; err != nil {
+		return 0, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return total, nil
+}
+
+func (r *refundGormRepository) SumActiveAmountTx(ctx context.Context, tx *gorm.DB, paymentInfoID int64) (float64, error) {
+	var total float64
+	if err := tx.WithContext(ctx).
+		Model(&models.Refund{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("payment_info_id = ? AND status IN ?", paymentInfoID, models.ActiveRefundStatuses).
+		Scan(&total).Error; err != nil {
+		return 0, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return total, nil
+}
@@ -0,0 +1,200 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+)
+
+type reviewGormRepository struct {
+	db *gorm.DB
+}
+
+func NewReviewGormRepository(db *gorm.DB) repositories.ReviewRepository {
+	return &reviewGormRepository{db}
+}
+
+func (r *reviewGormRepository) Create(ctx context.Context, review *models.Review) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(review).Error; err != nil {
+			return err
+		}
+		if review.Counted() {
+			return applyRatingDelta(tx, review.ProductID, float64(review.Rating), 1)
+		}
+		return nil
+	})
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			return customErr.NewError(customErr.DUPLICATED_ERROR, "You have already reviewed this product", http.StatusBadRequest, nil)
+		}
+		return reviewError(err)
+	}
+	return nil
+}
+
+// Update saves the review and moves the product rating from the previous state to the new one
+func (r *reviewGormRepository) Update(ctx context.Context, review *models.Review, previous models.Review, replaceImages bool) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if replaceImages {
+			if err := tx.Where("review_id = ?", review.ID).Delete(&models.ReviewImage{}).Error; err != nil {
+				return err
+			}
+			for i := range review.Images {
+				review.Images[i].ID = 0
+				review.Images[i].ReviewID = review.ID
+			}
+		}
+		if err := tx.Omit("Images").Save(review).Error; err != nil {
+			return err
+		}
+		if replaceImages && len(review.Images) > 0 {
+			if err := tx.Create(&review.Images).Error; err != nil {
+				return err
+			}
+		}
+
+		var sum float64
+		var count int
+		if previous.Counted() {
+			sum -= float64(previous.Rating)
+			count--
+		}
+		if review.Counted() {
+			sum += float64(review.Rating)
+			count++
+		}
+		if sum == 0 && count == 0 {
+			return nil
+		}
+		return applyRatingDelta(tx, review.ProductID, sum, count)
+	})
+	if err != nil {
+		return reviewError(err)
+	}
+	return nil
+}
+
+func (r *reviewGormRepository) Delete(ctx context.Context, review *models.Review) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("review_id = ?", review.ID).Delete(&models.ReviewImage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(review).Error; err != nil {
+			return err
+		}
+		if review.Counted() {
+			return applyRatingDelta(tx, review.ProductID, -float64(review.Rating), -1)
+		}
+		return nil
+	})
+	if err != nil {
+		return reviewError(err)
+	}
+	return nil
+}
+
+func (r *reviewGormRepository) GetByID(ctx context.Context, id uint) (*models.Review, error) {
+	var review models.Review
+	if err := r.db.WithContext(ctx).Preload("Images").First(&review, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Review not found", http.StatusNotFound, nil)
+		}
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &review, nil
+}
+
+func (r *reviewGormRepository) List(ctx context.Context, filter dto.ReviewFilter, page, pageSize int) ([]*models.Review, int, error) {
+	query := r.db.WithContext(ctx).Model(&models.Review{})
+	if filter.ProductID != nil {
+		query = query.Where("product_id = ?", *filter.ProductID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Rating != nil {
+		query = query.Where("rating = ?", *filter.Rating)
+	}
+
+	var totalItem int64
+	if err := query.Count(&totalItem).Error; err != nil {
+		return nil, 0, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+
+	var reviews []*models.Review
+	if err := query.Preload("Images").
+		Order("id DESC").
+		Limit(pageSize).
+		Offset(page * pageSize).
+		Find(&reviews).Error; err != nil {
+		return nil, 0, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+
+	totalPage := int((totalItem + int64(pageSize) - 1) / int64(pageSize))
+	return reviews, totalPage, nil
+}
+
+func (r *reviewGormRepository) FindDeliveredOrder(ctx context.Context, userID uint, productID uint) (uint, error) {
+	var orderIDs []uint
+	if err := r.db.WithContext(ctx).
+		Table("orders o").
+		Select("o.id").
+		Joins("JOIN order_items oi ON oi.order_id = o.id AND oi.order_type = ?", models.OrderTypeOrder).
+		Joins("JOIN product_variants pv ON pv.id = oi.product_variant_id").
+		Where("o.user_id = ? AND o.status = ? AND pv.product_id = ?", userID, models.OrderStateDone, productID).
+		Order("o.id DESC").
+		Limit(1).
+		Pluck("o.id", &orderIDs).Error; err != nil {
+		return 0, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	if len(orderIDs) == 0 {
+		return 0, nil
+	}
+	return orderIDs[0], nil
+}
+
+// applyRatingDelta updates the product rating from the change in rating sum and count,
+// the product row is locked so concurrent reviews do not lose updates
+func applyRatingDelta(tx *gorm.DB, productID uint, sumDelta float64, countDelta int) error {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "average_rating", "number_rating").
+		First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return customErr.NewError(customErr.ITEM_NOT_FOUND, "Product not found", http.StatusNotFound, nil)
+		}
+		return err
+	}
+
+	count := int(product.NumberRating) + countDelta
+	average := 0.0
+	if count > 0 {
+		average = (product.AverageRating*float64(product.NumberRating) + sumDelta) / float64(count)
+	} else {
+		count = 0
+	}
+
+	return tx.Model(&models.Product{}).
+		Where("id = ?", productID).
+		UpdateColumns(map[string]interface{}{
+			"average_rating": average,
+			"number_rating":  count,
+		}).Error
+}
+
+func reviewError(err error) error {
+	var custom *customErr.Error
+	if errors.As(err, &custom) {
+		return err
+	}
+	return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+}
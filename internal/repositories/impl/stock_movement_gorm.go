@@ -0,0 +1,200 @@
+package impl
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"time"
+)
+
+type stockMovementGormRepository struct {
+	db *gorm.DB
+}
+
+func NewStockMovementGormRepository(db *gorm.DB) repositories.StockMovementRepository {
+	return &stockMovementGormRepository{db}
+}
+
+type variantDeltaSum struct {
+	ProductVariantID uint
+	Total            int
+}
+
+func (s *stockMovementGormRepository) Create(ctx context.Context, movements []models.StockMovement) error {
+	return s.CreateTx(ctx, s.db, movements)
+}
+
+func (s *stockMovementGormRepository) CreateTx(ctx context.Context, tx *gorm.DB, movements []models.StockMovement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+	if err := tx.WithContext(ctx).Create(&movements).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while recording stock movement", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// ApplyPendingTx folds every not yet applied on-hand movement into product_variants.quantity
+// and marks those rows applied in the caller's transaction.
+func (s *stockMovementGormRepository) ApplyPendingTx(ctx context.Context, tx *gorm.DB) ([]models.StockLevelChange, error) {
+	tx = tx.WithContext(ctx)
+	var pending []models.StockMovement
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("applied_at IS NULL AND reason IN ?", models.OnHandStockReasons).
+		Find(&pending).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Apply stock movements failed", http.StatusInternalServerError, err)
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	deltas := make(map[uint]int)
+	ids := make([]uint, 0, len(pending))
+	for _, m := range pending {
+		deltas[m.ProductVariantID] += m.Delta
+		ids = append(ids, m.ID)
+	}
+
+	changes := make([]models.StockLevelChange, 0, len(deltas))
+	for variantID, delta := range deltas {
+		if delta == 0 {
+			continue
+		}
+		change, err := shiftQuantityTx(tx, variantID, delta)
+		if err != nil {
+			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Apply stock movements failed", http.StatusInternalServerError, err)
+		}
+		changes = append(changes, change)
+	}
+
+	if err := tx.Model(&models.StockMovement{}).
+		Where("id IN ?", ids).
+		UpdateColumn("applied_at", time.Now()).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Apply stock movements failed", http.StatusInternalServerError, err)
+	}
+	return changes, nil
+}
+
+// RebuildQuantityTx overwrites product_variants.quantity with the on-hand sum of the ledger
+func (s *stockMovementGormRepository) RebuildQuantityTx(ctx context.Context, tx *gorm.DB) ([]models.StockLevelChange, error) {
+	tx = tx.WithContext(ctx)
+	var sums []variantDeltaSum
+	if err := tx.Model(&models.StockMovement{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("product_variant_id, COALESCE(SUM(delta), 0) as total").
+		Where("reason IN ?", models.OnHandStockReasons).
+		Group("product_variant_id").
+		Scan(&sums).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Rebuild stock from ledger failed", http.StatusInternalServerError, err)
+	}
+
+	changes := make([]models.StockLevelChange, 0, len(sums))
+	for _, sum := range sums {
+		total := max(sum.Total, 0)
+		change, err := lockQuantityTx(tx, sum.ProductVariantID)
+		if err != nil {
+			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Rebuild stock from ledger failed", http.StatusInternalServerError, err)
+		}
+		if err := tx.Model(&models.ProductVariant{}).
+			Where("id = ?", sum.ProductVariantID).
+			UpdateColumn("quantity", total).Error; err != nil {
+			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Rebuild stock from ledger failed", http.StatusInternalServerError, err)
+		}
+		change.After = total
+		changes = append(changes, change)
+	}
+
+	if err := tx.Model(&models.StockMovement{}).
+		Where("applied_at IS NULL AND reason IN ?", models.OnHandStockReasons).
+		UpdateColumn("applied_at", time.Now()).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Rebuild stock from ledger failed", http.StatusInternalServerError, err)
+	}
+	return changes, nil
+}
+
+// SumAvailable returns SUM(delta) of every movement, i.e. stock that can still be reserved
+func (s *stockMovementGormRepository) SumAvailable(ctx context.Context, variantIDs []uint) (map[uint]int, error) {
+	var sums []variantDeltaSum
+	if err := s.db.WithContext(ctx).
+		Model(&models.StockMovement{}).
+		Select("product_variant_id, COALESCE(SUM(delta), 0) as total").
+		Where("product_variant_id IN ?", variantIDs).
+		Group("product_variant_id").
+		Scan(&sums).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+
+	result := make(map[uint]int, len(sums))
+	for _, sum := range sums {
+		result[sum.ProductVariantID] = sum.Total
+	}
+	return result, nil
+}
+
+// SeedOpeningBalances records the current quantity of variants that have no ledger row yet
+func (s *stockMovementGormRepository) SeedOpeningBalances(ctx context.Context) (int64, error) {
+	res := s.db.WithContext(ctx).Exec(`
+INSERT INTO stock_movements (product_variant_id, delta, reason, order_id, order_type, applied_at, created_at)
+SELECT pv.id, pv.quantity, ?, 0, '', NOW(), NOW()
+FROM product_variants pv
+WHERE NOT EXISTS (SELECT 1 FROM stock_movements sm WHERE sm.product_variant_id = pv.id)`,
+		models.StockReasonAdjustment)
+	if res.Error != nil {
+		return 0, customErr.NewError(customErr.INTERNAL_ERROR, "Seed stock ledger failed", http.StatusInternalServerError, res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+func (s *stockMovementGormRepository) ListByVariant(ctx context.Context, variantID uint) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+	if err := s.db.WithContext(ctx).
+		Where("product_variant_id = ?", variantID).
+		Order("id ASC").
+		Find(&movements).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return movements, nil
+}
+
+// recordAdjustmentTx writes an already applied manual adjustment inside the caller's transaction
+func recordAdjustmentTx(tx *gorm.DB, variantID uint, delta int) error {
+	now := time.Now()
+	return tx.Create(&models.StockMovement{
+		ProductVariantID: variantID,
+		Delta:            delta,
+		Reason:           models.StockReasonAdjustment,
+		AppliedAt:        &now,
+	}).Error
+}
+
+// lockQuantityTx locks the variant row and returns its current on-hand quantity as both levels
+func lockQuantityTx(tx *gorm.DB, variantID uint) (models.StockLevelChange, error) {
+	var variant models.ProductVariant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "product_id", "quantity").
+		Where("id = ?", variantID).
+		First(&variant).Error; err != nil {
+		return models.StockLevelChange{}, err
+	}
+	quantity := int(variant.Quantity)
+	return models.StockLevelChange{ProductVariantID: variant.ID, ProductID: variant.ProductID, Before: quantity, After: quantity}, nil
+}
+
+// shiftQuantityTx adds delta to the on-hand quantity of a locked variant
+func shiftQuantityTx(tx *gorm.DB, variantID uint, delta int) (models.StockLevelChange, error) {
+	change, err := lockQuantityTx(tx, variantID)
+	if err != nil {
+		return change, err
+	}
+	if err := tx.Model(&models.ProductVariant{}).
+		Where("id = ?", variantID).
+		UpdateColumn("quantity", gorm.Expr("quantity + ?", delta)).Error; err != nil {
+		return change, err
+	}
+	change.After = change.Before + delta
+	return change, nil
+}
@@ -0,0 +1,85 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type userGormRepository struct {
+	db *gorm.DB
+}
+
+func NewUserGormRepository(db *gorm.DB) repositories.UserRepository {
+	return &userGormRepository{db}
+}
+
+// List returns one page of users and the total page count
+func (u *userGormRepository) List(ctx context.Context, filter dto.UserFilter, page, pageSize int) ([]*models.User, int, error) {
+	query := u.db.WithContext(ctx).Model(&models.User{})
+	if filter.Search != "" {
+		like := "%" + filter.Search + "%"
+		query = query.Where("email LIKE ? OR full_name LIKE ? OR phone_number LIKE ?", like, like, like)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Active != nil {
+		query = query.Where("active = ?", *filter.Active)
+	}
+
+	var totalItem int64
+	if err := query.Count(&totalItem).Error; err != nil {
+		return nil, 0, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+
+	var users []*models.User
+	if err := query.Order("user_id DESC").
+		Limit(pageSize).
+		Offset(page * pageSize).
+		Find(&users).Error; err != nil {
+		return nil, 0, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+
+	totalPage := int((totalItem + int64(pageSize) - 1) / int64(pageSize))
+	return users, totalPage, nil
+}
+
+func (u *userGormRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := u.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "User not found", http.StatusNotFound, nil)
+		}
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return &user, nil
+}
+
+func (u *userGormRepository) UpdateRole(ctx context.Context, id uint, role models.Role, merchantID *uint) error {
+	if err := u.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("user_id = ?", id).
+		Updates(map[string]interface{}{
+			"role":        role,
+			"merchant_id": merchantID,
+		}).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (u *userGormRepository) UpdateActive(ctx context.Context, id uint, active uint8) error {
+	if err := u.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("user_id = ?", id).
+		Update("active", active).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
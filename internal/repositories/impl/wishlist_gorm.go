@@ -0,0 +1,94 @@
+package impl
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"time"
+)
+
+type wishlistGormRepository struct {
+	db *gorm.DB
+}
+
+func NewWishlistGormRepository(db *gorm.DB) repositories.WishlistRepository {
+	return &wishlistGormRepository{db}
+}
+
+func (w *wishlistGormRepository) ListByUserId(ctx context.Context, userID uint) ([]models.WishlistItem, error) {
+	var items []models.WishlistItem
+	if err := w.db.WithContext(ctx).
+		Preload("Variant").
+		Preload("Variant.OptionValues").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&items).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return items, nil
+}
+
+// Upsert adds the variant to the wishlist or only changes the notification setting if it is already there
+func (w *wishlistGormRepository) Upsert(ctx context.Context, item *models.WishlistItem) error {
+	if err := w.db.WithContext(ctx).
+		Omit("Variant", "User").
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "product_variant_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"notify_back_in_stock", "updated_at"}),
+		}).
+		Create(item).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error while saving wishlist", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func (w *wishlistGormRepository) Delete(ctx context.Context, userID uint, productVariantID uint) error {
+	res := w.db.WithContext(ctx).
+		Where("user_id = ? AND product_variant_id = ?", userID, productVariantID).
+		Delete(&models.WishlistItem{})
+	if res.Error != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return customErr.NewError(customErr.ITEM_NOT_FOUND, "Product variant not in wishlist", http.StatusNotFound, nil)
+	}
+	return nil
+}
+
+func (w *wishlistGormRepository) ListSubscribers(ctx context.Context, productVariantID uint) ([]models.WishlistItem, error) {
+	var items []models.WishlistItem
+	if err := w.db.WithContext(ctx).
+		Joins("User").
+		Preload("Variant.Product").
+		Where("wishlist_items.product_variant_id = ? AND wishlist_items.notify_back_in_stock = ?", productVariantID, true).
+		Where("User.active = ?", 1).
+		Find(&items).Error; err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return items, nil
+}
+
+func (w *wishlistGormRepository) ClaimNotification(ctx context.Context, id uint, notifiedBefore time.Time) (bool, error) {
+	res := w.db.WithContext(ctx).
+		Model(&models.WishlistItem{}).
+		Where("id = ? AND (last_notified_at IS NULL OR last_notified_at < ?)", id, notifiedBefore).
+		UpdateColumn("last_notified_at", time.Now())
+	if res.Error != nil {
+		return false, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, res.Error)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (w *wishlistGormRepository) ReleaseNotification(ctx context.Context, id uint, previous *time.Time) error {
+	if err := w.db.WithContext(ctx).
+		Model(&models.WishlistItem{}).
+		Where("id = ?", id).
+		UpdateColumn("last_notified_at", previous).Error; err != nil {
+		return customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
@@ -11,7 +11,9 @@ type MerchantRepository interface {
 	GetByID(ctx context.Context, id uint) (*models.Merchant, error)
 	GetByIDTx(ctx context.Context, tx *gorm.DB, id uint) (*models.Merchant, error)
 	Update(ctx context.Context, merchant *models.Merchant) error
+	UpdateTx(ctx context.Context, tx *gorm.DB, merchant *models.Merchant) error
 	Delete(ctx context.Context, id uint) error
+	DeleteTx(ctx context.Context, tx *gorm.DB, id uint) error
 	List(ctx context.Context) ([]models.Merchant, error)
 	GetByName(ctx context.Context, name string) (*models.Merchant, error)
 	GetByNameTx(ctx context.Context, tx *gorm.DB, name string) (*models.Merchant, error)
@@ -10,4 +10,6 @@ type OrderItemRepository interface {
 	CreateTx(ctx context.Context, tx *gorm.DB, orderItem *models.OrderItem) (*models.OrderItem, error)
 	Create(ctx context.Context, orderItem *models.OrderItem) error
 	Save(ctx context.Context, orderItem *models.OrderItem) error
+	SaveTx(ctx context.Context, tx *gorm.DB, orderItem *models.OrderItem) error
+	UpdateStatusTx(ctx context.Context, tx *gorm.DB, orderItem *models.OrderItem) error
 }
@@ -14,7 +14,12 @@ type OrderRepository interface {
 	GetByIdAndUserId(ctx context.Context, orderID uint, userID uint) (*models.Order, error)
 	GetById(ctx context.Context, orderID uint) (*models.Order, error)
 	Update(ctx context.Context, order *models.Order) error
+	UpdateTx(ctx context.Context, tx *gorm.DB, order *models.Order) error
+	GetByIdForUpdateTx(ctx context.Context, tx *gorm.DB, orderID uint) (*models.Order, error)
+	UpdateStatusTx(ctx context.Context, tx *gorm.DB, orderID uint, status models.OrderStatus) error
 	GetsByStatusAndUserId(ctx context.Context, status models.OrderStatus, userId uint) ([]*models.Order, error)
 	ListByUserId(ctx context.Context, userID uint) ([]*models.Order, error)
 	ListByAdmin(ctx context.Context) ([]*models.Order, error)
+	ListByMerchant(ctx context.Context, merchantID uint) ([]*models.Order, error)
+	ListMerchantIDs(ctx context.Context, orderID uint) ([]uint, error)
 }
@@ -0,0 +1,13 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"gorm.io/gorm"
+)
+
+type OrderStatusHistoryRepository interface {
+	Create(ctx context.Context, history *models.OrderStatusHistory) error
+	CreateTx(ctx context.Context, tx *gorm.DB, history *models.OrderStatusHistory) error
+	ListByOrderId(ctx context.Context, orderID uint) ([]models.OrderStatusHistory, error)
+}
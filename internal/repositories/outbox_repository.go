@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+type OutboxRepository interface {
+	Create(ctx context.Context, event *models.OutboxEvent) error
+	CreateTx(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	MarkDelivered(ctx context.Context, id uint) error
+	MarkRetry(ctx context.Context, id uint, nextAttemptAt time.Time, lastErr string) error
+	MarkDead(ctx context.Context, id uint, lastErr string) error
+	Stats(ctx context.Context, eventType string) (*models.OutboxStats, error)
+	// ListSince returns the events of the type created since the time in creation order, delivered or not.
+	// It relies on delivered rows being retained, see models.OutboxEvent
+	ListSince(ctx context.Context, eventType string, since time.Time) ([]models.OutboxEvent, error)
+}
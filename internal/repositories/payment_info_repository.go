@@ -4,11 +4,20 @@ import (
 	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/minh6824pro/nxrGO/internal/models"
+	"gorm.io/gorm"
+	"time"
 )
 
 type PaymentInfoRepository interface {
 	Create(ctx context.Context, payment *models.PaymentInfo) error
+	CreateTx(ctx context.Context, tx *gorm.DB, payment *models.PaymentInfo) error
 	Save(ctx context.Context, payment *models.PaymentInfo) error
+	SaveTx(ctx context.Context, tx *gorm.DB, payment *models.PaymentInfo) error
 	GetByID(ctx context.Context, paymentInfoID int64) (*models.PaymentInfo, error)
+	GetByIDForUpdateTx(ctx context.Context, tx *gorm.DB, paymentInfoID int64) (*models.PaymentInfo, error)
+	CompareAndSetStatus(ctx context.Context, payment *models.PaymentInfo, from models.PaymentStatus) (bool, error)
+	CompareAndSetStatusTx(ctx context.Context, tx *gorm.DB, payment *models.PaymentInfo, from models.PaymentStatus) (bool, error)
+	ClaimDueForCheck(ctx context.Context, limit int, lease time.Duration, createdBefore time.Time) ([]models.PaymentInfo, error)
+	ScheduleCheck(ctx context.Context, paymentInfoID int64, at time.Time) error
 	GetByIdAndUserIdAndOrderId(c *gin.Context, paymentId int64, userId, orderId uint) (models.PaymentInfo, *models.Order, *models.DraftOrder, error)
 }
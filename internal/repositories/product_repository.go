@@ -12,12 +12,17 @@ type ProductRepository interface {
 	Create(ctx context.Context, c *models.Product) (*models.Product, error)
 	CreateWithTx(ctx context.Context, tx *gorm.DB, c *models.Product) (*models.Product, error)
 	GetByID(ctx context.Context, id uint) (*models.Product, error)
+	GetMerchantID(ctx context.Context, id uint) (uint, error)
 	GetByIdPreloadVariant(ctx context.Context, id uint) (*models.Product, error)
 	Update(ctx context.Context, c *models.Product) error
 	Delete(ctx context.Context, id uint) error
+	DeleteTx(ctx context.Context, tx *gorm.DB, id uint) error
 	List(ctx context.Context) ([]models.Product, error)
 	ListWithPagination(ctx context.Context, page int, size int) ([]models.Product, int64, int64, error)
 	GetAllProductId(ctx context.Context) ([]uint, error)
+	// GetIdsByReference lists products pointing at the row, column is merchant_id, brand_id or category_id
+	GetIdsByReference(ctx context.Context, column string, id uint) ([]uint, error)
+	GetIdsByOrder(ctx context.Context, orderID uint) ([]uint, error)
 	GetProductListFilter(ctx context.Context, priceMin, priceMax *float64, priceAsc *bool, totalBuyDescStr *bool, page, pageSize int) ([]CacheModel.ListProductQueryCache, int, error)
 	GetProductListFilterOptimized(ctx context.Context, priceMin, priceMax *float64, priceAsc *bool, totalBuyDescStr *bool, page, pageSize int) ([]CacheModel.ListProductQueryCache, int, error)
 }
@@ -13,15 +13,18 @@ type ProductVariantRepository interface {
 	CreateWithTx(ctx context.Context, tx *gorm.DB, variant *models.ProductVariant) (*models.ProductVariant, error)
 	GetByID(ctx context.Context, id uint) (*models.ProductVariant, error)
 	GetByIDNoPreload(ctx context.Context, id uint) (*models.ProductVariant, error)
+	GetMerchantID(ctx context.Context, id uint) (uint, error)
 	GetByIDForRedisCache(ctx context.Context, id uint) (*models.ProductVariant, error)
 	GetByIDSForRedisCache(ctx context.Context, productVariantIds []uint) ([]models.ProductVariant, error)
 	CheckExistsAndQuantity(ctx context.Context, id uint, quantity uint) error
 	Update(ctx context.Context, variant *models.ProductVariant) error
-	IncreaseQuantity(ctx context.Context, quantityMap map[uint]uint) error
-	DecreaseQuantity(ctx context.Context, quantityMap map[uint]uint) error
+	IncreaseQuantityTx(ctx context.Context, tx *gorm.DB, quantityMap map[uint]uint) ([]models.StockLevelChange, error)
+	UpdatePrice(ctx context.Context, id uint, price float64) error
+	UpdatePriceTx(ctx context.Context, tx *gorm.DB, id uint, price float64) error
+	DecreaseQuantityTx(ctx context.Context, tx *gorm.DB, quantityMap map[uint]uint) ([]models.StockLevelChange, error)
 	Delete(ctx context.Context, id uint) error
 	List(ctx context.Context) ([]models.ProductVariant, error)
-	CheckAndDecreaseStock(ctx context.Context, pvID uint, quantity uint) (*models.ProductVariant, error)
+	CheckAndDecreaseStockTx(ctx context.Context, tx *gorm.DB, pvID uint, quantity uint) (*models.ProductVariant, error)
 	GetByIDSForProductMiniCache(ctx context.Context, productIds []uint) ([]models.ProductVariant, error)
 	ListByIds(ctx context.Context, list dto.ListProductVariantIds) ([]models.ProductVariant, error)
 }
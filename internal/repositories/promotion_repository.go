@@ -0,0 +1,19 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *models.Promotion) error
+	Save(ctx context.Context, promotion *models.Promotion) error
+	Delete(ctx context.Context, promotion *models.Promotion) error
+	GetByID(ctx context.Context, id uint) (*models.Promotion, error)
+	GetByCode(ctx context.Context, code string) (*models.Promotion, error)
+	List(ctx context.Context, merchantID *uint, page, pageSize int) ([]*models.Promotion, int, error)
+	CountUsages(ctx context.Context, promotionID uint, userID uint) (total int64, byUser int64, err error)
+	Redeem(ctx context.Context, promotionID uint, usages []models.PromotionUsage) error
+	ListUsagesByOrder(ctx context.Context, orderID uint) ([]models.PromotionUsage, error)
+	ReleaseByOrder(ctx context.Context, orderID uint) error
+}
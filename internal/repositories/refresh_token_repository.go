@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	GetByTokenID(ctx context.Context, tokenID string) (*models.RefreshToken, error)
+	Rotate(ctx context.Context, tokenID string, next *models.RefreshToken) (bool, error)
+	RevokeSession(ctx context.Context, userID uint, sessionID string) error
+	RevokeAllByUser(ctx context.Context, userID uint) error
+}
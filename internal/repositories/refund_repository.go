@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"gorm.io/gorm"
+)
+
+type RefundRepository interface {
+	CreateTx(ctx context.Context, tx *gorm.DB, refund *models.Refund) error
+	GetByID(ctx context.Context, refundID uint) (*models.Refund, error)
+	ListByUserId(ctx context.Context, userID uint) ([]models.Refund, error)
+	ListByStatus(ctx context.Context, status models.RefundStatus) ([]models.Refund, error)
+	UpdateStatus(ctx context.Context, refund *models.Refund, from ...models.RefundStatus) (bool, error)
+	UpdateStatusTx(ctx context.Context, tx *gorm.DB, refund *models.Refund, from ...models.RefundStatus) (bool, error)
+	SumActiveQuantitiesTx(ctx context.Context, tx *gorm.DB, orderID uint) (map[uint]uint, error)
+	SumActiveShippingFeeTx(ctx context.Context, tx *gorm.DB, paymentInfoID int64) (float64, error)
+	// SumActiveAmountTx is the money held by requested, approved or completed refunds of the payment
+	SumActiveAmountTx(ctx context.Context, tx *gorm.DB, paymentInfoID int64) (float64, error)
+}
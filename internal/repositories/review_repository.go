@@ -0,0 +1,18 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type ReviewRepository interface {
+	// Create, Update and Delete also apply the rating change to the product in the same transaction
+	Create(ctx context.Context, review *models.Review) error
+	Update(ctx context.Context, review *models.Review, previous models.Review, replaceImages bool) error
+	Delete(ctx context.Context, review *models.Review) error
+	GetByID(ctx context.Context, id uint) (*models.Review, error)
+	List(ctx context.Context, filter dto.ReviewFilter, page, pageSize int) ([]*models.Review, int, error)
+	// FindDeliveredOrder returns a DONE order of the user containing the product, 0 when there is none
+	FindDeliveredOrder(ctx context.Context, userID uint, productID uint) (uint, error)
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"gorm.io/gorm"
+)
+
+type StockMovementRepository interface {
+	Create(ctx context.Context, movements []models.StockMovement) error
+	CreateTx(ctx context.Context, tx *gorm.DB, movements []models.StockMovement) error
+	ApplyPendingTx(ctx context.Context, tx *gorm.DB) ([]models.StockLevelChange, error)
+	RebuildQuantityTx(ctx context.Context, tx *gorm.DB) ([]models.StockLevelChange, error)
+	SumAvailable(ctx context.Context, variantIDs []uint) (map[uint]int, error)
+	SeedOpeningBalances(ctx context.Context) (int64, error)
+	ListByVariant(ctx context.Context, variantID uint) ([]models.StockMovement, error)
+}
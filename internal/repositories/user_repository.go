@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type UserRepository interface {
+	List(ctx context.Context, filter dto.UserFilter, page, pageSize int) ([]*models.User, int, error)
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+	UpdateRole(ctx context.Context, id uint, role models.Role, merchantID *uint) error
+	UpdateActive(ctx context.Context, id uint, active uint8) error
+}
@@ -0,0 +1,19 @@
+package repositories
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"time"
+)
+
+type WishlistRepository interface {
+	ListByUserId(ctx context.Context, userID uint) ([]models.WishlistItem, error)
+	Upsert(ctx context.Context, item *models.WishlistItem) error
+	Delete(ctx context.Context, userID uint, productVariantID uint) error
+	// ListSubscribers returns the items of active users waiting for the variant to be restocked
+	ListSubscribers(ctx context.Context, productVariantID uint) ([]models.WishlistItem, error)
+	// ClaimNotification marks the item notified unless it already was after notifiedBefore, false means skip it
+	ClaimNotification(ctx context.Context, id uint, notifiedBefore time.Time) (bool, error)
+	// ReleaseNotification gives a claim back when nothing was sent, previous is the claimed item's LastNotifiedAt
+	ReleaseNotification(ctx context.Context, id uint, previous *time.Time) error
+}
@@ -0,0 +1,16 @@
+package services
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type AddressService interface {
+	Create(ctx context.Context, userID uint, input dto.CreateAddressInput) (*models.Address, error)
+	List(ctx context.Context, userID uint) ([]*models.Address, error)
+	GetByID(ctx context.Context, userID uint, id uint) (*models.Address, error)
+	Update(ctx context.Context, userID uint, id uint, input dto.UpdateAddressInput) (*models.Address, error)
+	Delete(ctx context.Context, userID uint, id uint) error
+	SetDefault(ctx context.Context, userID uint, id uint) (*models.Address, error)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"github.com/minh6824pro/nxrGO/internal/dto"
 	"github.com/minh6824pro/nxrGO/internal/models"
 )
@@ -9,5 +10,13 @@ type AuthService interface {
 	Register(dto.RegisterRequest) (*dto.AuthResponse, error)
 	Login(dto.LoginRequest) (*dto.AuthResponse, error)
 	GetProfile(userID uint) (*models.User, error)
-	RefreshToken(refreshToken string) (string, error)
+	RefreshToken(ctx context.Context, req dto.RefreshTokenRequest) (*dto.AuthResponse, error)
+	Logout(ctx context.Context, userID uint, sessionID string) error
+	LogoutAll(ctx context.Context, userID uint) error
+	SendVerificationEmail(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, req dto.VerifyEmailRequest) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error
+	UnlockUser(ctx context.Context, adminID uint, userID uint) (*models.User, error)
+	ListLockedUsers() ([]*models.User, error)
 }
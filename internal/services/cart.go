@@ -0,0 +1,16 @@
+package services
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+)
+
+type CartService interface {
+	Get(ctx context.Context, userID uint) (*dto.CartResponse, error)
+	AddItem(ctx context.Context, userID uint, input dto.CartItemInput) (*dto.CartResponse, error)
+	UpdateItem(ctx context.Context, userID uint, productVariantID uint, input dto.UpdateCartItemInput) (*dto.CartResponse, error)
+	RemoveItem(ctx context.Context, userID uint, productVariantID uint) (*dto.CartResponse, error)
+	Clear(ctx context.Context, userID uint) error
+	Merge(ctx context.Context, userID uint, input dto.MergeCartInput) (*dto.CartResponse, error)
+	Checkout(ctx context.Context, userID uint, input dto.CheckoutCartInput) (*dto.CreateOrderResponse, error)
+}
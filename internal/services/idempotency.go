@@ -0,0 +1,14 @@
+package services
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type IdempotencyService interface {
+	Begin(ctx context.Context, userID uint, key string, fingerprint string) (*models.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, record *models.IdempotencyKey, responseCode int, responseBody []byte) error
+	Renew(ctx context.Context, record *models.IdempotencyKey) error
+	Abort(ctx context.Context, record *models.IdempotencyKey) error
+	DeleteExpired(ctx context.Context) error
+}
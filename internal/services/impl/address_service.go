@@ -0,0 +1,155 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+type addressService struct {
+	repo repositories.AddressRepository
+}
+
+func NewAddressService(repo repositories.AddressRepository) services.AddressService {
+	return &addressService{repo: repo}
+}
+
+// Create saves the address, the first address of a user is the default one
+func (a *addressService) Create(ctx context.Context, userID uint, input dto.CreateAddressInput) (*models.Address, error) {
+	existing, err := a.repo.ListByUserId(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	address := &models.Address{
+		UserID:        userID,
+		Label:         input.Label,
+		RecipientName: input.RecipientName,
+		PhoneNumber:   input.PhoneNumber,
+		Street:        input.Street,
+		Ward:          input.Ward,
+		District:      input.District,
+		Province:      input.Province,
+		Latitude:      input.Latitude,
+		Longitude:     input.Longitude,
+	}
+	if err := a.repo.Create(ctx, address); err != nil {
+		return nil, err
+	}
+
+	if input.IsDefault || len(existing) == 0 {
+		if err := a.repo.SetDefault(ctx, userID, address.ID); err != nil {
+			return nil, err
+		}
+		address.IsDefault = true
+	}
+	return address, nil
+}
+
+func (a *addressService) List(ctx context.Context, userID uint) ([]*models.Address, error) {
+	return a.repo.ListByUserId(ctx, userID)
+}
+
+// GetByID returns the address if it belongs to the user
+func (a *addressService) GetByID(ctx context.Context, userID uint, id uint) (*models.Address, error) {
+	address, err := a.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if address.UserID != userID {
+		return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Address not found", http.StatusNotFound, nil)
+	}
+	return address, nil
+}
+
+func (a *addressService) Update(ctx context.Context, userID uint, id uint, input dto.UpdateAddressInput) (*models.Address, error) {
+	address, err := a.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Label != nil {
+		address.Label = *input.Label
+	}
+	if input.RecipientName != nil {
+		address.RecipientName = *input.RecipientName
+	}
+	if input.PhoneNumber != nil {
+		address.PhoneNumber = *input.PhoneNumber
+	}
+	if input.Street != nil {
+		address.Street = *input.Street
+	}
+	if input.Ward != nil {
+		address.Ward = *input.Ward
+	}
+	if input.District != nil {
+		address.District = *input.District
+	}
+	if input.Province != nil {
+		address.Province = *input.Province
+	}
+	// Toạ độ đổi theo cặp
+	if (input.Latitude == nil) != (input.Longitude == nil) {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "lat and lon must be updated together", http.StatusBadRequest, nil)
+	}
+	if input.Latitude != nil {
+		address.Latitude = *input.Latitude
+		address.Longitude = *input.Longitude
+	}
+	if err := validateRequiredAddressFields(address); err != nil {
+		return nil, err
+	}
+
+	if err := a.repo.Update(ctx, address); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+func (a *addressService) Delete(ctx context.Context, userID uint, id uint) error {
+	address, err := a.GetByID(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	return a.repo.Delete(ctx, address)
+}
+
+func (a *addressService) SetDefault(ctx context.Context, userID uint, id uint) (*models.Address, error) {
+	address, err := a.GetByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.repo.SetDefault(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	address.IsDefault = true
+	return address, nil
+}
+
+// validateRequiredAddressFields keeps an updated address as complete as a created one
+func validateRequiredAddressFields(address *models.Address) error {
+	required := []struct {
+		field string
+		value string
+	}{
+		{"recipient_name", address.RecipientName},
+		{"phone_number", address.PhoneNumber},
+		{"street", address.Street},
+		{"province", address.Province},
+		{"lat", address.Latitude},
+		{"lon", address.Longitude},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			return customErr.NewError(customErr.INVALID_INPUT, fmt.Sprintf("%s is required", r.field), http.StatusBadRequest, nil)
+		}
+	}
+	return nil
+}
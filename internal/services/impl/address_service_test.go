@@ -0,0 +1,44 @@
+package impl
+
+import (
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"strings"
+	"testing"
+)
+
+func completeAddress() *models.Address {
+	return &models.Address{
+		RecipientName: "Nguyen Van A",
+		PhoneNumber:   "0912345678",
+		Street:        "1 Le Loi",
+		Province:      "Ha Noi",
+		Latitude:      "21.0285",
+		Longitude:     "105.8542",
+	}
+}
+
+func TestValidateRequiredAddressFields(t *testing.T) {
+	if err := validateRequiredAddressFields(completeAddress()); err != nil {
+		t.Fatalf("complete address rejected: %v", err)
+	}
+
+	cases := []struct {
+		field string
+		clear func(a *models.Address)
+	}{
+		{"recipient_name", func(a *models.Address) { a.RecipientName = "" }},
+		{"phone_number", func(a *models.Address) { a.PhoneNumber = " " }},
+		{"street", func(a *models.Address) { a.Street = "" }},
+		{"province", func(a *models.Address) { a.Province = "\t" }},
+		{"lat", func(a *models.Address) { a.Latitude = "" }},
+		{"lon", func(a *models.Address) { a.Longitude = "" }},
+	}
+	for _, c := range cases {
+		address := completeAddress()
+		c.clear(address)
+		err := validateRequiredAddressFields(address)
+		if err == nil || !strings.Contains(err.Error(), c.field) {
+			t.Errorf("blank %s: got %v, want an error naming the field", c.field, err)
+		}
+	}
+}
@@ -1,26 +1,71 @@
 package impl
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/cache"
+	"github.com/minh6824pro/nxrGO/internal/config"
 	"github.com/minh6824pro/nxrGO/internal/dto"
 	"github.com/minh6824pro/nxrGO/internal/jwt"
+	"github.com/minh6824pro/nxrGO/internal/mail"
 	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/repositories"
 	"github.com/minh6824pro/nxrGO/internal/services"
 	"github.com/minh6824pro/nxrGO/pkg/errors"
 	"golang.org/x/crypto/bcrypt"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
 type authService struct {
-	repo       repositories.AuthRepository
-	jwtService *jwt.JWTService
+	repo             repositories.AuthRepository
+	jwtService       *jwt.JWTService
+	refreshTokenRepo repositories.RefreshTokenRepository
+	denylist         cache.TokenDenylistService
+	authTokenRepo    repositories.AuthTokenRepository
+	mailSender       mail.Sender
+	limiter          cache.RateLimiter
+	loginAttempts    cache.LoginAttemptService
 }
 
-func NewAuthService(repo repositories.AuthRepository, jwtSvc *jwt.JWTService) services.AuthService {
+const (
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = 30 * time.Minute
+
+	// Mỗi email nhận tối đa authMailLimit mail và thử token tối đa authTokenAttemptLimit lần mỗi cửa sổ
+	authMailLimit          = 3
+	authMailWindow         = time.Hour
+	authTokenAttemptLimit  = 5
+	authTokenAttemptWindow = 15 * time.Minute
+
+	// Sai quá loginDelayAfter lần thì phải chờ lâu dần mới được thử lại, tới loginLockThreshold lần thì khoá tài khoản
+	loginDelayAfter      = 2
+	loginMaxDelay        = 5 * time.Second
+	loginLockThreshold   = 5
+	loginLockDuration    = 15 * time.Minute
+	loginMaxLockDuration = 24 * time.Hour
+	loginIPLimit         = 50
+)
+
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("nxrGO-dummy-password"), bcrypt.DefaultCost)
+
+func NewAuthService(repo repositories.AuthRepository, jwtSvc *jwt.JWTService, refreshTokenRepo repositories.RefreshTokenRepository,
+	denylist cache.TokenDenylistService, authTokenRepo repositories.AuthTokenRepository, mailSender mail.Sender,
+	limiter cache.RateLimiter, loginAttempts cache.LoginAttemptService) services.AuthService {
 	return &authService{
-		repo:       repo,
-		jwtService: jwtSvc,
+		repo:             repo,
+		jwtService:       jwtSvc,
+		refreshTokenRepo: refreshTokenRepo,
+		denylist:         denylist,
+		authTokenRepo:    authTokenRepo,
+		mailSender:       mailSender,
+		limiter:          limiter,
+		loginAttempts:    loginAttempts,
 	}
 }
 
@@ -48,66 +93,427 @@ func (s *authService) Register(req dto.RegisterRequest) (*dto.AuthResponse, erro
 		return nil, errors.NewError(errors.INTERNAL_ERROR, "Error creating user", http.StatusInternalServerError, err)
 	}
 
-	accessToken, err := s.jwtService.GenerateToken(user)
-	if err != nil {
-		return nil, errors.NewError(errors.INTERNAL_ERROR, "Error generating token 1", http.StatusInternalServerError, err)
+	if err := s.sendAuthMail(context.Background(), user, models.AuthTokenEmailVerification); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", user.UserID, err)
 	}
-	refreshToken, err := s.jwtService.GenerateRefreshToken(user)
-	if err != nil {
-		return nil, errors.NewError(errors.INTERNAL_ERROR, "Error generating token 2", http.StatusInternalServerError, err)
+	if config.RequireEmailVerification {
+		// Chưa cấp token cho tới khi xác thực email
+		return &dto.AuthResponse{User: user}, nil
 	}
 
-	return &dto.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         user,
-	}, nil
+	return s.openSession(user, req.ClientInfo)
 }
 
+// Login trả cùng một lỗi cho email không tồn tại và sai mật khẩu, lần sai được đếm theo email và IP
 func (s *authService) Login(req dto.LoginRequest) (*dto.AuthResponse, error) {
+	ctx := context.Background()
+
+	if ipFailures, err := s.loginAttempts.IPFailures(ctx, req.IP); err != nil {
+		log.Printf("Error reading login failures: %v", err)
+	} else if ipFailures >= loginIPLimit {
+		return nil, errors.NewError(errors.TOO_MANY_REQUESTS, "Too many login attempts, try again later", http.StatusTooManyRequests, nil)
+	}
+	if lockedFor, err := s.loginAttempts.LockedFor(ctx, req.Email); err != nil {
+		log.Printf("Error reading login lock: %v", err)
+	} else if lockedFor > 0 {
+		return nil, accountLockedError(time.Now().Add(lockedFor))
+	}
+	if throttledFor, err := s.loginAttempts.ThrottledFor(ctx, req.Email); err != nil {
+		log.Printf("Error reading login throttle: %v", err)
+	} else if throttledFor > 0 {
+		return nil, loginThrottledError(throttledFor)
+	}
+
 	user, err := s.repo.FindByEmail(req.Email)
 	if err != nil || user.Active != uint8(1) {
-		return nil, errors.NewError(errors.INVALID_CREDENTIALS, "Email not exists", http.StatusUnauthorized, nil)
+		// So sánh với hash giả để thời gian phản hồi không lộ email có tồn tại
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		return nil, s.loginFailed(ctx, nil, req)
+	}
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		return nil, accountLockedError(*user.LockedUntil)
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, errors.NewError(errors.INVALID_CREDENTIALS, "Wrong password", http.StatusUnauthorized, nil)
+		return nil, s.loginFailed(ctx, user, req)
+	}
+	if err := s.loginAttempts.Reset(ctx, req.Email); err != nil {
+		log.Printf("Error resetting login failures: %v", err)
+	}
+
+	if config.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return nil, errors.NewError(errors.EMAIL_NOT_VERIFIED, "Email is not verified", http.StatusForbidden, nil)
 	}
 
 	now := time.Now()
 	user.LastLogin = &now
 	_ = s.repo.Update(user)
 
-	accessToken, err := s.jwtService.GenerateToken(user)
+	return s.openSession(user, req.ClientInfo)
+}
+
+// loginFailed counts the failure, locks the account once it reaches loginLockThreshold
+// and makes repeated failures wait before the next attempt
+func (s *authService) loginFailed(ctx context.Context, user *models.User, req dto.LoginRequest) error {
+	invalid := errors.NewError(errors.INVALID_CREDENTIALS, "Invalid email or password", http.StatusUnauthorized, nil)
+
+	accountFailures, _, err := s.loginAttempts.RecordFailure(ctx, req.Email, req.IP)
 	if err != nil {
-		return nil, err
+		log.Printf("Error recording login failure: %v", err)
+		return invalid
+	}
+
+	if accountFailures >= loginLockThreshold {
+		lockCount := uint(0)
+		if user != nil {
+			lockCount = user.LockCount
+		}
+		// Mỗi lần bị khoá tiếp theo thời gian khoá gấp đôi
+		duration := loginLockDuration << min(lockCount, 6)
+		if duration > loginMaxLockDuration {
+			duration = loginMaxLockDuration
+		}
+		if err := s.loginAttempts.Lock(ctx, req.Email, duration); err != nil {
+			log.Printf("Error locking account: %v", err)
+		}
+
+		now := time.Now()
+		lockedUntil := now.Add(duration)
+		if user != nil {
+			user.LockedUntil = &lockedUntil
+			user.LockCount++
+			user.LastLockedAt = &now
+			if err := s.repo.Update(user); err != nil {
+				log.Printf("Error recording lockout of user %d: %v", user.UserID, err)
+			}
+			log.Printf("User %d locked until %s after %d failed logins", user.UserID, lockedUntil.Format(time.RFC3339), accountFailures)
+		}
+		return accountLockedError(lockedUntil)
+	}
+
+	if accountFailures > loginDelayAfter {
+		delay := time.Duration(accountFailures-loginDelayAfter) * time.Second
+		if delay > loginMaxDelay {
+			delay = loginMaxDelay
+		}
+		if err := s.loginAttempts.Throttle(ctx, req.Email, delay); err != nil {
+			log.Printf("Error throttling login: %v", err)
+			return invalid
+		}
+		return loginThrottledError(delay)
 	}
-	refreshToken, err := s.jwtService.GenerateRefreshToken(user)
+	return invalid
+}
+
+func loginThrottledError(wait time.Duration) error {
+	return errors.NewErrorWithRetryAfter(errors.TOO_MANY_REQUESTS, "Invalid email or password, try again later", http.StatusTooManyRequests, nil, wait)
+}
+
+func accountLockedError(until time.Time) error {
+	return errors.NewErrorWithRetryAfter(errors.ACCOUNT_LOCKED,
+		fmt.Sprintf("Too many failed login attempts, account is locked until %s", until.Format(time.RFC3339)),
+		http.StatusLocked, nil, time.Until(until))
+}
+
+// UnlockUser lifts the login lockout of the user
+func (s *authService) UnlockUser(ctx context.Context, adminID uint, userID uint) (*models.User, error) {
+	user, err := s.repo.FindByID(userID)
 	if err != nil {
-		return nil, err
+		return nil, errors.NewError(errors.ITEM_NOT_FOUND, "User not found", http.StatusNotFound, err)
+	}
+	if err := s.loginAttempts.Reset(ctx, user.Email); err != nil {
+		return nil, errors.NewError(errors.INTERNAL_ERROR, "Error resetting login failures", http.StatusInternalServerError, err)
 	}
 
-	return &dto.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		User:         user,
-	}, nil
+	now := time.Now()
+	user.LockedUntil = nil
+	user.LastUnlockedAt = &now
+	user.UnlockedBy = &adminID
+	if err := s.repo.Update(user); err != nil {
+		return nil, errors.NewError(errors.INTERNAL_ERROR, "Error updating user", http.StatusInternalServerError, err)
+	}
+	log.Printf("User %d unlocked by admin %d", user.UserID, adminID)
+	return user, nil
+}
+
+func (s *authService) ListLockedUsers() ([]*models.User, error) {
+	users, err := s.repo.ListLocked()
+	if err != nil {
+		return nil, errors.NewError(errors.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return users, nil
 }
 
 func (s *authService) GetProfile(userID uint) (*models.User, error) {
 	return s.repo.FindByID(userID)
 }
 
-func (s *authService) RefreshToken(refreshToken string) (string, error) {
-	claims, err := s.jwtService.ValidateToken(refreshToken)
+// RefreshToken rotates the refresh token, using a rotated token again revokes its whole session
+func (s *authService) RefreshToken(ctx context.Context, req dto.RefreshTokenRequest) (*dto.AuthResponse, error) {
+	claims, err := s.jwtService.ValidateRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.refreshTokenRepo.GetByTokenID(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current.RotatedAt != nil && current.RevokedAt == nil {
+		if err := s.revokeSession(ctx, current.UserID, current.SessionID); err != nil {
+			return nil, err
+		}
+		return nil, errors.NewError(errors.UNAUTHORIZED, "Refresh token reuse detected, session revoked", http.StatusUnauthorized, nil)
+	}
+
+	user, err := s.repo.FindByID(current.UserID)
+	if err != nil || user.Active != uint8(1) {
+		return nil, errors.NewError(errors.ITEM_NOT_FOUND, "User not found", http.StatusUnauthorized, nil)
+	}
+
+	client := req.ClientInfo
+	if client.Device == "" {
+		client.Device = current.Device
+	}
+	resp, next, err := s.issueTokens(user, current.SessionID, client)
+	if err != nil {
+		return nil, err
+	}
+	rotated, err := s.refreshTokenRepo.Rotate(ctx, current.TokenID, next)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Lost the race to a concurrent refresh or the token is revoked/expired
+		return nil, errors.NewError(errors.UNAUTHORIZED, "Invalid refresh token", http.StatusUnauthorized, nil)
+	}
+	return resp, nil
+}
+
+// Logout closes the session of the current access token
+func (s *authService) Logout(ctx context.Context, userID uint, sessionID string) error {
+	return s.revokeSession(ctx, userID, sessionID)
+}
+
+// LogoutAll closes every session of the user
+func (s *authService) LogoutAll(ctx context.Context, userID uint) error {
+	if err := s.refreshTokenRepo.RevokeAllByUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.denylist.RevokeUser(ctx, userID, jwt.AccessTokenTTL); err != nil {
+		return errors.NewError(errors.INTERNAL_ERROR, "Error revoking access tokens", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// SendVerificationEmail mails a new verification token, unknown or verified emails are ignored
+// so the endpoint can't be used to find registered emails
+func (s *authService) SendVerificationEmail(ctx context.Context, email string) error {
+	if err := s.limit(ctx, "mail:"+string(models.AuthTokenEmailVerification), email, authMailLimit, authMailWindow); err != nil {
+		return err
+	}
+	user, err := s.repo.FindByEmail(email)
+	if err != nil || user.Active != uint8(1) || user.EmailVerifiedAt != nil {
+		return nil
+	}
+	// Lỗi gửi mail chỉ ghi log, phản hồi giống hệt trường hợp email không tồn tại
+	if err := s.sendAuthMail(ctx, user, models.AuthTokenEmailVerification); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", user.UserID, err)
+	}
+	return nil
+}
+
+func (s *authService) VerifyEmail(ctx context.Context, req dto.VerifyEmailRequest) error {
+	user, err := s.consumeAuthToken(ctx, req.Email, req.Token, models.AuthTokenEmailVerification)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := s.repo.Update(user); err != nil {
+		return errors.NewError(errors.INTERNAL_ERROR, "Error updating user", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// ForgotPassword mails a password reset token, unknown emails are ignored
+func (s *authService) ForgotPassword(ctx context.Context, email string) error {
+	if err := s.limit(ctx, "mail:"+string(models.AuthTokenPasswordReset), email, authMailLimit, authMailWindow); err != nil {
+		return err
+	}
+	user, err := s.repo.FindByEmail(email)
+	if err != nil || user.Active != uint8(1) {
+		return nil
+	}
+	// Lỗi gửi mail chỉ ghi log, phản hồi giống hệt trường hợp email không tồn tại
+	if err := s.sendAuthMail(ctx, user, models.AuthTokenPasswordReset); err != nil {
+		log.Printf("Error sending password reset email to user %d: %v", user.UserID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets the new password and closes every session of the user
+func (s *authService) ResetPassword(ctx context.Context, req dto.ResetPasswordRequest) error {
+	user, err := s.consumeAuthToken(ctx, req.Email, req.Token, models.AuthTokenPasswordReset)
+	if err != nil {
+		return err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.NewError(errors.INTERNAL_ERROR, "Error generating password", http.StatusInternalServerError, err)
+	}
+	user.Password = string(hashed)
+	// Nhận được mail reset cũng là đã xác thực email
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.repo.Update(user); err != nil {
+		return errors.NewError(errors.INTERNAL_ERROR, "Error updating user", http.StatusInternalServerError, err)
+	}
+	return s.LogoutAll(ctx, user.UserID)
+}
+
+// consumeAuthToken checks the mailed token belongs to the email and uses it up
+func (s *authService) consumeAuthToken(ctx context.Context, email string, token string, purpose models.AuthTokenPurpose) (*models.User, error) {
+	if err := s.limit(ctx, "token:"+string(purpose), email, authTokenAttemptLimit, authTokenAttemptWindow); err != nil {
+		return nil, err
+	}
+
+	invalid := errors.NewError(errors.INVALID_INPUT, "Invalid or expired token", http.StatusBadRequest, nil)
+	user, err := s.repo.FindByEmail(email)
+	if err != nil || user.Active != uint8(1) {
+		return nil, invalid
+	}
+	record, err := s.authTokenRepo.GetByHash(ctx, purpose, hashAuthToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if record.UserID != user.UserID {
+		return nil, invalid
+	}
+	consumed, err := s.authTokenRepo.Consume(ctx, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, invalid
+	}
+	return user, nil
+}
+
+// sendAuthMail replaces the pending token of the purpose with a new one and mails it
+func (s *authService) sendAuthMail(ctx context.Context, user *models.User, purpose models.AuthTokenPurpose) error {
+	if err := s.authTokenRepo.InvalidateByUser(ctx, user.UserID, purpose); err != nil {
+		return err
+	}
+
+	token := jwt.NewTokenID()
+	ttl := emailVerificationTTL
+	if purpose == models.AuthTokenPasswordReset {
+		ttl = passwordResetTTL
+	}
+	if err := s.authTokenRepo.Create(ctx, &models.AuthToken{
+		UserID:    user.UserID,
+		Purpose:   purpose,
+		TokenHash: hashAuthToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return err
+	}
+
+	query := url.Values{"email": {user.Email}, "token": {token}}.Encode()
+	var msg mail.Message
+	switch purpose {
+	case models.AuthTokenEmailVerification:
+		msg = mail.Message{
+			To:      user.Email,
+			Subject: "Verify your email",
+			Body: fmt.Sprintf("Hi %s,\n\nConfirm your email by opening the link below, it expires in %s.\n\n%s/verify-email?%s\n",
+				user.FullName, ttl, config.FrontendURL, query),
+		}
+	default:
+		msg = mail.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Hi %s,\n\nReset your password by opening the link below, it expires in %s.\nIgnore this email if you did not ask for it.\n\n%s/reset-password?%s\n",
+				user.FullName, ttl, config.FrontendURL, query),
+		}
+	}
+	if err := s.mailSender.Send(ctx, msg); err != nil {
+		return errors.NewError(errors.PROCESSING_FAILED, "Error sending email", http.StatusBadGateway, err)
+	}
+	return nil
+}
+
+// limit rate-limits an action per email, Redis errors let the request through
+func (s *authService) limit(ctx context.Context, action string, email string, limit int64, window time.Duration) error {
+	allowed, err := s.limiter.Allow(ctx, "auth:"+action+":"+strings.ToLower(email), limit, window)
 	if err != nil {
-		return "", err
+		log.Printf("Error checking rate limit: %v", err)
+		return nil
 	}
+	if !allowed {
+		return errors.NewError(errors.TOO_MANY_REQUESTS, "Too many requests, try again later", http.StatusTooManyRequests, nil)
+	}
+	return nil
+}
+
+func hashAuthToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
 
-	user, err := s.repo.FindByID(claims.UserID)
+// revokeSession revokes the refresh tokens of the session and denies its access tokens
+func (s *authService) revokeSession(ctx context.Context, userID uint, sessionID string) error {
+	if err := s.refreshTokenRepo.RevokeSession(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	if err := s.denylist.RevokeSession(ctx, sessionID, jwt.AccessTokenTTL); err != nil {
+		return errors.NewError(errors.INTERNAL_ERROR, "Error revoking access tokens", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// openSession starts a new session for the user
+func (s *authService) openSession(user *models.User, client dto.ClientInfo) (*dto.AuthResponse, error) {
+	resp, refreshToken, err := s.issueTokens(user, jwt.NewTokenID(), client)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshTokenRepo.Create(context.Background(), refreshToken); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// issueTokens signs a token pair for the session, the refresh token still has to be saved
+func (s *authService) issueTokens(user *models.User, sessionID string, client dto.ClientInfo) (*dto.AuthResponse, *models.RefreshToken, error) {
+	accessToken, err := s.jwtService.GenerateToken(user, sessionID)
 	if err != nil {
-		return "", errors.NewError(errors.ITEM_NOT_FOUND, "User not found", http.StatusUnauthorized, nil)
+		return nil, nil, errors.NewError(errors.INTERNAL_ERROR, "Error generating access token", http.StatusInternalServerError, err)
+	}
+	refreshToken, claims, err := s.jwtService.GenerateRefreshToken(user, sessionID)
+	if err != nil {
+		return nil, nil, errors.NewError(errors.INTERNAL_ERROR, "Error generating refresh token", http.StatusInternalServerError, err)
 	}
 
-	return s.jwtService.GenerateToken(user)
+	record := &models.RefreshToken{
+		TokenID:   claims.ID,
+		SessionID: sessionID,
+		UserID:    user.UserID,
+		Device:    truncate(client.Device, 255),
+		UserAgent: truncate(client.UserAgent, 255),
+		IP:        client.IP,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	return &dto.AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		User:         user,
+	}, record, nil
 }
@@ -0,0 +1,371 @@
+package impl
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/minh6824pro/nxrGO/internal/cache"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/jwt"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+)
+
+type fakeAuthRepo struct {
+	repositories.AuthRepository
+	users map[uint]*models.User
+}
+
+func (f *fakeAuthRepo) FindByEmail(email string) (*models.User, error) {
+	for _, user := range f.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeAuthRepo) FindByID(id uint) (*models.User, error) {
+	if user, ok := f.users[id]; ok {
+		return user, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeAuthRepo) Update(user *models.User) error {
+	f.users[user.UserID] = user
+	return nil
+}
+
+// memoryRefreshTokenRepo follows the conditions of the gorm repository
+type memoryRefreshTokenRepo struct {
+	tokens map[string]*models.RefreshToken
+}
+
+func (m *memoryRefreshTokenRepo) Create(ctx context.Context, token *models.RefreshToken) error {
+	m.tokens[token.TokenID] = token
+	return nil
+}
+
+func (m *memoryRefreshTokenRepo) GetByTokenID(ctx context.Context, tokenID string) (*models.RefreshToken, error) {
+	token, ok := m.tokens[tokenID]
+	if !ok {
+		return nil, customErr.NewError(customErr.UNAUTHORIZED, "Invalid refresh token", http.StatusUnauthorized, nil)
+	}
+	found := *token
+	return &found, nil
+}
+
+func (m *memoryRefreshTokenRepo) Rotate(ctx context.Context, tokenID string, next *models.RefreshToken) (bool, error) {
+	token := m.tokens[tokenID]
+	if token == nil || token.RotatedAt != nil || token.RevokedAt != nil || !token.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+	now := time.Now()
+	token.RotatedAt = &now
+	m.tokens[next.TokenID] = next
+	return true, nil
+}
+
+func (m *memoryRefreshTokenRepo) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.UserID == userID && token.SessionID == sessionID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *memoryRefreshTokenRepo) RevokeAllByUser(ctx context.Context, userID uint) error {
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeDenylist struct {
+	cache.TokenDenylistService
+	revokedSessions []string
+}
+
+func (f *fakeDenylist) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	f.revokedSessions = append(f.revokedSessions, sessionID)
+	return nil
+}
+
+// memoryLoginAttempts counts failures without expiry, tests clear throttles to stand for the client waiting
+type memoryLoginAttempts struct {
+	accountFailures map[string]int64
+	ipFailures      map[string]int64
+	locks           map[string]time.Duration
+	throttles       map[string]time.Duration
+}
+
+func newMemoryLoginAttempts() *memoryLoginAttempts {
+	return &memoryLoginAttempts{
+		accountFailures: make(map[string]int64),
+		ipFailures:      make(map[string]int64),
+		locks:           make(map[string]time.Duration),
+		throttles:       make(map[string]time.Duration),
+	}
+}
+
+func (m *memoryLoginAttempts) RecordFailure(ctx context.Context, email string, ip string) (int64, int64, error) {
+	m.accountFailures[email]++
+	m.ipFailures[ip]++
+	return m.accountFailures[email], m.ipFailures[ip], nil
+}
+
+func (m *memoryLoginAttempts) IPFailures(ctx context.Context, ip string) (int64, error) {
+	return m.ipFailures[ip], nil
+}
+
+func (m *memoryLoginAttempts) Lock(ctx context.Context, email string, ttl time.Duration) error {
+	m.locks[email] = ttl
+	delete(m.accountFailures, email)
+	return nil
+}
+
+func (m *memoryLoginAttempts) LockedFor(ctx context.Context, email string) (time.Duration, error) {
+	return m.locks[email], nil
+}
+
+func (m *memoryLoginAttempts) Throttle(ctx context.Context, email string, ttl time.Duration) error {
+	m.throttles[email] = ttl
+	return nil
+}
+
+func (m *memoryLoginAttempts) ThrottledFor(ctx context.Context, email string) (time.Duration, error) {
+	return m.throttles[email], nil
+}
+
+func (m *memoryLoginAttempts) Reset(ctx context.Context, email string) error {
+	delete(m.accountFailures, email)
+	delete(m.throttles, email)
+	delete(m.locks, email)
+	return nil
+}
+
+const testPassword = "correct-password"
+
+func useTestKeyring(t *testing.T) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyring, err := jwt.NewKeyring("test", &jwt.SigningKey{ID: "test", Method: jwtlib.SigningMethodEdDSA, Private: private, Public: public})
+	if err != nil {
+		t.Fatalf("keyring: %v", err)
+	}
+	jwt.SetKeyring(keyring)
+	t.Cleanup(func() { jwt.SetKeyring(nil) })
+}
+
+type testAuth struct {
+	service   *authService
+	users     *fakeAuthRepo
+	tokens    *memoryRefreshTokenRepo
+	denylist  *fakeDenylist
+	attempts  *memoryLoginAttempts
+	aliceUser *models.User
+}
+
+func newTestAuthService(t *testing.T) *testAuth {
+	t.Helper()
+	useTestKeyring(t)
+	hashed, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	alice := &models.User{UserID: 1, Email: "alice@example.com", Password: string(hashed), Role: models.RoleUser, Active: 1}
+	a := &testAuth{
+		users:     &fakeAuthRepo{users: map[uint]*models.User{1: alice}},
+		tokens:    &memoryRefreshTokenRepo{tokens: make(map[string]*models.RefreshToken)},
+		denylist:  &fakeDenylist{},
+		attempts:  newMemoryLoginAttempts(),
+		aliceUser: alice,
+	}
+	a.service = NewAuthService(a.users, jwt.NewJWTService(), a.tokens, a.denylist, nil, nil, nil, a.attempts).(*authService)
+	return a
+}
+
+func (a *testAuth) login(t *testing.T) *dto.AuthResponse {
+	t.Helper()
+	resp, err := a.service.Login(dto.LoginRequest{Email: "alice@example.com", Password: testPassword, ClientInfo: dto.ClientInfo{IP: "10.0.0.1"}})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	return resp
+}
+
+func (a *testAuth) refresh(refreshToken string) (*dto.AuthResponse, error) {
+	return a.service.RefreshToken(context.Background(), dto.RefreshTokenRequest{RefreshToken: refreshToken})
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	a := newTestAuthService(t)
+	first := a.login(t)
+
+	second, err := a.refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refreshing must issue a new refresh token")
+	}
+	third, err := a.refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatalf("the rotated token must be usable: %v", err)
+	}
+
+	firstClaims, _ := jwt.NewJWTService().ValidateRefreshToken(first.RefreshToken)
+	thirdClaims, _ := jwt.NewJWTService().ValidateRefreshToken(third.RefreshToken)
+	if firstClaims.SessionID != thirdClaims.SessionID {
+		t.Fatal("rotation must keep the session")
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	a := newTestAuthService(t)
+	first := a.login(t)
+	second, err := a.refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	// The old token comes back, someone else holds a copy of it
+	if _, err := a.refresh(first.RefreshToken); err == nil {
+		t.Fatal("reusing a rotated refresh token must fail")
+	}
+	claims, _ := jwt.NewJWTService().ValidateRefreshToken(first.RefreshToken)
+	if len(a.denylist.revokedSessions) != 1 || a.denylist.revokedSessions[0] != claims.SessionID {
+		t.Fatalf("expected the access tokens of the session to be revoked, got %v", a.denylist.revokedSessions)
+	}
+	if _, err := a.refresh(second.RefreshToken); err == nil {
+		t.Fatal("the latest token of a revoked session must fail too")
+	}
+}
+
+func TestRefreshTokenRejectsAccessToken(t *testing.T) {
+	a := newTestAuthService(t)
+	resp := a.login(t)
+	if _, err := a.refresh(resp.AccessToken); err == nil {
+		t.Fatal("an access token must not refresh")
+	}
+}
+
+func (a *testAuth) loginWith(password string) error {
+	_, err := a.service.Login(dto.LoginRequest{Email: "alice@example.com", Password: password, ClientInfo: dto.ClientInfo{IP: "10.0.0.1"}})
+	return err
+}
+
+func errorStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	return customErr.ParseError(err).HTTPCode
+}
+
+func TestLoginThrottlesThenLocksRepeatedFailures(t *testing.T) {
+	a := newTestAuthService(t)
+
+	want := []struct {
+		status     int
+		retryAfter time.Duration
+	}{
+		{http.StatusUnauthorized, 0},
+		{http.StatusUnauthorized, 0},
+		{http.StatusTooManyRequests, time.Second},
+		{http.StatusTooManyRequests, 2 * time.Second},
+		{http.StatusLocked, loginLockDuration},
+	}
+	for i, w := range want {
+		// The client waits for Retry-After before trying again
+		a.attempts.throttles = make(map[string]time.Duration)
+		err := a.loginWith("wrong")
+		if got := errorStatus(err); got != w.status {
+			t.Fatalf("failure %d: expected status %d, got %d", i+1, w.status, got)
+		}
+		if w.retryAfter == 0 {
+			continue
+		}
+		retryAfter := customErr.ParseError(err).RetryAfter
+		if retryAfter > w.retryAfter || retryAfter < w.retryAfter-5*time.Second {
+			t.Fatalf("failure %d: expected Retry-After about %s, got %s", i+1, w.retryAfter, retryAfter)
+		}
+	}
+
+	if a.aliceUser.LockCount != 1 || a.aliceUser.LockedUntil == nil {
+		t.Fatalf("expected the lockout to be recorded on the user, got %+v", a.aliceUser)
+	}
+	if a.attempts.locks["alice@example.com"] != loginLockDuration {
+		t.Fatalf("expected a %s lock, got %s", loginLockDuration, a.attempts.locks["alice@example.com"])
+	}
+	// Even the right password is refused while locked
+	if got := errorStatus(a.loginWith(testPassword)); got != http.StatusLocked {
+		t.Fatalf("expected 423 while locked, got %d", got)
+	}
+}
+
+func TestLoginRefusesThrottledAttemptWithoutCheckingPassword(t *testing.T) {
+	a := newTestAuthService(t)
+	a.attempts.throttles["alice@example.com"] = 3 * time.Second
+
+	err := a.loginWith(testPassword)
+	if got := errorStatus(err); got != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", got)
+	}
+	if customErr.ParseError(err).RetryAfter != 3*time.Second {
+		t.Fatalf("expected Retry-After of the remaining throttle, got %s", customErr.ParseError(err).RetryAfter)
+	}
+	if a.attempts.accountFailures["alice@example.com"] != 0 {
+		t.Fatal("a throttled attempt must not count as a failure")
+	}
+}
+
+func TestLoginLockDoublesWithEveryLockout(t *testing.T) {
+	a := newTestAuthService(t)
+	a.aliceUser.LockCount = 2
+	a.attempts.accountFailures["alice@example.com"] = loginLockThreshold - 1
+
+	if got := errorStatus(a.loginWith("wrong")); got != http.StatusLocked {
+		t.Fatalf("expected 423, got %d", got)
+	}
+	if got := a.attempts.locks["alice@example.com"]; got != 4*loginLockDuration {
+		t.Fatalf("expected a %s lock, got %s", 4*loginLockDuration, got)
+	}
+}
+
+func TestLoginBlocksIPOverLimit(t *testing.T) {
+	a := newTestAuthService(t)
+	a.attempts.ipFailures["10.0.0.1"] = loginIPLimit
+
+	if got := errorStatus(a.loginWith(testPassword)); got != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", got)
+	}
+}
+
+func TestLoginSuccessResetsFailures(t *testing.T) {
+	a := newTestAuthService(t)
+	if err := a.loginWith("wrong"); err == nil {
+		t.Fatal("expected the wrong password to fail")
+	}
+	a.login(t)
+	if a.attempts.accountFailures["alice@example.com"] != 0 {
+		t.Fatal("a successful login must reset the failures")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/event"
 	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/repositories"
 	"github.com/minh6824pro/nxrGO/internal/services"
@@ -11,11 +12,13 @@ import (
 )
 
 type brandService struct {
-	repo repositories.BrandRepository
+	db       *gorm.DB
+	repo     repositories.BrandRepository
+	eventBus event.EventPublisher
 }
 
-func NewBrandService(repo repositories.BrandRepository) services.BrandService {
-	return &brandService{repo}
+func NewBrandService(db *gorm.DB, repo repositories.BrandRepository, eventBus event.EventPublisher) services.BrandService {
+	return &brandService{db, repo, eventBus}
 }
 
 func (brandService *brandService) Create(ctx context.Context, b *dto.CreateBrandInput) (*models.Brand, error) {
@@ -36,7 +39,12 @@ func (brandService *brandService) Update(ctx context.Context, b *models.Brand) e
 	}
 
 	existing.Name = b.Name
-	return brandService.repo.Update(ctx, existing)
+	return brandService.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := brandService.repo.UpdateTx(ctx, tx, existing); err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, brandService.eventBus, event.CatalogBrand, existing.ID, false)
+	})
 }
 
 func (brandService *brandService) Delete(ctx context.Context, id uint) error {
@@ -44,7 +52,12 @@ func (brandService *brandService) Delete(ctx context.Context, id uint) error {
 	if err != nil {
 		return err
 	}
-	return brandService.repo.Delete(ctx, id)
+	return brandService.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := brandService.repo.DeleteTx(ctx, tx, id); err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, brandService.eventBus, event.CatalogBrand, id, true)
+	})
 }
 
 func (brandService *brandService) List(ctx context.Context) ([]models.Brand, error) {
@@ -60,7 +73,13 @@ func (brandService *brandService) Patch(ctx context.Context, id uint, input *dto
 	if input.Name != "" {
 		existing.Name = input.Name
 	}
-	if err := brandService.repo.Update(ctx, existing); err != nil {
+	err = brandService.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := brandService.repo.UpdateTx(ctx, tx, existing); err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, brandService.eventBus, event.CatalogBrand, id, false)
+	})
+	if err != nil {
 		return nil, err
 	}
 
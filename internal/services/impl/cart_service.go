@@ -0,0 +1,391 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/cache"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"log"
+	"net/http"
+)
+
+type cartService struct {
+	repo               repositories.CartRepository
+	cartCache          cache.CartCacheService
+	productVariantRepo repositories.ProductVariantRepository
+	orderService       services.OrderService
+	promotionService   services.PromotionService
+}
+
+func NewCartService(repo repositories.CartRepository, cartCache cache.CartCacheService,
+	productVariantRepo repositories.ProductVariantRepository, orderService services.OrderService,
+	promotionService services.PromotionService) services.CartService {
+	return &cartService{
+		repo:               repo,
+		cartCache:          cartCache,
+		productVariantRepo: productVariantRepo,
+		orderService:       orderService,
+		promotionService:   promotionService,
+	}
+}
+
+func (s *cartService) Get(ctx context.Context, userID uint) (*dto.CartResponse, error) {
+	items, err := s.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cart, _, err := s.revalidate(ctx, items)
+	return cart, err
+}
+
+// AddItem adds the quantity to the line of the variant, the total must fit the available stock
+func (s *cartService) AddItem(ctx context.Context, userID uint, input dto.CartItemInput) (*dto.CartResponse, error) {
+	items, err := s.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	quantity := input.Quantity
+	for _, item := range items {
+		if item.ProductVariantID == input.ProductVariantID {
+			quantity += item.Quantity
+			break
+		}
+	}
+	return s.setQuantity(ctx, userID, input.ProductVariantID, quantity)
+}
+
+func (s *cartService) UpdateItem(ctx context.Context, userID uint, productVariantID uint, input dto.UpdateCartItemInput) (*dto.CartResponse, error) {
+	items, err := s.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if findCartItem(items, productVariantID) == nil {
+		return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Item not found in cart", http.StatusNotFound, nil)
+	}
+	return s.setQuantity(ctx, userID, productVariantID, input.Quantity)
+}
+
+func (s *cartService) RemoveItem(ctx context.Context, userID uint, productVariantID uint) (*dto.CartResponse, error) {
+	if err := s.repo.Delete(ctx, userID, productVariantID); err != nil {
+		return nil, err
+	}
+	items, err := s.refresh(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cart, _, err := s.revalidate(ctx, items)
+	return cart, err
+}
+
+func (s *cartService) Clear(ctx context.Context, userID uint) error {
+	if err := s.repo.DeleteByUserId(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.cartCache.Delete(ctx, userID); err != nil {
+		log.Printf("cart: failed to drop cache of user %d: %v", userID, err)
+	}
+	return nil
+}
+
+// Merge adds the cart kept by the client before login, quantities are summed and capped at the available stock.
+// Variants no longer sold are skipped.
+func (s *cartService) Merge(ctx context.Context, userID uint, input dto.MergeCartInput) (*dto.CartResponse, error) {
+	items, err := s.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	quantities := make(map[uint]uint)
+	var ids []uint
+	for _, in := range input.Items {
+		if _, ok := quantities[in.ProductVariantID]; !ok {
+			ids = append(ids, in.ProductVariantID)
+		}
+		quantities[in.ProductVariantID] += in.Quantity
+	}
+	for _, item := range items {
+		if q, ok := quantities[item.ProductVariantID]; ok {
+			quantities[item.ProductVariantID] = q + item.Quantity
+		}
+	}
+
+	variants, err := s.listVariants(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		variant, ok := variants[id]
+		if !ok || !variantOnSale(variant) || variant.Quantity == 0 {
+			continue
+		}
+		if err := s.repo.Upsert(ctx, &models.CartItem{
+			UserID:           userID,
+			ProductVariantID: id,
+			Quantity:         min(quantities[id], variant.Quantity),
+			PriceAtAdd:       variant.Price,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	items, err = s.refresh(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cart, _, err := s.revalidate(ctx, items)
+	return cart, err
+}
+
+// Checkout builds the order from the cart with the current prices, shipping fees and coupon,
+// the ordered lines are removed from the cart once the order is created
+func (s *cartService) Checkout(ctx context.Context, userID uint, input dto.CheckoutCartInput) (*dto.CreateOrderResponse, error) {
+	items, err := s.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Cart is empty", http.StatusBadRequest, nil)
+	}
+	cart, infos, err := s.revalidate(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+	if !cart.Valid {
+		return nil, customErr.NewError(customErr.INSUFFICIENT_STOCK, "Some items in cart are unavailable or out of stock", http.StatusConflict, nil)
+	}
+
+	address, err := s.orderService.ResolveShippingAddress(ctx, userID, input.AddressID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make(map[uint]uint)
+	for _, d := range input.Deliveries {
+		deliveries[d.MerchantID] = d.DeliveryID
+	}
+
+	orderInput := dto.CreateOrderInput{
+		UserID:        userID,
+		PaymentMethod: input.PaymentMethod,
+		AddressID:     &input.AddressID,
+		CouponCode:    input.CouponCode,
+	}
+	var variantIDs []uint
+	var lines []dto.PromotionLine
+	merchants := make(map[uint]bool)
+	shippingFees := make(map[uint]float64)
+	for _, line := range cart.Items {
+		info := infos[line.ProductVariantID]
+		orderInput.OrderItems = append(orderInput.OrderItems, dto.CreateOrderItem{
+			ProductVariantID: info.ID,
+			Quantity:         line.Quantity,
+			Price:            info.Price,
+			Timestamp:        info.Timestamp,
+			Signature:        info.Signature,
+			MerchantID:       info.MerchantID,
+		})
+		orderInput.Total += info.Price * float64(line.Quantity)
+		variantIDs = append(variantIDs, line.ProductVariantID)
+		lines = append(lines, dto.PromotionLine{
+			ProductVariantID: info.ID,
+			MerchantID:       info.MerchantID,
+			Price:            info.Price,
+			Quantity:         line.Quantity,
+		})
+
+		if merchants[info.MerchantID] {
+			continue
+		}
+		fee, err := s.shippingFee(ctx, info.MerchantID, deliveries, address)
+		if err != nil {
+			return nil, err
+		}
+		merchants[info.MerchantID] = true
+		shippingFees[info.MerchantID] = fee.Fee
+		orderInput.ShippingFeeInput = append(orderInput.ShippingFeeInput, *fee)
+		orderInput.ShippingFee += fee.Fee
+	}
+	orderInput.Total += orderInput.ShippingFee
+
+	if input.CouponCode != "" {
+		quote, err := s.promotionService.Evaluate(ctx, userID, input.CouponCode, lines, shippingFees)
+		if err != nil {
+			return nil, err
+		}
+		orderInput.Total -= quote.TotalDiscount()
+	}
+
+	order, err := s.orderService.Create(ctx, orderInput)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.DeleteVariants(ctx, userID, variantIDs); err != nil {
+		log.Printf("cart: failed to remove ordered items of user %d: %v", userID, err)
+	}
+	if _, err := s.refresh(ctx, userID); err != nil {
+		log.Printf("cart: failed to refresh cache of user %d: %v", userID, err)
+	}
+	return order, nil
+}
+
+// shippingFee returns the signed fee of the delivery chosen for the merchant
+func (s *cartService) shippingFee(ctx context.Context, merchantID uint, deliveries map[uint]uint, address *models.Address) (*dto.ShippingFeeResponse, error) {
+	deliveryID, ok := deliveries[merchantID]
+	if !ok {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, fmt.Sprintf("Delivery for merchant %d is required", merchantID), http.StatusBadRequest, nil)
+	}
+	fees, err := s.orderService.CalculateShippingFees(ctx, merchantID, address.Longitude, address.Latitude)
+	if err != nil {
+		return nil, err
+	}
+	for _, fee := range fees {
+		if fee.DeliveryID == deliveryID {
+			return fee, nil
+		}
+	}
+	return nil, customErr.NewError(customErr.BAD_REQUEST, fmt.Sprintf("Delivery %d not found", deliveryID), http.StatusBadRequest, nil)
+}
+
+func (s *cartService) setQuantity(ctx context.Context, userID uint, productVariantID uint, quantity uint) (*dto.CartResponse, error) {
+	variants, err := s.listVariants(ctx, []uint{productVariantID})
+	if err != nil {
+		return nil, err
+	}
+	variant, ok := variants[productVariantID]
+	if !ok || !variantOnSale(variant) {
+		return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Product variant not found", http.StatusNotFound, nil)
+	}
+	if quantity > variant.Quantity {
+		return nil, customErr.NewError(customErr.INSUFFICIENT_STOCK, fmt.Sprintf("Only %d items left in stock", variant.Quantity), http.StatusBadRequest, nil)
+	}
+
+	if err := s.repo.Upsert(ctx, &models.CartItem{
+		UserID:           userID,
+		ProductVariantID: productVariantID,
+		Quantity:         quantity,
+		PriceAtAdd:       variant.Price,
+	}); err != nil {
+		return nil, err
+	}
+	items, err := s.refresh(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cart, _, err := s.revalidate(ctx, items)
+	return cart, err
+}
+
+// load reads the cart from Redis, falling back to the database when it is not cached or Redis is down
+func (s *cartService) load(ctx context.Context, userID uint) ([]models.CartItem, error) {
+	items, ok, err := s.cartCache.Get(ctx, userID)
+	if err != nil {
+		log.Printf("cart: failed to read cache of user %d: %v", userID, err)
+	}
+	if ok {
+		return items, nil
+	}
+	return s.refresh(ctx, userID)
+}
+
+// refresh reads the cart from the database and writes it back to Redis
+func (s *cartService) refresh(ctx context.Context, userID uint) ([]models.CartItem, error) {
+	items, err := s.repo.ListByUserId(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cartCache.Set(ctx, userID, items); err != nil {
+		log.Printf("cart: failed to write cache of user %d: %v", userID, err)
+	}
+	return items, nil
+}
+
+// revalidate checks every line against the current price and available stock.
+// It also returns the signed variant info used to build the order on checkout.
+func (s *cartService) revalidate(ctx context.Context, items []models.CartItem) (*dto.CartResponse, map[uint]dto.VariantCartInfoResponse, error) {
+	cart := &dto.CartResponse{Items: []dto.CartLineResponse{}, Valid: true}
+	infos := make(map[uint]dto.VariantCartInfoResponse)
+	if len(items) == 0 {
+		return cart, infos, nil
+	}
+
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductVariantID)
+	}
+	variants, err := s.listVariants(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var onSale []models.ProductVariant
+	for _, id := range ids {
+		if variant, ok := variants[id]; ok && variantOnSale(variant) {
+			onSale = append(onSale, variant)
+		}
+	}
+	list, err := MapToVariantCartResponse(ctx, onSale)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, info := range list {
+		infos[info.ID] = info
+	}
+
+	for _, item := range items {
+		line := dto.CartLineResponse{
+			ProductVariantID: item.ProductVariantID,
+			Quantity:         item.Quantity,
+			PriceAtAdd:       item.PriceAtAdd,
+		}
+		if info, ok := infos[item.ProductVariantID]; ok {
+			line.Available = true
+			line.AvailableQuantity = info.Quantity
+			line.UnitPrice = info.Price
+			line.PriceChanged = info.Price != item.PriceAtAdd
+			line.InStock = item.Quantity <= info.Quantity
+			line.Subtotal = info.Price * float64(item.Quantity)
+			line.Variant = &info
+			cart.Subtotal += line.Subtotal
+		}
+		if !line.Available || !line.InStock {
+			cart.Valid = false
+		}
+		cart.Items = append(cart.Items, line)
+	}
+	return cart, infos, nil
+}
+
+// listVariants returns the variants by id, quantities already exclude reserved stock
+func (s *cartService) listVariants(ctx context.Context, ids []uint) (map[uint]models.ProductVariant, error) {
+	result := make(map[uint]models.ProductVariant)
+	if len(ids) == 0 {
+		return result, nil
+	}
+	variants, err := s.productVariantRepo.ListByIds(ctx, dto.ListProductVariantIds{Ids: ids})
+	if err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	for _, variant := range variants {
+		result[variant.ID] = variant
+	}
+	return result, nil
+}
+
+// variantOnSale is false when the product was deleted or deactivated
+func variantOnSale(variant models.ProductVariant) bool {
+	return variant.Product.ID != 0 && variant.Product.Active
+}
+
+func findCartItem(items []models.CartItem, productVariantID uint) *models.CartItem {
+	for i := range items {
+		if items[i].ProductVariantID == productVariantID {
+			return &items[i]
+		}
+	}
+	return nil
+}
@@ -3,17 +3,21 @@ package impl
 import (
 	"context"
 	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/event"
 	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/repositories"
 	"github.com/minh6824pro/nxrGO/internal/services"
+	"gorm.io/gorm"
 )
 
 type categoryService struct {
-	repo repositories.CategoryRepository
+	db       *gorm.DB
+	repo     repositories.CategoryRepository
+	eventBus event.EventPublisher
 }
 
-func NewCategoryService(r repositories.CategoryRepository) services.CategoryService {
-	return &categoryService{r}
+func NewCategoryService(db *gorm.DB, r repositories.CategoryRepository, eventBus event.EventPublisher) services.CategoryService {
+	return &categoryService{db, r, eventBus}
 }
 
 func (categoryService *categoryService) Create(ctx context.Context, c *dto.CreateCategoryInput) (*models.Category, error) {
@@ -25,7 +29,12 @@ func (categoryService *categoryService) GetByID(ctx context.Context, id uint) (*
 }
 
 func (categoryService *categoryService) Update(ctx context.Context, c *models.Category) error {
-	return categoryService.repo.Update(ctx, c)
+	return categoryService.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := categoryService.repo.UpdateTx(ctx, tx, c); err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, categoryService.eventBus, event.CatalogCategory, c.ID, false)
+	})
 }
 
 func (categoryService *categoryService) Delete(ctx context.Context, id uint) error {
@@ -35,7 +44,12 @@ func (categoryService *categoryService) Delete(ctx context.Context, id uint) err
 		return err
 	}
 
-	return categoryService.repo.Delete(ctx, id)
+	return categoryService.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := categoryService.repo.DeleteTx(ctx, tx, id); err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, categoryService.eventBus, event.CatalogCategory, id, true)
+	})
 }
 
 func (categoryService *categoryService) List(ctx context.Context) ([]models.Category, error) {
@@ -55,7 +69,13 @@ func (categoryService *categoryService) Patch(ctx context.Context, id uint, inpu
 		existing.Description = *input.Description
 	}
 
-	if err := categoryService.repo.Update(ctx, existing); err != nil {
+	err = categoryService.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := categoryService.repo.UpdateTx(ctx, tx, existing); err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, categoryService.eventBus, event.CatalogCategory, id, false)
+	})
+	if err != nil {
 		return nil, err
 	}
 
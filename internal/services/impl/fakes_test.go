@@ -0,0 +1,62 @@
+package impl
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/minh6824pro/nxrGO/internal/event"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"sync"
+	"testing"
+)
+
+// txDriver is a database/sql driver that only opens and ends transactions, so services can run their
+// transactions in tests while every query goes through hand-written repository fakes
+type txDriver struct{}
+
+type txConn struct{}
+
+type txNoop struct{}
+
+func (txDriver) Open(name string) (driver.Conn, error) { return txConn{}, nil }
+
+func (txConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("unexpected query in test: " + query)
+}
+func (txConn) Close() error              { return nil }
+func (txConn) Begin() (driver.Tx, error) { return txNoop{}, nil }
+
+func (txNoop) Commit() error   { return nil }
+func (txNoop) Rollback() error { return nil }
+
+var registerTxDriver sync.Once
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	registerTxDriver.Do(func() { sql.Register("txonly", txDriver{}) })
+	conn, err := sql.Open("txonly", "")
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+	return db
+}
+
+// fakeEventBus records the catalog changes written to the outbox
+type fakeEventBus struct {
+	event.EventPublisher
+	catalog []event.CatalogChangedEvent
+}
+
+func (f *fakeEventBus) PublishCatalogChangedTx(tx *gorm.DB, e event.CatalogChangedEvent) error {
+	f.catalog = append(f.catalog, e)
+	return nil
+}
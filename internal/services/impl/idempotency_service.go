@@ -0,0 +1,106 @@
+package impl
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyLease is how long an in-progress key stays claimed without renewal,
+	// a crashed request frees its key once the lease runs out
+	idempotencyLease = 30 * time.Second
+)
+
+type idempotencyService struct {
+	idempotencyKeyRepo repositories.IdempotencyKeyRepository
+}
+
+func NewIdempotencyService(idempotencyKeyRepo repositories.IdempotencyKeyRepository) services.IdempotencyService {
+	return &idempotencyService{idempotencyKeyRepo: idempotencyKeyRepo}
+}
+
+// Begin claims the key for a new request. When the key was already used with the same fingerprint
+// it returns the stored record and replay = true, with another fingerprint it returns a conflict.
+func (i *idempotencyService) Begin(ctx context.Context, userID uint, key string, fingerprint string) (*models.IdempotencyKey, bool, error) {
+	// Second attempt runs after an expired record is removed
+	for attempt := 0; attempt < 2; attempt++ {
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      models.IdempotencyInProgress,
+			ExpiresAt:   time.Now().Add(idempotencyLease),
+		}
+		created, err := i.idempotencyKeyRepo.CreateIfAbsent(ctx, record)
+		if err != nil {
+			return nil, false, err
+		}
+		if created {
+			return record, false, nil
+		}
+
+		existing, err := i.idempotencyKeyRepo.GetByUserIdAndKey(ctx, userID, key)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing.ExpiresAt.Before(time.Now()) {
+			if err := i.idempotencyKeyRepo.Delete(ctx, existing.ID); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+		if existing.Fingerprint != fingerprint {
+			return nil, false, customErr.NewError(customErr.IDEMPOTENCY_KEY_CONFLICT, "Idempotency-Key was already used with a different request", http.StatusUnprocessableEntity, nil)
+		}
+		if existing.Status != models.IdempotencyCompleted {
+			return nil, false, customErr.NewError(customErr.IDEMPOTENCY_KEY_IN_PROGRESS, "A request with this Idempotency-Key is still being processed", http.StatusConflict, nil)
+		}
+		return existing, true, nil
+	}
+	return nil, false, customErr.NewError(customErr.IDEMPOTENCY_KEY_IN_PROGRESS, "A request with this Idempotency-Key is still being processed", http.StatusConflict, nil)
+}
+
+func (i *idempotencyService) Complete(ctx context.Context, record *models.IdempotencyKey, responseCode int, responseBody []byte) error {
+	record.Status = models.IdempotencyCompleted
+	record.ResponseCode = responseCode
+	record.ResponseBody = string(responseBody)
+	record.ExpiresAt = time.Now().Add(idempotencyKeyTTL)
+	return i.idempotencyKeyRepo.SaveResponse(ctx, record.ID, responseCode, record.ResponseBody, record.ExpiresAt)
+}
+
+// Renew extends the lease of a request still being handled
+func (i *idempotencyService) Renew(ctx context.Context, record *models.IdempotencyKey) error {
+	expiresAt := time.Now().Add(idempotencyLease)
+	renewed, err := i.idempotencyKeyRepo.ExtendLease(ctx, record.ID, expiresAt)
+	if err != nil {
+		return err
+	}
+	if !renewed {
+		return customErr.NewError(customErr.IDEMPOTENCY_KEY_IN_PROGRESS, "Idempotency key is no longer held by this request", http.StatusConflict, nil)
+	}
+	record.ExpiresAt = expiresAt
+	return nil
+}
+
+// Abort releases the key so the client can retry, used when the handler panicked before writing a response
+func (i *idempotencyService) Abort(ctx context.Context, record *models.IdempotencyKey) error {
+	return i.idempotencyKeyRepo.Delete(ctx, record.ID)
+}
+
+func (i *idempotencyService) DeleteExpired(ctx context.Context) error {
+	deleted, err := i.idempotencyKeyRepo.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %d expired idempotency keys", deleted)
+	}
+	return nil
+}
@@ -5,20 +5,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/event"
 	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/repositories"
 	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
 	"net/http"
 	"net/url"
 	"time"
 )
 
 type merchantService struct {
-	repo repositories.MerchantRepository
+	db       *gorm.DB
+	repo     repositories.MerchantRepository
+	authRepo repositories.AuthRepository
+	eventBus event.EventPublisher
 }
 
-func NewMerchantService(repo repositories.MerchantRepository) services.MerchantService {
-	return &merchantService{repo}
+func NewMerchantService(db *gorm.DB, repo repositories.MerchantRepository, authRepo repositories.AuthRepository, eventBus event.EventPublisher) services.MerchantService {
+	return &merchantService{db: db, repo: repo, authRepo: authRepo, eventBus: eventBus}
 }
 
 func (merchantService *merchantService) Create(ctx context.Context, m *dto.CreateMerchantInput) (*models.Merchant, error) {
@@ -71,6 +77,26 @@ func (merchantService *merchantService) GetByID(ctx context.Context, id uint) (*
 	return merchantService.repo.GetByID(ctx, id)
 }
 
+// AddMember turns a user into a MERCHANT managing the merchant, the new role applies from the next login
+func (merchantService *merchantService) AddMember(ctx context.Context, merchantID uint, userID uint) (*models.User, error) {
+	if _, err := merchantService.repo.GetByID(ctx, merchantID); err != nil {
+		return nil, err
+	}
+	user, err := merchantService.authRepo.FindByID(userID)
+	if err != nil {
+		return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "User not found", http.StatusNotFound, err)
+	}
+	if user.Role == models.RoleAdmin {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Admin can not be a merchant member", http.StatusBadRequest, nil)
+	}
+	user.Role = models.RoleMerchant
+	user.MerchantID = &merchantID
+	if err := merchantService.authRepo.Update(user); err != nil {
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	return user, nil
+}
+
 //func (merchantService *merchantService) UpdateOrderStatus(ctx context.Context, m *models.Merchant) error {
 //	existing, err := merchantService.repo.GetByID(ctx, m.ID)
 //
@@ -93,7 +119,12 @@ func (merchantService *merchantService) Delete(ctx context.Context, id uint) err
 		return err
 	}
 
-	return merchantService.repo.Delete(ctx, id)
+	return merchantService.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := merchantService.repo.DeleteTx(ctx, tx, id); err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, merchantService.eventBus, event.CatalogMerchant, id, true)
+	})
 }
 
 func (merchantService *merchantService) List(ctx context.Context) ([]models.Merchant, error) {
@@ -109,7 +140,13 @@ func (merchantService *merchantService) Patch(ctx context.Context, id uint, inpu
 	if input.Name != "" {
 		existing.Name = input.Name
 	}
-	if err := merchantService.repo.Update(ctx, existing); err != nil {
+	err = merchantService.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := merchantService.repo.UpdateTx(ctx, tx, existing); err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, merchantService.eventBus, event.CatalogMerchant, id, false)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -11,21 +11,32 @@ import (
 	"github.com/minh6824pro/nxrGO/internal/dto"
 	event "github.com/minh6824pro/nxrGO/internal/event"
 	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/payment"
 	repositories "github.com/minh6824pro/nxrGO/internal/repositories"
 	"github.com/minh6824pro/nxrGO/internal/services"
 	utils "github.com/minh6824pro/nxrGO/internal/utils"
 	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
-	"github.com/payOSHQ/payos-lib-golang"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	// draftReservationGrace leaves time for a webhook of a payment made right before the link expired
+	draftReservationGrace = 1 * time.Minute
+	// paymentLinkTTL is how long a provider payment link can be paid
+	paymentLinkTTL       = 5 * time.Minute
+	draftExpiryBatchSize = 100
+	draftExpiryReason    = "Reservation expired before payment"
+)
+
 type orderService struct {
 	db                  *gorm.DB
 	productVariantRepo  repositories.ProductVariantRepository
@@ -36,14 +47,23 @@ type orderService struct {
 	paymentInfoRepo     repositories.PaymentInfoRepository
 	productVariantCache cache.ProductVariantRedis
 	eventBus            event.EventPublisher
-	updateStockAgg      *event.UpdateStockAggregator
+	stockLedger         services.StockLedgerService
+	paymentProvider     payment.PaymentProvider
+	refundService       services.RefundService
+	statusHistoryRepo   repositories.OrderStatusHistoryRepository
+	addressRepo         repositories.AddressRepository
+	promotionService    services.PromotionService
+	wishlistService     services.WishlistService
 }
 
 func NewOrderService(db *gorm.DB, productVariantRepo repositories.ProductVariantRepository, orderItemRepo repositories.OrderItemRepository,
 	orderRepo repositories.OrderRepository, merchantRepo repositories.MerchantRepository, draftOrderRepo repositories.DraftOrderRepository,
 	paymentInfoRepo repositories.PaymentInfoRepository,
 	productVariantCache cache.ProductVariantRedis,
-	eventBus event.EventPublisher, updateStockAgg *event.UpdateStockAggregator) services.OrderService {
+	eventBus event.EventPublisher, stockLedger services.StockLedgerService, paymentProvider payment.PaymentProvider,
+	refundService services.RefundService, statusHistoryRepo repositories.OrderStatusHistoryRepository,
+	addressRepo repositories.AddressRepository, promotionService services.PromotionService,
+	wishlistService services.WishlistService) services.OrderService {
 	service := &orderService{
 		db:                  db,
 		productVariantRepo:  productVariantRepo,
@@ -54,14 +74,31 @@ func NewOrderService(db *gorm.DB, productVariantRepo repositories.ProductVariant
 		paymentInfoRepo:     paymentInfoRepo,
 		productVariantCache: productVariantCache,
 		eventBus:            eventBus,
-		updateStockAgg:      updateStockAgg,
+		stockLedger:         stockLedger,
+		paymentProvider:     paymentProvider,
+		refundService:       refundService,
+		statusHistoryRepo:   statusHistoryRepo,
+		addressRepo:         addressRepo,
+		promotionService:    promotionService,
+		wishlistService:     wishlistService,
 	}
-	service.registerEventHandlers()
-
 	return service
 }
 
 func (o *orderService) Create(ctx context.Context, input dto.CreateOrderInput) (*dto.CreateOrderResponse, error) {
+	// Địa chỉ đã lưu thay cho địa chỉ nhập tay
+	if input.AddressID != nil {
+		address, err := o.ResolveShippingAddress(ctx, input.UserID, *input.AddressID)
+		if err != nil {
+			return nil, err
+		}
+		input.ShippingAddress = truncate(address.FullAddress(), 255)
+		input.RecipientName = address.RecipientName
+		input.PhoneNumber = address.PhoneNumber
+		input.Latitude = address.Latitude
+		input.Longitude = address.Longitude
+	}
+
 	// Validate info with signature
 	var totalPrice float64
 	for _, oi := range input.OrderItems {
@@ -71,9 +108,6 @@ func (o *orderService) Create(ctx context.Context, input dto.CreateOrderInput) (
 		totalPrice += oi.Price * float64(oi.Quantity)
 
 	}
-	if totalPrice != input.Total-input.ShippingFee {
-		return nil, customErr.NewError(customErr.INVALID_PRICE, "Invalid total price", http.StatusBadRequest, nil)
-	}
 
 	// Validate shipping fee
 	var totalShippingFee float64
@@ -87,6 +121,19 @@ func (o *orderService) Create(ctx context.Context, input dto.CreateOrderInput) (
 	if totalShippingFee != input.ShippingFee {
 		return nil, customErr.NewError(customErr.INVALID_PRICE, "Invalid total shippingFee", http.StatusBadRequest, nil)
 	}
+
+	// Coupon discount is computed from the signed prices and shipping fees, the total must include it
+	var quote *dto.PromotionQuote
+	if input.CouponCode != "" {
+		var err error
+		if quote, err = o.quoteCoupon(ctx, input); err != nil {
+			return nil, err
+		}
+	}
+	if totalPrice-quote.TotalDiscount() != input.Total-input.ShippingFee {
+		return nil, customErr.NewError(customErr.INVALID_PRICE, "Invalid total price", http.StatusBadRequest, nil)
+	}
+
 	// Begin check quantity
 	var orderItems []models.OrderItem
 	var draftOrder models.DraftOrder
@@ -96,7 +143,7 @@ func (o *orderService) Create(ctx context.Context, input dto.CreateOrderInput) (
 	if err != nil {
 		// Process with DB
 		log.Printf("Create with Db")
-		draftOrder, orderItems, err = o.CreateOrderWithDb(ctx, input)
+		draftOrder, orderItems, err = o.CreateOrderWithDb(ctx, input, quote)
 		if err != nil {
 			return nil, err
 		}
@@ -262,44 +309,71 @@ return {"OK"}
 			ShippingAddress: input.ShippingAddress,
 			PaymentMethod:   input.PaymentMethod,
 			PhoneNumber:     input.PhoneNumber,
+			RecipientName:   input.RecipientName,
 			DeliveryMode:    input.ShippingFeeInput[0].Mode,
 			Latitude:        input.Latitude,
 			Longitude:       input.Longitude,
 		}
-		if err := o.draftOrderRepo.Create(ctx, &draftOrder); err != nil {
-			return nil, customErr.NewError(customErr.INTERNAL_ERROR, fmt.Sprintf("Draft order creation error: %v", err.Error()), http.StatusBadRequest, nil)
-		}
+		// Draft, items and the ledger reservation of the stock taken by the lua script are written together
+		err = o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if _, err := o.draftOrderRepo.CreateTx(ctx, tx, &draftOrder); err != nil {
+				return customErr.NewError(customErr.INTERNAL_ERROR, fmt.Sprintf("Draft order creation error: %v", err.Error()), http.StatusBadRequest, nil)
+			}
 
-		// Create order items
-		for _, item := range input.OrderItems {
-			orderItem := models.OrderItem{
-				OrderID:          draftOrder.ID,
-				ProductVariantID: item.ProductVariantID,
-				OrderType:        models.OrderTypeDraftOrder,
-				Quantity:         item.Quantity,
-				Price:            item.Price,
-				TotalPrice:       item.Price * float64(item.Quantity),
-				MerchantID:       item.MerchantID,
+			// Create order items
+			for i, item := range input.OrderItems {
+				orderItem := models.OrderItem{
+					OrderID:          draftOrder.ID,
+					ProductVariantID: item.ProductVariantID,
+					OrderType:        models.OrderTypeDraftOrder,
+					Quantity:         item.Quantity,
+					Price:            item.Price,
+					Discount:         quote.ItemDiscount(i),
+					TotalPrice:       item.Price*float64(item.Quantity) - quote.ItemDiscount(i),
+					MerchantID:       item.MerchantID,
+				}
+				if _, err := o.orderItemRepo.CreateTx(ctx, tx, &orderItem); err != nil {
+					return err
+				}
+				orderItems = append(orderItems, orderItem)
 			}
-			if err := o.orderItemRepo.Create(ctx, &orderItem); err != nil {
-				return nil, err
+			if err := o.stockLedger.ReserveTx(ctx, tx, draftOrder.ID, orderItems); err != nil {
+				return err
+			}
+			// Create Delivery detail
+			newDeliveryDetail := models.DeliveryDetail{
+				OrderID:    draftOrder.ID,
+				OrderType:  models.OrderTypeDraftOrder,
+				DeliveryID: input.ShippingFeeInput[0].DeliveryID,
+			}
+			if err := tx.Create(&newDeliveryDetail).Error; err != nil {
+				return err
+			}
+			draftOrder.Delivery = newDeliveryDetail
+			return nil
+		})
+		if err != nil {
+			// Nothing was reserved in DB, give the stock taken by the lua script back
+			if err := o.productVariantCache.IncrementStockIfExists(requestedItems(input.OrderItems)); err != nil {
+				log.Printf("Error returning stock to redis: %v", err)
 			}
-			orderItems = append(orderItems, orderItem)
-		}
-		// Create Delivery detail
-		newDeliveryDetail := models.DeliveryDetail{
-			OrderID:    draftOrder.ID,
-			OrderType:  models.OrderTypeDraftOrder,
-			DeliveryID: input.ShippingFeeInput[0].DeliveryID,
-		}
-		if err := o.db.Create(&newDeliveryDetail).Error; err != nil {
 			return nil, err
 		}
-		draftOrder.Delivery = newDeliveryDetail
+	}
+
+	// Redeem the coupon once the draft exists, the stock goes back if the coupon ran out meanwhile
+	if err := o.promotionService.Redeem(ctx, draftOrder.ID, quote); err != nil {
+		o.abandonDraft(ctx, &draftOrder, orderItems)
+		return nil, err
 	}
 
 	// Create PaymentInfo
-	if err := o.CreatePayment(ctx, &draftOrder, orderItems, input.Total, input.ShippingFee); err != nil {
+	if err := o.CreatePayment(ctx, &draftOrder, orderItems, input.Total, input.ShippingFee, quote); err != nil {
+		if o.abandonDraft(ctx, &draftOrder, orderItems) {
+			if err := o.promotionService.Release(ctx, draftOrder.ID); err != nil {
+				log.Printf("Error releasing promotion of draft order %d: %v", draftOrder.ID, err)
+			}
+		}
 		return nil, err
 	}
 
@@ -325,7 +399,7 @@ return {"OK"}
 		}
 		if draftOrder.PaymentMethod == models.PaymentMethodCOD {
 			// Split order if COD
-			subDraftOrders, err := o.SplitOrder(ctx, &draftOrder, orderItems, merchantIDs, input.ShippingFeeInput)
+			subDraftOrders, err := o.SplitOrder(ctx, &draftOrder, orderItems, merchantIDs, input.ShippingFeeInput, quote.ShippingDiscounts())
 			if err != nil {
 				return nil, err
 			}
@@ -369,7 +443,7 @@ return {"OK"}
 	}
 
 }
-func (o *orderService) CreatePayment(ctx context.Context, draftOrder *models.DraftOrder, orderItems []models.OrderItem, total float64, shippingFee float64) error {
+func (o *orderService) CreatePayment(ctx context.Context, draftOrder *models.DraftOrder, orderItems []models.OrderItem, total float64, shippingFee float64, quote *dto.PromotionQuote) error {
 
 	var paymentInfo = &models.PaymentInfo{
 		ID:          GeneratePaymentInfoID(),
@@ -379,46 +453,63 @@ func (o *orderService) CreatePayment(ctx context.Context, draftOrder *models.Dra
 		OrderType:   models.OrderTypeDraftOrder,
 		Status:      models.PaymentPending,
 	}
-	if err := o.paymentInfoRepo.Create(ctx, paymentInfo); err != nil {
-		log.Printf(err.Error(), "while creating payment info")
-		return customErr.NewError(customErr.INTERNAL_ERROR, "CreatePayment error", http.StatusInternalServerError, err)
-	}
-	paymentLink := ""
-	if draftOrder.PaymentMethod == models.PaymentMethodBank {
-		// Create PayOS payment link
-		paymentData, err := CreatePayOSPayment(paymentInfo.ID, 10000, MapOrderItemsToPayOSItems(orderItems, int(shippingFee)), fmt.Sprintf("Thanh toán đơn hàng %d", draftOrder.ID), "http://localhost:5173/success", "http://localhost:5173/cancel")
+	if quote != nil {
+		paymentInfo.CouponCode = quote.Code
+		paymentInfo.Discount = quote.Discount
+		paymentInfo.ShippingDiscount = quote.ShippingDiscount
+	}
+	var paymentEvent *event.PayOSPaymentCreatedEvent
+	if draftOrder.PaymentMethod.IsOnline() {
+		// Create payment link
+		paymentData, err := o.paymentProvider.CreateLink(ctx, payment.CreateLinkRequest{
+			PaymentID:   paymentInfo.ID,
+			Amount:      10000,
+			Items:       MapOrderItemsToPaymentItems(orderItems, paymentInfo),
+			Description: fmt.Sprintf("Thanh toán đơn hàng %d", draftOrder.ID),
+			ReturnURL:   "http://localhost:5173/success",
+			CancelURL:   "http://localhost:5173/cancel",
+			ExpiresAt:   time.Now().Add(paymentLinkTTL),
+		})
 		if err != nil {
 			log.Println("CreatePayment error", err.Error())
 			log.Print(paymentInfo.ID)
 			return customErr.NewError(customErr.INTERNAL_ERROR, "CreatePayment error", http.StatusInternalServerError, err)
 		}
-		paymentLink = paymentData.CheckoutUrl
+		paymentInfo.PaymentLink = paymentData.CheckoutURL
+		// Hold stock until the link can no longer be paid
+		reservationExpiresAt := paymentData.ExpiresAt.Add(draftReservationGrace)
+		draftOrder.ReservationExpiresAt = &reservationExpiresAt
 
-		// Publish event after create payOS link
-		paymentEvent := event.PayOSPaymentCreatedEvent{
+		paymentEvent = &event.PayOSPaymentCreatedEvent{
 			Id:            paymentInfo.ID,
 			OrderID:       draftOrder.ID,
-			PaymentLink:   paymentData.CheckoutUrl,
+			PaymentLink:   paymentData.CheckoutURL,
 			Total:         10000,
 			PaymentMethod: string(draftOrder.PaymentMethod),
 			CreatedAt:     time.Now(),
 		}
-
-		if err := o.eventBus.PublishPaymentCreated(paymentEvent); err != nil {
-			log.Printf("Failed to publish payment created event: %v", err)
-		}
-		log.Printf("PayOs payment created for order %d: %s", draftOrder.ID, paymentData.CheckoutUrl)
-
+		log.Printf("%s payment created for order %d: %s", o.paymentProvider.Name(), draftOrder.ID, paymentData.CheckoutURL)
 	}
 
-	paymentInfo.PaymentLink = paymentLink
-	if err := o.paymentInfoRepo.Save(ctx, paymentInfo); err != nil {
-		log.Printf(err.Error(), "while saving payment info")
-		return customErr.NewError(customErr.INTERNAL_ERROR, "Save Payment error", http.StatusInternalServerError, err)
-	}
 	draftOrder.PaymentInfos = append(draftOrder.PaymentInfos, *paymentInfo)
-	if err := o.draftOrderRepo.Save(ctx, draftOrder); err != nil {
-		log.Printf(err.Error(), "while saving draftOrder")
+	// Create payment info, save draft order and payment created event together
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := o.paymentInfoRepo.CreateTx(ctx, tx, paymentInfo); err != nil {
+			log.Printf("Error creating payment info: %v", err)
+			return customErr.NewError(customErr.INTERNAL_ERROR, "CreatePayment error", http.StatusInternalServerError, err)
+		}
+		if err := o.draftOrderRepo.SaveTx(ctx, tx, draftOrder); err != nil {
+			log.Printf("Error saving draftOrder: %v", err)
+			return err
+		}
+		if paymentEvent != nil {
+			if err := o.eventBus.PublishPaymentCreatedTx(tx, *paymentEvent); err != nil {
+				return customErr.NewError(customErr.INTERNAL_ERROR, "Publish payment created event error", http.StatusInternalServerError, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 	return nil
@@ -426,12 +517,39 @@ func (o *orderService) CreatePayment(ctx context.Context, draftOrder *models.Dra
 
 // No split order
 func (o *orderService) DraftOrderToOrder(ctx context.Context, draftOrder *models.DraftOrder, orderItems []models.OrderItem) (models.Order, error) {
+	var order models.Order
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = o.draftOrderToOrderTx(ctx, tx, draftOrder, orderItems)
+		return err
+	})
+	return order, err
+}
+
+// lockOpenDraftTx holds the draft until tx ends, a draft already converted or cancelled is never converted again
+func (o *orderService) lockOpenDraftTx(ctx context.Context, tx *gorm.DB, draftOrderID uint) error {
+	open, err := o.draftOrderRepo.LockOpenTx(ctx, tx, draftOrderID)
+	if err != nil {
+		return err
+	}
+	if !open {
+		return customErr.NewError(customErr.BAD_REQUEST, fmt.Sprintf("Draft order %d is already closed", draftOrderID), http.StatusConflict, nil)
+	}
+	return nil
+}
+
+// draftOrderToOrderTx writes the order, its sale and the closed draft together so the reservation is never counted twice
+func (o *orderService) draftOrderToOrderTx(ctx context.Context, tx *gorm.DB, draftOrder *models.DraftOrder, orderItems []models.OrderItem) (models.Order, error) {
+	if err := o.lockOpenDraftTx(ctx, tx, draftOrder.ID); err != nil {
+		return models.Order{}, err
+	}
 	order := models.Order{
 		UserID:          draftOrder.UserID,
 		Status:          draftOrder.Status,
 		PaymentMethod:   draftOrder.PaymentMethod,
 		ShippingAddress: draftOrder.ShippingAddress,
 		PhoneNumber:     draftOrder.PhoneNumber,
+		RecipientName:   draftOrder.RecipientName,
 		OrderItems:      orderItems,
 		PaymentInfos:    draftOrder.PaymentInfos,
 		ParentID:        draftOrder.ParentID,
@@ -440,22 +558,48 @@ func (o *orderService) DraftOrderToOrder(ctx context.Context, draftOrder *models
 		Latitude:        draftOrder.Latitude,
 		Longitude:       draftOrder.Longitude,
 	}
-	if err := o.orderRepo.Create(ctx, &order); err != nil {
+	if _, err := o.orderRepo.CreateTx(ctx, tx, &order); err != nil {
+		return order, err
+	}
+	if err := o.recordStatusTx(ctx, tx, order.ID, models.HistoryEventCreate, "", order.Status,
+		models.Actor{UserID: order.UserID, Role: models.RoleUser}, fmt.Sprintf("Created from draft order %d", draftOrder.ID)); err != nil {
+		return order, err
+	}
+	if err := o.stockLedger.SellTx(ctx, tx, draftOrder.ID, order.ID, orderItems); err != nil {
 		return order, err
 	}
-	log.Print(order.OrderItems)
 
 	draftOrder.ToOrderID = &order.ID
 	draftOrder.PaymentInfos = nil
 	draftOrder.OrderItems = nil
 	draftOrder.Delivery = models.DeliveryDetail{}
-	if err := o.draftOrderRepo.Save(ctx, draftOrder); err != nil {
+	if err := o.draftOrderRepo.SaveTx(ctx, tx, draftOrder); err != nil {
 		return order, err
 	}
 	return order, nil
 }
 
 func (o *orderService) DraftsOrderToOrder(ctx context.Context, draftOrder []*models.DraftOrder) ([]models.Order, error) {
+	var orders []models.Order
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		orders, err = o.draftsOrderToOrderTx(ctx, tx, draftOrder)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// draftsOrderToOrderTx writes the parent, the sub orders and their sales together,
+// a failed sub order leaves every draft reserved
+func (o *orderService) draftsOrderToOrderTx(ctx context.Context, tx *gorm.DB, draftOrder []*models.DraftOrder) ([]models.Order, error) {
+	for _, draft := range draftOrder {
+		if err := o.lockOpenDraftTx(ctx, tx, draft.ID); err != nil {
+			return nil, err
+		}
+	}
 	var orders []models.Order
 	// Create parent order
 	i := len(draftOrder) - 1
@@ -465,6 +609,7 @@ func (o *orderService) DraftsOrderToOrder(ctx context.Context, draftOrder []*mod
 		PaymentMethod:   draftOrder[i].PaymentMethod,
 		ShippingAddress: draftOrder[i].ShippingAddress,
 		PhoneNumber:     draftOrder[i].PhoneNumber,
+		RecipientName:   draftOrder[i].RecipientName,
 		OrderItems:      nil,
 		PaymentInfos:    draftOrder[i].PaymentInfos,
 		ParentID:        draftOrder[i].ParentID,
@@ -473,7 +618,11 @@ func (o *orderService) DraftsOrderToOrder(ctx context.Context, draftOrder []*mod
 		Latitude:        draftOrder[i].Latitude,
 		Longitude:       draftOrder[i].Longitude,
 	}
-	if err := o.orderRepo.Create(ctx, &order); err != nil {
+	if _, err := o.orderRepo.CreateTx(ctx, tx, &order); err != nil {
+		return nil, err
+	}
+	if err := o.recordStatusTx(ctx, tx, order.ID, models.HistoryEventCreate, "", order.Status,
+		models.Actor{UserID: order.UserID, Role: models.RoleUser}, fmt.Sprintf("Created from draft order %d", draftOrder[i].ID)); err != nil {
 		return nil, err
 	}
 	orders = append(orders, order)
@@ -481,7 +630,8 @@ func (o *orderService) DraftsOrderToOrder(ctx context.Context, draftOrder []*mod
 	draftOrder[i].PaymentInfos = nil
 	draftOrder[i].OrderItems = nil
 	draftOrder[i].Delivery = models.DeliveryDetail{}
-	if err := o.draftOrderRepo.Save(ctx, draftOrder[i]); err != nil {
+	if err := o.draftOrderRepo.SaveTx(ctx, tx, draftOrder[i]); err != nil {
+		return nil, err
 	}
 	// create sub order
 	for i = 0; i < len(draftOrder)-1; i++ {
@@ -491,6 +641,7 @@ func (o *orderService) DraftsOrderToOrder(ctx context.Context, draftOrder []*mod
 			PaymentMethod:   draftOrder[i].PaymentMethod,
 			ShippingAddress: draftOrder[i].ShippingAddress,
 			PhoneNumber:     draftOrder[i].PhoneNumber,
+			RecipientName:   draftOrder[i].RecipientName,
 			OrderItems:      draftOrder[i].OrderItems,
 			PaymentInfos:    draftOrder[i].PaymentInfos,
 			ParentID:        &order.ID,
@@ -499,16 +650,22 @@ func (o *orderService) DraftsOrderToOrder(ctx context.Context, draftOrder []*mod
 			Latitude:        draftOrder[i].Latitude,
 			Longitude:       draftOrder[i].Longitude,
 		}
-		if err := o.orderRepo.Create(ctx, &subOrder); err != nil {
-			log.Println("Create sub order error")
+		if _, err := o.orderRepo.CreateTx(ctx, tx, &subOrder); err != nil {
+			return nil, err
+		}
+		if err := o.recordStatusTx(ctx, tx, subOrder.ID, models.HistoryEventSplit, "", subOrder.Status,
+			models.Actor{UserID: subOrder.UserID, Role: models.RoleUser}, fmt.Sprintf("Split from order %d", order.ID)); err != nil {
+			return nil, err
+		}
+		if err := o.stockLedger.SellTx(ctx, tx, draftOrder[i].ID, subOrder.ID, subOrder.OrderItems); err != nil {
+			return nil, err
 		}
 		orders = append(orders, subOrder)
 		draftOrder[i].ToOrderID = &subOrder.ID
 		draftOrder[i].PaymentInfos = nil
 		draftOrder[i].OrderItems = nil
 		draftOrder[i].Delivery = models.DeliveryDetail{}
-		err := o.draftOrderRepo.Save(ctx, draftOrder[i])
-		if err != nil {
+		if err := o.draftOrderRepo.SaveTx(ctx, tx, draftOrder[i]); err != nil {
 			return nil, err
 		}
 	}
@@ -523,170 +680,199 @@ func (o *orderService) DraftOrderToOrderResponse(ctx context.Context, draftOrder
 		PaymentMethod:   draftOrder.PaymentMethod,
 		ShippingAddress: draftOrder.ShippingAddress,
 		PhoneNumber:     draftOrder.PhoneNumber,
+		RecipientName:   draftOrder.RecipientName,
 		PaymentInfos:    draftOrder.PaymentInfos,
 		OrderItems:      orderItems,
 	}
 	return order, nil
 }
 
-func (o *orderService) PayOSPaymentSuccess(ctx context.Context, paymentInfoID int64) {
+func (o *orderService) PaymentSuccess(ctx context.Context, paymentInfoID int64) {
 	paymentInfo, err := o.paymentInfoRepo.GetByID(ctx, paymentInfoID)
 	if err != nil {
 		log.Printf(err.Error(), "while getting draftOrder to update PayOSPayment")
 		return
 	}
-	// If draft -> convert to order
-	if paymentInfo.OrderType == models.OrderTypeDraftOrder {
+	// Webhook and reconciliation poll may both report the payment, only the first one finalizes it
+	nextStatus, ok := utils.CanTransitionPayment(paymentInfo.Status, utils.EventPaySuccess)
+	if !ok {
+		log.Printf("Payment %d already finalized with status %s", paymentInfo.ID, paymentInfo.Status)
+		return
+	}
+	currentStatus := paymentInfo.Status
+	paymentInfo.Status = nextStatus
 
-		if nextStatus, ok := utils.CanTransitionPayment(paymentInfo.Status, utils.EventPaySuccess); ok {
-			paymentInfo.Status = nextStatus
-			err = o.paymentInfoRepo.Save(ctx, paymentInfo)
-			if err != nil {
-				log.Printf(err.Error(), "while saving payment info")
-			}
-		} else {
-			log.Printf("error 1 while transitioning payment info from PayOSPayment payment id: %d", paymentInfo.ID)
-		}
-		draftOrder, err := o.draftOrderRepo.GetById(ctx, paymentInfo.OrderID)
+	// Everything the conversion needs is read before claiming the payment,
+	// a failure leaves it pending so the next reconciliation poll retries it
+	var draftOrder *models.DraftOrder
+	var split *paidDraftSplit
+	if paymentInfo.OrderType == models.OrderTypeDraftOrder {
+		draftOrder, err = o.draftOrderRepo.GetById(ctx, paymentInfo.OrderID)
 		if err != nil {
 			log.Printf(err.Error(), "while getting draftOrder")
 			return
 		}
+		for i := range draftOrder.PaymentInfos {
+			if draftOrder.PaymentInfos[i].ID == paymentInfo.ID {
+				draftOrder.PaymentInfos[i].Status = nextStatus
+				draftOrder.PaymentInfos[i].NextCheckAt = nil
+			}
+		}
 		// Check if order need to split
 		if draftOrder.ParentID != nil && *draftOrder.ParentID == 0 {
-			log.Println("NEED SPLIT")
-			//split
-			infos, err2 := o.draftOrderRepo.GetForSplit(ctx, draftOrder.ID)
-			if err2 != nil {
-				log.Println(err2, " While split order (bank payment success)")
+			if split, err = o.planPaidDraftSplit(ctx, draftOrder); err != nil {
+				log.Printf("Error planning split of draft order %d (bank payment success): %v", draftOrder.ID, err)
 				return
 			}
-			//Get merchant id distinct
-			var merchantIDs []uint
-			merchantIDMap := make(map[uint]bool)
-			itemAndMerchantMap := make(map[uint]uint) // key: OrderItem ID, value: MerchantID
-
-			for _, info := range infos {
-				if !merchantIDMap[info.MerchantID] {
-					merchantIDMap[info.MerchantID] = true
-					merchantIDs = append(merchantIDs, info.MerchantID)
-				}
-				itemAndMerchantMap[info.ID] = info.MerchantID
-			}
-			//Inject merchant ID for orderItems
-			for i := range draftOrder.OrderItems {
-				draftOrder.OrderItems[i].MerchantID = itemAndMerchantMap[draftOrder.OrderItems[i].ID]
-			}
-			log.Println(merchantIDs)
-			var shippingFeeResponse []dto.ShippingFeeResponse
-			for _, merchantID := range merchantIDs {
-				fee, err := o.CalculateShippingFee(ctx, merchantID, draftOrder.Longitude, draftOrder.Latitude, infos[0].DeliveryID)
-				if err != nil {
-					return
-				}
-				shippingFeeResponse = append(shippingFeeResponse, fee...)
+		}
+	}
 
+	// Claim and conversion commit together, a paid draft is never left without its order.
+	// The draft is locked before the payment like every path that closes it, a draft switched to COD
+	// or expired meanwhile aborts the conversion instead of creating a second order
+	var claimed bool
+	err = o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if draftOrder != nil {
+			if err := o.lockOpenDraftTx(ctx, tx, draftOrder.ID); err != nil {
+				return err
 			}
-			for _, oi := range draftOrder.OrderItems {
-				log.Println(oi.MerchantID, "merchant")
-			}
-			draftsSplit, err := o.SplitOrder(ctx, draftOrder, draftOrder.OrderItems, merchantIDs, shippingFeeResponse)
+		}
+		var err error
+		claimed, err = o.paymentInfoRepo.CompareAndSetStatusTx(ctx, tx, paymentInfo, currentStatus)
+		if err != nil || !claimed || draftOrder == nil {
+			return err
+		}
+		if split != nil {
+			draftsSplit, err := o.splitOrderTx(ctx, tx, draftOrder, draftOrder.OrderItems, split.merchantIDs, split.shippingFees, split.shippingDiscounts)
 			if err != nil {
-				log.Printf(err.Error(), "while split order (bank payment success)")
-				return
+				return err
 			}
+			_, err = o.draftsOrderToOrderTx(ctx, tx, draftsSplit)
+			return err
+		}
+		_, err = o.draftOrderToOrderTx(ctx, tx, draftOrder, draftOrder.OrderItems)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error finalizing payment %d: %v", paymentInfo.ID, err)
+		return
+	}
+	if !claimed {
+		log.Printf("Payment %d already finalized by another caller", paymentInfo.ID)
+	}
+}
 
-			_, err = o.DraftsOrderToOrder(ctx, draftsSplit)
-			if err != nil {
-				log.Printf(err.Error(), "while convert drafts order to order(bank payment success)")
-				return
-			}
+// paidDraftSplit is what splitting a paid multi merchant draft needs besides the draft itself
+type paidDraftSplit struct {
+	merchantIDs       []uint
+	shippingFees      []dto.ShippingFeeResponse
+	shippingDiscounts map[uint]float64
+}
 
-		} else {
-			// not split
-			_, err = o.DraftOrderToOrder(ctx, draftOrder, draftOrder.OrderItems)
-			if err != nil {
-				log.Printf(err.Error(), "while create Order to update PayOSPayment")
-				return
-			}
+// planPaidDraftSplit assigns merchants to the draft items and quotes the shipping fee of each merchant
+func (o *orderService) planPaidDraftSplit(ctx context.Context, draftOrder *models.DraftOrder) (*paidDraftSplit, error) {
+	infos, err := o.draftOrderRepo.GetForSplit(ctx, draftOrder.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Draft order has no items to split", http.StatusInternalServerError, nil)
+	}
+	//Get merchant id distinct
+	split := &paidDraftSplit{}
+	merchantIDMap := make(map[uint]bool)
+	itemAndMerchantMap := make(map[uint]uint) // key: OrderItem ID, value: MerchantID
+
+	for _, info := range infos {
+		if !merchantIDMap[info.MerchantID] {
+			merchantIDMap[info.MerchantID] = true
+			split.merchantIDs = append(split.merchantIDs, info.MerchantID)
 		}
-	} else {
-		// if order update
-		if nextStatus, ok := utils.CanTransitionPayment(paymentInfo.Status, utils.EventPaySuccess); ok {
-			paymentInfo.Status = nextStatus
-			err = o.paymentInfoRepo.Save(ctx, paymentInfo)
-			if err != nil {
-				log.Printf(err.Error(), "while saving payment info")
-			}
-		} else {
-			log.Printf("error 1 while transitioning payment info from PayOSPayment payment id: %d", paymentInfo.ID)
+		itemAndMerchantMap[info.ID] = info.MerchantID
+	}
+	//Inject merchant ID for orderItems
+	for i := range draftOrder.OrderItems {
+		draftOrder.OrderItems[i].MerchantID = itemAndMerchantMap[draftOrder.OrderItems[i].ID]
+	}
+	for _, merchantID := range split.merchantIDs {
+		fee, err := o.CalculateShippingFee(ctx, merchantID, draftOrder.Longitude, draftOrder.Latitude, infos[0].DeliveryID)
+		if err != nil {
+			return nil, err
 		}
+		split.shippingFees = append(split.shippingFees, fee...)
 	}
+	if split.shippingDiscounts, err = o.promotionService.ShippingDiscounts(ctx, draftOrder.ID); err != nil {
+		return nil, err
+	}
+	return split, nil
 }
 
 func (o *orderService) GetById(ctx context.Context, orderID uint, userID uint) (*models.Order, error) {
 	return o.orderRepo.GetByIdAndUserId(ctx, orderID, userID)
 }
 
-func MapOrderItemsToPayOSItems(orderItem []models.OrderItem, shippingFee int) []payos.Item {
-	var items []payos.Item
+// MapOrderItemsToPaymentItems lists items at the price actually paid, discounted lines are priced as a whole
+func MapOrderItemsToPaymentItems(orderItem []models.OrderItem, paymentInfo *models.PaymentInfo) []payment.Item {
+	var items []payment.Item
 
 	for _, oi := range orderItem {
-		item := payos.Item{
+		item := payment.Item{
 			Name:     fmt.Sprintf("%s (Variant #%d)", oi.Variant.Product.Name, oi.ProductVariantID),
-			Price:    int(oi.Price),
+			Price:    int(math.Round(oi.Price)),
 			Quantity: int(oi.Quantity),
 		}
+		if oi.Discount > 0 {
+			// The discount rarely splits evenly per unit, keep the line total exact
+			item.Name = fmt.Sprintf("%s x%d", item.Name, oi.Quantity)
+			item.Price = int(math.Round(oi.TotalPrice))
+			item.Quantity = 1
+		}
 		items = append(items, item)
 	}
 
-	items = append(items, payos.Item{
-		Name:     "Shipping Fee",
-		Price:    shippingFee,
-		Quantity: 1,
-	})
+	if shippingFee := paymentInfo.ShippingFee - paymentInfo.ShippingDiscount; shippingFee > 0 {
+		items = append(items, payment.Item{
+			Name:     "Shipping Fee",
+			Price:    int(math.Round(shippingFee)),
+			Quantity: 1,
+		})
+	}
 	return items
 }
 
-func (o *orderService) registerEventHandlers() {
-	o.eventBus.Subscribe(func(e event.PayOSPaymentCreatedEvent) {
-		log.Printf("Tracking payment created for order %d: %s", e.Id, e.PaymentLink)
-		var data *payos.PaymentLinkDataType
-		for {
-			var err error
-			data, err = payos.GetPaymentLinkInformation(strconv.FormatInt(e.Id, 10))
-			if err != nil {
-				log.Printf("Error getting payment info: %v", err)
-				return
-			}
-
-			if data.Status != "PENDING" {
-				break
-			}
-
-			time.Sleep(10 * time.Second)
-		}
-
-		if data.Status == "PAID" {
-			o.PayOSPaymentSuccess(context.Background(), e.Id)
-		} else {
-			reasonStr := "Cancelled/Expired via payos"
-			if data.CancellationReason != nil {
-				reasonStr = *data.CancellationReason
-			}
-			o.PayOSPaymentCancelled(context.Background(), e.Id, data.Status, reasonStr)
-		}
-		log.Printf("Payment status updated and no longer pending for order %d", e.Id)
-	})
+// HandlePaymentCreated schedules the first reconciliation check, the webhook usually finalizes the payment before it
+func (o *orderService) HandlePaymentCreated(e event.PayOSPaymentCreatedEvent) error {
+	log.Printf("Tracking payment created for order %d: %s", e.Id, e.PaymentLink)
+	return o.paymentInfoRepo.ScheduleCheck(context.Background(), e.Id, e.CreatedAt.Add(paymentFirstCheckDelay))
 }
 
-func (o *orderService) PayOSPaymentCancelled(ctx context.Context, paymentInfoId int64, status string, reason string) {
+func (o *orderService) PaymentCancelled(ctx context.Context, paymentInfoId int64, status string, reason string) {
 	paymentInfo, err := o.paymentInfoRepo.GetByID(ctx, paymentInfoId)
 	if err != nil {
-		log.Printf(err.Error(), "while getting draftOrder to update PayOSPayment")
+		log.Printf("Error getting payment info %d: %v", paymentInfoId, err)
+		return
+	}
+
+	// Webhook and reconciliation poll may both report the payment, only the first one finalizes it
+	nextStatus, ok := utils.CanTransitionPayment(paymentInfo.Status, utils.EventPayCancel)
+	if !ok {
+		log.Printf("Payment %d already finalized with status %s", paymentInfo.ID, paymentInfo.Status)
+		return
+	}
+	currentStatus := paymentInfo.Status
+	now := time.Now()
+	paymentInfo.Status = nextStatus
+	paymentInfo.CancellationReason = reason
+	paymentInfo.CancellationAt = &now
+	claimed, err := o.paymentInfoRepo.CompareAndSetStatus(ctx, paymentInfo, currentStatus)
+	if err != nil {
+		log.Printf("Error saving payment info %d: %v", paymentInfo.ID, err)
+		return
+	}
+	if !claimed {
+		log.Printf("Payment %d already finalized by another caller", paymentInfo.ID)
 		return
 	}
-	val := uint(0)
 
 	if paymentInfo.OrderType == models.OrderTypeDraftOrder {
 		draftOrder, err := o.draftOrderRepo.GetById(ctx, paymentInfo.OrderID)
@@ -697,192 +883,482 @@ func (o *orderService) PayOSPaymentCancelled(ctx context.Context, paymentInfoId
 		if draftOrder.PaymentInfos[0].ID == paymentInfoId && draftOrder.ToOrderID == nil {
 			// Latest payment => cancel order
 			log.Println("la payment moi nhat nen xu ly cancelled")
-			draftOrder.ToOrderID = &val
-			if err := o.draftOrderRepo.Save(ctx, draftOrder); err != nil {
-				log.Printf(err.Error(), "while saving draft order to update PayOSPayment Fail")
-			}
 			orderItems := draftOrder.OrderItems
-
-			err = o.productVariantCache.IncrementStock(orderItems)
+			released, err := o.releaseDraft(ctx, draftOrder.ID, orderItems)
 			if err != nil {
-				log.Printf(err.Error(), "while incrementing stock variant after cancelled payment")
+				log.Printf("Error closing draft order %d after cancelled payment: %v", draftOrder.ID, err)
+				return
+			}
+			if !released {
+				log.Printf("Draft order %d already closed by another caller", draftOrder.ID)
+				return
 			}
 
-		}
-
-		// Not latest => update paymentinfo
-		if nextStatus, ok := utils.CanTransitionPayment(paymentInfo.Status, utils.EventPayCancel); ok {
-			paymentInfo.Status = nextStatus
-			paymentInfo.CancellationReason = reason
-			now := time.Now()
-			paymentInfo.CancellationAt = &now
-			err = o.paymentInfoRepo.Save(ctx, paymentInfo)
+			err = o.productVariantCache.IncrementStockIfExists(orderItems)
 			if err != nil {
-				log.Printf(err.Error(), "while saving payment info")
+				log.Printf(err.Error(), "while incrementing stock variant after cancelled payment")
+			}
+			o.wishlistService.NotifyBackInStock(ctx, stockIncreases(orderItems))
+			if err := o.promotionService.Release(ctx, draftOrder.ID); err != nil {
+				log.Printf("Error releasing promotion of draft order %d: %v", draftOrder.ID, err)
 			}
-		} else {
-			log.Printf("error 2 while transitioning payment info from PayOSPayment payment id: %d", paymentInfo.ID)
 		}
 	} else {
 		// if is order
-		order, err2 := o.orderRepo.GetById(ctx, paymentInfo.OrderID)
-		if err2 != nil {
+		order, err := o.orderRepo.GetById(ctx, paymentInfo.OrderID)
+		if err != nil {
 			log.Printf(err.Error(), "while getting Order to update PayOSPayment")
 			return
 		}
 		if order.PaymentInfos[0].ID == paymentInfoId {
-			// Latest payment -> cancel payment & order
-			if nextStatus, ok := utils.CanTransitionPayment(paymentInfo.Status, utils.EventPayCancel); ok {
-				paymentInfo.Status = nextStatus
-				paymentInfo.CancellationReason = reason
-				now := time.Now()
-				paymentInfo.CancellationAt = &now
-				err = o.paymentInfoRepo.Save(ctx, paymentInfo)
-
-				if nextOrderStatus, ok := utils.CanTransitionOrder(order.Status, utils.EventCancel); ok == nil {
-					order.Status = nextOrderStatus
-					err = o.orderRepo.Save(ctx, order)
+			// Latest payment -> cancel order
+			if nextOrderStatus, ok := utils.CanTransitionOrder(order.Status, utils.EventCancel); ok == nil {
+				previousStatus := order.Status
+				order.Status = nextOrderStatus
+				if err := o.orderRepo.Save(ctx, order); err != nil {
+					log.Printf("Error cancelling order %d: %v", order.ID, err)
+					return
 				}
-				// publish cancel event -> add stock
-				o.updateStockAgg.AddOrder(*order)
-				if err != nil {
-					log.Printf(err.Error(), "while saving payment info")
+				o.recordStatus(ctx, order.ID, string(utils.EventCancel), previousStatus, nextOrderStatus,
+					models.SystemActor, fmt.Sprintf("Payment %d cancelled: %s", paymentInfoId, reason))
+				// record cancel in stock ledger -> add stock
+				if err := o.stockLedger.Restock(ctx, order); err != nil {
+					log.Printf("Error restocking order %d: %v", order.ID, err)
+				} else {
+					o.wishlistService.NotifyBackInStock(ctx, stockIncreases(outstandingItems(order.OrderItems)))
+				}
+			}
+		}
+	}
+}
+
+func (o *orderService) UpdateQuantity(ctx context.Context) error {
+	// Get draft order that are converted to  order
+	draftOrders, err := o.draftOrderRepo.GetsForDbUpdate(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Apply sales, returns and adjustments recorded in the stock ledger
+	_, err = o.stockLedger.Flush(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Delete draft order that are converted to order
+	for _, d := range draftOrders {
+		err := o.draftOrderRepo.Delete(ctx, d.ID)
+		if err != nil {
+			return err
+		}
+		log.Printf("Deleted draft order %d", d.ID)
+	}
+
+	log.Printf("UpdateOrderStatus Db successfully")
+
+	//Clean draft order that can't be converted to order
+	err = o.CleanDraft(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Cleaned draft orders")
+	return nil
+}
+
+// ExpireReservations gives back the stock of unpaid drafts whose reservation expired
+func (o *orderService) ExpireReservations(ctx context.Context) (int, error) {
+	now := time.Now()
+	drafts, err := o.draftOrderRepo.ListExpiredReservations(ctx, now, now.Add(-paymentLinkTTL-draftReservationGrace), draftExpiryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, draft := range drafts {
+		var claimed bool
+		err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			claimed, err = o.draftOrderRepo.ExpireReservationTx(ctx, tx, draft.ID, draftExpiryReason)
+			if err != nil || !claimed {
+				return err
+			}
+			return o.stockLedger.ReleaseTx(ctx, tx, draft.ID, draft.OrderItems)
+		})
+		if err != nil {
+			log.Printf("Error expiring draft order %d: %v", draft.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		o.wishlistService.NotifyBackInStock(ctx, stockIncreases(draft.OrderItems))
+
+		// DB no longer counts the draft as reserved, give the stock back to cached variants
+		if err := o.productVariantCache.IncrementStockIfExists(draft.OrderItems); err != nil {
+			log.Printf("Error returning stock of draft order %d to redis: %v", draft.ID, err)
+			for _, oi := range draft.OrderItems {
+				if err := o.productVariantCache.DeleteProductVariantHash(oi.ProductVariantID); err != nil {
+					log.Printf("Error deleting product variant cache %d: %v", oi.ProductVariantID, err)
 				}
-			} else {
-				log.Printf("error 2 while transitioning payment info from PayOSPayment payment id: %d", paymentInfo.ID)
 			}
-		} else {
-			// Not latest payment info -> cancel payment
-			if nextStatus, ok := utils.CanTransitionPayment(paymentInfo.Status, utils.EventPayCancel); ok {
-				paymentInfo.Status = nextStatus
-				paymentInfo.CancellationReason = reason
-				now := time.Now()
-				paymentInfo.CancellationAt = &now
-				err = o.paymentInfoRepo.Save(ctx, paymentInfo)
-
-			} else {
-				log.Printf("error 2 while transitioning payment info from PayOSPayment payment id: %d", paymentInfo.ID)
+		}
+		if err := o.promotionService.Release(ctx, draft.ID); err != nil {
+			log.Printf("Error releasing promotion of draft order %d: %v", draft.ID, err)
+		}
+		expired++
+	}
+	if expired > 0 {
+		log.Printf("Expired %d draft order reservations", expired)
+	}
+	return expired, nil
+}
+
+func (o *orderService) CleanDraft(ctx context.Context) error {
+	err := o.draftOrderRepo.CleanDraft(ctx)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *orderService) GetsByStatus(ctx context.Context, status models.OrderStatus, userId uint) ([]*models.Order, error) {
+
+	return o.orderRepo.GetsByStatusAndUserId(ctx, status, userId)
+}
+
+func (o *orderService) UpdateOrderStatus(ctx context.Context, orderId uint, event utils.OrderEvent, actor models.Actor, note string) (*models.Order, error) {
+	var order *models.Order
+	var restocked []models.OrderItem
+	// Stock, refund request and status are written together so a return is never half recorded.
+	// The order is locked like item cancels and returns so both never settle the same quantities
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = o.orderRepo.GetByIdForUpdateTx(ctx, tx, orderId)
+		if err != nil {
+			return err
+		}
+		nextStatus, err := utils.CanTransitionOrder(order.Status, event)
+		if err != nil {
+			log.Println(err.Error())
+			return err
+		}
+		if event == utils.EventDone && order.HasReturnInProgress() {
+			return customErr.NewError(customErr.BAD_REQUEST, "Order has item returns in progress", http.StatusBadRequest, nil)
+		}
+		// Increase stock if cancel before ship or  after completing return_shipping
+		if (nextStatus == models.OrderStateCancelled && models.IsBeforeOrderStatus(order.Status, models.OrderStateProcessing)) ||
+			nextStatus == models.OrderStateReturned {
+			log.Printf("Order %d is already processing cancel/return", order.ID)
+			restocked = outstandingItems(order.OrderItems)
+			if err := o.stockLedger.RestockTx(ctx, tx, order); err != nil {
+				return err
+			}
+			if err := o.settleItemsTx(ctx, tx, order, nextStatus); err != nil {
+				return err
+			}
+		}
+		// Online paid money goes back through an admin approved refund
+		if nextStatus == models.OrderStateCancelled || nextStatus == models.OrderStateReturned {
+			refund, err := o.refundService.RequestRemainingTx(ctx, tx, order,
+				nextStatus == models.OrderStateCancelled, fmt.Sprintf("Order %s", strings.ToLower(string(nextStatus))))
+			if err != nil {
+				return err
+			}
+			if refund != nil {
+				log.Printf("Requested refund %d of order %d", refund.ID, order.ID)
+			}
+		}
+		if err := o.recordStatusTx(ctx, tx, order.ID, string(event), order.Status, nextStatus, actor, note); err != nil {
+			return err
+		}
+		// total_buy của sản phẩm tăng qua trigger khi đơn DONE
+		if nextStatus == models.OrderStateDone {
+			if err := o.publishOrderDoneTx(tx, order.ID); err != nil {
+				return err
+			}
+		}
+		// Update Status
+		order.Status = nextStatus
+		return o.orderRepo.UpdateTx(ctx, tx, order)
+	})
+	if err != nil {
+		log.Printf(err.Error(), "while saving order")
+		return nil, err
+	}
+	o.wishlistService.NotifyBackInStock(ctx, stockIncreases(restocked))
+
+	return order, nil
+}
+
+// settleItemsTx marks what is left of every item cancelled or returned once the whole order is
+func (o *orderService) settleItemsTx(ctx context.Context, tx *gorm.DB, order *models.Order, nextStatus models.OrderStatus) error {
+	for i := range order.OrderItems {
+		oi := &order.OrderItems[i]
+		if nextStatus == models.OrderStateCancelled {
+			oi.CancelledQuantity += oi.OutstandingQuantity()
+		} else {
+			oi.ReturnedQuantity += oi.OutstandingQuantity()
+		}
+		oi.ReturnRequestedQuantity = 0
+		oi.RecomputeStatus()
+		if err := o.orderItemRepo.UpdateStatusTx(ctx, tx, oi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CancelItems cancels part of an order before it is processed, the cancelled quantity goes back
+// to stock and its money is refunded. Cancelling everything cancels the order.
+func (o *orderService) CancelItems(ctx context.Context, orderID uint, actor models.Actor, input dto.OrderItemsRequest) (*models.Order, error) {
+	var order *models.Order
+	var restock []models.OrderItem
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = o.lockOwnedOrderTx(ctx, tx, orderID, actor.UserID)
+		if err != nil {
+			return err
+		}
+		if !models.IsBeforeOrderStatus(order.Status, models.OrderStateConfirmed) {
+			return customErr.NewError(customErr.BAD_REQUEST, "Items can only be cancelled before the order is processed", http.StatusBadRequest, nil)
+		}
+		quantities, err := requestedItemQuantities(order, input.Items)
+		if err != nil {
+			return err
+		}
+
+		for i := range order.OrderItems {
+			oi := &order.OrderItems[i]
+			quantity, ok := quantities[oi.ID]
+			if !ok {
+				continue
+			}
+			if quantity > oi.OutstandingQuantity() {
+				return customErr.NewError(customErr.BAD_REQUEST, "Cancel quantity exceeds the quantity left on the item", http.StatusBadRequest, nil)
 			}
-
+			oi.CancelledQuantity += quantity
+			oi.RecomputeStatus()
+			if err := o.orderItemRepo.UpdateStatusTx(ctx, tx, oi); err != nil {
+				return err
+			}
+			restock = append(restock, models.OrderItem{ProductVariantID: oi.ProductVariantID, Quantity: quantity})
+		}
+		if err := o.stockLedger.RestockItemsTx(ctx, tx, order.ID, restock); err != nil {
+			return err
+		}
+		if _, err := o.refundService.RequestItemsTx(ctx, tx, order, quantities, input.Reason); err != nil {
+			return err
 		}
-	}
-}
 
-func (o *orderService) UpdateQuantity(ctx context.Context) error {
-	o.updateStocks()
-	// Get draft order that are converted to  order
-	draftOrders, err := o.draftOrderRepo.GetsForDbUpdate(ctx)
+		nextStatus, ok := order.AggregateItemStatus()
+		if !ok || nextStatus != models.OrderStateCancelled {
+			return nil
+		}
+		// Nothing left to ship, the shipping fee is refunded too
+		if _, err := o.refundService.RequestRemainingTx(ctx, tx, order, true, input.Reason); err != nil {
+			return err
+		}
+		if err := o.recordStatusTx(ctx, tx, order.ID, string(utils.EventCancel), order.Status, nextStatus, actor, "All items cancelled"); err != nil {
+			return err
+		}
+		order.Status = nextStatus
+		return o.orderRepo.UpdateStatusTx(ctx, tx, order.ID, nextStatus)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	totalQuantityByVariant := make(map[uint]uint)
+	o.wishlistService.NotifyBackInStock(ctx, stockIncreases(restock))
+	return order, nil
+}
 
-	// Sum quantity for each key: Product Variant id
-	for _, d := range draftOrders {
-		log.Printf("Draft Order ID: %d, Order ID: %d", d.ID, d.ToOrderID)
-		order, err := o.orderRepo.GetById(ctx, *d.ToOrderID)
+// RequestItemReturn asks to send back part of a shipped or delivered order
+func (o *orderService) RequestItemReturn(ctx context.Context, orderID uint, userID uint, input dto.OrderItemsRequest) (*models.Order, error) {
+	var order *models.Order
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = o.lockOwnedOrderTx(ctx, tx, orderID, userID)
 		if err != nil {
 			return err
 		}
-		orderItem := order.OrderItems
-		for _, oi := range orderItem {
-			totalQuantityByVariant[oi.ProductVariantID] += oi.Quantity
+		if _, err := utils.CanTransitionOrder(order.Status, utils.EventRequestReturn); err != nil {
+			return err
+		}
+		quantities, err := requestedItemQuantities(order, input.Items)
+		if err != nil {
+			return err
 		}
-	}
-	log.Printf("Total quantity of variants: %d", totalQuantityByVariant)
 
-	err = o.productVariantRepo.DecreaseQuantity(ctx, totalQuantityByVariant)
+		for i := range order.OrderItems {
+			oi := &order.OrderItems[i]
+			quantity, ok := quantities[oi.ID]
+			if !ok {
+				continue
+			}
+			if quantity > oi.ReturnableQuantity() {
+				return customErr.NewError(customErr.BAD_REQUEST, "Return quantity exceeds the quantity left on the item", http.StatusBadRequest, nil)
+			}
+			oi.ReturnRequestedQuantity += quantity
+			oi.RecomputeStatus()
+			if err := o.orderItemRepo.UpdateStatusTx(ctx, tx, oi); err != nil {
+				return err
+			}
+		}
+		log.Printf("Requested return of %v on order %d: %s", quantities, order.ID, input.Reason)
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return order, nil
+}
 
-	// Delete draft order that are converted to order
-	for _, d := range draftOrders {
-		err := o.draftOrderRepo.Delete(ctx, d.ID)
+// ReceiveItemReturn records returned items arriving back, restocks them and refunds their price
+func (o *orderService) ReceiveItemReturn(ctx context.Context, orderID uint, actor models.Actor, input dto.OrderItemsRequest) (*models.Order, error) {
+	var order *models.Order
+	var restock []models.OrderItem
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = o.orderRepo.GetByIdForUpdateTx(ctx, tx, orderID)
 		if err != nil {
 			return err
 		}
-		log.Printf("Deleted draft order %d", d.ID)
-	}
-
-	log.Printf("UpdateOrderStatus Db successfully")
-
-	// Delete redis cache
-	for key, _ := range totalQuantityByVariant {
-		err := o.productVariantCache.DeleteProductVariantHash(key)
+		quantities, err := requestedItemQuantities(order, input.Items)
 		if err != nil {
-			log.Print(err)
+			return err
 		}
-	}
-	log.Println("map delete redis", totalQuantityByVariant)
-	log.Printf("Delete redis Cache successfully")
 
-	//Clean draft order that can't be converted to order
-	err = o.CleanDraft(ctx)
-	if err != nil {
-		return err
-	}
-	log.Printf("Cleaned draft orders")
-	return nil
-}
+		for i := range order.OrderItems {
+			oi := &order.OrderItems[i]
+			quantity, ok := quantities[oi.ID]
+			if !ok {
+				continue
+			}
+			if quantity > oi.ReturnRequestedQuantity {
+				return customErr.NewError(customErr.BAD_REQUEST, "Received quantity exceeds the quantity requested to return", http.StatusBadRequest, nil)
+			}
+			oi.ReturnRequestedQuantity -= quantity
+			oi.ReturnedQuantity += quantity
+			oi.RecomputeStatus()
+			if err := o.orderItemRepo.UpdateStatusTx(ctx, tx, oi); err != nil {
+				return err
+			}
+			restock = append(restock, models.OrderItem{ProductVariantID: oi.ProductVariantID, Quantity: quantity})
+		}
+		if err := o.stockLedger.RestockItemsTx(ctx, tx, order.ID, restock); err != nil {
+			return err
+		}
+		if _, err := o.refundService.RequestItemsTx(ctx, tx, order, quantities, input.Reason); err != nil {
+			return err
+		}
 
-func (o *orderService) CleanDraft(ctx context.Context) error {
-	err := o.draftOrderRepo.CleanDraft(ctx)
+		if nextStatus, ok := order.AggregateItemStatus(); ok && nextStatus != order.Status {
+			if err := o.recordStatusTx(ctx, tx, order.ID, models.HistoryEventReceiveReturn, order.Status, nextStatus, actor, input.Reason); err != nil {
+				return err
+			}
+			order.Status = nextStatus
+			return o.orderRepo.UpdateStatusTx(ctx, tx, order.ID, nextStatus)
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	o.wishlistService.NotifyBackInStock(ctx, stockIncreases(restock))
+	return order, nil
 }
 
-func (o *orderService) updateStocks() {
-	data := o.updateStockAgg.Flush()
-	for key, value := range data {
-		err := o.db.Model(&models.ProductVariant{}).
-			Where("id = ?", key).
-			UpdateColumn("quantity", gorm.Expr("quantity + ?", value)).
-			Error
+// RejectItemReturn drops requested item returns that will not be accepted
+func (o *orderService) RejectItemReturn(ctx context.Context, orderID uint, input dto.OrderItemsRequest) (*models.Order, error) {
+	var order *models.Order
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = o.orderRepo.GetByIdForUpdateTx(ctx, tx, orderID)
 		if err != nil {
-			log.Printf("Error updating product variant: %d: %v", key, err)
+			return err
 		}
-		err = o.productVariantCache.DeleteProductVariantHash(key)
+		quantities, err := requestedItemQuantities(order, input.Items)
 		if err != nil {
-			log.Printf("Error deleting product variant cache: %v", err)
+			return err
+		}
+
+		for i := range order.OrderItems {
+			oi := &order.OrderItems[i]
+			quantity, ok := quantities[oi.ID]
+			if !ok {
+				continue
+			}
+			if quantity > oi.ReturnRequestedQuantity {
+				return customErr.NewError(customErr.BAD_REQUEST, "Rejected quantity exceeds the quantity requested to return", http.StatusBadRequest, nil)
+			}
+			oi.ReturnRequestedQuantity -= quantity
+			oi.RecomputeStatus()
+			if err := o.orderItemRepo.UpdateStatusTx(ctx, tx, oi); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetStatusHistory returns the timeline of an order to its owner or to an admin
+func (o *orderService) GetStatusHistory(ctx context.Context, orderID uint, actor models.Actor) ([]models.OrderStatusHistory, error) {
+	if actor.Role != models.RoleAdmin {
+		if _, err := o.orderRepo.GetByIdAndUserId(ctx, orderID, actor.UserID); err != nil {
+			return nil, err
 		}
 	}
-	log.Print("Update stocks successfully")
+	return o.statusHistoryRepo.ListByOrderId(ctx, orderID)
 }
 
-func (o *orderService) GetsByStatus(ctx context.Context, status models.OrderStatus, userId uint) ([]*models.Order, error) {
+// recordStatusTx appends a row to the order timeline inside the caller's transaction
+func (o *orderService) recordStatusTx(ctx context.Context, tx *gorm.DB, orderID uint, event string, from, to models.OrderStatus, actor models.Actor, note string) error {
+	return o.statusHistoryRepo.CreateTx(ctx, tx, &models.OrderStatusHistory{
+		OrderID:    orderID,
+		ActorID:    actor.UserID,
+		ActorRole:  actor.Role,
+		Event:      event,
+		FromStatus: from,
+		ToStatus:   to,
+		Note:       note,
+	})
+}
 
-	return o.orderRepo.GetsByStatusAndUserId(ctx, status, userId)
+// recordStatus is used by flows that are not transactional, a missing row only costs the timeline
+func (o *orderService) recordStatus(ctx context.Context, orderID uint, event string, from, to models.OrderStatus, actor models.Actor, note string) {
+	if err := o.recordStatusTx(ctx, o.db, orderID, event, from, to, actor, note); err != nil {
+		log.Printf("Error recording history of order %d: %v", orderID, err)
+	}
 }
 
-func (o *orderService) UpdateOrderStatus(ctx context.Context, orderId uint, event utils.OrderEvent) (*models.Order, error) {
-	order, err := o.orderRepo.GetById(ctx, orderId)
+func (o *orderService) lockOwnedOrderTx(ctx context.Context, tx *gorm.DB, orderID uint, userID uint) (*models.Order, error) {
+	order, err := o.orderRepo.GetByIdForUpdateTx(ctx, tx, orderID)
 	if err != nil {
 		return nil, err
 	}
-	if nextStatus, err := utils.CanTransitionOrder(order.Status, event); err != nil {
-		log.Printf(err.Error())
-		return nil, err
-	} else {
+	if order.UserID != userID {
+		return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Order not found", http.StatusNotFound, nil)
+	}
+	return order, nil
+}
 
-		// Increase stock if cancel before ship or  after completing return_shipping
-		if (nextStatus == models.OrderStateCancelled && models.IsBeforeOrderStatus(order.Status, models.OrderStateProcessing)) ||
-			nextStatus == models.OrderStateReturned {
-			log.Printf("Order %d is already processing cancel/return", order.ID)
-			o.updateStockAgg.AddOrder(*order)
-		}
-		// Update Status
-		order.Status = nextStatus
-		err = o.orderRepo.Update(ctx, order)
-		if err != nil {
-			log.Printf(err.Error(), "while saving order")
-			return nil, err
+// requestedItemQuantities sums the requested quantity per order item and checks every item belongs to the order
+func requestedItemQuantities(order *models.Order, items []dto.OrderItemQuantityInput) (map[uint]uint, error) {
+	owned := make(map[uint]bool, len(order.OrderItems))
+	for _, oi := range order.OrderItems {
+		owned[oi.ID] = true
+	}
+	quantities := make(map[uint]uint, len(items))
+	for _, item := range items {
+		if !owned[item.OrderItemID] {
+			return nil, customErr.NewError(customErr.BAD_REQUEST, "Order item does not belong to this order", http.StatusBadRequest, nil)
 		}
-
-		return order, nil
+		quantities[item.OrderItemID] += item.Quantity
 	}
+	return quantities, nil
 }
 
 func (o *orderService) MapOrderToCreateOrderResponse(ctx context.Context, order *models.Order) (*dto.CreateOrderResponse, error) {
@@ -896,7 +1372,9 @@ func (o *orderService) MapOrderToCreateOrderResponse(ctx context.Context, order
 			ProductVariantID: item.ProductVariantID,
 			Quantity:         item.Quantity,
 			Price:            item.Price,
+			Discount:         item.Discount,
 			TotalPrice:       item.TotalPrice,
+			Status:           item.Status,
 		})
 	}
 
@@ -906,6 +1384,9 @@ func (o *orderService) MapOrderToCreateOrderResponse(ctx context.Context, order
 			ID:                 order.PaymentInfos[0].ID,
 			Total:              order.PaymentInfos[0].Total,
 			ShippingFee:        order.PaymentInfos[0].ShippingFee,
+			Discount:           order.PaymentInfos[0].Discount,
+			ShippingDiscount:   order.PaymentInfos[0].ShippingDiscount,
+			CouponCode:         order.PaymentInfos[0].CouponCode,
 			Status:             order.PaymentInfos[0].Status,
 			PaymentLink:        order.PaymentInfos[0].PaymentLink,
 			CancellationReason: order.PaymentInfos[0].CancellationReason,
@@ -919,6 +1400,7 @@ func (o *orderService) MapOrderToCreateOrderResponse(ctx context.Context, order
 		PaymentMethod:   string(order.PaymentMethod),
 		ShippingAddress: order.ShippingAddress,
 		PhoneNumber:     order.PhoneNumber,
+		RecipientName:   order.RecipientName,
 		PaymentInfo:     paymentInfo,
 		OrderItems:      orderItems,
 		CreatedAt:       order.CreatedAt,
@@ -943,6 +1425,7 @@ func (o *orderService) MapDraftOrderToCreateOrderResponse(ctx context.Context, o
 			ProductVariantID: item.ProductVariantID,
 			Quantity:         item.Quantity,
 			Price:            item.Price,
+			Discount:         item.Discount,
 			TotalPrice:       item.TotalPrice,
 		})
 	}
@@ -953,6 +1436,9 @@ func (o *orderService) MapDraftOrderToCreateOrderResponse(ctx context.Context, o
 			ID:                 order.PaymentInfos[0].ID,
 			Total:              order.PaymentInfos[0].Total,
 			ShippingFee:        order.PaymentInfos[0].ShippingFee,
+			Discount:           order.PaymentInfos[0].Discount,
+			ShippingDiscount:   order.PaymentInfos[0].ShippingDiscount,
+			CouponCode:         order.PaymentInfos[0].CouponCode,
 			Status:             order.PaymentInfos[0].Status,
 			PaymentLink:        order.PaymentInfos[0].PaymentLink,
 			CancellationReason: order.PaymentInfos[0].CancellationReason,
@@ -967,6 +1453,7 @@ func (o *orderService) MapDraftOrderToCreateOrderResponse(ctx context.Context, o
 		ShippingAddress: order.ShippingAddress,
 		DeliveryMode:    order.DeliveryMode,
 		PhoneNumber:     order.PhoneNumber,
+		RecipientName:   order.RecipientName,
 		PaymentInfo:     paymentInfo,
 		OrderItems:      orderItems,
 		CreatedAt:       order.CreatedAt,
@@ -1031,7 +1518,9 @@ func (o *orderService) MapOrderToListOrderDataResponse(ctx context.Context, orde
 			ProductVariantID: item.ProductVariantID,
 			Quantity:         item.Quantity,
 			Price:            item.Price,
+			Discount:         item.Discount,
 			TotalPrice:       item.TotalPrice,
+			Status:           item.Status,
 			ProductName:      productString,
 		})
 	}
@@ -1042,6 +1531,9 @@ func (o *orderService) MapOrderToListOrderDataResponse(ctx context.Context, orde
 			ID:                 order.PaymentInfos[0].ID,
 			Total:              order.PaymentInfos[0].Total,
 			ShippingFee:        order.PaymentInfos[0].ShippingFee,
+			Discount:           order.PaymentInfos[0].Discount,
+			ShippingDiscount:   order.PaymentInfos[0].ShippingDiscount,
+			CouponCode:         order.PaymentInfos[0].CouponCode,
 			Status:             order.PaymentInfos[0].Status,
 			PaymentLink:        order.PaymentInfos[0].PaymentLink,
 			CancellationReason: order.PaymentInfos[0].CancellationReason,
@@ -1055,6 +1547,7 @@ func (o *orderService) MapOrderToListOrderDataResponse(ctx context.Context, orde
 		PaymentMethod:   string(order.PaymentMethod),
 		ShippingAddress: order.ShippingAddress,
 		PhoneNumber:     order.PhoneNumber,
+		RecipientName:   order.RecipientName,
 		PaymentInfo:     paymentInfo,
 		OrderItems:      orderItems,
 		DeliveryMode:    order.DeliveryMode,
@@ -1082,6 +1575,7 @@ func (o *orderService) MapDraftOrderToListOrderDataResponse(ctx context.Context,
 			ProductVariantID: item.ProductVariantID,
 			Quantity:         item.Quantity,
 			Price:            item.Price,
+			Discount:         item.Discount,
 			TotalPrice:       item.TotalPrice,
 			ProductName:      productString,
 		})
@@ -1093,6 +1587,9 @@ func (o *orderService) MapDraftOrderToListOrderDataResponse(ctx context.Context,
 			ID:                 order.PaymentInfos[0].ID,
 			Total:              order.PaymentInfos[0].Total,
 			ShippingFee:        order.PaymentInfos[0].ShippingFee,
+			Discount:           order.PaymentInfos[0].Discount,
+			ShippingDiscount:   order.PaymentInfos[0].ShippingDiscount,
+			CouponCode:         order.PaymentInfos[0].CouponCode,
 			Status:             order.PaymentInfos[0].Status,
 			PaymentLink:        order.PaymentInfos[0].PaymentLink,
 			CancellationReason: order.PaymentInfos[0].CancellationReason,
@@ -1106,6 +1603,7 @@ func (o *orderService) MapDraftOrderToListOrderDataResponse(ctx context.Context,
 		PaymentMethod:   string(order.PaymentMethod),
 		ShippingAddress: order.ShippingAddress,
 		PhoneNumber:     order.PhoneNumber,
+		RecipientName:   order.RecipientName,
 		PaymentInfo:     paymentInfo,
 		OrderItems:      orderItems,
 		DeliveryMode:    order.DeliveryMode,
@@ -1197,6 +1695,14 @@ func (o *orderService) ListByAdmin(c *gin.Context) ([]*dto.OrderData, error) {
 	return results, nil
 }
 
+func (o *orderService) ListByMerchant(ctx context.Context, merchantID uint) ([]*dto.OrderData, error) {
+	orders, err := o.orderRepo.ListByMerchant(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	return o.MapOrdersToListOrderDataResponses(ctx, orders)
+}
+
 func (o *orderService) loadAndCacheProductVariants(ctx context.Context, ids []uint) ([]models.ProductVariant, error) {
 	// Get List from Db
 	variants, err := o.productVariantRepo.GetByIDSForRedisCache(ctx, ids)
@@ -1230,7 +1736,7 @@ func (o *orderService) loadAndCacheProductVariants(ctx context.Context, ids []ui
 	return variants, nil
 }
 
-func (o *orderService) CreateOrderWithDb(ctx context.Context, input dto.CreateOrderInput) (models.DraftOrder, []models.OrderItem, error) {
+func (o *orderService) CreateOrderWithDb(ctx context.Context, input dto.CreateOrderInput, quote *dto.PromotionQuote) (models.DraftOrder, []models.OrderItem, error) {
 	var createdDraftOrder models.DraftOrder
 	var createdItems []models.OrderItem
 
@@ -1314,6 +1820,7 @@ func (o *orderService) CreateOrderWithDb(ctx context.Context, input dto.CreateOr
 			Status:          models.OrderStatePending,
 			ShippingAddress: input.ShippingAddress,
 			PhoneNumber:     input.PhoneNumber,
+			RecipientName:   input.RecipientName,
 			DeliveryMode:    input.ShippingFeeInput[0].Mode,
 			Latitude:        input.Latitude,
 			Longitude:       input.Longitude,
@@ -1323,14 +1830,15 @@ func (o *orderService) CreateOrderWithDb(ctx context.Context, input dto.CreateOr
 		}
 
 		// 6. Create order_items
-		for _, item := range input.OrderItems {
+		for i, item := range input.OrderItems {
 			newItem := models.OrderItem{
 				OrderID:          createdDraftOrder.ID,
 				OrderType:        models.OrderTypeDraftOrder,
 				ProductVariantID: item.ProductVariantID,
 				Quantity:         item.Quantity,
 				Price:            item.Price,
-				TotalPrice:       float64(item.Quantity) * item.Price,
+				Discount:         quote.ItemDiscount(i),
+				TotalPrice:       float64(item.Quantity)*item.Price - quote.ItemDiscount(i),
 				MerchantID:       item.MerchantID,
 			}
 			createdItems = append(createdItems, newItem)
@@ -1339,6 +1847,9 @@ func (o *orderService) CreateOrderWithDb(ctx context.Context, input dto.CreateOr
 		if err := tx.Create(&createdItems).Error; err != nil {
 			return err
 		}
+		if err := o.stockLedger.ReserveTx(ctx, tx, createdDraftOrder.ID, createdItems); err != nil {
+			return err
+		}
 
 		// 7. Create Delivery detail
 		newDeliveryDetail := models.DeliveryDetail{
@@ -1419,8 +1930,12 @@ func (o *orderService) ChangePaymentMethod(c *gin.Context, paymentChange dto.Cha
 				shippingFeeResponse = append(shippingFeeResponse, fee...)
 			}
 
+			shippingDiscounts, err := o.promotionService.ShippingDiscounts(c, draft.ID)
+			if err != nil {
+				return nil, err
+			}
 			// Change payment method and convert to order
-			orderUpdated, err := o.ChangeToCODPaymentFromDraft(c, draft, payment.Total, payment.ShippingFee)
+			orderUpdated, err := o.ChangeToCODPaymentFromDraft(c, draft, payment)
 			if err != nil {
 				return orderUpdated, err
 			}
@@ -1430,25 +1945,25 @@ func (o *orderService) ChangePaymentMethod(c *gin.Context, paymentChange dto.Cha
 				orderUpdated.OrderItems[i].MerchantID = itemAndMerchantMap[orderUpdated.OrderItems[i].ID]
 			}
 			// Split
-			o.SplitOrderAfterChangePaymentMethod(c, orderUpdated, orderUpdated.OrderItems, merchantIDs, shippingFeeResponse)
+			o.SplitOrderAfterChangePaymentMethod(c, orderUpdated, orderUpdated.OrderItems, merchantIDs, shippingFeeResponse, shippingDiscounts)
 
 			cancelReason := "Change payment method"
-			_, err = payos.CancelPaymentLink(strconv.FormatInt(payment.ID, 10), &cancelReason)
+			err = o.paymentProvider.CancelLink(c, payment.ID, cancelReason)
 			if err != nil {
-				log.Println("Payment id: ", payment.ID, " cant cancel payment payos", err)
+				log.Println("Payment id: ", payment.ID, " cant cancel payment link", err)
 			}
 			return orderUpdated, nil
 		} else {
 			//nosplit
-			orderUpdated, err := o.ChangeToCODPaymentFromDraft(c, draft, payment.Total, payment.ShippingFee)
+			orderUpdated, err := o.ChangeToCODPaymentFromDraft(c, draft, payment)
 			if err != nil {
 				return orderUpdated, err
 			}
 			// Cancel payment link
 			cancelReason := "Change payment method"
-			_, err = payos.CancelPaymentLink(strconv.FormatInt(payment.ID, 10), &cancelReason)
+			err = o.paymentProvider.CancelLink(c, payment.ID, cancelReason)
 			if err != nil {
-				log.Println("Payment id: ", payment.ID, " cant cancel payment payos", err)
+				log.Println("Payment id: ", payment.ID, " cant cancel payment link", err)
 			}
 			return orderUpdated, nil
 		}
@@ -1461,18 +1976,18 @@ func (o *orderService) ChangePaymentMethod(c *gin.Context, paymentChange dto.Cha
 			return nil, customErr.NewError(customErr.BAD_REQUEST, fmt.Sprintf("Cant change payment method from %s to %s", order.PaymentMethod, paymentChange.PaymentMethod), http.StatusBadRequest, nil)
 		}
 		if paymentChange.PaymentMethod == models.PaymentMethodCOD {
-			updatedOrder, err2 := o.ChangeToCODPaymentFromOrder(c, order, payment.Total, payment.ShippingFee)
+			updatedOrder, err2 := o.ChangeToCODPaymentFromOrder(c, order, payment)
 			if err2 != nil {
 				return nil, err2
 			}
 			cancelReason := "Change payment method"
-			_, err = payos.CancelPaymentLink(strconv.FormatInt(payment.ID, 10), &cancelReason)
+			err = o.paymentProvider.CancelLink(c, payment.ID, cancelReason)
 			if err != nil {
-				log.Println("Payment id: ", payment.ID, " cant cancel payment payos", err)
+				log.Println("Payment id: ", payment.ID, " cant cancel payment link", err)
 			}
 			return updatedOrder, nil
 		} else if paymentChange.PaymentMethod == models.PaymentMethodBank {
-			updatedOrder, err := o.ChangeToBankPaymentFromOrder(c, order, payment.Total, payment.ShippingFee)
+			updatedOrder, err := o.ChangeToBankPaymentFromOrder(c, order, payment)
 			if err != nil {
 				return nil, err
 			}
@@ -1487,7 +2002,7 @@ func (o *orderService) ChangePaymentMethod(c *gin.Context, paymentChange dto.Cha
 	return order, nil
 }
 
-func (o *orderService) ChangeToCODPaymentFromDraft(c *gin.Context, draft *models.DraftOrder, total float64, shippingFee float64) (*models.Order, error) {
+func (o *orderService) ChangeToCODPaymentFromDraft(c *gin.Context, draft *models.DraftOrder, previous models.PaymentInfo) (*models.Order, error) {
 
 	order := &models.Order{
 		UserID:          draft.UserID,
@@ -1495,49 +2010,71 @@ func (o *orderService) ChangeToCODPaymentFromDraft(c *gin.Context, draft *models
 		PaymentMethod:   models.PaymentMethodCOD,
 		ShippingAddress: draft.ShippingAddress,
 		PhoneNumber:     draft.PhoneNumber,
+		RecipientName:   draft.RecipientName,
 		DeliveryMode:    draft.DeliveryMode,
 		Longitude:       draft.Longitude,
 		Latitude:        draft.Latitude,
 		Delivery:        draft.Delivery,
 	}
-	if err := o.orderRepo.Create(c, order); err != nil {
-		return nil, err
-	}
+	var paymentInfo *models.PaymentInfo
+	// Order, moved items, sale and closed draft are written together so the reservation is never counted twice.
+	// The bank payment is cancelled in the same transaction, a late webhook or poll can't convert the draft again
+	err := o.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := o.lockOpenDraftTx(c, tx, draft.ID); err != nil {
+			return err
+		}
+		now := time.Now()
+		cancelled := previous
+		cancelled.Status = models.PaymentCanceled
+		cancelled.CancellationReason = "Change payment method"
+		cancelled.CancellationAt = &now
+		claimed, err := o.paymentInfoRepo.CompareAndSetStatusTx(c, tx, &cancelled, models.PaymentPending)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return customErr.NewError(customErr.BAD_REQUEST, "Cant change payment method", http.StatusConflict, nil)
+		}
 
-	var paymentInfo = &models.PaymentInfo{
-		ID:          GeneratePaymentInfoID(),
-		Total:       total,
-		ShippingFee: shippingFee,
-		OrderID:     order.ID,
-		OrderType:   models.OrderTypeOrder,
-		Status:      models.PaymentPending,
-	}
-	if err := o.paymentInfoRepo.Create(c, paymentInfo); err != nil {
-		log.Printf(err.Error(), "while creating payment info")
-		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "CreatePayment error", http.StatusInternalServerError, err)
-	}
-	for _, oi := range draft.OrderItems {
-		oi.OrderID = order.ID
-		oi.OrderType = models.OrderTypeOrder
-		if err := o.orderItemRepo.Save(c, &oi); err != nil {
-			log.Printf(err.Error(), "while saving order item")
+		if _, err := o.orderRepo.CreateTx(c, tx, order); err != nil {
+			return err
+		}
+		if err := o.recordStatusTx(c, tx, order.ID, models.HistoryEventCreate, "", order.Status,
+			models.Actor{UserID: order.UserID, Role: models.RoleUser}, fmt.Sprintf("Created from draft order %d after switching to COD", draft.ID)); err != nil {
+			return err
+		}
+
+		paymentInfo = nextPaymentInfo(previous, order.ID)
+		if err := o.paymentInfoRepo.CreateTx(c, tx, paymentInfo); err != nil {
+			log.Printf(err.Error(), "while creating payment info")
+			return customErr.NewError(customErr.INTERNAL_ERROR, "CreatePayment error", http.StatusInternalServerError, err)
+		}
+		for _, oi := range draft.OrderItems {
+			oi.OrderID = order.ID
+			oi.OrderType = models.OrderTypeOrder
+			if err := o.orderItemRepo.SaveTx(c, tx, &oi); err != nil {
+				return customErr.NewError(customErr.INTERNAL_ERROR, "Move order item error", http.StatusInternalServerError, err)
+			}
+			order.OrderItems = append(order.OrderItems, oi)
+		}
+		if err := o.stockLedger.SellTx(c, tx, draft.ID, order.ID, order.OrderItems); err != nil {
+			return err
 		}
-		order.OrderItems = append(order.OrderItems, oi)
-	}
-	order.OrderItems = draft.OrderItems
 
-	draft.OrderItems = nil
-	draft.ToOrderID = &order.ID
-	draft.Delivery = models.DeliveryDetail{}
-	if err := o.draftOrderRepo.Save(c, draft); err != nil {
-		log.Printf(err.Error(), "while saving draft")
+		draft.OrderItems = nil
+		draft.ToOrderID = &order.ID
+		draft.Delivery = models.DeliveryDetail{}
+		return o.draftOrderRepo.SaveTx(c, tx, draft)
+	})
+	if err != nil {
+		return nil, err
 	}
 	order.PaymentInfos = nil
 	order.PaymentInfos = append(order.PaymentInfos, *paymentInfo)
 	return order, nil
 }
 
-func (o *orderService) ChangeToCODPaymentFromOrder(c *gin.Context, order *models.Order, total float64, shippingFee float64) (*models.Order, error) {
+func (o *orderService) ChangeToCODPaymentFromOrder(c *gin.Context, order *models.Order, previous models.PaymentInfo) (*models.Order, error) {
 
 	order.PaymentMethod = models.PaymentMethodCOD
 
@@ -1545,14 +2082,7 @@ func (o *orderService) ChangeToCODPaymentFromOrder(c *gin.Context, order *models
 		return nil, err
 	}
 
-	var paymentInfo = &models.PaymentInfo{
-		ID:          GeneratePaymentInfoID(),
-		Total:       total,
-		ShippingFee: shippingFee,
-		OrderID:     order.ID,
-		OrderType:   models.OrderTypeOrder,
-		Status:      models.PaymentPending,
-	}
+	var paymentInfo = nextPaymentInfo(previous, order.ID)
 	if err := o.paymentInfoRepo.Create(c, paymentInfo); err != nil {
 		log.Printf(err.Error(), "while creating payment info")
 		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "CreatePayment error", http.StatusInternalServerError, err)
@@ -1562,55 +2092,73 @@ func (o *orderService) ChangeToCODPaymentFromOrder(c *gin.Context, order *models
 	return order, nil
 }
 
-func (o *orderService) ChangeToBankPaymentFromOrder(c *gin.Context, order *models.Order, total float64, shippingFee float64) (*models.Order, error) {
+func (o *orderService) ChangeToBankPaymentFromOrder(c *gin.Context, order *models.Order, previous models.PaymentInfo) (*models.Order, error) {
 
 	order.PaymentMethod = models.PaymentMethodBank
-	var paymentInfo = &models.PaymentInfo{
-		ID:          GeneratePaymentInfoID(),
-		Total:       total,
-		ShippingFee: shippingFee,
-		OrderID:     order.ID,
-		OrderType:   models.OrderTypeOrder,
-		Status:      models.PaymentPending,
-	}
+	var paymentInfo = nextPaymentInfo(previous, order.ID)
 
 	err := o.orderRepo.Save(c, order)
 	if err != nil {
 		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Change order payment error", http.StatusInternalServerError, err)
 	}
-	bankPayment, err := CreatePayOSPayment(paymentInfo.ID, paymentInfo.Total, MapOrderItemsToPayOSItems(order.OrderItems, int(paymentInfo.ShippingFee)), "Thanh toan don hang", "localhost:5173", "localhost:5173")
+	bankPayment, err := o.paymentProvider.CreateLink(c, payment.CreateLinkRequest{
+		PaymentID:   paymentInfo.ID,
+		Amount:      int(paymentInfo.Total),
+		Items:       MapOrderItemsToPaymentItems(order.OrderItems, paymentInfo),
+		Description: "Thanh toan don hang",
+		ReturnURL:   "localhost:5173",
+		CancelURL:   "localhost:5173",
+		ExpiresAt:   time.Now().Add(paymentLinkTTL),
+	})
 	if err != nil {
 		log.Printf(err.Error(), "while creating payment info")
 		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "CreatePayment error", http.StatusInternalServerError, err)
 	}
 
-	// Publish event after create payOS link
+	log.Printf("%s payment created for order %d: %s", o.paymentProvider.Name(), order.ID, bankPayment.CheckoutURL)
+
+	// Save payment link & payment created event together
+	paymentInfo.PaymentLink = bankPayment.CheckoutURL
 	paymentEvent := event.PayOSPaymentCreatedEvent{
 		Id:            paymentInfo.ID,
 		OrderID:       order.ID,
-		PaymentLink:   bankPayment.CheckoutUrl,
+		PaymentLink:   bankPayment.CheckoutURL,
 		Total:         10000,
 		PaymentMethod: string(order.PaymentMethod),
 		CreatedAt:     time.Now(),
 	}
-
-	if err := o.eventBus.PublishPaymentCreated(paymentEvent); err != nil {
-		log.Printf("Failed to publish payment created event: %v", err)
-	}
-	log.Printf("PayOs payment created for order %d: %s", order.ID, bankPayment.CheckoutUrl)
-
-	// Save payment link & return
-	paymentInfo.PaymentLink = bankPayment.CheckoutUrl
-
-	if err := o.paymentInfoRepo.Create(c, paymentInfo); err != nil {
-		log.Printf(err.Error(), "while creating payment info")
-		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "CreatePayment error", http.StatusInternalServerError, err)
+	err = o.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := o.paymentInfoRepo.CreateTx(c, tx, paymentInfo); err != nil {
+			log.Printf("Error creating payment info: %v", err)
+			return customErr.NewError(customErr.INTERNAL_ERROR, "CreatePayment error", http.StatusInternalServerError, err)
+		}
+		if err := o.eventBus.PublishPaymentCreatedTx(tx, paymentEvent); err != nil {
+			return customErr.NewError(customErr.INTERNAL_ERROR, "Publish payment created event error", http.StatusInternalServerError, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	order.PaymentInfos = nil
 	order.PaymentInfos = append(order.PaymentInfos, *paymentInfo)
 	return order, nil
 }
-func (o *orderService) SplitOrder(ctx context.Context, draftOrder *models.DraftOrder, orderItems []models.OrderItem, merchantIDs []uint, shippingFeeResponses []dto.ShippingFeeResponse) ([]*models.DraftOrder, error) {
+func (o *orderService) SplitOrder(ctx context.Context, draftOrder *models.DraftOrder, orderItems []models.OrderItem, merchantIDs []uint, shippingFeeResponses []dto.ShippingFeeResponse, shippingDiscounts map[uint]float64) ([]*models.DraftOrder, error) {
+	var subDraftOrders []*models.DraftOrder
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		subDraftOrders, err = o.splitOrderTx(ctx, tx, draftOrder, orderItems, merchantIDs, shippingFeeResponses, shippingDiscounts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return subDraftOrders, nil
+}
+
+// splitOrderTx moves the items of a multi merchant draft into one sub draft per merchant
+func (o *orderService) splitOrderTx(ctx context.Context, tx *gorm.DB, draftOrder *models.DraftOrder, orderItems []models.OrderItem, merchantIDs []uint, shippingFeeResponses []dto.ShippingFeeResponse, shippingDiscounts map[uint]float64) ([]*models.DraftOrder, error) {
 
 	groups := make(map[uint][]models.OrderItem)
 
@@ -1629,12 +2177,13 @@ func (o *orderService) SplitOrder(ctx context.Context, draftOrder *models.DraftO
 			ShippingAddress: draftOrder.ShippingAddress,
 			PaymentMethod:   draftOrder.PaymentMethod,
 			PhoneNumber:     draftOrder.PhoneNumber,
+			RecipientName:   draftOrder.RecipientName,
 			ParentID:        &draftOrder.ID,
 			DeliveryMode:    draftOrder.DeliveryMode,
 			Latitude:        draftOrder.Latitude,
 			Longitude:       draftOrder.Longitude,
 		}
-		if err := o.draftOrderRepo.Create(ctx, draftOrderSplit); err != nil {
+		if _, err := o.draftOrderRepo.CreateTx(ctx, tx, draftOrderSplit); err != nil {
 			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Split order error", http.StatusInternalServerError, err)
 		}
 
@@ -1645,16 +2194,17 @@ func (o *orderService) SplitOrder(ctx context.Context, draftOrder *models.DraftO
 			OrderType:  models.OrderTypeDraftOrder,
 		}
 
-		if err := o.db.WithContext(ctx).Create(&subDeliveryDetail).Error; err != nil {
+		if err := tx.Create(&subDeliveryDetail).Error; err != nil {
 			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Split order error 2", http.StatusInternalServerError, err)
 		}
 
-		// Change order items reference
-		var total float64
+		// Change order items reference, item totals already carry their share of the discount
+		var total, discount float64
 		for _, orderItemSplit := range orderItemsSplit {
 			orderItemSplit.OrderID = draftOrderSplit.ID
 			total += orderItemSplit.TotalPrice
-			if err := o.orderItemRepo.Save(ctx, &orderItemSplit); err != nil {
+			discount += orderItemSplit.Discount
+			if err := o.orderItemRepo.SaveTx(ctx, tx, &orderItemSplit); err != nil {
 				return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Change order item reference error", http.StatusInternalServerError, err)
 			}
 		}
@@ -1670,16 +2220,19 @@ func (o *orderService) SplitOrder(ctx context.Context, draftOrder *models.DraftO
 		log.Println("1, tempshippingfee: ", tempShippingFee, " total:", total)
 		// Create payment for sub draft
 		var paymentSplit = &models.PaymentInfo{
-			ID:          GeneratePaymentInfoID(),
-			Total:       total + tempShippingFee,
-			ShippingFee: tempShippingFee,
-			OrderID:     draftOrderSplit.ID,
-			OrderType:   models.OrderTypeDraftOrder,
-			Status:      draftOrder.PaymentInfos[0].Status,
-			ParentID:    &draftOrder.PaymentInfos[0].ID,
+			ID:               GeneratePaymentInfoID(),
+			Total:            total + tempShippingFee - shippingDiscounts[merchantID],
+			ShippingFee:      tempShippingFee,
+			Discount:         discount,
+			ShippingDiscount: shippingDiscounts[merchantID],
+			CouponCode:       draftOrder.PaymentInfos[0].CouponCode,
+			OrderID:          draftOrderSplit.ID,
+			OrderType:        models.OrderTypeDraftOrder,
+			Status:           draftOrder.PaymentInfos[0].Status,
+			ParentID:         &draftOrder.PaymentInfos[0].ID,
 		}
 		log.Println("2, paymentsplit: ", paymentSplit.Total, " ,", paymentSplit.ShippingFee)
-		if err := o.paymentInfoRepo.Create(ctx, paymentSplit); err != nil {
+		if err := o.paymentInfoRepo.CreateTx(ctx, tx, paymentSplit); err != nil {
 			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "CreatePayment error", http.StatusInternalServerError, err)
 		}
 
@@ -1696,21 +2249,18 @@ func (o *orderService) SplitOrder(ctx context.Context, draftOrder *models.DraftO
 	draftOrder.OrderItems = nil
 	draftOrder.ParentID = &temp1
 	draftOrder.PaymentInfos[0].ParentID = &temp2
-	err := o.draftOrderRepo.Save(ctx, draftOrder)
-	if err != nil {
-		log.Println("Error while split draft order ", err)
+	if err := o.draftOrderRepo.SaveTx(ctx, tx, draftOrder); err != nil {
+		return nil, err
 	}
-
-	err = o.paymentInfoRepo.Save(ctx, &draftOrder.PaymentInfos[0])
-	if err != nil {
-		log.Println("Error while split draft order ", err)
+	if err := o.paymentInfoRepo.SaveTx(ctx, tx, &draftOrder.PaymentInfos[0]); err != nil {
+		return nil, err
 	}
 	subDraftOrders = append(subDraftOrders, draftOrder)
 
 	return subDraftOrders, nil
 }
 
-func (o *orderService) SplitOrderAfterChangePaymentMethod(ctx context.Context, order *models.Order, orderItems []models.OrderItem, merchantIDs []uint, shippingFeeResponses []dto.ShippingFeeResponse) ([]*models.Order, error) {
+func (o *orderService) SplitOrderAfterChangePaymentMethod(ctx context.Context, order *models.Order, orderItems []models.OrderItem, merchantIDs []uint, shippingFeeResponses []dto.ShippingFeeResponse, shippingDiscounts map[uint]float64) ([]*models.Order, error) {
 
 	groups := make(map[uint][]models.OrderItem)
 
@@ -1730,6 +2280,7 @@ func (o *orderService) SplitOrderAfterChangePaymentMethod(ctx context.Context, o
 			ShippingAddress: order.ShippingAddress,
 			PaymentMethod:   order.PaymentMethod,
 			PhoneNumber:     order.PhoneNumber,
+			RecipientName:   order.RecipientName,
 			ParentID:        &order.ID,
 			DeliveryMode:    order.DeliveryMode,
 			Latitude:        order.Latitude,
@@ -1738,6 +2289,8 @@ func (o *orderService) SplitOrderAfterChangePaymentMethod(ctx context.Context, o
 		if err := o.orderRepo.Create(ctx, orderSplit); err != nil {
 			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Split order error", http.StatusInternalServerError, err)
 		}
+		o.recordStatus(ctx, orderSplit.ID, models.HistoryEventSplit, "", orderSplit.Status,
+			models.Actor{UserID: orderSplit.UserID, Role: models.RoleUser}, fmt.Sprintf("Split from order %d", order.ID))
 
 		// Create sub delivery detail
 		subDeliveryDetail := &models.DeliveryDetail{
@@ -1750,11 +2303,12 @@ func (o *orderService) SplitOrderAfterChangePaymentMethod(ctx context.Context, o
 			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Split order error 2", http.StatusInternalServerError, err)
 		}
 
-		// Change order items reference
-		var total float64
+		// Change order items reference, item totals already carry their share of the discount
+		var total, discount float64
 		for _, orderItemSplit := range orderItemsSplit {
 			orderItemSplit.OrderID = orderSplit.ID
 			total += orderItemSplit.TotalPrice
+			discount += orderItemSplit.Discount
 			orderItemSplit.OrderType = models.OrderTypeOrder
 			if err := o.orderItemRepo.Save(ctx, &orderItemSplit); err != nil {
 				return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Change order item reference error", http.StatusInternalServerError, err)
@@ -1772,13 +2326,16 @@ func (o *orderService) SplitOrderAfterChangePaymentMethod(ctx context.Context, o
 		log.Println("1, tempshippingfee: ", tempShippingFee, " total:", total)
 		// Create payment for sub draft
 		var paymentSplit = &models.PaymentInfo{
-			ID:          GeneratePaymentInfoID(),
-			Total:       total + tempShippingFee,
-			ShippingFee: tempShippingFee,
-			OrderID:     orderSplit.ID,
-			OrderType:   models.OrderTypeOrder,
-			Status:      order.PaymentInfos[0].Status,
-			ParentID:    &order.PaymentInfos[0].ID,
+			ID:               GeneratePaymentInfoID(),
+			Total:            total + tempShippingFee - shippingDiscounts[merchantID],
+			ShippingFee:      tempShippingFee,
+			Discount:         discount,
+			ShippingDiscount: shippingDiscounts[merchantID],
+			CouponCode:       order.PaymentInfos[0].CouponCode,
+			OrderID:          orderSplit.ID,
+			OrderType:        models.OrderTypeOrder,
+			Status:           order.PaymentInfos[0].Status,
+			ParentID:         &order.PaymentInfos[0].ID,
 		}
 		log.Println("2, paymentsplit: ", paymentSplit.Total, " ,", paymentSplit.ShippingFee)
 		if err := o.paymentInfoRepo.Create(ctx, paymentSplit); err != nil {
@@ -1812,6 +2369,18 @@ func (o *orderService) SplitOrderAfterChangePaymentMethod(ctx context.Context, o
 	return subOrders, nil
 }
 
+// ResolveShippingAddress returns a saved address of the user
+func (o *orderService) ResolveShippingAddress(ctx context.Context, userID uint, addressID uint) (*models.Address, error) {
+	address, err := o.addressRepo.GetByID(ctx, addressID)
+	if err != nil {
+		return nil, err
+	}
+	if address.UserID != userID {
+		return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Address not found", http.StatusNotFound, nil)
+	}
+	return address, nil
+}
+
 func (o *orderService) CalculateShippingFees(c context.Context, merchantID uint, destLon, destLat string) ([]*dto.ShippingFeeResponse, error) {
 	merchant, err := o.merchantRepo.GetByID(c, merchantID)
 	if err != nil {
@@ -1914,3 +2483,101 @@ func (o *orderService) CalculateShippingFee(c context.Context, merchantID uint,
 
 	return shippingFee, nil
 }
+
+// quoteCoupon evaluates the coupon of the order against its signed prices and shipping fees
+func (o *orderService) quoteCoupon(ctx context.Context, input dto.CreateOrderInput) (*dto.PromotionQuote, error) {
+	lines := make([]dto.PromotionLine, 0, len(input.OrderItems))
+	for _, oi := range input.OrderItems {
+		lines = append(lines, dto.PromotionLine{
+			ProductVariantID: oi.ProductVariantID,
+			MerchantID:       oi.MerchantID,
+			Price:            oi.Price,
+			Quantity:         oi.Quantity,
+		})
+	}
+	shippingFees := make(map[uint]float64)
+	for _, shipping := range input.ShippingFeeInput {
+		shippingFees[shipping.MerchantID] += shipping.Fee
+	}
+	return o.promotionService.Evaluate(ctx, input.UserID, input.CouponCode, lines, shippingFees)
+}
+
+// abandonDraft closes a draft order that can not be placed and gives its stock back, false when it was already closed
+func (o *orderService) abandonDraft(ctx context.Context, draftOrder *models.DraftOrder, orderItems []models.OrderItem) bool {
+	released, err := o.releaseDraft(ctx, draftOrder.ID, orderItems)
+	if err != nil {
+		log.Printf("Error closing draft order %d: %v", draftOrder.ID, err)
+		return false
+	}
+	if !released {
+		return false
+	}
+	closed := uint(0)
+	draftOrder.ToOrderID = &closed
+	if err := o.productVariantCache.IncrementStockIfExists(orderItems); err != nil {
+		log.Printf("Error returning stock of draft order %d to redis: %v", draftOrder.ID, err)
+	}
+	o.wishlistService.NotifyBackInStock(ctx, stockIncreases(orderItems))
+	return true
+}
+
+// releaseDraft closes the draft and releases its reservation together, false when it was converted
+// or closed by another caller such as the expiry worker so the stock goes back only once
+func (o *orderService) releaseDraft(ctx context.Context, draftOrderID uint, orderItems []models.OrderItem) (bool, error) {
+	var released bool
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		open, err := o.draftOrderRepo.LockOpenTx(ctx, tx, draftOrderID)
+		if err != nil || !open {
+			return err
+		}
+		if err := o.draftOrderRepo.CloseTx(ctx, tx, draftOrderID); err != nil {
+			return err
+		}
+		if err := o.stockLedger.ReleaseTx(ctx, tx, draftOrderID, orderItems); err != nil {
+			return err
+		}
+		released = true
+		return nil
+	})
+	return released, err
+}
+
+// publishOrderDoneTx tells the search index that total_buy of the order's products changed
+func (o *orderService) publishOrderDoneTx(tx *gorm.DB, orderID uint) error {
+	return o.eventBus.PublishCatalogChangedTx(tx, event.NewCatalogChangedEvent(event.CatalogOrder, orderID, false))
+}
+
+// requestedItems carries the requested quantities in the shape the stock cache expects
+func requestedItems(items []dto.CreateOrderItem) []models.OrderItem {
+	result := make([]models.OrderItem, 0, len(items))
+	for _, oi := range items {
+		result = append(result, models.OrderItem{ProductVariantID: oi.ProductVariantID, Quantity: oi.Quantity})
+	}
+	return result
+}
+
+// stockIncreases sums the quantities going back to stock per variant
+func stockIncreases(items []models.OrderItem) map[uint]uint {
+	increases := make(map[uint]uint, len(items))
+	for _, oi := range items {
+		if oi.Quantity > 0 {
+			increases[oi.ProductVariantID] += oi.Quantity
+		}
+	}
+	return increases
+}
+
+// nextPaymentInfo replaces the payment of an order after a payment method change, amount and discount are kept
+func nextPaymentInfo(previous models.PaymentInfo, orderID uint) *models.PaymentInfo {
+	return &models.PaymentInfo{
+		ID:               GeneratePaymentInfoID(),
+		Total:            previous.Total,
+		ShippingFee:      previous.ShippingFee,
+		Discount:         previous.Discount,
+		ShippingDiscount: previous.ShippingDiscount,
+		CouponCode:       previous.CouponCode,
+		OrderID:          orderID,
+		OrderType:        models.OrderTypeOrder,
+		Status:           models.PaymentPending,
+	}
+}
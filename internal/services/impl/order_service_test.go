@@ -0,0 +1,443 @@
+package impl
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/payment"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"github.com/minh6824pro/nxrGO/internal/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// fakeDraftOrderRepo holds one draft, closed stands for a draft converted or cancelled by another caller
+type fakeDraftOrderRepo struct {
+	repositories.DraftOrderRepository
+	draft  *models.DraftOrder
+	closed bool
+	locked []uint
+}
+
+func (f *fakeDraftOrderRepo) GetById(ctx context.Context, orderID uint) (*models.DraftOrder, error) {
+	found := *f.draft
+	return &found, nil
+}
+
+func (f *fakeDraftOrderRepo) LockOpenTx(ctx context.Context, tx *gorm.DB, draftOrderID uint) (bool, error) {
+	f.locked = append(f.locked, draftOrderID)
+	return !f.closed, nil
+}
+
+func (f *fakeDraftOrderRepo) CloseTx(ctx context.Context, tx *gorm.DB, draftOrderID uint) error {
+	closed := uint(0)
+	f.draft.ToOrderID = &closed
+	f.closed = true
+	return nil
+}
+
+func (f *fakeDraftOrderRepo) SaveTx(ctx context.Context, tx *gorm.DB, order *models.DraftOrder) error {
+	*f.draft = *order
+	f.closed = order.ToOrderID != nil
+	return nil
+}
+
+type memoryPaymentInfoRepo struct {
+	repositories.PaymentInfoRepository
+	payments map[int64]*models.PaymentInfo
+}
+
+func (f *memoryPaymentInfoRepo) GetByID(ctx context.Context, paymentInfoID int64) (*models.PaymentInfo, error) {
+	found := *f.payments[paymentInfoID]
+	return &found, nil
+}
+
+func (f *memoryPaymentInfoRepo) CompareAndSetStatus(ctx context.Context, payment *models.PaymentInfo, from models.PaymentStatus) (bool, error) {
+	return f.CompareAndSetStatusTx(ctx, nil, payment, from)
+}
+
+func (f *memoryPaymentInfoRepo) CompareAndSetStatusTx(ctx context.Context, tx *gorm.DB, payment *models.PaymentInfo, from models.PaymentStatus) (bool, error) {
+	stored := f.payments[payment.ID]
+	if stored.Status != from {
+		return false, nil
+	}
+	stored.Status = payment.Status
+	stored.CancellationReason = payment.CancellationReason
+	stored.CancellationAt = payment.CancellationAt
+	return true, nil
+}
+
+func (f *memoryPaymentInfoRepo) CreateTx(ctx context.Context, tx *gorm.DB, payment *models.PaymentInfo) error {
+	f.payments[payment.ID] = payment
+	return nil
+}
+
+// fakeOrderRepo hands out stale when read without a lock and current when locked
+type fakeOrderRepo struct {
+	repositories.OrderRepository
+	created []*models.Order
+	stale   *models.Order
+	current *models.Order
+}
+
+func copyOrder(order *models.Order) *models.Order {
+	found := *order
+	found.OrderItems = append([]models.OrderItem(nil), order.OrderItems...)
+	return &found
+}
+
+func (f *fakeOrderRepo) GetById(ctx context.Context, orderID uint) (*models.Order, error) {
+	return copyOrder(f.stale), nil
+}
+
+func (f *fakeOrderRepo) GetByIdForUpdateTx(ctx context.Context, tx *gorm.DB, orderID uint) (*models.Order, error) {
+	return copyOrder(f.current), nil
+}
+
+func (f *fakeOrderRepo) UpdateTx(ctx context.Context, tx *gorm.DB, order *models.Order) error {
+	f.current = copyOrder(order)
+	return nil
+}
+
+func (f *fakeOrderRepo) CreateTx(ctx context.Context, tx *gorm.DB, order *models.Order) (*models.Order, error) {
+	order.ID = uint(100 + len(f.created))
+	f.created = append(f.created, order)
+	return order, nil
+}
+
+type fakeOrderItemRepo struct {
+	repositories.OrderItemRepository
+}
+
+func (f *fakeOrderItemRepo) SaveTx(ctx context.Context, tx *gorm.DB, orderItem *models.OrderItem) error {
+	return nil
+}
+
+func (f *fakeOrderItemRepo) UpdateStatusTx(ctx context.Context, tx *gorm.DB, orderItem *models.OrderItem) error {
+	return nil
+}
+
+type fakeStatusHistoryRepo struct {
+	repositories.OrderStatusHistoryRepository
+}
+
+func (f *fakeStatusHistoryRepo) CreateTx(ctx context.Context, tx *gorm.DB, history *models.OrderStatusHistory) error {
+	return nil
+}
+
+type fakeSaleLedger struct {
+	services.StockLedgerService
+	soldOrders []uint
+	restocked  []models.OrderItem
+	released   []models.OrderItem
+}
+
+func (f *fakeSaleLedger) ReleaseTx(ctx context.Context, tx *gorm.DB, draftOrderID uint, items []models.OrderItem) error {
+	f.released = append(f.released, items...)
+	return nil
+}
+
+func (f *fakeSaleLedger) RestockTx(ctx context.Context, tx *gorm.DB, order *models.Order) error {
+	f.restocked = append(f.restocked, outstandingItems(order.OrderItems)...)
+	return nil
+}
+
+func (f *fakeSaleLedger) SellTx(ctx context.Context, tx *gorm.DB, draftOrderID uint, orderID uint, items []models.OrderItem) error {
+	f.soldOrders = append(f.soldOrders, orderID)
+	return nil
+}
+
+type fakeRefundRequests struct {
+	services.RefundService
+	requested int
+}
+
+func (f *fakeRefundRequests) RequestRemainingTx(ctx context.Context, tx *gorm.DB, order *models.Order, includeShipping bool, reason string) (*models.Refund, error) {
+	f.requested++
+	return nil, nil
+}
+
+type fakePromotions struct {
+	services.PromotionService
+	released []uint
+}
+
+func (f *fakePromotions) Release(ctx context.Context, orderID uint) error {
+	f.released = append(f.released, orderID)
+	return nil
+}
+
+type fakeWishlist struct {
+	services.WishlistService
+}
+
+func (f *fakeWishlist) NotifyBackInStock(ctx context.Context, increases map[uint]uint) {}
+
+type testOrders struct {
+	service     *orderService
+	drafts      *fakeDraftOrderRepo
+	payments    *memoryPaymentInfoRepo
+	orders      *fakeOrderRepo
+	ledger      *fakeSaleLedger
+	refunds     *fakeRefundRequests
+	promotions  *fakePromotions
+	cache       *fakeProductVariantCache
+	bankPayment *models.PaymentInfo
+}
+
+// newTestOrderService has an open BANK draft waiting for its pending payment
+func newTestOrderService(t *testing.T) *testOrders {
+	bankPayment := &models.PaymentInfo{ID: 7, Total: 100, OrderID: 3, OrderType: models.OrderTypeDraftOrder, Status: models.PaymentPending}
+	drafts := &fakeDraftOrderRepo{draft: &models.DraftOrder{
+		ID:            3,
+		UserID:        1,
+		Status:        models.OrderStatePending,
+		PaymentMethod: models.PaymentMethodBank,
+		OrderItems:    []models.OrderItem{{ID: 1, ProductVariantID: 5, Quantity: 1, Price: 100, TotalPrice: 100}},
+		PaymentInfos:  []models.PaymentInfo{*bankPayment},
+	}}
+	payments := &memoryPaymentInfoRepo{payments: map[int64]*models.PaymentInfo{bankPayment.ID: bankPayment}}
+	orders := &fakeOrderRepo{}
+	ledger := &fakeSaleLedger{}
+	refunds := &fakeRefundRequests{}
+	promotions := &fakePromotions{}
+	variantCache := &fakeProductVariantCache{}
+	service := &orderService{
+		db:                  newTestDB(t),
+		draftOrderRepo:      drafts,
+		paymentInfoRepo:     payments,
+		orderRepo:           orders,
+		orderItemRepo:       &fakeOrderItemRepo{},
+		statusHistoryRepo:   &fakeStatusHistoryRepo{},
+		stockLedger:         ledger,
+		refundService:       refunds,
+		promotionService:    promotions,
+		productVariantCache: variantCache,
+		wishlistService:     &fakeWishlist{},
+	}
+	return &testOrders{service: service, drafts: drafts, payments: payments, orders: orders, ledger: ledger, refunds: refunds,
+		promotions: promotions, cache: variantCache, bankPayment: bankPayment}
+}
+
+func testGinContext() *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	return c
+}
+
+func TestPaymentSuccessConvertsDraftOnce(t *testing.T) {
+	o := newTestOrderService(t)
+
+	o.service.PaymentSuccess(context.Background(), o.bankPayment.ID)
+	o.service.PaymentSuccess(context.Background(), o.bankPayment.ID)
+
+	if len(o.orders.created) != 1 || len(o.ledger.soldOrders) != 1 {
+		t.Fatalf("orders %d, sales %d, want one of each", len(o.orders.created), len(o.ledger.soldOrders))
+	}
+	if o.payments.payments[o.bankPayment.ID].Status != models.PaymentSuccess {
+		t.Fatalf("payment status %s, want SUCCESS", o.payments.payments[o.bankPayment.ID].Status)
+	}
+	if o.drafts.draft.ToOrderID == nil || *o.drafts.draft.ToOrderID != o.orders.created[0].ID {
+		t.Fatal("draft must point to its order")
+	}
+}
+
+func TestPaymentSuccessLeavesClosedDraftAlone(t *testing.T) {
+	o := newTestOrderService(t)
+	// Expired or switched to COD while the payment was still pending
+	o.drafts.closed = true
+
+	o.service.PaymentSuccess(context.Background(), o.bankPayment.ID)
+
+	if len(o.orders.created) != 0 || len(o.ledger.soldOrders) != 0 {
+		t.Fatal("a closed draft must not be converted again")
+	}
+	if len(o.drafts.locked) != 1 {
+		t.Fatalf("draft locked %d times, want it locked before the payment is claimed", len(o.drafts.locked))
+	}
+}
+
+func TestChangeToCODCancelsBankPayment(t *testing.T) {
+	o := newTestOrderService(t)
+	draft, _ := o.drafts.GetById(context.Background(), 3)
+
+	order, err := o.service.ChangeToCODPaymentFromDraft(testGinContext(), draft, *o.bankPayment)
+	if err != nil {
+		t.Fatalf("ChangeToCODPaymentFromDraft: %v", err)
+	}
+	previous := o.payments.payments[o.bankPayment.ID]
+	if previous.Status != models.PaymentCanceled || previous.CancellationAt == nil {
+		t.Fatalf("bank payment %+v, want it cancelled with the switch", previous)
+	}
+	if order.PaymentMethod != models.PaymentMethodCOD || len(o.ledger.soldOrders) != 1 {
+		t.Fatal("draft must become a COD order with its sale recorded")
+	}
+
+	// A late webhook for the bank payment finds it cancelled
+	o.service.PaymentSuccess(context.Background(), o.bankPayment.ID)
+	if len(o.orders.created) != 1 || len(o.ledger.soldOrders) != 1 {
+		t.Fatal("a late bank payment must not create a second order")
+	}
+}
+
+func TestChangeToCODRefusesPaidDraft(t *testing.T) {
+	o := newTestOrderService(t)
+	draft, _ := o.drafts.GetById(context.Background(), 3)
+	o.payments.payments[o.bankPayment.ID].Status = models.PaymentSuccess
+
+	_, err := o.service.ChangeToCODPaymentFromDraft(testGinContext(), draft, *o.bankPayment)
+	if got := errorStatus(err); got != http.StatusConflict {
+		t.Fatalf("err = %v, want a conflict", err)
+	}
+	if len(o.orders.created) != 0 {
+		t.Fatal("no order must be created for a paid draft")
+	}
+}
+
+func TestChangeToCODRefusesClosedDraft(t *testing.T) {
+	o := newTestOrderService(t)
+	draft, _ := o.drafts.GetById(context.Background(), 3)
+	o.drafts.closed = true
+
+	if _, err := o.service.ChangeToCODPaymentFromDraft(testGinContext(), draft, *o.bankPayment); err == nil {
+		t.Fatal("a closed draft must not be switched to COD")
+	}
+	if len(o.orders.created) != 0 || o.payments.payments[o.bankPayment.ID].Status != models.PaymentPending {
+		t.Fatal("nothing must change for a closed draft")
+	}
+}
+
+func TestMapOrderItemsToPaymentItemsSendsNetAmounts(t *testing.T) {
+	product := models.Product{Name: "Shirt"}
+	items := []models.OrderItem{
+		{ProductVariantID: 1, Variant: models.ProductVariant{Product: product}, Quantity: 2, Price: 100, TotalPrice: 200},
+		// 3 x 100 with 50 off does not split evenly per unit
+		{ProductVariantID: 2, Variant: models.ProductVariant{Product: product}, Quantity: 3, Price: 100, Discount: 50, TotalPrice: 250},
+	}
+	paymentInfo := &models.PaymentInfo{ShippingFee: 30, ShippingDiscount: 10}
+
+	got := MapOrderItemsToPaymentItems(items, paymentInfo)
+	want := []payment.Item{
+		{Name: "Shirt (Variant #1)", Price: 100, Quantity: 2},
+		{Name: "Shirt (Variant #2) x3", Price: 250, Quantity: 1},
+		{Name: "Shipping Fee", Price: 20, Quantity: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MapOrderItemsToPaymentItems = %+v, want %+v", got, want)
+	}
+}
+
+func TestMapOrderItemsToPaymentItemsSkipsFreeShipping(t *testing.T) {
+	items := []models.OrderItem{{ProductVariantID: 1, Quantity: 1, Price: 100, TotalPrice: 100}}
+	paymentInfo := &models.PaymentInfo{ShippingFee: 30, ShippingDiscount: 30}
+
+	got := MapOrderItemsToPaymentItems(items, paymentInfo)
+	if len(got) != 1 || got[0].Name == "Shipping Fee" {
+		t.Fatalf("MapOrderItemsToPaymentItems = %+v, want no shipping line", got)
+	}
+}
+
+// withOrder makes unlocked reads see stale while the locked row already has current
+func (o *testOrders) withOrder(stale, current *models.Order) {
+	o.orders.stale, o.orders.current = stale, current
+}
+
+func TestUpdateOrderStatusCancelsWhatIsLeftOnLockedRow(t *testing.T) {
+	o := newTestOrderService(t)
+	item := models.OrderItem{ID: 1, ProductVariantID: 5, Quantity: 3}
+	cancelledItem := item
+	// One unit was cancelled by an item cancel that committed after the unlocked read
+	cancelledItem.CancelledQuantity = 1
+	o.withOrder(
+		&models.Order{ID: 9, Status: models.OrderStatePending, OrderItems: []models.OrderItem{item}},
+		&models.Order{ID: 9, Status: models.OrderStatePending, OrderItems: []models.OrderItem{cancelledItem}})
+
+	order, err := o.service.UpdateOrderStatus(context.Background(), 9, utils.EventCancel, models.SystemActor, "")
+	if err != nil {
+		t.Fatalf("UpdateOrderStatus: %v", err)
+	}
+	if len(o.ledger.restocked) != 1 || o.ledger.restocked[0].Quantity != 2 {
+		t.Fatalf("restocked %+v, want the 2 units left", o.ledger.restocked)
+	}
+	if order.Status != models.OrderStateCancelled || o.orders.current.OrderItems[0].CancelledQuantity != 3 {
+		t.Fatalf("order %+v, want every unit cancelled", order)
+	}
+}
+
+func TestUpdateOrderStatusChecksTransitionOnLockedRow(t *testing.T) {
+	o := newTestOrderService(t)
+	item := models.OrderItem{ID: 1, ProductVariantID: 5, Quantity: 3}
+	o.withOrder(
+		&models.Order{ID: 9, Status: models.OrderStatePending, OrderItems: []models.OrderItem{item}},
+		&models.Order{ID: 9, Status: models.OrderStateCancelled, OrderItems: []models.OrderItem{item}})
+
+	if _, err := o.service.UpdateOrderStatus(context.Background(), 9, utils.EventCancel, models.SystemActor, ""); err == nil {
+		t.Fatal("an order cancelled meanwhile must not be cancelled again")
+	}
+	if len(o.ledger.restocked) != 0 || o.refunds.requested != 0 {
+		t.Fatal("nothing must be restocked or refunded twice")
+	}
+}
+
+func TestUpdateOrderStatusDoneChecksReturnsOnLockedRow(t *testing.T) {
+	o := newTestOrderService(t)
+	item := models.OrderItem{ID: 1, ProductVariantID: 5, Quantity: 3}
+	returning := item
+	returning.ReturnRequestedQuantity = 1
+	o.withOrder(
+		&models.Order{ID: 9, Status: models.OrderStateDelivered, OrderItems: []models.OrderItem{item}},
+		&models.Order{ID: 9, Status: models.OrderStateDelivered, OrderItems: []models.OrderItem{returning}})
+
+	if _, err := o.service.UpdateOrderStatus(context.Background(), 9, utils.EventDone, models.SystemActor, ""); err == nil {
+		t.Fatal("an order with a return requested meanwhile must not be done")
+	}
+	if o.orders.current.Status != models.OrderStateDelivered {
+		t.Fatalf("status %s, want it unchanged", o.orders.current.Status)
+	}
+}
+
+func TestPaymentCancelledReleasesDraftOnce(t *testing.T) {
+	o := newTestOrderService(t)
+
+	o.service.PaymentCancelled(context.Background(), o.bankPayment.ID, string(models.PaymentCanceled), "user cancelled")
+
+	if o.payments.payments[o.bankPayment.ID].Status != models.PaymentCanceled || !o.drafts.closed {
+		t.Fatal("payment and draft must be closed")
+	}
+	if len(o.ledger.released) != 1 || len(o.cache.incremented) != 1 || len(o.promotions.released) != 1 {
+		t.Fatalf("released %d, cached %d, promotions %d, want each once",
+			len(o.ledger.released), len(o.cache.incremented), len(o.promotions.released))
+	}
+}
+
+func TestPaymentCancelledSkipsDraftClosedMeanwhile(t *testing.T) {
+	o := newTestOrderService(t)
+	// The expiry worker closed the draft after the payment was cancelled
+	o.drafts.closed = true
+
+	o.service.PaymentCancelled(context.Background(), o.bankPayment.ID, string(models.PaymentCanceled), "user cancelled")
+
+	if len(o.ledger.released) != 0 || len(o.cache.incremented) != 0 || len(o.promotions.released) != 0 {
+		t.Fatal("a draft closed by another caller must not give its stock back again")
+	}
+}
+
+func TestAbandonDraftReleasesOnce(t *testing.T) {
+	o := newTestOrderService(t)
+	draft, _ := o.drafts.GetById(context.Background(), 3)
+
+	if !o.service.abandonDraft(context.Background(), draft, draft.OrderItems) {
+		t.Fatal("an open draft must be abandoned")
+	}
+	if o.service.abandonDraft(context.Background(), draft, draft.OrderItems) {
+		t.Fatal("a closed draft must not be abandoned twice")
+	}
+	if len(o.ledger.released) != 1 || len(o.cache.incremented) != 1 {
+		t.Fatalf("released %d, cached %d, want each once", len(o.ledger.released), len(o.cache.incremented))
+	}
+}
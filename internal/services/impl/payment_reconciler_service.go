@@ -0,0 +1,120 @@
+package impl
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/payment"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"log"
+	"time"
+)
+
+const (
+	paymentCheckWorkers  = 4
+	paymentCheckInterval = 15 * time.Second
+	// Webhook is expected first, the worker only polls payments the webhook did not finalize
+	paymentFirstCheckDelay = 2 * time.Minute
+	paymentCheckLease      = 2 * time.Minute
+	paymentCheckMaxDelay   = 15 * time.Minute
+)
+
+type paymentReconciler struct {
+	orderService    services.OrderService
+	paymentInfoRepo repositories.PaymentInfoRepository
+	paymentProvider payment.PaymentProvider
+}
+
+func NewPaymentReconciler(orderService services.OrderService, paymentInfoRepo repositories.PaymentInfoRepository, paymentProvider payment.PaymentProvider) services.PaymentReconciler {
+	return &paymentReconciler{
+		orderService:    orderService,
+		paymentInfoRepo: paymentInfoRepo,
+		paymentProvider: paymentProvider,
+	}
+}
+
+// Start polls past due pending payments with a fixed number of workers until ctx is cancelled
+func (p *paymentReconciler) Start(ctx context.Context) {
+	jobs := make(chan models.PaymentInfo, paymentCheckWorkers)
+
+	for i := 0; i < paymentCheckWorkers; i++ {
+		go func() {
+			for paymentInfo := range jobs {
+				if err := p.check(ctx, paymentInfo); err != nil {
+					log.Printf("Error reconciling payment %d: %v", paymentInfo.ID, err)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		ticker := time.NewTicker(paymentCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			paymentInfos, err := p.paymentInfoRepo.ClaimDueForCheck(ctx, cap(jobs)-len(jobs), paymentCheckLease, time.Now().Add(-paymentFirstCheckDelay))
+			if err != nil {
+				log.Printf("Error loading payments to reconcile: %v", err)
+			}
+			for _, paymentInfo := range paymentInfos {
+				jobs <- paymentInfo
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Reconcile asks the payment provider for the payment status and finalizes the payment if it is no longer pending
+func (p *paymentReconciler) Reconcile(ctx context.Context, paymentInfoID int64) error {
+	paymentInfo, err := p.paymentInfoRepo.GetByID(ctx, paymentInfoID)
+	if err != nil {
+		return err
+	}
+	return p.check(ctx, *paymentInfo)
+}
+
+func (p *paymentReconciler) check(ctx context.Context, paymentInfo models.PaymentInfo) error {
+	if paymentInfo.Status != models.PaymentPending {
+		return nil
+	}
+
+	status, err := p.paymentProvider.GetStatus(ctx, paymentInfo.ID)
+	if err != nil {
+		p.scheduleNext(ctx, paymentInfo)
+		return err
+	}
+
+	switch status.Status {
+	case payment.LinkPaid:
+		p.orderService.PaymentSuccess(ctx, paymentInfo.ID)
+	case payment.LinkCancelled, payment.LinkExpired:
+		reason := "Cancelled/Expired via " + p.paymentProvider.Name()
+		if status.CancellationReason != "" {
+			reason = status.CancellationReason
+		}
+		p.orderService.PaymentCancelled(ctx, paymentInfo.ID, string(status.Status), reason)
+	default:
+		p.scheduleNext(ctx, paymentInfo)
+	}
+	return nil
+}
+
+// scheduleNext doubles the delay after every check that found the payment still pending
+func (p *paymentReconciler) scheduleNext(ctx context.Context, paymentInfo models.PaymentInfo) {
+	delay := paymentFirstCheckDelay
+	for i := 1; i < paymentInfo.CheckAttempts && delay < paymentCheckMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > paymentCheckMaxDelay {
+		delay = paymentCheckMaxDelay
+	}
+	if err := p.paymentInfoRepo.ScheduleCheck(ctx, paymentInfo.ID, time.Now().Add(delay)); err != nil {
+		log.Printf("Error scheduling next check of payment %d: %v", paymentInfo.ID, err)
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/minh6824pro/nxrGO/internal/cache"
 	"github.com/minh6824pro/nxrGO/internal/dto"
 	"github.com/minh6824pro/nxrGO/internal/elastic"
-	"github.com/minh6824pro/nxrGO/internal/elastic/document"
+	"github.com/minh6824pro/nxrGO/internal/event"
 	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/models/CacheModel"
 	repositories "github.com/minh6824pro/nxrGO/internal/repositories"
@@ -25,7 +25,7 @@ import (
 func NewProductService(db *gorm.DB, productRepo repositories.ProductRepository, brandRepo repositories.BrandRepository, merchanRepo repositories.MerchantRepository,
 	categoryRepo repositories.CategoryRepository, productVariantRepo repositories.ProductVariantRepository, variantOptionValueRepo repositories.VariantOptionValueRepository,
 	variantOptionRepo repositories.VariantOptionRepository, productCache cache.ProductCacheService,
-	productVariantService services.ProductVariantService, elastic elastic.ProductElasticRepository) services.ProductService {
+	productVariantService services.ProductVariantService, elastic elastic.ProductElasticRepository, eventBus event.EventPublisher) services.ProductService {
 	return &productService{
 		db:                     db,
 		productRepo:            productRepo,
@@ -38,6 +38,7 @@ func NewProductService(db *gorm.DB, productRepo repositories.ProductRepository,
 		productCacheService:    productCache,
 		productVariantService:  productVariantService,
 		elasticProductRepo:     elastic,
+		eventBus:               eventBus,
 	}
 }
 
@@ -53,6 +54,7 @@ type productService struct {
 	productVariantService  services.ProductVariantService
 	productCacheService    cache.ProductCacheService
 	elasticProductRepo     elastic.ProductElasticRepository
+	eventBus               event.EventPublisher
 }
 
 //	func (productService *productService) Create(ctx context.Context, input dto.CreateProductInput) (*models.Product, error) {
@@ -218,6 +220,12 @@ func (productService *productService) Create(ctx context.Context, input dto.Crea
 		}
 	}
 
+	// Index sản phẩm mới cùng transaction
+	if err := productService.eventBus.PublishCatalogChangedTx(tx, event.NewCatalogChangedEvent(event.CatalogProduct, createdProduct.ID, false)); err != nil {
+		tx.Rollback()
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Error recording catalog change", http.StatusInternalServerError, err)
+	}
+
 	// ✅ Commit transaction nếu mọi thứ đều OK
 	if err := tx.Commit().Error; err != nil {
 		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Failed to commit transaction", http.StatusInternalServerError, err)
@@ -259,8 +267,12 @@ func (productService *productService) Delete(ctx context.Context, id uint) error
 		return err
 	}
 
-	return productService.productRepo.Delete(ctx, id)
-
+	return productService.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := productService.productRepo.DeleteTx(ctx, tx, id); err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, productService.eventBus, event.CatalogProduct, id, true)
+	})
 }
 
 func (productService *productService) Patch(ctx context.Context, product *models.Product) error {
@@ -323,22 +335,26 @@ func (productService *productService) getOrCreateMerchant(ctx context.Context, t
 	return merchant.ID, nil
 }
 
-func (productService *productService) GetProductList(ctx context.Context, name string, priceMin, priceMax *float64,
-	priceAsc *bool, totalBuyDesc *bool, page, pageSize int, lat, lon *float64) ([]*CacheModel.ProductMiniCache, int, error) {
+func (productService *productService) GetProductList(ctx context.Context, q dto.ProductSearchQuery) (*dto.ProductSearchResponse, error) {
 	var ListProductCache []*CacheModel.ProductMiniCache
 
 	//// Elastic
-	listProductElastic, totalPages, _, err := productService.elasticProductRepo.GetProductList(ctx, name, priceMin, priceMax, priceAsc, totalBuyDesc, page, pageSize, lat, lon)
+	result, err := productService.elasticProductRepo.GetProductList(ctx, q)
 	if err != nil {
 		log.Println("Elastic failed, fallback DB, ", err)
 	} else {
 		log.Println("Elastic success")
-		return MapElasticDocsToProductMiniCache(listProductElastic), totalPages, nil
-	}
-	// GetDB
-	listProductFilter, total, err := productService.productRepo.GetProductListFilterOptimized(ctx, priceMin, priceMax, priceAsc, totalBuyDesc, page, pageSize)
+		return &dto.ProductSearchResponse{
+			Data:       MapElasticHitsToProductSearchItems(result.Hits),
+			Total:      result.TotalPages,
+			Facets:     result.Facets,
+			DidYouMean: result.Suggestion,
+		}, nil
+	}
+	// GetDB, chỉ lọc theo giá
+	listProductFilter, total, err := productService.productRepo.GetProductListFilterOptimized(ctx, q.PriceMin, q.PriceMax, q.PriceAsc, q.TotalBuyDesc, q.Page, q.PageSize)
 	if err != nil {
-		return nil, 0, customErr.NewError(customErr.UNEXPECTED_ERROR, "Failed to get product list", http.StatusInternalServerError, err)
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Failed to get product list", http.StatusInternalServerError, err)
 	}
 	err = productService.productCacheService.PingRedis(ctx)
 	if err != nil {
@@ -346,34 +362,58 @@ func (productService *productService) GetProductList(ctx context.Context, name s
 	} else {
 		ListProductCache, err = productService.GetProductCacheInfo(ctx, listProductFilter)
 	}
-	return ListProductCache, total, nil
+	items := make([]*dto.ProductSearchItem, 0, len(ListProductCache))
+	for _, product := range ListProductCache {
+		items = append(items, &dto.ProductSearchItem{ProductMiniCache: *product})
+	}
+	return &dto.ProductSearchResponse{Data: items, Total: total}, nil
+}
+
+func (productService *productService) Autocomplete(ctx context.Context, prefix string, size int) (*dto.AutocompleteResponse, error) {
+	result, err := productService.elasticProductRepo.Autocomplete(ctx, prefix, size)
+	if err != nil {
+		return nil, customErr.NewError(customErr.UNEXPECTED_ERROR, "Failed to get suggestions", http.StatusInternalServerError, err)
+	}
+	return result, nil
 }
 
-func MapElasticDocsToProductMiniCache(productElastic []document.ProductDocument) []*CacheModel.ProductMiniCache {
-	var productCaches []*CacheModel.ProductMiniCache
-	for _, product := range productElastic {
+func MapElasticHitsToProductSearchItems(hits []elastic.ProductHit) []*dto.ProductSearchItem {
+	items := make([]*dto.ProductSearchItem, 0, len(hits))
+	for _, hit := range hits {
+		product := hit.Document
 		pID := uint(0)
 		if product.ID != "" {
 			if tmp, err := strconv.ParseUint(product.ID, 10, 32); err == nil {
 				pID = uint(tmp)
 			}
 		}
-		product := &CacheModel.ProductMiniCache{
-			ID:            pID,
-			Name:          product.Name,
-			AverageRating: product.AverageRating,
-			NumberRating:  product.NumberRating,
-			Image:         product.Image,
-			TotalBuy:      product.TotalBuy,
-			Price:         product.Price[0],
-			Location:      product.Location,
-			Merchant:      product.Merchant,
-			Brand:         product.Brand,
-			Category:      product.Category,
+		item := &dto.ProductSearchItem{
+			ProductMiniCache: CacheModel.ProductMiniCache{
+				ID:            pID,
+				Name:          product.Name,
+				AverageRating: product.AverageRating,
+				NumberRating:  product.NumberRating,
+				Image:         product.Image,
+				TotalBuy:      product.TotalBuy,
+				Location:      product.Location,
+				Merchant:      product.Merchant,
+				Brand:         product.Brand,
+				Category:      product.Category,
+			},
+			Score:      hit.Score,
+			DistanceKm: hit.DistanceKm,
+			Highlights: hit.Highlights,
+		}
+		// Giá lấy theo variant khớp nhất, document cũ chưa có variants thì lấy giá đầu tiên
+		if hit.Variant != nil {
+			item.VariantId = hit.Variant.ID
+			item.Price = hit.Variant.Price
+		} else if len(product.Price) > 0 {
+			item.Price = product.Price[0]
 		}
-		productCaches = append(productCaches, product)
+		items = append(items, item)
 	}
-	return productCaches
+	return items
 }
 
 func (productService *productService) GetProductListManagement(ctx context.Context, priceMin, priceMax *float64,
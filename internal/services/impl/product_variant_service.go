@@ -23,19 +23,23 @@ import (
 )
 
 type productVariantService struct {
+	db                  *gorm.DB
 	productRepo         repositories.ProductRepository
 	productVariantRepo  repositories.ProductVariantRepository
 	productVariantCache cache.ProductVariantRedis
-	updateStockAgg      *event.UpdateStockAggregator
+	wishlistService     services.WishlistService
+	eventBus            event.EventPublisher
 }
 
-func NewProductVariantService(productRepo repositories.ProductRepository, productVariantRepo repositories.ProductVariantRepository,
-	productVariantCache cache.ProductVariantRedis, updateStockAgg *event.UpdateStockAggregator) services.ProductVariantService {
+func NewProductVariantService(db *gorm.DB, productRepo repositories.ProductRepository, productVariantRepo repositories.ProductVariantRepository,
+	productVariantCache cache.ProductVariantRedis, wishlistService services.WishlistService, eventBus event.EventPublisher) services.ProductVariantService {
 	return &productVariantService{
+		db:                  db,
 		productRepo:         productRepo,
 		productVariantRepo:  productVariantRepo,
 		productVariantCache: productVariantCache,
-		updateStockAgg:      updateStockAgg,
+		wishlistService:     wishlistService,
+		eventBus:            eventBus,
 	}
 }
 
@@ -66,7 +70,14 @@ func (p productVariantService) Create(ctx context.Context, input dto.CreateProdu
 		OptionValues: optionValues,
 	}
 
-	createdProductVariant, err := p.productVariantRepo.Create(ctx, productVariant)
+	var createdProductVariant *models.ProductVariant
+	err = p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		createdProductVariant, err = p.productVariantRepo.CreateWithTx(ctx, tx, productVariant)
+		if err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, p.eventBus, event.CatalogProduct, product.ID, false)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +114,7 @@ func (p productVariantService) IncreaseStock(c *gin.Context, id uint, input dto.
 		return nil, err
 	}
 	if input.Quantity > 0 {
-		err := p.productVariantRepo.IncreaseQuantity(c, map[uint]uint{
+		err := p.adjustStock(c, p.productVariantRepo.IncreaseQuantityTx, map[uint]uint{
 			id: input.Quantity,
 		})
 		if err != nil {
@@ -115,6 +126,7 @@ func (p productVariantService) IncreaseStock(c *gin.Context, id uint, input dto.
 		if err != nil {
 			log.Println("Cache 1", err)
 		}
+		p.wishlistService.NotifyBackInStock(c.Request.Context(), map[uint]uint{id: input.Quantity})
 	} else {
 		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error 3", http.StatusInternalServerError, nil)
 	}
@@ -122,6 +134,28 @@ func (p productVariantService) IncreaseStock(c *gin.Context, id uint, input dto.
 	return pv, nil
 }
 
+func (p productVariantService) UpdatePrice(ctx context.Context, id uint, input dto.UpdatePriceRequest) (*models.ProductVariant, error) {
+	pv, err := p.productVariantRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := p.productVariantRepo.UpdatePriceTx(ctx, tx, id, input.Price); err != nil {
+			return err
+		}
+		return publishCatalogChangedTx(tx, p.eventBus, event.CatalogProduct, pv.ProductID, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	pv.Price = input.Price
+	// Remove cache
+	if err := p.productVariantCache.DeleteProductVariantHash(id); err != nil {
+		log.Println("Cache price", err)
+	}
+	return pv, nil
+}
+
 func (p productVariantService) DecreaseStock(c *gin.Context, id uint, input dto.UpdateStockRequest) (*models.ProductVariant, error) {
 
 	if input.Quantity == 0 {
@@ -134,7 +168,19 @@ func (p productVariantService) DecreaseStock(c *gin.Context, id uint, input dto.
 	err = p.productVariantCache.PingRedis(c)
 	if err != nil {
 		// Redis die -> db
-		pv, err = p.productVariantRepo.CheckAndDecreaseStock(c, id, input.Quantity)
+		err = p.db.WithContext(c).Transaction(func(tx *gorm.DB) error {
+			var err error
+			if pv, err = p.productVariantRepo.CheckAndDecreaseStockTx(c, tx, id, input.Quantity); err != nil {
+				return err
+			}
+			// in_stock của product trên search index
+			return publishStockCrossingsTx(tx, p.eventBus, []models.StockLevelChange{{
+				ProductVariantID: pv.ID,
+				ProductID:        pv.ProductID,
+				Before:           int(pv.Quantity + input.Quantity),
+				After:            int(pv.Quantity),
+			}})
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -171,7 +217,7 @@ func (p productVariantService) DecreaseStock(c *gin.Context, id uint, input dto.
 			return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected Error 2", http.StatusInternalServerError, err)
 		}
 		if input.Quantity <= uint(quantityInt) {
-			err := p.productVariantRepo.DecreaseQuantity(c, map[uint]uint{
+			err := p.adjustStock(c, p.productVariantRepo.DecreaseQuantityTx, map[uint]uint{
 				id: input.Quantity,
 			})
 			if err != nil {
@@ -192,6 +238,18 @@ func (p productVariantService) DecreaseStock(c *gin.Context, id uint, input dto.
 
 }
 
+// adjustStock writes a manual adjustment and re-indexes products that sold out or came back in stock in one transaction
+func (p productVariantService) adjustStock(ctx context.Context, adjust func(ctx context.Context, tx *gorm.DB, quantityMap map[uint]uint) ([]models.StockLevelChange, error), quantityMap map[uint]uint) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		changes, err := adjust(ctx, tx, quantityMap)
+		if err != nil {
+			return err
+		}
+		// in_stock của product trên search index
+		return publishStockCrossingsTx(tx, p.eventBus, changes)
+	})
+}
+
 //	func (p productVariantService) loadAndCacheProductVariants(ctx context.Context, ids []uint) ([]models.ProductVariant, error) {
 //		// Get List from Db
 //		variants, err := p.productVariantRepo.GetByIDSForRedisCache(ctx, ids)
@@ -0,0 +1,384 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type promotionService struct {
+	repo               repositories.PromotionRepository
+	productVariantRepo repositories.ProductVariantRepository
+}
+
+func NewPromotionService(repo repositories.PromotionRepository, productVariantRepo repositories.ProductVariantRepository) services.PromotionService {
+	return &promotionService{
+		repo:               repo,
+		productVariantRepo: productVariantRepo,
+	}
+}
+
+// Create saves the promotion, promotions created by a merchant only apply to its own products
+func (p *promotionService) Create(ctx context.Context, actor models.Actor, input dto.CreatePromotionInput) (*models.Promotion, error) {
+	promotion := &models.Promotion{
+		Code:          normalizeCode(input.Code),
+		Name:          input.Name,
+		Description:   input.Description,
+		Type:          input.Type,
+		Value:         input.Value,
+		MaxDiscount:   input.MaxDiscount,
+		MinOrderValue: input.MinOrderValue,
+		MerchantID:    input.MerchantID,
+		CategoryID:    input.CategoryID,
+		BrandID:       input.BrandID,
+		UsageLimit:    input.UsageLimit,
+		PerUserLimit:  input.PerUserLimit,
+		StartsAt:      input.StartsAt,
+		EndsAt:        input.EndsAt,
+		Active:        true,
+		CreatedBy:     actor.UserID,
+	}
+	if input.Active != nil {
+		promotion.Active = *input.Active
+	}
+	if actor.Role == models.RoleMerchant {
+		merchantID := actor.MerchantID
+		promotion.MerchantID = &merchantID
+	}
+	if err := validatePromotion(promotion); err != nil {
+		return nil, err
+	}
+	if err := p.repo.Create(ctx, promotion); err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (p *promotionService) Update(ctx context.Context, actor models.Actor, id uint, input dto.UpdatePromotionInput) (*models.Promotion, error) {
+	promotion, err := p.GetByID(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		promotion.Name = *input.Name
+	}
+	if input.Description != nil {
+		promotion.Description = *input.Description
+	}
+	if input.Value != nil {
+		promotion.Value = *input.Value
+	}
+	if input.MaxDiscount != nil {
+		promotion.MaxDiscount = *input.MaxDiscount
+	}
+	if input.MinOrderValue != nil {
+		promotion.MinOrderValue = *input.MinOrderValue
+	}
+	if input.UsageLimit != nil {
+		promotion.UsageLimit = *input.UsageLimit
+	}
+	if input.PerUserLimit != nil {
+		promotion.PerUserLimit = *input.PerUserLimit
+	}
+	if input.StartsAt != nil {
+		promotion.StartsAt = input.StartsAt
+	}
+	if input.EndsAt != nil {
+		promotion.EndsAt = input.EndsAt
+	}
+	if input.Active != nil {
+		promotion.Active = *input.Active
+	}
+	if err := validatePromotion(promotion); err != nil {
+		return nil, err
+	}
+	if err := p.repo.Save(ctx, promotion); err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (p *promotionService) Delete(ctx context.Context, actor models.Actor, id uint) error {
+	promotion, err := p.GetByID(ctx, actor, id)
+	if err != nil {
+		return err
+	}
+	return p.repo.Delete(ctx, promotion)
+}
+
+// GetByID returns the promotion, merchants only see their own promotions
+func (p *promotionService) GetByID(ctx context.Context, actor models.Actor, id uint) (*models.Promotion, error) {
+	promotion, err := p.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if actor.Role == models.RoleMerchant && (promotion.MerchantID == nil || *promotion.MerchantID != actor.MerchantID) {
+		return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Promotion not found", http.StatusNotFound, nil)
+	}
+	return promotion, nil
+}
+
+func (p *promotionService) List(ctx context.Context, actor models.Actor, page, pageSize int) ([]*models.Promotion, int, error) {
+	if actor.Role == models.RoleMerchant {
+		merchantID := actor.MerchantID
+		return p.repo.List(ctx, &merchantID, page, pageSize)
+	}
+	return p.repo.List(ctx, nil, page, pageSize)
+}
+
+// Quote evaluates the coupon against the current price of the items
+func (p *promotionService) Quote(ctx context.Context, userID uint, input dto.QuotePromotionInput) (*dto.PromotionQuote, error) {
+	ids := make([]uint, 0, len(input.Items))
+	for _, item := range input.Items {
+		ids = append(ids, item.ProductVariantID)
+	}
+	variants, err := p.productVariantRepo.ListByIds(ctx, dto.ListProductVariantIds{Ids: ids})
+	if err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	byID := make(map[uint]models.ProductVariant)
+	for _, variant := range variants {
+		byID[variant.ID] = variant
+	}
+
+	lines := make([]dto.PromotionLine, 0, len(input.Items))
+	for _, item := range input.Items {
+		variant, ok := byID[item.ProductVariantID]
+		if !ok {
+			return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, fmt.Sprintf("Product variant %d not found", item.ProductVariantID), http.StatusNotFound, nil)
+		}
+		lines = append(lines, dto.PromotionLine{
+			ProductVariantID: variant.ID,
+			MerchantID:       variant.Product.MerchantID,
+			Price:            variant.Price,
+			Quantity:         item.Quantity,
+		})
+	}
+	shippingFees := make(map[uint]float64)
+	for _, fee := range input.ShippingFees {
+		shippingFees[fee.MerchantID] += fee.Fee
+	}
+	return p.Evaluate(ctx, userID, input.Code, lines, shippingFees)
+}
+
+// Evaluate computes the discount of the coupon and splits it across the lines and merchants.
+// Amounts are whole VND, the remainder of a split goes to the last share.
+func (p *promotionService) Evaluate(ctx context.Context, userID uint, code string, lines []dto.PromotionLine, shippingFees map[uint]float64) (*dto.PromotionQuote, error) {
+	promotion, err := p.repo.GetByCode(ctx, normalizeCode(code))
+	if err != nil {
+		return nil, err
+	}
+	if !promotion.Active || !promotion.InWindow(time.Now()) {
+		return nil, customErr.NewError(customErr.INVALID_PROMOTION, "Promotion is not available", http.StatusBadRequest, nil)
+	}
+
+	total, byUser, err := p.repo.CountUsages(ctx, promotion.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if promotion.UsageLimit > 0 && total >= int64(promotion.UsageLimit) {
+		return nil, customErr.NewError(customErr.INVALID_PROMOTION, "Promotion usage limit reached", http.StatusBadRequest, nil)
+	}
+	if promotion.PerUserLimit > 0 && byUser >= int64(promotion.PerUserLimit) {
+		return nil, customErr.NewError(customErr.INVALID_PROMOTION, "You have already used this promotion", http.StatusBadRequest, nil)
+	}
+
+	eligible, err := p.eligibleLines(ctx, promotion, lines)
+	if err != nil {
+		return nil, err
+	}
+	weights := make([]float64, len(lines))
+	var subtotal float64
+	for i, line := range lines {
+		if eligible[i] {
+			weights[i] = line.Price * float64(line.Quantity)
+			subtotal += weights[i]
+		}
+	}
+	if subtotal == 0 {
+		return nil, customErr.NewError(customErr.INVALID_PROMOTION, "Promotion does not apply to these items", http.StatusBadRequest, nil)
+	}
+	if subtotal < promotion.MinOrderValue {
+		return nil, customErr.NewError(customErr.INVALID_PROMOTION,
+			fmt.Sprintf("Promotion needs a minimum order value of %.0f", promotion.MinOrderValue), http.StatusBadRequest, nil)
+	}
+
+	quote := &dto.PromotionQuote{
+		PromotionID: promotion.ID,
+		Code:        promotion.Code,
+		Type:        promotion.Type,
+		UserID:      userID,
+	}
+	merchants := make(map[uint]*dto.PromotionMerchantDiscount)
+	var merchantIDs []uint
+	for i, line := range lines {
+		if _, ok := merchants[line.MerchantID]; !ok && eligible[i] {
+			merchants[line.MerchantID] = &dto.PromotionMerchantDiscount{MerchantID: line.MerchantID}
+			merchantIDs = append(merchantIDs, line.MerchantID)
+		}
+	}
+
+	switch promotion.Type {
+	case models.PromotionPercentage:
+		quote.Discount = capDiscount(math.Floor(subtotal*promotion.Value/100), promotion.MaxDiscount)
+	case models.PromotionFixed:
+		quote.Discount = math.Min(math.Floor(promotion.Value), subtotal)
+	case models.PromotionFreeShipping:
+		fees := make([]float64, len(merchantIDs))
+		var feeTotal float64
+		for i, merchantID := range merchantIDs {
+			fees[i] = shippingFees[merchantID]
+			feeTotal += fees[i]
+		}
+		quote.ShippingDiscount = capDiscount(feeTotal, promotion.MaxDiscount)
+		for i, share := range allocateDiscount(quote.ShippingDiscount, fees) {
+			merchants[merchantIDs[i]].ShippingDiscount = share
+		}
+	}
+
+	shares := allocateDiscount(quote.Discount, weights)
+	for i, line := range lines {
+		quote.Items = append(quote.Items, dto.PromotionItemDiscount{ProductVariantID: line.ProductVariantID, Discount: shares[i]})
+		if shares[i] > 0 {
+			merchants[line.MerchantID].Discount += shares[i]
+		}
+	}
+	for _, merchantID := range merchantIDs {
+		quote.Merchants = append(quote.Merchants, *merchants[merchantID])
+	}
+	return quote, nil
+}
+
+// Redeem records the quote against the draft order, limits are checked again under lock
+func (p *promotionService) Redeem(ctx context.Context, orderID uint, quote *dto.PromotionQuote) error {
+	if quote == nil {
+		return nil
+	}
+	usages := make([]models.PromotionUsage, 0, len(quote.Merchants))
+	for _, m := range quote.Merchants {
+		usages = append(usages, models.PromotionUsage{
+			PromotionID:      quote.PromotionID,
+			UserID:           quote.UserID,
+			OrderID:          orderID,
+			MerchantID:       m.MerchantID,
+			Discount:         m.Discount,
+			ShippingDiscount: m.ShippingDiscount,
+		})
+	}
+	return p.repo.Redeem(ctx, quote.PromotionID, usages)
+}
+
+// Release gives the usage back when the draft order is cancelled or expires unpaid
+func (p *promotionService) Release(ctx context.Context, orderID uint) error {
+	return p.repo.ReleaseByOrder(ctx, orderID)
+}
+
+// ShippingDiscounts maps merchant to the shipping discount redeemed by the draft order
+func (p *promotionService) ShippingDiscounts(ctx context.Context, orderID uint) (map[uint]float64, error) {
+	usages, err := p.repo.ListUsagesByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[uint]float64)
+	for _, usage := range usages {
+		result[usage.MerchantID] += usage.ShippingDiscount
+	}
+	return result, nil
+}
+
+// eligibleLines marks the lines matching the merchant, category and brand of the promotion
+func (p *promotionService) eligibleLines(ctx context.Context, promotion *models.Promotion, lines []dto.PromotionLine) ([]bool, error) {
+	eligible := make([]bool, len(lines))
+	if promotion.CategoryID == nil && promotion.BrandID == nil {
+		for i, line := range lines {
+			eligible[i] = promotion.MerchantID == nil || *promotion.MerchantID == line.MerchantID
+		}
+		return eligible, nil
+	}
+
+	ids := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		ids = append(ids, line.ProductVariantID)
+	}
+	variants, err := p.productVariantRepo.ListByIds(ctx, dto.ListProductVariantIds{Ids: ids})
+	if err != nil {
+		return nil, customErr.NewError(customErr.INTERNAL_ERROR, "Unexpected error", http.StatusInternalServerError, err)
+	}
+	products := make(map[uint]models.Product)
+	for _, variant := range variants {
+		products[variant.ID] = variant.Product
+	}
+	for i, line := range lines {
+		product, ok := products[line.ProductVariantID]
+		eligible[i] = ok &&
+			(promotion.MerchantID == nil || *promotion.MerchantID == line.MerchantID) &&
+			(promotion.CategoryID == nil || *promotion.CategoryID == product.CategoryID) &&
+			(promotion.BrandID == nil || *promotion.BrandID == product.BrandID)
+	}
+	return eligible, nil
+}
+
+func validatePromotion(promotion *models.Promotion) error {
+	switch promotion.Type {
+	case models.PromotionPercentage:
+		if promotion.Value <= 0 || promotion.Value > 100 {
+			return customErr.NewError(customErr.VALIDATION_ERROR, "Percentage must be between 0 and 100", http.StatusBadRequest, nil)
+		}
+	case models.PromotionFixed:
+		if promotion.Value <= 0 {
+			return customErr.NewError(customErr.VALIDATION_ERROR, "Fixed discount must be positive", http.StatusBadRequest, nil)
+		}
+	}
+	if promotion.StartsAt != nil && promotion.EndsAt != nil && !promotion.EndsAt.After(*promotion.StartsAt) {
+		return customErr.NewError(customErr.VALIDATION_ERROR, "ends_at must be after starts_at", http.StatusBadRequest, nil)
+	}
+	return nil
+}
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func capDiscount(amount, max float64) float64 {
+	if max > 0 && amount > max {
+		return math.Floor(max)
+	}
+	return amount
+}
+
+// allocateDiscount splits amount proportionally to weights in whole units
+func allocateDiscount(amount float64, weights []float64) []float64 {
+	shares := make([]float64, len(weights))
+	var total float64
+	last := -1
+	for i, w := range weights {
+		total += w
+		if w > 0 {
+			last = i
+		}
+	}
+	if amount <= 0 || total <= 0 {
+		return shares
+	}
+	remaining := amount
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if i == last {
+			shares[i] = remaining
+			break
+		}
+		shares[i] = math.Min(math.Floor(amount*w/total), remaining)
+		remaining -= shares[i]
+	}
+	return shares
+}
@@ -0,0 +1,362 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/payment"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"github.com/minh6824pro/nxrGO/internal/utils"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+// refundableOrderStatuses are the states where a customer may ask for money back
+var refundableOrderStatuses = map[models.OrderStatus]bool{
+	models.OrderStateCancelled:       true,
+	models.OrderStateReturnRequested: true,
+	models.OrderStateReturnShipping:  true,
+	models.OrderStateReturned:        true,
+}
+
+type refundService struct {
+	db              *gorm.DB
+	refundRepo      repositories.RefundRepository
+	orderRepo       repositories.OrderRepository
+	paymentInfoRepo repositories.PaymentInfoRepository
+	paymentProvider payment.PaymentProvider
+}
+
+func NewRefundService(db *gorm.DB, refundRepo repositories.RefundRepository, orderRepo repositories.OrderRepository,
+	paymentInfoRepo repositories.PaymentInfoRepository, paymentProvider payment.PaymentProvider) services.RefundService {
+	return &refundService{
+		db:              db,
+		refundRepo:      refundRepo,
+		orderRepo:       orderRepo,
+		paymentInfoRepo: paymentInfoRepo,
+		paymentProvider: paymentProvider,
+	}
+}
+
+func (r *refundService) Request(ctx context.Context, userID uint, input dto.CreateRefundRequest) (*models.Refund, error) {
+	order, err := r.orderRepo.GetByIdAndUserId(ctx, input.OrderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !order.PaymentMethod.IsOnline() {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Only orders paid online can be refunded", http.StatusBadRequest, nil)
+	}
+	if !refundableOrderStatuses[order.Status] {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Order must be cancelled or returned to be refunded", http.StatusBadRequest, nil)
+	}
+	paymentInfo := successfulPayment(order)
+	if paymentInfo == nil {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Order has no successful payment to refund", http.StatusBadRequest, nil)
+	}
+
+	var requested map[uint]uint
+	if len(input.Items) > 0 {
+		requested = make(map[uint]uint, len(input.Items))
+		for _, item := range input.Items {
+			requested[item.OrderItemID] += item.Quantity
+		}
+	}
+	// Shipping fee is only given back when a cancelled order is refunded as a whole
+	includeShipping := order.Status == models.OrderStateCancelled && requested == nil
+
+	var refund *models.Refund
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		refund, err = r.createTx(ctx, tx, order, paymentInfo.ID, requested, includeShipping, input.Reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if refund == nil {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Nothing left to refund for this order", http.StatusBadRequest, nil)
+	}
+	return refund, nil
+}
+
+// RequestItemsTx asks a refund for the given quantities of cancelled or returned order items,
+// it returns nil when the order was not paid online.
+func (r *refundService) RequestItemsTx(ctx context.Context, tx *gorm.DB, order *models.Order, quantities map[uint]uint, reason string) (*models.Refund, error) {
+	if !order.PaymentMethod.IsOnline() || len(quantities) == 0 {
+		return nil, nil
+	}
+	paymentInfo := successfulPayment(order)
+	if paymentInfo == nil {
+		return nil, nil
+	}
+	requested := make(map[uint]uint, len(quantities))
+	for id, quantity := range quantities {
+		requested[id] = quantity
+	}
+	return r.createTx(ctx, tx, order, paymentInfo.ID, requested, false, reason)
+}
+
+// RequestRemainingTx asks a refund for everything of the order not refunded yet,
+// it returns nil when the order was not paid online or is already fully refunded.
+func (r *refundService) RequestRemainingTx(ctx context.Context, tx *gorm.DB, order *models.Order, includeShipping bool, reason string) (*models.Refund, error) {
+	if !order.PaymentMethod.IsOnline() {
+		return nil, nil
+	}
+	paymentInfo := successfulPayment(order)
+	if paymentInfo == nil {
+		return nil, nil
+	}
+	return r.createTx(ctx, tx, order, paymentInfo.ID, nil, includeShipping, reason)
+}
+
+func (r *refundService) ListByUserId(ctx context.Context, userID uint) ([]models.Refund, error) {
+	return r.refundRepo.ListByUserId(ctx, userID)
+}
+
+func (r *refundService) ListByAdmin(ctx context.Context, status models.RefundStatus) ([]models.Refund, error) {
+	return r.refundRepo.ListByStatus(ctx, status)
+}
+
+// Approve sends the refund to the payment provider. Providers without a refund API leave it
+// APPROVED and flagged manual until staff confirm the transfer with Complete.
+func (r *refundService) Approve(ctx context.Context, refundID uint, adminID uint) (*models.Refund, error) {
+	refund, err := r.refundRepo.GetByID(ctx, refundID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	refund.Status = models.RefundApproved
+	refund.ReviewedBy = &adminID
+	refund.ReviewedAt = &now
+	refund.FailureReason = ""
+	// A failed refund can be approved again to retry the provider
+	claimed, err := r.refundRepo.UpdateStatus(ctx, refund, models.RefundRequested, models.RefundFailed)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, customErr.NewError(customErr.VERSION_CONFLICT, "Refund was already reviewed", http.StatusConflict, nil)
+	}
+
+	paymentInfo, err := r.paymentInfoRepo.GetByID(ctx, refund.PaymentInfoID)
+	if err != nil {
+		return nil, err
+	}
+	err = r.paymentProvider.Refund(ctx, providerPaymentID(paymentInfo), int(math.Round(refund.Amount)), refund.Reason)
+	if errors.Is(err, payment.ErrRefundNotSupported) {
+		refund.Manual = true
+		if _, err := r.refundRepo.UpdateStatus(ctx, refund, models.RefundApproved); err != nil {
+			return nil, err
+		}
+		return refund, nil
+	}
+	if err != nil {
+		log.Printf("Provider refund of refund %d failed: %v", refund.ID, err)
+		refund.Status = models.RefundFailed
+		refund.FailureReason = truncate(err.Error(), 255)
+		if _, saveErr := r.refundRepo.UpdateStatus(ctx, refund, models.RefundApproved); saveErr != nil {
+			log.Printf("Error saving failed refund %d: %v", refund.ID, saveErr)
+		}
+		return nil, customErr.NewError(customErr.PROCESSING_FAILED, "Payment provider could not refund", http.StatusBadGateway, err)
+	}
+	return r.complete(ctx, refund)
+}
+
+func (r *refundService) Reject(ctx context.Context, refundID uint, adminID uint, reason string) (*models.Refund, error) {
+	refund, err := r.refundRepo.GetByID(ctx, refundID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	refund.Status = models.RefundRejected
+	refund.RejectReason = reason
+	refund.ReviewedBy = &adminID
+	refund.ReviewedAt = &now
+	claimed, err := r.refundRepo.UpdateStatus(ctx, refund, models.RefundRequested)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, customErr.NewError(customErr.VERSION_CONFLICT, "Refund was already reviewed", http.StatusConflict, nil)
+	}
+	return refund, nil
+}
+
+// Complete confirms an approved refund whose money was transferred outside the provider
+func (r *refundService) Complete(ctx context.Context, refundID uint) (*models.Refund, error) {
+	refund, err := r.refundRepo.GetByID(ctx, refundID)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Status != models.RefundApproved {
+		return nil, customErr.NewError(customErr.VERSION_CONFLICT, "Only approved refunds can be completed", http.StatusConflict, nil)
+	}
+	// Provider refunds are completed by the provider call, staff only confirm transfers they made themselves
+	if !refund.Manual {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Only manual refunds can be completed by staff", http.StatusBadRequest, nil)
+	}
+	return r.complete(ctx, refund)
+}
+
+// complete marks the refund done and adds it to the refunded amount of the payment in one transaction
+func (r *refundService) complete(ctx context.Context, refund *models.Refund) (*models.Refund, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		paymentInfo, err := r.paymentInfoRepo.GetByIDForUpdateTx(ctx, tx, refund.PaymentInfoID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		refund.Status = models.RefundCompleted
+		refund.CompletedAt = &now
+		claimed, err := r.refundRepo.UpdateStatusTx(ctx, tx, refund, models.RefundApproved)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return customErr.NewError(customErr.VERSION_CONFLICT, "Refund was already completed", http.StatusConflict, nil)
+		}
+
+		if exceedsPaid(paymentInfo.RefundedAmount, refund.Amount, paymentInfo.Total) {
+			return customErr.NewError(customErr.BAD_REQUEST, "Refund exceeds the amount paid", http.StatusBadRequest, nil)
+		}
+		paymentInfo.RefundedAmount += refund.Amount
+		if paymentInfo.RefundedAmount >= paymentInfo.Total {
+			if nextStatus, ok := utils.CanTransitionPayment(paymentInfo.Status, utils.EventRefund); ok {
+				paymentInfo.Status = nextStatus
+			}
+		}
+		return r.paymentInfoRepo.SaveTx(ctx, tx, paymentInfo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// createTx holds the payment row lock while computing what is left to refund, so two requests
+// for the same order can not refund an item twice. nil means nothing is left.
+func (r *refundService) createTx(ctx context.Context, tx *gorm.DB, order *models.Order, paymentInfoID int64,
+	requested map[uint]uint, includeShipping bool, reason string) (*models.Refund, error) {
+	paymentInfo, err := r.paymentInfoRepo.GetByIDForUpdateTx(ctx, tx, paymentInfoID)
+	if err != nil {
+		return nil, err
+	}
+	if paymentInfo.Status != models.PaymentSuccess {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Payment is not refundable", http.StatusBadRequest, nil)
+	}
+	held, err := r.refundRepo.SumActiveQuantitiesTx(ctx, tx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []models.RefundItem
+	var amount float64
+	for _, oi := range order.OrderItems {
+		remaining := oi.Quantity - min(held[oi.ID], oi.Quantity)
+		quantity := remaining
+		if requested != nil {
+			want, ok := requested[oi.ID]
+			if !ok {
+				continue
+			}
+			if want > remaining {
+				return nil, customErr.NewError(customErr.BAD_REQUEST, "Refund quantity exceeds the quantity left to refund", http.StatusBadRequest, nil)
+			}
+			quantity = want
+			delete(requested, oi.ID)
+		}
+		if quantity == 0 {
+			continue
+		}
+		itemAmount := oi.AmountFor(quantity)
+		items = append(items, models.RefundItem{
+			OrderItemID:      oi.ID,
+			ProductVariantID: oi.ProductVariantID,
+			Quantity:         quantity,
+			Amount:           itemAmount,
+		})
+		amount += itemAmount
+	}
+	if len(requested) > 0 {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Order item does not belong to this order", http.StatusBadRequest, nil)
+	}
+
+	var shippingFee float64
+	if includeShipping {
+		heldShipping, err := r.refundRepo.SumActiveShippingFeeTx(ctx, tx, paymentInfo.ID)
+		if err != nil {
+			return nil, err
+		}
+		shippingFee = refundableShippingFee(paymentInfo, heldShipping)
+	}
+	if len(items) == 0 && shippingFee == 0 {
+		return nil, nil
+	}
+	heldAmount, err := r.refundRepo.SumActiveAmountTx(ctx, tx, paymentInfo.ID)
+	if err != nil {
+		return nil, err
+	}
+	if exceedsPaid(heldAmount, amount+shippingFee, paymentInfo.Total) {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Refund exceeds the amount paid", http.StatusBadRequest, nil)
+	}
+
+	refund := &models.Refund{
+		OrderID:       order.ID,
+		PaymentInfoID: paymentInfo.ID,
+		UserID:        order.UserID,
+		Amount:        amount + shippingFee,
+		ShippingFee:   shippingFee,
+		Reason:        reason,
+		Status:        models.RefundRequested,
+		Provider:      r.paymentProvider.Name(),
+		Items:         items,
+	}
+	if err := r.refundRepo.CreateTx(ctx, tx, refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// refundableShippingFee is the shipping fee the customer actually paid minus what refunds already hold,
+// the coupon shipping discount was never charged so it is not given back
+func refundableShippingFee(paymentInfo *models.PaymentInfo, held float64) float64 {
+	return math.Max(paymentInfo.ShippingFee-paymentInfo.ShippingDiscount-held, 0)
+}
+
+// exceedsPaid tells whether refunding amount on top of refunded would give back more than total,
+// amounts are compared in cents to ignore float rounding
+func exceedsPaid(refunded, amount, total float64) bool {
+	return math.Round((refunded+amount)*100) > math.Round(total*100)
+}
+
+func successfulPayment(order *models.Order) *models.PaymentInfo {
+	for i := range order.PaymentInfos {
+		if order.PaymentInfos[i].Status == models.PaymentSuccess {
+			return &order.PaymentInfos[i]
+		}
+	}
+	return nil
+}
+
+// providerPaymentID is the payment the customer paid at the provider, sub orders of a split order
+// share the link of their parent payment
+func providerPaymentID(paymentInfo *models.PaymentInfo) int64 {
+	if paymentInfo.ParentID != nil && *paymentInfo.ParentID != 0 {
+		return *paymentInfo.ParentID
+	}
+	return paymentInfo.ID
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,140 @@
+package impl
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type fakeRefundRepo struct {
+	repositories.RefundRepository
+	refund *models.Refund
+}
+
+func (f *fakeRefundRepo) GetByID(ctx context.Context, id uint) (*models.Refund, error) {
+	found := *f.refund
+	return &found, nil
+}
+
+func (f *fakeRefundRepo) UpdateStatusTx(ctx context.Context, tx *gorm.DB, refund *models.Refund, from ...models.RefundStatus) (bool, error) {
+	for _, status := range from {
+		if f.refund.Status == status {
+			*f.refund = *refund
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type fakePaymentInfoRepo struct {
+	repositories.PaymentInfoRepository
+	paymentInfo *models.PaymentInfo
+	saved       *models.PaymentInfo
+}
+
+func (f *fakePaymentInfoRepo) GetByIDForUpdateTx(ctx context.Context, tx *gorm.DB, paymentInfoID int64) (*models.PaymentInfo, error) {
+	found := *f.paymentInfo
+	return &found, nil
+}
+
+func (f *fakePaymentInfoRepo) SaveTx(ctx context.Context, tx *gorm.DB, payment *models.PaymentInfo) error {
+	f.saved = payment
+	return nil
+}
+
+func newTestRefundService(t *testing.T, refund *models.Refund, paymentInfo *models.PaymentInfo) (*refundService, *fakeRefundRepo, *fakePaymentInfoRepo) {
+	refundRepo := &fakeRefundRepo{refund: refund}
+	paymentInfoRepo := &fakePaymentInfoRepo{paymentInfo: paymentInfo}
+	service := NewRefundService(newTestDB(t), refundRepo, nil, paymentInfoRepo, nil).(*refundService)
+	return service, refundRepo, paymentInfoRepo
+}
+
+func TestRefundableShippingFee(t *testing.T) {
+	paymentInfo := &models.PaymentInfo{ShippingFee: 30000, ShippingDiscount: 10000}
+	cases := []struct {
+		held float64
+		want float64
+	}{
+		{held: 0, want: 20000},
+		{held: 5000, want: 15000},
+		{held: 20000, want: 0},
+		{held: 25000, want: 0},
+	}
+	for _, c := range cases {
+		if got := refundableShippingFee(paymentInfo, c.held); got != c.want {
+			t.Errorf("refundableShippingFee(held %v) = %v, want %v", c.held, got, c.want)
+		}
+	}
+}
+
+func TestExceedsPaid(t *testing.T) {
+	cases := []struct {
+		refunded, amount, total float64
+		want                    bool
+	}{
+		{refunded: 0, amount: 100, total: 100, want: false},
+		{refunded: 60, amount: 40, total: 100, want: false},
+		{refunded: 60, amount: 40.01, total: 100, want: true},
+		// 0.1 + 0.2 is not exactly 0.3 in float64
+		{refunded: 0.1, amount: 0.2, total: 0.3, want: false},
+	}
+	for _, c := range cases {
+		if got := exceedsPaid(c.refunded, c.amount, c.total); got != c.want {
+			t.Errorf("exceedsPaid(%v, %v, %v) = %v, want %v", c.refunded, c.amount, c.total, got, c.want)
+		}
+	}
+}
+
+func TestCompleteRejectsProviderRefunds(t *testing.T) {
+	refund := &models.Refund{ID: 1, PaymentInfoID: 9, Amount: 100, Status: models.RefundApproved}
+	service, refundRepo, paymentInfoRepo := newTestRefundService(t, refund, &models.PaymentInfo{ID: 9, Total: 100, Status: models.PaymentSuccess})
+
+	if _, err := service.Complete(context.Background(), 1); err == nil {
+		t.Fatal("a provider refund must not be completed by staff")
+	}
+	if refundRepo.refund.Status != models.RefundApproved || paymentInfoRepo.saved != nil {
+		t.Fatal("nothing must change when completing is refused")
+	}
+}
+
+func TestCompleteAddsRefundedAmount(t *testing.T) {
+	refund := &models.Refund{ID: 1, PaymentInfoID: 9, Amount: 40, Status: models.RefundApproved, Manual: true}
+	service, refundRepo, paymentInfoRepo := newTestRefundService(t, refund, &models.PaymentInfo{ID: 9, Total: 100, RefundedAmount: 60, Status: models.PaymentSuccess})
+
+	if _, err := service.Complete(context.Background(), 1); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if refundRepo.refund.Status != models.RefundCompleted {
+		t.Fatalf("expected the refund to be completed, got %s", refundRepo.refund.Status)
+	}
+	if paymentInfoRepo.saved.RefundedAmount != 100 || paymentInfoRepo.saved.Status != models.PaymentRefund {
+		t.Fatalf("expected the payment to be fully refunded, got %+v", paymentInfoRepo.saved)
+	}
+}
+
+func TestCompleteRejectsRefundAbovePaid(t *testing.T) {
+	refund := &models.Refund{ID: 1, PaymentInfoID: 9, Amount: 50, Status: models.RefundApproved, Manual: true}
+	service, _, paymentInfoRepo := newTestRefundService(t, refund, &models.PaymentInfo{ID: 9, Total: 100, RefundedAmount: 60, Status: models.PaymentSuccess})
+
+	if _, err := service.Complete(context.Background(), 1); err == nil {
+		t.Fatal("expected refunding more than paid to fail")
+	}
+	if paymentInfoRepo.saved != nil {
+		t.Fatalf("the payment must not be saved, got %+v", paymentInfoRepo.saved)
+	}
+}
+
+func TestTruncateKeepsRunesWhole(t *testing.T) {
+	reason := strings.Repeat("ệ", 10)
+	got := truncate(reason, 4)
+	if !utf8.ValidString(got) || got != strings.Repeat("ệ", 4) {
+		t.Fatalf("truncate cut a rune: %q", got)
+	}
+	if truncate("short", 10) != "short" {
+		t.Fatal("short strings must be kept")
+	}
+}
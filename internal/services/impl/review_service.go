@@ -0,0 +1,204 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/minh6824pro/nxrGO/internal/cache"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/elastic"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"time"
+)
+
+type reviewService struct {
+	repo               repositories.ReviewRepository
+	productRepo        repositories.ProductRepository
+	productCache       cache.ProductCacheService
+	elasticProductRepo elastic.ProductElasticRepository
+}
+
+func NewReviewService(repo repositories.ReviewRepository, productRepo repositories.ProductRepository,
+	productCache cache.ProductCacheService, elasticProductRepo elastic.ProductElasticRepository) services.ReviewService {
+	return &reviewService{
+		repo:               repo,
+		productRepo:        productRepo,
+		productCache:       productCache,
+		elasticProductRepo: elasticProductRepo,
+	}
+}
+
+// Create saves the review, only users who received the product in a DONE order can review it
+func (r *reviewService) Create(ctx context.Context, userID uint, productID uint, input dto.CreateReviewInput) (*models.Review, error) {
+	if _, err := r.productRepo.GetMerchantID(ctx, productID); err != nil {
+		return nil, err
+	}
+	orderID, err := r.repo.FindDeliveredOrder(ctx, userID, productID)
+	if err != nil {
+		return nil, err
+	}
+	if orderID == 0 {
+		return nil, customErr.NewError(customErr.FORBIDDEN, "You can only review products from a completed order", http.StatusForbidden, nil)
+	}
+
+	review := &models.Review{
+		ProductID: productID,
+		UserID:    userID,
+		OrderID:   orderID,
+		Rating:    input.Rating,
+		Content:   input.Content,
+		Images:    reviewImages(input.Images),
+		Status:    models.ReviewVisible,
+	}
+	if err := r.repo.Create(ctx, review); err != nil {
+		return nil, err
+	}
+	r.refreshProductRating(ctx, productID)
+	return review, nil
+}
+
+// Update lets the author change the rating, text and images of the review
+func (r *reviewService) Update(ctx context.Context, userID uint, id uint, input dto.UpdateReviewInput) (*models.Review, error) {
+	review, err := r.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if review.UserID != userID {
+		return nil, customErr.NewError(customErr.ITEM_NOT_FOUND, "Review not found", http.StatusNotFound, nil)
+	}
+
+	previous := *review
+	if input.Rating != nil {
+		review.Rating = *input.Rating
+	}
+	if input.Content != nil {
+		review.Content = *input.Content
+	}
+	if input.Images != nil {
+		review.Images = reviewImages(*input.Images)
+	}
+	if err := r.repo.Update(ctx, review, previous, input.Images != nil); err != nil {
+		return nil, err
+	}
+	if previous.Rating != review.Rating && review.Counted() {
+		r.refreshProductRating(ctx, review.ProductID)
+	}
+	return review, nil
+}
+
+// Delete removes the review, by its author or an admin
+func (r *reviewService) Delete(ctx context.Context, actor models.Actor, id uint) error {
+	review, err := r.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if actor.Role != models.RoleAdmin && review.UserID != actor.UserID {
+		return customErr.NewError(customErr.ITEM_NOT_FOUND, "Review not found", http.StatusNotFound, nil)
+	}
+	if err := r.repo.Delete(ctx, review); err != nil {
+		return err
+	}
+	if review.Counted() {
+		r.refreshProductRating(ctx, review.ProductID)
+	}
+	return nil
+}
+
+// Reply sets the answer of the merchant owning the product, admins can reply too
+func (r *reviewService) Reply(ctx context.Context, actor models.Actor, id uint, input dto.ReplyReviewInput) (*models.Review, error) {
+	review, err := r.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if actor.Role == models.RoleMerchant {
+		merchantID, err := r.productRepo.GetMerchantID(ctx, review.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if merchantID != actor.MerchantID {
+			return nil, customErr.NewError(customErr.FORBIDDEN, "You can only reply to reviews of your own products", http.StatusForbidden, nil)
+		}
+	}
+
+	now := time.Now()
+	repliedBy := actor.UserID
+	review.MerchantReply = input.Reply
+	review.RepliedBy = &repliedBy
+	review.RepliedAt = &now
+	if err := r.repo.Update(ctx, review, *review, false); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// Moderate hides or shows the review, hidden reviews leave the product rating
+func (r *reviewService) Moderate(ctx context.Context, actor models.Actor, id uint, input dto.ModerateReviewInput) (*models.Review, error) {
+	review, err := r.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *review
+	now := time.Now()
+	moderatedBy := actor.UserID
+	review.Status = input.Status
+	review.ModerationNote = input.Note
+	review.ModeratedBy = &moderatedBy
+	review.ModeratedAt = &now
+	if err := r.repo.Update(ctx, review, previous, false); err != nil {
+		return nil, err
+	}
+	if previous.Status != review.Status {
+		r.refreshProductRating(ctx, review.ProductID)
+	}
+	return review, nil
+}
+
+// ListByProduct returns the visible reviews of the product
+func (r *reviewService) ListByProduct(ctx context.Context, productID uint, rating *uint8, page, pageSize int) ([]*models.Review, int, error) {
+	return r.repo.List(ctx, dto.ReviewFilter{
+		ProductID: &productID,
+		Status:    models.ReviewVisible,
+		Rating:    rating,
+	}, page, pageSize)
+}
+
+func (r *reviewService) List(ctx context.Context, filter dto.ReviewFilter, page, pageSize int) ([]*models.Review, int, error) {
+	return r.repo.List(ctx, filter, page, pageSize)
+}
+
+// refreshProductRating drops the cached mini products and pushes the new rating to elastic,
+// the rating is already saved so failures are only logged
+func (r *reviewService) refreshProductRating(ctx context.Context, productID uint) {
+	product, err := r.productRepo.GetByIdPreloadVariant(ctx, productID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to load product %d after rating change: %v", productID, err)
+		}
+		return
+	}
+
+	variantIDs := make([]uint, len(product.Variants))
+	for i, variant := range product.Variants {
+		variantIDs[i] = variant.ID
+	}
+	if err := r.productCache.DeleteProductMiniCache(ctx, productID, variantIDs); err != nil {
+		log.Printf("Failed to delete mini cache of product %d: %v", productID, err)
+	}
+	if err := r.elasticProductRepo.UpdateRating(ctx, productID, product.AverageRating, product.NumberRating); err != nil {
+		log.Printf("Failed to update elastic rating of product %d: %v", productID, err)
+	}
+}
+
+func reviewImages(urls []string) []models.ReviewImage {
+	images := make([]models.ReviewImage, len(urls))
+	for i, url := range urls {
+		images[i] = models.ReviewImage{URL: url}
+	}
+	return images
+}
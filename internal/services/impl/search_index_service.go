@@ -0,0 +1,306 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/elastic"
+	"github.com/minh6824pro/nxrGO/internal/event"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type searchIndexService struct {
+	elasticClient      *elastic.ElasticClient
+	elasticProductRepo elastic.ProductElasticRepository
+	productRepo        repositories.ProductRepository
+	merchantRepo       repositories.MerchantRepository
+	brandRepo          repositories.BrandRepository
+	categoryRepo       repositories.CategoryRepository
+	outboxRepo         repositories.OutboxRepository
+
+	mu            sync.Mutex
+	lastIndexedAt *time.Time
+	lastEventLag  time.Duration
+	lastError     string
+}
+
+func NewSearchIndexService(elasticClient *elastic.ElasticClient, elasticProductRepo elastic.ProductElasticRepository, productRepo repositories.ProductRepository,
+	merchantRepo repositories.MerchantRepository, brandRepo repositories.BrandRepository, categoryRepo repositories.CategoryRepository,
+	outboxRepo repositories.OutboxRepository) services.SearchIndexService {
+	return &searchIndexService{
+		elasticClient:      elasticClient,
+		elasticProductRepo: elasticProductRepo,
+		productRepo:        productRepo,
+		merchantRepo:       merchantRepo,
+		brandRepo:          brandRepo,
+		categoryRepo:       categoryRepo,
+		outboxRepo:         outboxRepo,
+	}
+}
+
+func (s *searchIndexService) HandleCatalogChanged(e event.CatalogChangedEvent) error {
+	err := s.apply(context.Background(), e)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.lastError = fmt.Sprintf("%s %d: %v", e.Entity, e.ID, err)
+		return err
+	}
+	now := time.Now()
+	s.lastIndexedAt = &now
+	s.lastEventLag = now.Sub(e.OccurredAt)
+	s.lastError = ""
+	return nil
+}
+
+// apply re-reads the changed rows, the event only says what changed so a late or repeated delivery is harmless
+func (s *searchIndexService) apply(ctx context.Context, e event.CatalogChangedEvent) error {
+	switch e.Entity {
+	case event.CatalogProduct:
+		return s.elasticProductRepo.SyncProducts(ctx, []uint{e.ID})
+	case event.CatalogOrder:
+		ids, err := s.productRepo.GetIdsByOrder(ctx, e.ID)
+		if err != nil {
+			return err
+		}
+		return s.elasticProductRepo.SyncProducts(ctx, ids)
+	case event.CatalogMerchant:
+		return s.applyReference(ctx, e, "merchant_id", func() (map[string]interface{}, error) {
+			merchant, err := s.merchantRepo.GetByID(ctx, e.ID)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"merchant":  merchant.Name,
+				"location":  merchant.Location,
+				"geo_point": fmt.Sprintf("%s,%s", merchant.Latitude, merchant.Longitude),
+			}, nil
+		})
+	case event.CatalogBrand:
+		return s.applyReference(ctx, e, "brand_id", func() (map[string]interface{}, error) {
+			brand, err := s.brandRepo.GetByID(ctx, e.ID)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"brand": brand.Name}, nil
+		})
+	case event.CatalogCategory:
+		return s.applyReference(ctx, e, "category_id", func() (map[string]interface{}, error) {
+			category, err := s.categoryRepo.GetByID(ctx, e.ID)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"category": category.Name}, nil
+		})
+	default:
+		log.Printf("Skipping catalog changed event of unknown entity %s", e.Entity)
+		return nil
+	}
+}
+
+// applyReference copies the merchant, brand or category fields into the documents of its products,
+// after a delete the products are synced in full
+func (s *searchIndexService) applyReference(ctx context.Context, e event.CatalogChangedEvent, column string,
+	fields func() (map[string]interface{}, error)) error {
+	ids, err := s.productRepo.GetIdsByReference(ctx, column, e.ID)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if e.Deleted {
+		return s.elasticProductRepo.SyncProducts(ctx, ids)
+	}
+	doc, err := fields()
+	if err != nil {
+		return err
+	}
+	return s.elasticProductRepo.UpdateProductFields(ctx, ids, doc)
+}
+
+func (s *searchIndexService) Status(ctx context.Context) (*dto.SearchSyncStatus, error) {
+	stats, err := s.outboxRepo.Stats(ctx, event.CatalogChangedEventType)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.elasticClient.AliasedIndex(ctx, elastic.ProductReadAlias)
+	if err != nil {
+		return nil, err
+	}
+	status := &dto.SearchSyncStatus{
+		Index:           current,
+		PendingEvents:   stats.Pending,
+		DeadEvents:      stats.Dead,
+		OldestPendingAt: stats.OldestPendingAt,
+	}
+	if stats.OldestPendingAt != nil {
+		status.LagSeconds = time.Since(*stats.OldestPendingAt).Seconds()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status.LastIndexedAt = s.lastIndexedAt
+	status.LastEventLagSeconds = s.lastEventLag.Seconds()
+	status.LastError = s.lastError
+	return status, nil
+}
+
+func (s *searchIndexService) Reindex(ctx context.Context) (*dto.ReindexResult, error) {
+	current, indices, err := s.productIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target := elastic.ProductIndexName(indices[len(indices)-1].Version + 1)
+
+	// Changes made from now on only reach the current index, they are replayed on the new one after the swap
+	startedAt := time.Now()
+	if err := s.elasticClient.CreateProductIndex(ctx, target); err != nil {
+		return nil, err
+	}
+	loaded, err := s.elasticProductRepo.IndexAllProducts(ctx, target)
+	if err != nil {
+		return nil, s.abandonIndex(ctx, target, err)
+	}
+	count, err := s.elasticClient.CountDocuments(ctx, target)
+	if err != nil {
+		return nil, s.abandonIndex(ctx, target, err)
+	}
+	if count != loaded {
+		return nil, s.abandonIndex(ctx, target, fmt.Errorf("index %s has %d documents, expected %d", target, count, loaded))
+	}
+
+	if err := s.elasticClient.SwapProductAliases(ctx, current.Name, target); err != nil {
+		return nil, s.abandonIndex(ctx, target, err)
+	}
+	log.Printf("Aliases swapped from %s to %s", current.Name, target)
+
+	result := &dto.ReindexResult{PreviousIndex: current.Name, Index: target, Documents: count}
+	if result.ReplayedEvents, err = s.replay(ctx, startedAt); err != nil {
+		return result, fmt.Errorf("index %s is live but replaying changes failed: %w", target, err)
+	}
+
+	// Keep the previous version for a rollback, older ones are dropped
+	for _, idx := range indices {
+		if idx.Name == current.Name {
+			continue
+		}
+		if err := s.elasticClient.DeleteIndex(ctx, idx.Name); err != nil {
+			log.Printf("Error deleting old index %s: %v", idx.Name, err)
+		}
+	}
+	return result, nil
+}
+
+func (s *searchIndexService) Rollback(ctx context.Context) (*dto.ReindexResult, error) {
+	current, indices, err := s.productIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var previous *elastic.ProductIndex
+	for i := range indices {
+		if indices[i].Version < current.Version {
+			previous = &indices[i]
+		}
+	}
+	if previous == nil {
+		return nil, fmt.Errorf("no index version older than %s to roll back to", current.Name)
+	}
+
+	if err := s.elasticClient.SwapProductAliases(ctx, current.Name, previous.Name); err != nil {
+		return nil, err
+	}
+	log.Printf("Aliases swapped back from %s to %s", current.Name, previous.Name)
+
+	result := &dto.ReindexResult{PreviousIndex: current.Name, Index: previous.Name}
+	// The previous version stopped receiving changes when the current one started being built
+	if result.ReplayedEvents, err = s.replay(ctx, current.CreatedAt); err != nil {
+		return result, fmt.Errorf("index %s is live but replaying changes failed: %w", previous.Name, err)
+	}
+	if result.Documents, err = s.elasticClient.CountDocuments(ctx, previous.Name); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// productIndices returns the index behind the read alias and all physical versions, oldest first
+func (s *searchIndexService) productIndices(ctx context.Context) (*elastic.ProductIndex, []elastic.ProductIndex, error) {
+	name, err := s.elasticClient.AliasedIndex(ctx, elastic.ProductReadAlias)
+	if err != nil {
+		return nil, nil, err
+	}
+	if name == "" {
+		return nil, nil, fmt.Errorf("alias %s does not exist, start the server once to create it", elastic.ProductReadAlias)
+	}
+	indices, err := s.elasticClient.ProductIndices(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range indices {
+		if indices[i].Name == name {
+			return &indices[i], indices, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("alias %s points to %s which is not a versioned index", elastic.ProductReadAlias, name)
+}
+
+// replay applies again the catalog changes recorded since the time, through the write alias.
+// Outbox rows are never purged, if they ever are the replay misses changes and a full Reindex is needed instead
+func (s *searchIndexService) replay(ctx context.Context, since time.Time) (int, error) {
+	events, err := s.outboxRepo.ListSince(ctx, event.CatalogChangedEventType, since)
+	if err != nil {
+		return 0, err
+	}
+	for i, e := range events {
+		var payload event.CatalogChangedEvent
+		if err := json.Unmarshal([]byte(e.Payload), &payload); err != nil {
+			log.Printf("Skipping outbox event %d with invalid payload: %v", e.ID, err)
+			continue
+		}
+		if err := s.apply(ctx, payload); err != nil {
+			return i, err
+		}
+	}
+	return len(events), nil
+}
+
+// abandonIndex drops a version that was not swapped in, the aliases still point to the current one
+func (s *searchIndexService) abandonIndex(ctx context.Context, name string, cause error) error {
+	if err := s.elasticClient.DeleteIndex(ctx, name); err != nil {
+		log.Printf("Error deleting abandoned index %s: %v", name, err)
+	}
+	return fmt.Errorf("reindex into %s failed: %w", name, cause)
+}
+
+// publishCatalogChangedTx records the change in the outbox of the catalog write's transaction
+func publishCatalogChangedTx(tx *gorm.DB, eventBus event.EventPublisher, entity event.CatalogEntity, id uint, deleted bool) error {
+	if err := eventBus.PublishCatalogChangedTx(tx, event.NewCatalogChangedEvent(entity, id, deleted)); err != nil {
+		return customErr.NewError(customErr.UNEXPECTED_ERROR, "Error recording catalog change", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// publishStockCrossingsTx re-indexes the products whose variants sold out or came back in stock
+func publishStockCrossingsTx(tx *gorm.DB, eventBus event.EventPublisher, changes []models.StockLevelChange) error {
+	published := make(map[uint]bool)
+	for _, change := range changes {
+		if !change.CrossedZero() || published[change.ProductID] {
+			continue
+		}
+		published[change.ProductID] = true
+		if err := eventBus.PublishCatalogChangedTx(tx, event.NewCatalogChangedEvent(event.CatalogProduct, change.ProductID, false)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
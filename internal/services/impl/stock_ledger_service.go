@@ -0,0 +1,174 @@
+package impl
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/cache"
+	"github.com/minh6824pro/nxrGO/internal/event"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"gorm.io/gorm"
+	"log"
+)
+
+type stockLedgerService struct {
+	db                  *gorm.DB
+	stockMovementRepo   repositories.StockMovementRepository
+	productVariantCache cache.ProductVariantRedis
+	eventBus            event.EventPublisher
+}
+
+func NewStockLedgerService(db *gorm.DB, stockMovementRepo repositories.StockMovementRepository, productVariantCache cache.ProductVariantRedis,
+	eventBus event.EventPublisher) services.StockLedgerService {
+	return &stockLedgerService{
+		db:                  db,
+		stockMovementRepo:   stockMovementRepo,
+		productVariantCache: productVariantCache,
+		eventBus:            eventBus,
+	}
+}
+
+func (s *stockLedgerService) ReserveTx(ctx context.Context, tx *gorm.DB, draftOrderID uint, items []models.OrderItem) error {
+	return s.stockMovementRepo.CreateTx(ctx, tx, buildMovements(items, -1, models.StockReasonReserve, draftOrderID, models.OrderTypeDraftOrder))
+}
+
+// ReleaseTx gives back the stock held by a draft order that will never become an order,
+// callers close the draft in the same transaction so a reservation is released once
+func (s *stockLedgerService) ReleaseTx(ctx context.Context, tx *gorm.DB, draftOrderID uint, items []models.OrderItem) error {
+	return s.stockMovementRepo.CreateTx(ctx, tx, buildMovements(items, 1, models.StockReasonRelease, draftOrderID, models.OrderTypeDraftOrder))
+}
+
+// SellTx turns the reservation of a draft order into a sale of the order created in the same transaction
+func (s *stockLedgerService) SellTx(ctx context.Context, tx *gorm.DB, draftOrderID uint, orderID uint, items []models.OrderItem) error {
+	movements := buildMovements(items, 1, models.StockReasonRelease, draftOrderID, models.OrderTypeDraftOrder)
+	movements = append(movements, buildMovements(items, -1, models.StockReasonSale, orderID, models.OrderTypeOrder)...)
+	return s.stockMovementRepo.CreateTx(ctx, tx, movements)
+}
+
+// Restock puts the items of a cancelled or returned order back on hand,
+// quantities already cancelled or returned item by item were restocked before
+func (s *stockLedgerService) Restock(ctx context.Context, order *models.Order) error {
+	return s.stockMovementRepo.Create(ctx, buildMovements(outstandingItems(order.OrderItems), 1, models.StockReasonReturn, order.ID, models.OrderTypeOrder))
+}
+
+func (s *stockLedgerService) RestockTx(ctx context.Context, tx *gorm.DB, order *models.Order) error {
+	return s.stockMovementRepo.CreateTx(ctx, tx, buildMovements(outstandingItems(order.OrderItems), 1, models.StockReasonReturn, order.ID, models.OrderTypeOrder))
+}
+
+// RestockItemsTx puts back only the given quantities of a partially cancelled or returned order
+func (s *stockLedgerService) RestockItemsTx(ctx context.Context, tx *gorm.DB, orderID uint, items []models.OrderItem) error {
+	return s.stockMovementRepo.CreateTx(ctx, tx, buildMovements(items, 1, models.StockReasonReturn, orderID, models.OrderTypeOrder))
+}
+
+// Flush applies pending sales, returns and adjustments to product_variants.quantity
+func (s *stockLedgerService) Flush(ctx context.Context) (map[uint]int, error) {
+	changes, err := s.applyTx(ctx, s.stockMovementRepo.ApplyPendingTx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]int, len(changes))
+	for _, change := range changes {
+		applied[change.ProductVariantID] = change.After - change.Before
+	}
+	s.invalidateCache(applied)
+	log.Println("Flushed stock ledger:", applied)
+	return applied, nil
+}
+
+// Rebuild recomputes product_variants.quantity from the whole ledger, used after a crash
+func (s *stockLedgerService) Rebuild(ctx context.Context) error {
+	changes, err := s.applyTx(ctx, s.stockMovementRepo.RebuildQuantityTx)
+	if err != nil {
+		return err
+	}
+	rebuilt := make(map[uint]int, len(changes))
+	for _, change := range changes {
+		rebuilt[change.ProductVariantID] = change.After
+	}
+	s.invalidateCache(rebuilt)
+	log.Printf("Rebuilt stock of %d product variants from ledger", len(rebuilt))
+	return nil
+}
+
+// applyTx writes on-hand quantities and re-indexes products that sold out or came back in stock in one transaction
+func (s *stockLedgerService) applyTx(ctx context.Context, apply func(ctx context.Context, tx *gorm.DB) ([]models.StockLevelChange, error)) ([]models.StockLevelChange, error) {
+	var changes []models.StockLevelChange
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if changes, err = apply(ctx, tx); err != nil {
+			return err
+		}
+		return publishStockCrossingsTx(tx, s.eventBus, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ReconcileCache overwrites cached quantity that drifted from the ledger
+func (s *stockLedgerService) ReconcileCache(ctx context.Context, variantIDs []uint) error {
+	if len(variantIDs) == 0 {
+		return nil
+	}
+	available, err := s.stockMovementRepo.SumAvailable(ctx, variantIDs)
+	if err != nil {
+		return err
+	}
+	for variantID, quantity := range available {
+		if quantity < 0 {
+			quantity = 0
+		}
+		if err := s.productVariantCache.SetQuantityIfExists(variantID, quantity); err != nil {
+			log.Printf("Error reconciling product variant cache %d: %v", variantID, err)
+		}
+	}
+	return nil
+}
+
+func (s *stockLedgerService) SeedOpeningBalances(ctx context.Context) error {
+	seeded, err := s.stockMovementRepo.SeedOpeningBalances(ctx)
+	if err != nil {
+		return err
+	}
+	if seeded > 0 {
+		log.Printf("Seeded stock ledger opening balance for %d product variants", seeded)
+	}
+	return nil
+}
+
+func (s *stockLedgerService) ListByVariant(ctx context.Context, variantID uint) ([]models.StockMovement, error) {
+	return s.stockMovementRepo.ListByVariant(ctx, variantID)
+}
+
+func (s *stockLedgerService) invalidateCache(variants map[uint]int) {
+	for variantID := range variants {
+		if err := s.productVariantCache.DeleteProductVariantHash(variantID); err != nil {
+			log.Printf("Error deleting product variant cache: %v", err)
+		}
+	}
+}
+
+func outstandingItems(items []models.OrderItem) []models.OrderItem {
+	outstanding := make([]models.OrderItem, 0, len(items))
+	for i := range items {
+		if quantity := items[i].OutstandingQuantity(); quantity > 0 {
+			outstanding = append(outstanding, models.OrderItem{ProductVariantID: items[i].ProductVariantID, Quantity: quantity})
+		}
+	}
+	return outstanding
+}
+
+func buildMovements(items []models.OrderItem, sign int, reason models.StockMovementReason, orderID uint, orderType models.OrderType) []models.StockMovement {
+	movements := make([]models.StockMovement, 0, len(items))
+	for _, oi := range items {
+		movements = append(movements, models.StockMovement{
+			ProductVariantID: oi.ProductVariantID,
+			Delta:            sign * int(oi.Quantity),
+			Reason:           reason,
+			OrderID:          orderID,
+			OrderType:        orderType,
+		})
+	}
+	return movements
+}
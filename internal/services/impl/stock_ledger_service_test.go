@@ -0,0 +1,152 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"github.com/minh6824pro/nxrGO/internal/cache"
+	"github.com/minh6824pro/nxrGO/internal/event"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"gorm.io/gorm"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type fakeStockMovementRepo struct {
+	repositories.StockMovementRepository
+	changes []models.StockLevelChange
+	err     error
+	created []models.StockMovement
+}
+
+func (f *fakeStockMovementRepo) RebuildQuantityTx(ctx context.Context, tx *gorm.DB) ([]models.StockLevelChange, error) {
+	return f.changes, f.err
+}
+
+func (f *fakeStockMovementRepo) ApplyPendingTx(ctx context.Context, tx *gorm.DB) ([]models.StockLevelChange, error) {
+	return f.changes, f.err
+}
+
+func (f *fakeStockMovementRepo) CreateTx(ctx context.Context, tx *gorm.DB, movements []models.StockMovement) error {
+	f.created = append(f.created, movements...)
+	return f.err
+}
+
+type fakeProductVariantCache struct {
+	cache.ProductVariantRedis
+	deleted     []uint
+	incremented []models.OrderItem
+}
+
+func (f *fakeProductVariantCache) DeleteProductVariantHash(id uint) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+func (f *fakeProductVariantCache) IncrementStockIfExists(orderItems []models.OrderItem) error {
+	f.incremented = append(f.incremented, orderItems...)
+	return nil
+}
+
+func newTestStockLedger(t *testing.T, repo *fakeStockMovementRepo) (*stockLedgerService, *fakeProductVariantCache, *fakeEventBus) {
+	variantCache := &fakeProductVariantCache{}
+	eventBus := &fakeEventBus{}
+	return NewStockLedgerService(newTestDB(t), repo, variantCache, eventBus).(*stockLedgerService), variantCache, eventBus
+}
+
+func TestRebuildReindexesProductsThatCrossedZero(t *testing.T) {
+	repo := &fakeStockMovementRepo{changes: []models.StockLevelChange{
+		{ProductVariantID: 1, ProductID: 10, Before: 0, After: 5},
+		{ProductVariantID: 2, ProductID: 10, Before: 3, After: 0},
+		{ProductVariantID: 3, ProductID: 20, Before: 2, After: 4},
+		{ProductVariantID: 4, ProductID: 30, Before: 4, After: 0},
+	}}
+	ledger, variantCache, eventBus := newTestStockLedger(t, repo)
+
+	if err := ledger.Rebuild(context.Background()); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+
+	var reindexed []uint
+	for _, e := range eventBus.catalog {
+		if e.Entity != event.CatalogProduct || e.Deleted {
+			t.Fatalf("unexpected catalog event %+v", e)
+		}
+		reindexed = append(reindexed, e.ID)
+	}
+	if !reflect.DeepEqual(reindexed, []uint{10, 30}) {
+		t.Fatalf("expected products 10 and 30 to be re-indexed once, got %v", reindexed)
+	}
+	sort.Slice(variantCache.deleted, func(i, j int) bool { return variantCache.deleted[i] < variantCache.deleted[j] })
+	if !reflect.DeepEqual(variantCache.deleted, []uint{1, 2, 3, 4}) {
+		t.Fatalf("expected the cache of every rebuilt variant to be dropped, got %v", variantCache.deleted)
+	}
+}
+
+func TestRebuildKeepsCacheWhenLedgerFails(t *testing.T) {
+	repo := &fakeStockMovementRepo{err: errors.New("boom")}
+	ledger, variantCache, eventBus := newTestStockLedger(t, repo)
+
+	if err := ledger.Rebuild(context.Background()); err == nil {
+		t.Fatal("expected the ledger error")
+	}
+	if len(eventBus.catalog) != 0 || len(variantCache.deleted) != 0 {
+		t.Fatalf("nothing must be published or invalidated, got %v and %v", eventBus.catalog, variantCache.deleted)
+	}
+}
+
+func TestFlushReturnsAppliedDeltas(t *testing.T) {
+	repo := &fakeStockMovementRepo{changes: []models.StockLevelChange{
+		{ProductVariantID: 1, ProductID: 10, Before: 5, After: 2},
+		{ProductVariantID: 2, ProductID: 20, Before: 0, After: 3},
+	}}
+	ledger, _, eventBus := newTestStockLedger(t, repo)
+
+	applied, err := ledger.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !reflect.DeepEqual(applied, map[uint]int{1: -3, 2: 3}) {
+		t.Fatalf("unexpected applied deltas %v", applied)
+	}
+	if len(eventBus.catalog) != 1 || eventBus.catalog[0].ID != 20 {
+		t.Fatalf("expected only product 20 to be re-indexed, got %v", eventBus.catalog)
+	}
+}
+
+func TestSellTxReleasesReservationAndRecordsSale(t *testing.T) {
+	repo := &fakeStockMovementRepo{}
+	ledger, _, _ := newTestStockLedger(t, repo)
+	items := []models.OrderItem{{ProductVariantID: 1, Quantity: 2}, {ProductVariantID: 2, Quantity: 1}}
+
+	if err := ledger.SellTx(context.Background(), nil, 7, 9, items); err != nil {
+		t.Fatalf("SellTx: %v", err)
+	}
+
+	total := make(map[uint]int)
+	onHand := make(map[uint]int)
+	for _, m := range repo.created {
+		total[m.ProductVariantID] += m.Delta
+		if m.Reason.AffectsOnHand() {
+			onHand[m.ProductVariantID] += m.Delta
+		}
+	}
+	// The reservation already took the stock out of available, the sale only moves it out of on hand
+	if !reflect.DeepEqual(total, map[uint]int{1: 0, 2: 0}) {
+		t.Fatalf("unexpected available stock change %v", total)
+	}
+	if !reflect.DeepEqual(onHand, map[uint]int{1: -2, 2: -1}) {
+		t.Fatalf("unexpected on hand stock change %v", onHand)
+	}
+}
+
+func TestRestockSkipsCancelledAndReturnedQuantities(t *testing.T) {
+	items := outstandingItems([]models.OrderItem{
+		{ProductVariantID: 1, Quantity: 3, CancelledQuantity: 1},
+		{ProductVariantID: 2, Quantity: 2, ReturnedQuantity: 2},
+	})
+	if len(items) != 1 || items[0].ProductVariantID != 1 || items[0].Quantity != 2 {
+		t.Fatalf("unexpected outstanding items %+v", items)
+	}
+}
@@ -0,0 +1,123 @@
+package impl
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/cache"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	customErr "github.com/minh6824pro/nxrGO/pkg/errors"
+	"log"
+	"net/http"
+)
+
+type userService struct {
+	userRepo     repositories.UserRepository
+	merchantRepo repositories.MerchantRepository
+	authService  services.AuthService
+	userStatus   cache.UserStatusService
+}
+
+func NewUserService(userRepo repositories.UserRepository, merchantRepo repositories.MerchantRepository,
+	authService services.AuthService, userStatus cache.UserStatusService) services.UserService {
+	return &userService{
+		userRepo:     userRepo,
+		merchantRepo: merchantRepo,
+		authService:  authService,
+		userStatus:   userStatus,
+	}
+}
+
+func (u *userService) List(ctx context.Context, filter dto.UserFilter, page, pageSize int) ([]*models.User, int, error) {
+	return u.userRepo.List(ctx, filter, page, pageSize)
+}
+
+func (u *userService) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	return u.userRepo.GetByID(ctx, id)
+}
+
+// ChangeRole updates role and merchant, tokens still carry the old role so the user is logged out
+func (u *userService) ChangeRole(ctx context.Context, adminID uint, id uint, input dto.ChangeUserRoleInput) (*models.User, error) {
+	if adminID == id {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Can not change your own role", http.StatusBadRequest, nil)
+	}
+	user, err := u.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var merchantID *uint
+	if input.Role == models.RoleMerchant {
+		if input.MerchantID == nil {
+			return nil, customErr.NewError(customErr.BAD_REQUEST, "merchant_id is required for MERCHANT role", http.StatusBadRequest, nil)
+		}
+		if _, err := u.merchantRepo.GetByID(ctx, *input.MerchantID); err != nil {
+			return nil, err
+		}
+		merchantID = input.MerchantID
+	}
+
+	if err := u.userRepo.UpdateRole(ctx, id, input.Role, merchantID); err != nil {
+		return nil, err
+	}
+	if err := u.authService.LogoutAll(ctx, id); err != nil {
+		return nil, err
+	}
+	log.Printf("User %d role changed from %s to %s by admin %d", id, user.Role, input.Role, adminID)
+
+	user.Role = input.Role
+	user.MerchantID = merchantID
+	return user, nil
+}
+
+// Deactivate blocks the account and revokes its sessions, AuthMiddleware rejects its access tokens right away
+func (u *userService) Deactivate(ctx context.Context, adminID uint, id uint) (*models.User, error) {
+	if adminID == id {
+		return nil, customErr.NewError(customErr.BAD_REQUEST, "Can not deactivate your own account", http.StatusBadRequest, nil)
+	}
+	user, err := u.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.userRepo.UpdateActive(ctx, id, 0); err != nil {
+		return nil, err
+	}
+	u.invalidateStatus(ctx, id)
+	if err := u.authService.LogoutAll(ctx, id); err != nil {
+		return nil, err
+	}
+	log.Printf("User %d deactivated by admin %d", id, adminID)
+
+	user.Active = 0
+	return user, nil
+}
+
+func (u *userService) Reactivate(ctx context.Context, id uint) (*models.User, error) {
+	user, err := u.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.userRepo.UpdateActive(ctx, id, 1); err != nil {
+		return nil, err
+	}
+	u.invalidateStatus(ctx, id)
+
+	user.Active = 1
+	return user, nil
+}
+
+func (u *userService) ForceLogout(ctx context.Context, id uint) error {
+	if _, err := u.userRepo.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return u.authService.LogoutAll(ctx, id)
+}
+
+// invalidateStatus drops the cached status, it expires on its own if Redis is down
+func (u *userService) invalidateStatus(ctx context.Context, id uint) {
+	if err := u.userStatus.Invalidate(ctx, id); err != nil {
+		log.Printf("Error invalidating cached status of user %d: %v", id, err)
+	}
+}
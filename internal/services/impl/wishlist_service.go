@@ -0,0 +1,151 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/minh6824pro/nxrGO/internal/cache"
+	"github.com/minh6824pro/nxrGO/internal/config"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"github.com/minh6824pro/nxrGO/internal/notification"
+	"github.com/minh6824pro/nxrGO/internal/repositories"
+	"github.com/minh6824pro/nxrGO/internal/services"
+	"log"
+	"time"
+)
+
+const (
+	// A subscriber is notified at most once per variant in this window, even if the variant sells out again
+	backInStockDedupWindow = 24 * time.Hour
+	// Notifications over the limit of a user are dropped
+	backInStockUserLimit  = 10
+	backInStockUserWindow = time.Hour
+)
+
+type wishlistService struct {
+	repo               repositories.WishlistRepository
+	productVariantRepo repositories.ProductVariantRepository
+	stockMovementRepo  repositories.StockMovementRepository
+	rateLimiter        cache.RateLimiter
+	notifier           notification.Notifier
+}
+
+func NewWishlistService(repo repositories.WishlistRepository, productVariantRepo repositories.ProductVariantRepository,
+	stockMovementRepo repositories.StockMovementRepository, rateLimiter cache.RateLimiter, notifier notification.Notifier) services.WishlistService {
+	return &wishlistService{
+		repo:               repo,
+		productVariantRepo: productVariantRepo,
+		stockMovementRepo:  stockMovementRepo,
+		rateLimiter:        rateLimiter,
+		notifier:           notifier,
+	}
+}
+
+func (w *wishlistService) List(ctx context.Context, userID uint) ([]models.WishlistItem, error) {
+	return w.repo.ListByUserId(ctx, userID)
+}
+
+// AddItem saves the variant, adding it again only changes the notification setting
+func (w *wishlistService) AddItem(ctx context.Context, userID uint, input dto.WishlistItemInput) (*models.WishlistItem, error) {
+	variant, err := w.productVariantRepo.GetByID(ctx, input.ProductVariantID)
+	if err != nil {
+		return nil, err
+	}
+	item := &models.WishlistItem{
+		UserID:            userID,
+		ProductVariantID:  variant.ID,
+		NotifyBackInStock: true,
+	}
+	if input.NotifyBackInStock != nil {
+		item.NotifyBackInStock = *input.NotifyBackInStock
+	}
+	if err := w.repo.Upsert(ctx, item); err != nil {
+		return nil, err
+	}
+	item.Variant = *variant
+	return item, nil
+}
+
+func (w *wishlistService) RemoveItem(ctx context.Context, userID uint, productVariantID uint) error {
+	return w.repo.Delete(ctx, userID, productVariantID)
+}
+
+func (w *wishlistService) NotifyBackInStock(ctx context.Context, increases map[uint]uint) {
+	if len(increases) == 0 {
+		return
+	}
+	// Request context ends with the response, sending runs after it
+	go w.notifyBackInStock(context.WithoutCancel(ctx), increases)
+}
+
+// notifyBackInStock notifies subscribers of the variants whose available stock went from 0 to positive
+func (w *wishlistService) notifyBackInStock(ctx context.Context, increases map[uint]uint) {
+	variantIDs := make([]uint, 0, len(increases))
+	for variantID := range increases {
+		variantIDs = append(variantIDs, variantID)
+	}
+	available, err := w.stockMovementRepo.SumAvailable(ctx, variantIDs)
+	if err != nil {
+		log.Printf("Error checking stock for back in stock notifications: %v", err)
+		return
+	}
+
+	for variantID, increase := range increases {
+		quantity := available[variantID]
+		if quantity <= 0 || quantity-int(increase) > 0 {
+			continue
+		}
+		subscribers, err := w.repo.ListSubscribers(ctx, variantID)
+		if err != nil {
+			log.Printf("Error listing subscribers of product variant %d: %v", variantID, err)
+			continue
+		}
+		for _, item := range subscribers {
+			w.notifySubscriber(ctx, item)
+		}
+	}
+}
+
+// notifySubscriber claims the dedup slot of the item and gives it back unless the notification was sent
+func (w *wishlistService) notifySubscriber(ctx context.Context, item models.WishlistItem) {
+	claimed, err := w.repo.ClaimNotification(ctx, item.ID, time.Now().Add(-backInStockDedupWindow))
+	if err != nil {
+		log.Printf("Error claiming back in stock notification of wishlist item %d: %v", item.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	if !w.sendBackInStock(ctx, item) {
+		if err := w.repo.ReleaseNotification(ctx, item.ID, item.LastNotifiedAt); err != nil {
+			log.Printf("Error releasing back in stock notification of wishlist item %d: %v", item.ID, err)
+		}
+	}
+}
+
+// sendBackInStock reports whether the notification went out
+func (w *wishlistService) sendBackInStock(ctx context.Context, item models.WishlistItem) bool {
+	allowed, err := w.rateLimiter.Allow(ctx, fmt.Sprintf("backInStock:%d", item.UserID), backInStockUserLimit, backInStockUserWindow)
+	if err != nil {
+		log.Printf("Error rate limiting back in stock notification of user %d: %v", item.UserID, err)
+		return false
+	}
+	if !allowed {
+		log.Printf("Dropped back in stock notification of product variant %d for user %d, rate limited", item.ProductVariantID, item.UserID)
+		return false
+	}
+
+	product := item.Variant.Product
+	err = w.notifier.Notify(ctx, notification.Notification{
+		UserID:  item.UserID,
+		Email:   item.User.Email,
+		Subject: fmt.Sprintf("Back in stock: %s", product.Name),
+		Body: fmt.Sprintf("Hi %s,\n\n%s from your wishlist is back in stock at %.0f.\n\n%s/products/%d\n",
+			item.User.FullName, product.Name, item.Variant.Price, config.FrontendURL, product.ID),
+	})
+	if err != nil {
+		log.Printf("Error sending back in stock notification of product variant %d to user %d: %v", item.ProductVariantID, item.UserID, err)
+		return false
+	}
+	return true
+}
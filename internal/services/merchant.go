@@ -13,4 +13,5 @@ type MerchantService interface {
 	Delete(ctx context.Context, id uint) error
 	List(ctx context.Context) ([]models.Merchant, error)
 	Patch(ctx context.Context, id uint, input *dto.UpdateMerchantInput) (*models.Merchant, error)
+	AddMember(ctx context.Context, merchantID uint, userID uint) (*models.User, error)
 }
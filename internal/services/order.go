@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/event"
 	"github.com/minh6824pro/nxrGO/internal/models"
 	"github.com/minh6824pro/nxrGO/internal/utils"
 )
@@ -11,12 +12,22 @@ import (
 type OrderService interface {
 	Create(ctx context.Context, input dto.CreateOrderInput) (*dto.CreateOrderResponse, error)
 	GetById(ctx context.Context, orderID uint, userID uint) (*models.Order, error)
-	PayOSPaymentSuccess(ctx context.Context, paymentInfoID int64)
+	PaymentSuccess(ctx context.Context, paymentInfoID int64)
+	PaymentCancelled(ctx context.Context, paymentInfoId int64, status string, reason string)
+	HandlePaymentCreated(e event.PayOSPaymentCreatedEvent) error
 	UpdateQuantity(ctx context.Context) error
+	ExpireReservations(ctx context.Context) (int, error)
+	GetStatusHistory(ctx context.Context, orderID uint, actor models.Actor) ([]models.OrderStatusHistory, error)
 	GetsByStatus(ctx context.Context, status models.OrderStatus, userId uint) ([]*models.Order, error)
-	UpdateOrderStatus(ctx context.Context, orderId uint, status utils.OrderEvent) (*models.Order, error)
+	UpdateOrderStatus(ctx context.Context, orderId uint, status utils.OrderEvent, actor models.Actor, note string) (*models.Order, error)
+	CancelItems(ctx context.Context, orderID uint, actor models.Actor, input dto.OrderItemsRequest) (*models.Order, error)
+	RequestItemReturn(ctx context.Context, orderID uint, userID uint, input dto.OrderItemsRequest) (*models.Order, error)
+	ReceiveItemReturn(ctx context.Context, orderID uint, actor models.Actor, input dto.OrderItemsRequest) (*models.Order, error)
+	RejectItemReturn(ctx context.Context, orderID uint, input dto.OrderItemsRequest) (*models.Order, error)
 	ListByUserId(ctx context.Context, userID uint) ([]*dto.OrderData, error)
 	ChangePaymentMethod(c *gin.Context, payment dto.ChangePaymentMethodRequest, u uint) (*models.Order, error)
 	ListByAdmin(c *gin.Context) ([]*dto.OrderData, error)
+	ListByMerchant(ctx context.Context, merchantID uint) ([]*dto.OrderData, error)
 	CalculateShippingFees(c context.Context, merchantID uint, destLon, destLat string) ([]*dto.ShippingFeeResponse, error)
+	ResolveShippingAddress(ctx context.Context, userID uint, addressID uint) (*models.Address, error)
 }
@@ -0,0 +1,10 @@
+package services
+
+import (
+	"context"
+)
+
+type PaymentReconciler interface {
+	Start(ctx context.Context)
+	Reconcile(ctx context.Context, paymentInfoID int64) error
+}
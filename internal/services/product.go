@@ -15,5 +15,7 @@ type ProductService interface {
 	Patch(ctx context.Context, product *models.Product) error
 	GetProductListManagement(ctx context.Context, priceMin, priceMax *float64, priceAsc *bool, totalBuyDesc *bool, page, pageSize int) ([]*CacheModel.ProductMiniCache, int, error)
 
-	GetProductList(ctx context.Context, name string, priceMin, priceMax *float64, priceAsc *bool, totalBuyDesc *bool, page, pageSize int, lat, lon *float64) ([]*CacheModel.ProductMiniCache, int, error)
+	// GetProductList searches Elasticsearch, facets are nil when it falls back to DB
+	GetProductList(ctx context.Context, q dto.ProductSearchQuery) (*dto.ProductSearchResponse, error)
+	Autocomplete(ctx context.Context, prefix string, size int) (*dto.AutocompleteResponse, error)
 }
@@ -16,6 +16,7 @@ type ProductVariantService interface {
 	Patch(ctx context.Context, productVariant *models.ProductVariant) error
 	IncreaseStock(c *gin.Context, id uint, input dto.UpdateStockRequest) (*models.ProductVariant, error)
 	DecreaseStock(c *gin.Context, id uint, input dto.UpdateStockRequest) (*models.ProductVariant, error)
+	UpdatePrice(ctx context.Context, id uint, input dto.UpdatePriceRequest) (*models.ProductVariant, error)
 	CheckAndCacheProductVariants(ctx context.Context, ids []uint) ([]CacheModel.VariantLite, error)
 	ListByIds(ctx context.Context, list dto.ListProductVariantIds) ([]dto.VariantCartInfoResponse, error)
 }
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type PromotionService interface {
+	Create(ctx context.Context, actor models.Actor, input dto.CreatePromotionInput) (*models.Promotion, error)
+	Update(ctx context.Context, actor models.Actor, id uint, input dto.UpdatePromotionInput) (*models.Promotion, error)
+	Delete(ctx context.Context, actor models.Actor, id uint) error
+	GetByID(ctx context.Context, actor models.Actor, id uint) (*models.Promotion, error)
+	List(ctx context.Context, actor models.Actor, page, pageSize int) ([]*models.Promotion, int, error)
+	Quote(ctx context.Context, userID uint, input dto.QuotePromotionInput) (*dto.PromotionQuote, error)
+	Evaluate(ctx context.Context, userID uint, code string, lines []dto.PromotionLine, shippingFees map[uint]float64) (*dto.PromotionQuote, error)
+	Redeem(ctx context.Context, orderID uint, quote *dto.PromotionQuote) error
+	Release(ctx context.Context, orderID uint) error
+	ShippingDiscounts(ctx context.Context, orderID uint) (map[uint]float64, error)
+}
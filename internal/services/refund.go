@@ -0,0 +1,19 @@
+package services
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"gorm.io/gorm"
+)
+
+type RefundService interface {
+	Request(ctx context.Context, userID uint, input dto.CreateRefundRequest) (*models.Refund, error)
+	RequestItemsTx(ctx context.Context, tx *gorm.DB, order *models.Order, quantities map[uint]uint, reason string) (*models.Refund, error)
+	RequestRemainingTx(ctx context.Context, tx *gorm.DB, order *models.Order, includeShipping bool, reason string) (*models.Refund, error)
+	ListByUserId(ctx context.Context, userID uint) ([]models.Refund, error)
+	ListByAdmin(ctx context.Context, status models.RefundStatus) ([]models.Refund, error)
+	Approve(ctx context.Context, refundID uint, adminID uint) (*models.Refund, error)
+	Reject(ctx context.Context, refundID uint, adminID uint, reason string) (*models.Refund, error)
+	Complete(ctx context.Context, refundID uint) (*models.Refund, error)
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type ReviewService interface {
+	Create(ctx context.Context, userID uint, productID uint, input dto.CreateReviewInput) (*models.Review, error)
+	Update(ctx context.Context, userID uint, id uint, input dto.UpdateReviewInput) (*models.Review, error)
+	Delete(ctx context.Context, actor models.Actor, id uint) error
+	Reply(ctx context.Context, actor models.Actor, id uint, input dto.ReplyReviewInput) (*models.Review, error)
+	Moderate(ctx context.Context, actor models.Actor, id uint, input dto.ModerateReviewInput) (*models.Review, error)
+	ListByProduct(ctx context.Context, productID uint, rating *uint8, page, pageSize int) ([]*models.Review, int, error)
+	List(ctx context.Context, filter dto.ReviewFilter, page, pageSize int) ([]*models.Review, int, error)
+}
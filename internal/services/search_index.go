@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/event"
+)
+
+type SearchIndexService interface {
+	// HandleCatalogChanged applies the change to the products index, an error asks the outbox to retry
+	HandleCatalogChanged(e event.CatalogChangedEvent) error
+	Status(ctx context.Context) (*dto.SearchSyncStatus, error)
+	// Reindex builds the next index version from DB, verifies it and swaps the aliases to it
+	Reindex(ctx context.Context) (*dto.ReindexResult, error)
+	// Rollback swaps the aliases back to the previous index version
+	Rollback(ctx context.Context) (*dto.ReindexResult, error)
+}
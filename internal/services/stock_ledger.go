@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/models"
+	"gorm.io/gorm"
+)
+
+type StockLedgerService interface {
+	ReserveTx(ctx context.Context, tx *gorm.DB, draftOrderID uint, items []models.OrderItem) error
+	ReleaseTx(ctx context.Context, tx *gorm.DB, draftOrderID uint, items []models.OrderItem) error
+	SellTx(ctx context.Context, tx *gorm.DB, draftOrderID uint, orderID uint, items []models.OrderItem) error
+	Restock(ctx context.Context, order *models.Order) error
+	RestockTx(ctx context.Context, tx *gorm.DB, order *models.Order) error
+	RestockItemsTx(ctx context.Context, tx *gorm.DB, orderID uint, items []models.OrderItem) error
+	Flush(ctx context.Context) (map[uint]int, error)
+	Rebuild(ctx context.Context) error
+	ReconcileCache(ctx context.Context, variantIDs []uint) error
+	SeedOpeningBalances(ctx context.Context) error
+	ListByVariant(ctx context.Context, variantID uint) ([]models.StockMovement, error)
+}
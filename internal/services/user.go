@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+// UserService is the admin side of user accounts
+type UserService interface {
+	List(ctx context.Context, filter dto.UserFilter, page, pageSize int) ([]*models.User, int, error)
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+	ChangeRole(ctx context.Context, adminID uint, id uint, input dto.ChangeUserRoleInput) (*models.User, error)
+	Deactivate(ctx context.Context, adminID uint, id uint) (*models.User, error)
+	Reactivate(ctx context.Context, id uint) (*models.User, error)
+	ForceLogout(ctx context.Context, id uint) error
+}
@@ -0,0 +1,16 @@
+package services
+
+import (
+	"context"
+	"github.com/minh6824pro/nxrGO/internal/dto"
+	"github.com/minh6824pro/nxrGO/internal/models"
+)
+
+type WishlistService interface {
+	List(ctx context.Context, userID uint) ([]models.WishlistItem, error)
+	AddItem(ctx context.Context, userID uint, input dto.WishlistItemInput) (*models.WishlistItem, error)
+	RemoveItem(ctx context.Context, userID uint, productVariantID uint) error
+	// NotifyBackInStock is called after stock of the variants went up by the given quantities,
+	// subscribers of variants that were sold out are notified in the background
+	NotifyBackInStock(ctx context.Context, increases map[uint]uint)
+}
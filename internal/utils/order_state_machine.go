@@ -49,6 +49,10 @@ var stateMachine = map[models.OrderStatus]map[OrderEvent]models.OrderStatus{
 	models.OrderStateReturnShipping: {
 		EventReturn: models.OrderStateReturned,
 	},
+	models.OrderStatePartiallyReturned: {
+		EventDone:          models.OrderStateDone,
+		EventRequestReturn: models.OrderStateReturnRequested,
+	},
 	models.OrderStateDone: {},
 }
 
@@ -13,34 +13,54 @@ import (
 	"github.com/minh6824pro/nxrGO/internal/elastic"
 	event2 "github.com/minh6824pro/nxrGO/internal/event"
 	"github.com/minh6824pro/nxrGO/internal/jwt"
+	"github.com/minh6824pro/nxrGO/internal/mail"
 	modules2 "github.com/minh6824pro/nxrGO/internal/modules"
+	"github.com/minh6824pro/nxrGO/internal/notification"
+	"github.com/minh6824pro/nxrGO/internal/payment"
 	"github.com/minh6824pro/nxrGO/internal/repositories/impl"
 	impl2 "github.com/minh6824pro/nxrGO/internal/services/impl"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
-func InitAuthModule(db *gorm.DB) *modules2.AuthModule {
+func InitAuthModule(db *gorm.DB, redisClient *redis.Client, mailSender mail.Sender) *modules2.AuthModule {
 	wire.Build(
 		impl.NewAuthRepository,
+		impl.NewRefreshTokenGormRepository,
+		impl.NewAuthTokenGormRepository,
+		cache2.NewRateLimiter,
+		cache2.NewLoginAttemptService,
 		impl2.NewAuthService,
 		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
 		middleware.NewAuthMiddleware,
 		controllers2.NewAuthController,
+		controllers2.NewJWKSController,
+		impl.NewUserGormRepository,
+		impl.NewMerchantGormRepository,
+		impl2.NewUserService,
+		controllers2.NewUserController,
 		wire.Struct(new(modules2.AuthModule), "*"))
 	return nil
 }
 
-func InitMerchantModule(db *gorm.DB) *modules2.MerchantModule {
+func InitMerchantModule(db *gorm.DB, redisClient *redis.Client, eventBus event2.EventPublisher) *modules2.MerchantModule {
 	wire.Build(
 		impl.NewMerchantGormRepository,
+		impl.NewAuthRepository,
 		impl2.NewMerchantService,
 		controllers2.NewMerchantController,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
 		wire.Struct(new(modules2.MerchantModule), "*"))
 	return nil
 }
 
-func InitBrandModule(db *gorm.DB) *modules2.BrandModule {
+func InitBrandModule(db *gorm.DB, eventBus event2.EventPublisher) *modules2.BrandModule {
 	wire.Build(
 		impl.NewBrandGormRepository,
 		impl2.NewBrandService,
@@ -49,7 +69,7 @@ func InitBrandModule(db *gorm.DB) *modules2.BrandModule {
 	return nil
 }
 
-func InitCategoryModule(db *gorm.DB) *modules2.CategoryModule {
+func InitCategoryModule(db *gorm.DB, eventBus event2.EventPublisher) *modules2.CategoryModule {
 	wire.Build(
 		impl.NewCategoryGormRepository,
 		impl2.NewCategoryService,
@@ -58,7 +78,7 @@ func InitCategoryModule(db *gorm.DB) *modules2.CategoryModule {
 	return nil
 }
 
-func InitProductModule(db *gorm.DB, redisClient *redis.Client, es *elasticsearch.Client, redisContext context.Context, updateStockAgg *event2.UpdateStockAggregator) *modules2.ProductModule {
+func InitProductModule(db *gorm.DB, redisClient *redis.Client, es *elasticsearch.Client, redisContext context.Context, eventBus event2.EventPublisher, notifier notification.Notifier) *modules2.ProductModule {
 	wire.Build(
 		impl.NewProductGormRepository,
 		impl.NewMerchantGormRepository,
@@ -69,10 +89,21 @@ func InitProductModule(db *gorm.DB, redisClient *redis.Client, es *elasticsearch
 		impl.NewVariantOptionValueGormRepository,
 		cache2.NewProductVariantRedisService,
 		cache2.NewProductCacheService,
+		impl.NewStockMovementGormRepository,
+		impl.NewWishlistGormRepository,
+		cache2.NewRateLimiter,
+		impl2.NewWishlistService,
 		impl2.NewProductVariantService,
 		elastic.NewProductElasticRepo,
 		impl2.NewProductService,
 		controllers2.NewProductController,
+		impl.NewOrderGormRepository,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
+		middleware.NewMerchantScopeMiddleware,
 		wire.Struct(new(modules2.ProductModule), "*"))
 	return nil
 }
@@ -86,7 +117,7 @@ func InitVariantModule(db *gorm.DB) *modules2.VariantModule {
 	return nil
 }
 
-func InitOrderModule(db *gorm.DB, redisClient *redis.Client, redisContext context.Context, eventBus event2.EventPublisher, updateStockAgg *event2.UpdateStockAggregator) *modules2.OrderModule {
+func InitOrderModule(db *gorm.DB, redisClient *redis.Client, redisContext context.Context, eventBus event2.EventPublisher, paymentProvider payment.PaymentProvider, notifier notification.Notifier) *modules2.OrderModule {
 	wire.Build(
 		impl.NewProductVariantGormRepository,
 		impl.NewOrderItemGormRepository,
@@ -94,28 +125,58 @@ func InitOrderModule(db *gorm.DB, redisClient *redis.Client, redisContext contex
 		impl.NewDraftOrderGormRepository,
 		impl.NewPaymentInfoGormImpl,
 		impl.NewMerchantGormRepository,
+		impl.NewStockMovementGormRepository,
 		cache2.NewProductVariantRedisService,
+		impl.NewIdempotencyKeyGormRepository,
+		impl.NewRefundGormRepository,
+		impl.NewOrderStatusHistoryGormRepository,
+		impl2.NewStockLedgerService,
+		impl2.NewRefundService,
+		impl.NewAddressGormRepository,
+		impl.NewPromotionGormRepository,
+		impl2.NewPromotionService,
+		impl.NewWishlistGormRepository,
+		cache2.NewRateLimiter,
+		impl2.NewWishlistService,
 		impl2.NewOrderService,
+		impl2.NewIdempotencyService,
 		controllers2.NewOrderController,
 		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
 		middleware.NewAuthMiddleware,
+		middleware.NewIdempotencyMiddleware,
+		impl.NewProductGormRepository,
+		middleware.NewMerchantScopeMiddleware,
 		wire.Struct(new(modules2.OrderModule), "*"))
 	return nil
 }
 
-func InitProductVariantModule(db *gorm.DB, redisClient *redis.Client, redisContext context.Context, updateStockAgg *event2.UpdateStockAggregator) *modules2.ProductVariantModule {
+func InitProductVariantModule(db *gorm.DB, redisClient *redis.Client, redisContext context.Context, eventBus event2.EventPublisher, notifier notification.Notifier) *modules2.ProductVariantModule {
 	wire.Build(
 		impl.NewProductVariantGormRepository,
 		impl.NewProductGormRepository,
 		cache2.NewProductVariantRedisService,
+		impl.NewStockMovementGormRepository,
+		impl.NewWishlistGormRepository,
+		cache2.NewRateLimiter,
+		impl2.NewWishlistService,
 		impl2.NewProductVariantService,
 		controllers2.NewProductVariantController,
+		impl.NewOrderGormRepository,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
+		middleware.NewMerchantScopeMiddleware,
 		wire.Struct(new(modules2.ProductVariantModule), "*"))
 
 	return nil
 }
 
-func InitPayOSModule(db *gorm.DB, redisClient *redis.Client, redisContext context.Context, eventBus event2.EventPublisher, updateStockAgg *event2.UpdateStockAggregator) *modules2.PayOsModule {
+func InitPayOSModule(db *gorm.DB, redisClient *redis.Client, redisContext context.Context, eventBus event2.EventPublisher, paymentProvider payment.PaymentProvider, notifier notification.Notifier) *modules2.PayOsModule {
 	wire.Build(
 		impl.NewProductVariantGormRepository,
 		impl.NewOrderItemGormRepository,
@@ -123,9 +184,175 @@ func InitPayOSModule(db *gorm.DB, redisClient *redis.Client, redisContext contex
 		impl.NewPaymentInfoGormImpl,
 		impl.NewMerchantGormRepository,
 		impl.NewDraftOrderGormRepository,
+		impl.NewStockMovementGormRepository,
 		cache2.NewProductVariantRedisService,
+		impl.NewRefundGormRepository,
+		impl.NewOrderStatusHistoryGormRepository,
+		impl2.NewStockLedgerService,
+		impl2.NewRefundService,
+		impl.NewAddressGormRepository,
+		impl.NewPromotionGormRepository,
+		impl2.NewPromotionService,
+		impl.NewWishlistGormRepository,
+		cache2.NewRateLimiter,
+		impl2.NewWishlistService,
 		impl2.NewOrderService,
+		impl2.NewPaymentReconciler,
 		controllers2.NewWebhookController,
 		wire.Struct(new(modules2.PayOsModule), "*"))
 	return nil
 }
+
+func InitRefundModule(db *gorm.DB, redisClient *redis.Client, paymentProvider payment.PaymentProvider) *modules2.RefundModule {
+	wire.Build(
+		impl.NewRefundGormRepository,
+		impl.NewOrderGormRepository,
+		impl.NewPaymentInfoGormImpl,
+		impl2.NewRefundService,
+		controllers2.NewRefundController,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
+		wire.Struct(new(modules2.RefundModule), "*"))
+	return nil
+}
+
+func InitAddressModule(db *gorm.DB, redisClient *redis.Client) *modules2.AddressModule {
+	wire.Build(
+		impl.NewAddressGormRepository,
+		impl2.NewAddressService,
+		controllers2.NewAddressController,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
+		wire.Struct(new(modules2.AddressModule), "*"))
+	return nil
+}
+
+func InitCartModule(db *gorm.DB, redisClient *redis.Client, redisContext context.Context, eventBus event2.EventPublisher, paymentProvider payment.PaymentProvider, notifier notification.Notifier) *modules2.CartModule {
+	wire.Build(
+		impl.NewCartGormRepository,
+		cache2.NewCartCacheService,
+		impl.NewProductVariantGormRepository,
+		impl.NewOrderItemGormRepository,
+		impl.NewOrderGormRepository,
+		impl.NewDraftOrderGormRepository,
+		impl.NewPaymentInfoGormImpl,
+		impl.NewMerchantGormRepository,
+		impl.NewStockMovementGormRepository,
+		cache2.NewProductVariantRedisService,
+		impl.NewIdempotencyKeyGormRepository,
+		impl.NewRefundGormRepository,
+		impl.NewOrderStatusHistoryGormRepository,
+		impl2.NewStockLedgerService,
+		impl2.NewRefundService,
+		impl.NewAddressGormRepository,
+		impl.NewPromotionGormRepository,
+		impl2.NewPromotionService,
+		impl.NewWishlistGormRepository,
+		cache2.NewRateLimiter,
+		impl2.NewWishlistService,
+		impl2.NewOrderService,
+		impl2.NewIdempotencyService,
+		impl2.NewCartService,
+		controllers2.NewCartController,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
+		middleware.NewIdempotencyMiddleware,
+		wire.Struct(new(modules2.CartModule), "*"))
+	return nil
+}
+
+func InitPromotionModule(db *gorm.DB, redisClient *redis.Client) *modules2.PromotionModule {
+	wire.Build(
+		impl.NewPromotionGormRepository,
+		impl.NewProductVariantGormRepository,
+		impl2.NewPromotionService,
+		controllers2.NewPromotionController,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
+		wire.Struct(new(modules2.PromotionModule), "*"))
+	return nil
+}
+
+func InitReviewModule(db *gorm.DB, redisClient *redis.Client, redisContext context.Context) *modules2.ReviewModule {
+	wire.Build(
+		impl.NewReviewGormRepository,
+		impl.NewProductGormRepository,
+		impl.NewProductVariantGormRepository,
+		cache2.NewProductVariantRedisService,
+		cache2.NewProductCacheService,
+		elastic.NewProductElasticRepo,
+		impl2.NewReviewService,
+		controllers2.NewReviewController,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
+		wire.Struct(new(modules2.ReviewModule), "*"))
+	return nil
+}
+
+func InitWishlistModule(db *gorm.DB, redisClient *redis.Client, notifier notification.Notifier) *modules2.WishlistModule {
+	wire.Build(
+		impl.NewWishlistGormRepository,
+		impl.NewProductVariantGormRepository,
+		impl.NewStockMovementGormRepository,
+		cache2.NewRateLimiter,
+		impl2.NewWishlistService,
+		controllers2.NewWishlistController,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
+		wire.Struct(new(modules2.WishlistModule), "*"))
+	return nil
+}
+
+func InitStockModule(db *gorm.DB, redisClient *redis.Client, redisContext context.Context, eventBus event2.EventPublisher) *modules2.StockModule {
+	wire.Build(
+		impl.NewStockMovementGormRepository,
+		impl.NewProductVariantGormRepository,
+		cache2.NewProductVariantRedisService,
+		impl2.NewStockLedgerService,
+		controllers2.NewStockController,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
+		wire.Struct(new(modules2.StockModule), "*"))
+	return nil
+}
+
+func InitSearchModule(db *gorm.DB, redisClient *redis.Client) *modules2.SearchModule {
+	wire.Build(
+		elastic.NewElasticClient,
+		elastic.NewProductElasticRepo,
+		impl.NewProductGormRepository,
+		impl.NewMerchantGormRepository,
+		impl.NewBrandGormRepository,
+		impl.NewCategoryGormRepository,
+		impl.NewOutboxGormRepository,
+		impl2.NewSearchIndexService,
+		controllers2.NewSearchController,
+		jwt.NewJWTService,
+		cache2.NewTokenDenylistService,
+		cache2.NewUserStatusService,
+		impl.NewUserGormRepository,
+		middleware.NewAuthMiddleware,
+		wire.Struct(new(modules2.SearchModule), "*"))
+	return nil
+}
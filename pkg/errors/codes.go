@@ -18,4 +18,12 @@ const (
 	VERSION_CONFLICT    = "VERSION_CONFLICT"
 	PROCESSING_FAILED   = "PROCESSING_FAILED"
 	PROCESSING_TIMEOUT  = "PROCESSING_TIMEOUT"
+	SERVICE_UNAVAILABLE = "SERVICE_UNAVAILABLE"
+	TOO_MANY_REQUESTS   = "TOO_MANY_REQUESTS"
+	EMAIL_NOT_VERIFIED  = "EMAIL_NOT_VERIFIED"
+	ACCOUNT_LOCKED      = "ACCOUNT_LOCKED"
+	INVALID_PROMOTION   = "INVALID_PROMOTION"
+
+	IDEMPOTENCY_KEY_CONFLICT    = "IDEMPOTENCY_KEY_CONFLICT"
+	IDEMPOTENCY_KEY_IN_PROGRESS = "IDEMPOTENCY_KEY_IN_PROGRESS"
 )
@@ -2,7 +2,10 @@ package errors
 
 import (
 	"github.com/gin-gonic/gin"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Error struct {
@@ -11,6 +14,8 @@ type Error struct {
 	HTTPCode int            `json:"http_code"`
 	Err      error          `json:"-"`
 	Meta     map[string]any `json:"meta,omitempty"`
+	// RetryAfter is sent as the Retry-After header when set
+	RetryAfter time.Duration `json:"-"`
 }
 
 func (e *Error) Error() string {
@@ -36,6 +41,16 @@ func NewErrorWithMeta(code string, message string, hc int, e error, meta map[str
 	}
 }
 
+func NewErrorWithRetryAfter(code string, message string, hc int, e error, retryAfter time.Duration) error {
+	return &Error{
+		Code:       code,
+		Message:    message,
+		Err:        e,
+		HTTPCode:   hc,
+		RetryAfter: retryAfter,
+	}
+}
+
 func ParseError(err error) *Error {
 	if e, ok := err.(*Error); ok {
 		return e
@@ -60,5 +75,8 @@ func WriteError(c *gin.Context, err error) {
 			res[k] = v
 		}
 	}
+	if e.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+	}
 	c.JSON(e.HTTPCode, res)
 }
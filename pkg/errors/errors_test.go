@@ -0,0 +1,36 @@
+package errors
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteErrorSetsRetryAfterInWholeSeconds(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+
+	WriteError(c, NewErrorWithRetryAfter(TOO_MANY_REQUESTS, "Too many requests", http.StatusTooManyRequests, nil, 1500*time.Millisecond))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("expected Retry-After 2, got %q", got)
+	}
+}
+
+func TestWriteErrorWithoutRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+
+	WriteError(c, NewError(BAD_REQUEST, "Bad request", http.StatusBadRequest, nil))
+
+	if got := rec.Header().Get("Retry-After"); got != "" {
+		t.Fatalf("expected no Retry-After, got %q", got)
+	}
+}